@@ -0,0 +1,444 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/wailsapp/wails/v3/pkg/application"
+)
+
+// The API server exposes the methods of the services bound to the frontend
+// to the local tools (e.g. telemetry and test benches) with JSON-RPC 2.0 over HTTP,
+// and streams the emitted events with server-sent events.
+// It listens only on the loopback interface and every request must carry the token
+// stored in the settings, either in the Authorization header as a bearer token
+// or, for the event stream, in the token query parameter.
+//
+//	POST /rpc      {"jsonrpc": "2.0", "id": 1, "method": "BusService.GetLoad", "params": ["<bus entity id>"]}
+//	GET  /methods  list of the available methods
+//	GET  /events   stream of the events
+//
+// The calls are handled by the network of the focused window.
+
+const (
+	defaultAPIServerPort = 50321
+	minAPIServerPort     = 1024
+	maxAPIServerPort     = 65535
+
+	apiEventBufferSize = 64
+)
+
+// APIServerSettings configures the local automation API.
+type APIServerSettings struct {
+	Enabled bool `json:"enabled"`
+	Port    int  `json:"port"`
+	// Token must be sent by the clients with every request.
+	Token string `json:"token"`
+}
+
+func newDefaultAPIServerSettings() APIServerSettings {
+	return APIServerSettings{
+		Enabled: false,
+		Port:    defaultAPIServerPort,
+		Token:   newAPIToken(),
+	}
+}
+
+func newAPIToken() string {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf)
+}
+
+const (
+	rpcVersion = "2.0"
+
+	rpcErrParse          = -32700
+	rpcErrInvalidRequest = -32600
+	rpcErrMethodNotFound = -32601
+	rpcErrInvalidParams  = -32602
+	rpcErrInternal       = -32603
+	rpcErrService        = -32000
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  any             `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type apiMethod struct {
+	receiver reflect.Value
+	method   reflect.Method
+}
+
+var (
+	contextType = reflect.TypeFor[context.Context]()
+	errorType   = reflect.TypeFor[error]()
+)
+
+// apiExcludedMethods are the exported methods of the services
+// that are called by Wails and not by the frontend.
+var apiExcludedMethods = []string{"OnStartup", "OnShutdown", "ServiceName", "ServeHTTP"}
+
+type apiServer struct {
+	mux sync.Mutex
+
+	methods map[string]apiMethod
+
+	settings APIServerSettings
+	server   *http.Server
+
+	events *eventSubscribers
+	log    logger
+}
+
+func newAPIServer(services []application.Service, events *eventSubscribers, log logger) *apiServer {
+	s := &apiServer{
+		methods: make(map[string]apiMethod),
+
+		events: events,
+		log:    log,
+	}
+
+	for _, srv := range services {
+		s.registerService(srv.Instance())
+	}
+
+	return s
+}
+
+func (s *apiServer) registerService(srv any) {
+	srvVal := reflect.ValueOf(srv)
+	srvType := srvVal.Type()
+	srvName := srvType.Elem().Name()
+
+	for i := range srvType.NumMethod() {
+		method := srvType.Method(i)
+		if slices.Contains(apiExcludedMethods, method.Name) {
+			continue
+		}
+
+		s.methods[srvName+"."+method.Name] = apiMethod{
+			receiver: srvVal,
+			method:   method,
+		}
+	}
+}
+
+// update starts, stops or restarts the server to follow the settings.
+func (s *apiServer) update(settings APIServerSettings) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	if s.server != nil && s.settings == settings {
+		return
+	}
+
+	s.stop()
+	s.settings = settings
+
+	if !settings.Enabled {
+		return
+	}
+
+	if err := s.start(); err != nil {
+		s.log.error(fmt.Errorf("api server: %w", err))
+	}
+}
+
+// start must be called with the mutex held.
+func (s *apiServer) start() error {
+	if s.settings.Token == "" {
+		return errors.New("the token is empty")
+	}
+
+	listener, err := net.Listen("tcp", net.JoinHostPort("127.0.0.1", strconv.Itoa(s.settings.Port)))
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /rpc", s.handleRPC)
+	mux.HandleFunc("GET /methods", s.handleMethods)
+	mux.HandleFunc("GET /events", s.handleEvents)
+
+	s.server = &http.Server{
+		Handler:           s.authorize(mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func(server *http.Server) {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.log.error(fmt.Errorf("api server: %w", err))
+		}
+	}(s.server)
+
+	s.log.info("API SERVER LISTENING", "address", listener.Addr().String())
+
+	return nil
+}
+
+// stop must be called with the mutex held.
+func (s *apiServer) stop() {
+	if s.server == nil {
+		return
+	}
+
+	// the event streams are never idle, so the server is closed without waiting
+	if err := s.server.Close(); err != nil {
+		s.log.error(fmt.Errorf("api server: %w", err))
+	}
+	s.server = nil
+}
+
+func (s *apiServer) shutdown() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.stop()
+}
+
+func (s *apiServer) getToken() string {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	return s.settings.Token
+}
+
+// authorize rejects the requests without the token, and the ones
+// with a host that is not the loopback one to prevent DNS rebinding.
+func (s *apiServer) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+
+		if host != "127.0.0.1" && host != "localhost" {
+			http.Error(w, "invalid host", http.StatusForbidden)
+			return
+		}
+
+		// the browsers cannot set the headers of an event stream,
+		// the other endpoints accept only the header
+		token := ""
+		if r.URL.Path == "/events" {
+			token = r.URL.Query().Get("token")
+		}
+		if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+			token = bearer
+		}
+
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.getToken())) != 1 {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *apiServer) handleRPC(w http.ResponseWriter, r *http.Request) {
+	res := s.call(r)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		s.log.error(fmt.Errorf("api server: %w", err))
+	}
+}
+
+func (s *apiServer) call(r *http.Request) (res rpcResponse) {
+	res.JSONRPC = rpcVersion
+
+	req := rpcRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		res.Error = &rpcError{Code: rpcErrParse, Message: err.Error()}
+		return res
+	}
+	res.ID = req.ID
+
+	if req.JSONRPC != rpcVersion || len(req.Method) == 0 {
+		res.Error = &rpcError{Code: rpcErrInvalidRequest, Message: "invalid request"}
+		return res
+	}
+
+	method, ok := s.methods[req.Method]
+	if !ok {
+		res.Error = &rpcError{Code: rpcErrMethodNotFound, Message: fmt.Sprintf("method %s not found", req.Method)}
+		return res
+	}
+
+	args, err := s.decodeParams(r.Context(), method, req.Params)
+	if err != nil {
+		res.Error = &rpcError{Code: rpcErrInvalidParams, Message: err.Error()}
+		return res
+	}
+
+	defer func() {
+		if rec := recover(); rec != nil {
+			res.Result = nil
+			res.Error = &rpcError{Code: rpcErrInternal, Message: fmt.Sprint(rec)}
+		}
+	}()
+
+	results := method.method.Func.Call(append([]reflect.Value{method.receiver}, args...))
+
+	// the result is required on success, also for the methods without one
+	var result any = json.RawMessage("null")
+	for _, tmpRes := range results {
+		if tmpRes.Type() == errorType {
+			if !tmpRes.IsNil() {
+				res.Error = &rpcError{Code: rpcErrService, Message: tmpRes.Interface().(error).Error()}
+				return res
+			}
+			continue
+		}
+
+		result = tmpRes.Interface()
+	}
+	res.Result = result
+
+	return res
+}
+
+// decodeParams decodes the params of the request into the arguments of the method.
+// The params are positional, a context argument is filled with the one of the request.
+func (s *apiServer) decodeParams(ctx context.Context, method apiMethod, rawParams json.RawMessage) ([]reflect.Value, error) {
+	params := []json.RawMessage{}
+	if len(rawParams) > 0 && string(rawParams) != "null" {
+		if err := json.Unmarshal(rawParams, &params); err != nil {
+			return nil, errors.New("the params must be an array")
+		}
+	}
+
+	methodType := method.method.Type
+	args := []reflect.Value{}
+	paramIdx := 0
+
+	// the first input is the receiver
+	for i := 1; i < methodType.NumIn(); i++ {
+		argType := methodType.In(i)
+
+		if argType == contextType {
+			args = append(args, reflect.ValueOf(ctx))
+			continue
+		}
+
+		if paramIdx >= len(params) {
+			return nil, fmt.Errorf("missing param %d", paramIdx)
+		}
+
+		arg := reflect.New(argType)
+		if err := json.Unmarshal(params[paramIdx], arg.Interface()); err != nil {
+			return nil, fmt.Errorf("param %d: %w", paramIdx, err)
+		}
+		args = append(args, arg.Elem())
+
+		paramIdx++
+	}
+
+	if paramIdx != len(params) {
+		return nil, fmt.Errorf("expected %d params, got %d", paramIdx, len(params))
+	}
+
+	return args, nil
+}
+
+func (s *apiServer) handleMethods(w http.ResponseWriter, _ *http.Request) {
+	methodNames := []string{}
+	for name := range s.methods {
+		methodNames = append(methodNames, name)
+	}
+	slices.Sort(methodNames)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(methodNames); err != nil {
+		s.log.error(fmt.Errorf("api server: %w", err))
+	}
+}
+
+type apiEvent struct {
+	name string
+	data any
+}
+
+// handleEvents streams the emitted events. The events are dropped
+// if the client does not keep up with them.
+func (s *apiServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	eventCh := make(chan apiEvent, apiEventBufferSize)
+	unsubscribe := s.events.subscribe(func(name string, data []any) {
+		evt := apiEvent{name: name}
+		switch len(data) {
+		case 0:
+		case 1:
+			evt.data = data[0]
+		default:
+			evt.data = data
+		}
+
+		select {
+		case eventCh <- evt:
+		default:
+		}
+	})
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	flusher.Flush()
+
+	for {
+		select {
+		case evt := <-eventCh:
+			dataBuf, err := json.Marshal(evt.data)
+			if err != nil {
+				s.log.error(fmt.Errorf("api server: %w", err))
+				continue
+			}
+
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", evt.name, dataBuf); err != nil {
+				return
+			}
+			flusher.Flush()
+
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"strings"
 	"sync"
 
@@ -105,6 +106,10 @@ func newBusLoadMessage(msg *acmelib.MessageLoad) BusLoadMessage {
 type BusLoad struct {
 	Percentage float64          `json:"percentage"`
 	Messages   []BusLoadMessage `json:"messages"`
+
+	// Warning is set when the load exceeds the threshold of the preferences.
+	Warning          bool    `json:"warning"`
+	WarningThreshold float64 `json:"warningThreshold"`
 }
 
 func newBusLoad(load float64, msgLoads []*acmelib.MessageLoad) BusLoad {
@@ -113,9 +118,14 @@ func newBusLoad(load float64, msgLoads []*acmelib.MessageLoad) BusLoad {
 		messages = append(messages, newBusLoadMessage(tmpMsgLoad))
 	}
 
+	threshold := getPreferences().BusLoadWarningThreshold
+
 	return BusLoad{
 		Percentage: load,
 		Messages:   messages,
+
+		Warning:          load >= threshold,
+		WarningThreshold: threshold,
 	}
 }
 
@@ -123,13 +133,20 @@ type BusService struct {
 	*service[*acmelib.Bus, Bus, *busHandler]
 }
 
-func newBusService(mux *sync.RWMutex, sidebar *sidebarController) *BusService {
+func newBusService(mux *sync.RWMutex, emitter eventEmitter, sidebar *sidebarController) *BusService {
 	return &BusService{
-		service: newService(serviceKindBus, newBusHandler(sidebar), mux, sidebar),
+		service: newService(serviceKindBus, newBusHandler(sidebar), mux, emitter, sidebar),
 	}
 }
 
-func (s *BusService) GetLoad(entityID string) (BusLoad, error) {
+// forWindow returns the service of the window that made the call.
+func (s *BusService) forWindow(ctx context.Context) *BusService {
+	return s.resolve(ctx).busSrv
+}
+
+func (s *BusService) GetLoad(ctx context.Context, entityID string) (BusLoad, error) {
+	s = s.forWindow(ctx)
+
 	s.mux.RLock()
 	defer s.mux.RUnlock()
 
@@ -146,19 +163,23 @@ func (s *BusService) GetLoad(entityID string) (BusLoad, error) {
 	return newBusLoad(load, msgLoads), nil
 }
 
-func (s *BusService) UpdateName(entityID string, req UpdateNameReq) (Bus, error) {
+func (s *BusService) UpdateName(ctx context.Context, entityID string, req UpdateNameReq) (Bus, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateName)
 }
 
-func (s *BusService) UpdateDesc(entityID string, req UpdateDescReq) (Bus, error) {
+func (s *BusService) UpdateDesc(ctx context.Context, entityID string, req UpdateDescReq) (Bus, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateDesc)
 }
 
-func (s *BusService) UpdateBusType(entityID string, req UpdateBusTypeReq) (Bus, error) {
+func (s *BusService) UpdateBusType(ctx context.Context, entityID string, req UpdateBusTypeReq) (Bus, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateBusType)
 }
 
-func (s *BusService) UpdateBaudrate(entityID string, req UpdateBaudrateReq) (Bus, error) {
+func (s *BusService) UpdateBaudrate(ctx context.Context, entityID string, req UpdateBaudrateReq) (Bus, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateBaudrate)
 }
 
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+// runCLI runs the command given as arguments without starting the app.
+// It returns false if the arguments do not contain a command.
+//
+//	canturin script [-dry-run] <network file> <script file>
+func runCLI(args []string) (exitCode int, handled bool) {
+	if len(args) == 0 {
+		return 0, false
+	}
+
+	switch args[0] {
+	case "script":
+		if err := runScriptCLI(args[1:], os.Stdout, os.Stderr); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1, true
+		}
+		return 0, true
+	}
+
+	return 0, false
+}
+
+// runScriptCLI runs a script against a network file. The network is saved
+// if the script succeeds, unless the dry run is requested.
+func runScriptCLI(args []string, stdout, stderr io.Writer) error {
+	flags := flag.NewFlagSet("script", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	dryRun := flags.Bool("dry-run", false, "print the changes without saving them")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: canturin script [-dry-run] <network file> <script file>")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return fmt.Errorf("script: expected 2 arguments, got %d", flags.NArg())
+	}
+	netPath := flags.Arg(0)
+	scriptPath := flags.Arg(1)
+
+	source, err := os.ReadFile(scriptPath)
+	if err != nil {
+		return err
+	}
+
+	emitter := newDiscardEventEmitter()
+	log := newWriterLogger(stderr)
+	manager := newServiceManager(emitter, log, newFixedPrompter(), newConfigService(emitter, log), newNetworkClipboard())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	if err := manager.start(ctx); err != nil {
+		return err
+	}
+
+	if err := manager.openNetwork(netPath); err != nil {
+		return err
+	}
+
+	res, err := manager.scriptSrv.Run(ctx, RunScriptReq{
+		Name:   scriptPath,
+		Source: string(source),
+		DryRun: *dryRun,
+	})
+	fmt.Fprint(stdout, res.String())
+	if err != nil {
+		return err
+	}
+
+	if *dryRun || len(res.Changes) == 0 {
+		return nil
+	}
+
+	// nobody can confirm the overwrite of the changes made by another program
+	manager.fileMux.Lock()
+	changed, err := manager.hasFileChanged()
+	manager.fileMux.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	if changed {
+		return fmt.Errorf("script: %s has been changed by another program during the run", netPath)
+	}
+
+	return manager.saveNetwork()
+}
@@ -0,0 +1,525 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/squadracorsepolito/acmelib"
+)
+
+// The clipboard is used to copy entities between windows.
+// It is shared by the service managers of all the windows and holds
+// the copied entity together with the whole network it belongs to.
+// The network is stored encoded, so the changes made to it after the copy
+// are not pasted, and every paste decodes a new copy of the entities.
+
+type clipboardContent struct {
+	kind     EntityKind
+	entityID string
+	network  []byte
+}
+
+type networkClipboard struct {
+	mux     sync.Mutex
+	content *clipboardContent
+}
+
+func newNetworkClipboard() *networkClipboard {
+	return &networkClipboard{}
+}
+
+func (c *networkClipboard) set(content *clipboardContent) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.content = content
+}
+
+func (c *networkClipboard) get() *clipboardContent {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return c.content
+}
+
+type PasteMessageReq struct {
+	NodeEntityID    string `json:"nodeEntityId"`
+	InterfaceNumber int    `json:"interfaceNumber"`
+}
+
+// copyEntity copies the bus or the message with the given entity id into the clipboard.
+func (m *serviceManager) copyEntity(entityID string) error {
+	m.mux.RLock()
+
+	kind := EntityKindBus
+	if _, err := m.busCtr.get(entityID); err != nil {
+		if _, err := m.messageCtr.get(entityID); err != nil {
+			m.mux.RUnlock()
+			return errors.New("copy: only buses and messages can be copied")
+		}
+		kind = EntityKindMessage
+	}
+
+	netBuf := new(bytes.Buffer)
+	err := acmelib.SaveNetwork(m.network, acmelib.SaveEncodingJSON, nil, netBuf, nil)
+	m.mux.RUnlock()
+
+	if err != nil {
+		return err
+	}
+
+	m.clipboard.set(&clipboardContent{
+		kind:     kind,
+		entityID: entityID,
+		network:  netBuf.Bytes(),
+	})
+
+	return nil
+}
+
+// loadClipboard loads the network stored in the clipboard.
+// All the entity ids are replaced with new ones, so the pasted entities
+// never collide with the ones of the window they are pasted into,
+// even when it contains the same network.
+func (m *serviceManager) loadClipboard(kind EntityKind) (*acmelib.Network, acmelib.EntityID, error) {
+	content := m.clipboard.get()
+	if content == nil {
+		return nil, "", errors.New("paste: the clipboard is empty")
+	}
+
+	if content.kind != kind {
+		return nil, "", fmt.Errorf("paste: the clipboard contains a %s", content.kind)
+	}
+
+	var netData any
+	if err := json.Unmarshal(content.network, &netData); err != nil {
+		return nil, "", err
+	}
+
+	newIDs := make(map[string]string)
+	collectClipboardEntityIDs(netData, newIDs)
+	netData = replaceClipboardEntityIDs(netData, newIDs)
+
+	netBuf, err := json.Marshal(netData)
+	if err != nil {
+		return nil, "", err
+	}
+
+	net, err := acmelib.LoadNetwork(bytes.NewReader(netBuf), acmelib.SaveEncodingJSON)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return net, acmelib.EntityID(newIDs[content.entityID]), nil
+}
+
+// collectClipboardEntityIDs generates a new entity id for every
+// entity id declared in the JSON encoded network.
+func collectClipboardEntityIDs(data any, newIDs map[string]string) {
+	switch tmpData := data.(type) {
+	case map[string]any:
+		for key, val := range tmpData {
+			if key == "entityId" {
+				if entID, ok := val.(string); ok {
+					newIDs[entID] = newClipboardEntityID()
+				}
+				continue
+			}
+			collectClipboardEntityIDs(val, newIDs)
+		}
+
+	case []any:
+		for _, val := range tmpData {
+			collectClipboardEntityIDs(val, newIDs)
+		}
+	}
+}
+
+// replaceClipboardEntityIDs replaces the declared entity ids
+// and all the references to them. Only the values of the entity id keys
+// are replaced, so a name or a description equal to an entity id is kept.
+func replaceClipboardEntityIDs(data any, newIDs map[string]string) any {
+	switch tmpData := data.(type) {
+	case map[string]any:
+		for key, val := range tmpData {
+			if isClipboardEntityIDKey(key) {
+				tmpData[key] = replaceClipboardEntityID(val, newIDs)
+				continue
+			}
+			tmpData[key] = replaceClipboardEntityIDs(val, newIDs)
+		}
+
+	case []any:
+		for idx, val := range tmpData {
+			tmpData[idx] = replaceClipboardEntityIDs(val, newIDs)
+		}
+	}
+
+	return data
+}
+
+// isClipboardEntityIDKey returns true if the key holds an entity id
+// or a list of them (e.g. entityId, typeEntityId or fixedSignalEntityIds).
+func isClipboardEntityIDKey(key string) bool {
+	return key == "entityId" || strings.HasSuffix(key, "EntityId") || strings.HasSuffix(key, "EntityIds")
+}
+
+// replaceClipboardEntityID replaces the entity id, or the list of entity ids,
+// held by an entity id key.
+func replaceClipboardEntityID(val any, newIDs map[string]string) any {
+	switch tmpVal := val.(type) {
+	case string:
+		if newID, ok := newIDs[tmpVal]; ok {
+			return newID
+		}
+
+	case []any:
+		for idx, entID := range tmpVal {
+			tmpVal[idx] = replaceClipboardEntityID(entID, newIDs)
+		}
+	}
+
+	return val
+}
+
+// newClipboardEntityID returns a random id with the same format
+// of the ones generated by acmelib (21 characters nanoid).
+func newClipboardEntityID() string {
+	const alphabet = "_-0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+	buf := make([]byte, 21)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+
+	for idx, b := range buf {
+		buf[idx] = alphabet[int(b)%len(alphabet)]
+	}
+
+	return string(buf)
+}
+
+// pasteBus adds the bus stored in the clipboard to the network.
+func (m *serviceManager) pasteBus() error {
+	srcNet, busEntID, err := m.loadClipboard(EntityKindBus)
+	if err != nil {
+		return err
+	}
+
+	var bus *acmelib.Bus
+	for _, tmpBus := range srcNet.Buses() {
+		if tmpBus.EntityID() == busEntID {
+			bus = tmpBus
+			break
+		}
+	}
+
+	if bus == nil {
+		return errors.New("paste: bus not found in the clipboard")
+	}
+
+	if err := srcNet.RemoveBus(busEntID); err != nil {
+		return err
+	}
+
+	m.mux.Lock()
+	takenNames := make(map[string]struct{})
+	for _, tmpBus := range m.network.Buses() {
+		takenNames[tmpBus.Name()] = struct{}{}
+	}
+	if _, ok := takenNames[bus.Name()]; ok {
+		if err := bus.UpdateName(getNewName(bus.Name(), takenNames)); err != nil {
+			m.mux.Unlock()
+			return err
+		}
+	}
+
+	if err := m.renamePastedNodes(bus); err != nil {
+		m.mux.Unlock()
+		return err
+	}
+
+	messages := []*acmelib.Message{}
+	for _, nodeInt := range bus.NodeInterfaces() {
+		messages = append(messages, nodeInt.SentMessages()...)
+	}
+
+	if err := m.reuseSignalDefinitions(messages); err != nil {
+		m.mux.Unlock()
+		return err
+	}
+
+	if err := m.network.AddBus(bus); err != nil {
+		m.mux.Unlock()
+		return err
+	}
+	m.mux.Unlock()
+
+	m.clearEntities()
+	m.initNetwork(m.network)
+
+	m.sendPasteOperation(
+		func() error { return m.network.RemoveBus(bus.EntityID()) },
+		func() error { return m.network.AddBus(bus) },
+	)
+
+	return nil
+}
+
+// renamePastedNodes gives a new name and a new node id to the nodes of the pasted bus
+// that collide with the ones of the network. The caller must hold the mutex.
+func (m *serviceManager) renamePastedNodes(bus *acmelib.Bus) error {
+	takenNames := make(map[string]struct{})
+	takenNodeIDs := make(map[acmelib.NodeID]struct{})
+	for _, tmpNode := range m.nodeCtr.list() {
+		takenNames[tmpNode.Name()] = struct{}{}
+		takenNodeIDs[tmpNode.ID()] = struct{}{}
+	}
+
+	nameCollisions := []*acmelib.Node{}
+	nodeIDCollisions := []*acmelib.Node{}
+	for _, nodeInt := range bus.NodeInterfaces() {
+		node := nodeInt.Node()
+
+		if _, ok := takenNames[node.Name()]; ok {
+			nameCollisions = append(nameCollisions, node)
+		}
+		if _, ok := takenNodeIDs[node.ID()]; ok {
+			nodeIDCollisions = append(nodeIDCollisions, node)
+		}
+	}
+
+	// the new names and ids must not collide with the other pasted nodes either
+	for _, nodeInt := range bus.NodeInterfaces() {
+		takenNames[nodeInt.Node().Name()] = struct{}{}
+		takenNodeIDs[nodeInt.Node().ID()] = struct{}{}
+	}
+
+	for _, node := range nameCollisions {
+		newName := getNewName(node.Name(), takenNames)
+		if err := node.UpdateName(newName); err != nil {
+			return err
+		}
+		takenNames[newName] = struct{}{}
+	}
+
+	nodeID := acmelib.NodeID(1)
+	for _, node := range nodeIDCollisions {
+		for {
+			if _, ok := takenNodeIDs[nodeID]; !ok {
+				break
+			}
+			nodeID++
+		}
+
+		if err := node.UpdateID(nodeID); err != nil {
+			return err
+		}
+		takenNodeIDs[nodeID] = struct{}{}
+	}
+
+	return nil
+}
+
+// pasteMessage adds the message stored in the clipboard to the messages
+// sent by the given node interface. The receivers are not copied,
+// since they belong to the network the message was copied from.
+func (m *serviceManager) pasteMessage(req PasteMessageReq) error {
+	m.mux.RLock()
+	node, err := m.nodeCtr.get(req.NodeEntityID)
+	m.mux.RUnlock()
+
+	if err != nil {
+		return err
+	}
+
+	srcNet, msgEntID, err := m.loadClipboard(EntityKindMessage)
+	if err != nil {
+		return err
+	}
+
+	var msg *acmelib.Message
+	for _, bus := range srcNet.Buses() {
+		for _, nodeInt := range bus.NodeInterfaces() {
+			for _, tmpMsg := range nodeInt.SentMessages() {
+				if tmpMsg.EntityID() == msgEntID {
+					msg = tmpMsg
+				}
+			}
+		}
+	}
+
+	if msg == nil {
+		return errors.New("paste: message not found in the clipboard")
+	}
+
+	for _, recNodeInt := range msg.Receivers() {
+		if err := msg.RemoveReceiver(recNodeInt.Node().EntityID()); err != nil {
+			return err
+		}
+	}
+
+	if err := msg.SenderNodeInterface().RemoveSentMessage(msgEntID); err != nil {
+		return err
+	}
+
+	m.mux.Lock()
+	nodeInt, err := node.GetInterface(req.InterfaceNumber)
+	if err != nil {
+		m.mux.Unlock()
+		return err
+	}
+
+	takenNames := make(map[string]struct{})
+	for _, tmpMsg := range nodeInt.SentMessages() {
+		takenNames[tmpMsg.Name()] = struct{}{}
+	}
+	if _, ok := takenNames[msg.Name()]; ok {
+		if err := msg.UpdateName(getNewName(msg.Name(), takenNames)); err != nil {
+			m.mux.Unlock()
+			return err
+		}
+	}
+
+	if err := m.reuseSignalDefinitions([]*acmelib.Message{msg}); err != nil {
+		m.mux.Unlock()
+		return err
+	}
+
+	if err := nodeInt.AddSentMessage(msg); err != nil {
+		m.mux.Unlock()
+		return err
+	}
+	m.mux.Unlock()
+
+	m.clearEntities()
+	m.initNetwork(m.network)
+
+	m.sendPasteOperation(
+		func() error { return nodeInt.RemoveSentMessage(msg.EntityID()) },
+		func() error { return nodeInt.AddSentMessage(msg) },
+	)
+
+	return nil
+}
+
+// sendPasteOperation records a paste as a single history operation.
+// The services are reloaded after the undo and the redo, so all the entities
+// added by the paste (e.g. the nodes, the messages and the signals of a bus)
+// are removed and added back together.
+func (m *serviceManager) sendPasteOperation(remove, add func() error) {
+	reload := func(fn func() error) (any, error) {
+		m.mux.Lock()
+		err := fn()
+		m.mux.Unlock()
+
+		if err != nil {
+			return nil, err
+		}
+
+		m.clearEntities()
+		m.initNetwork(m.network)
+
+		m.mux.RLock()
+		defer m.mux.RUnlock()
+
+		return newNetwork(m.network), nil
+	}
+
+	m.historyCtr.sendOperation(
+		serviceKindNetwork,
+		func() (any, error) { return reload(remove) },
+		func() (any, error) { return reload(add) },
+	)
+}
+
+// reuseSignalDefinitions makes the signals of the pasted messages use the signal types,
+// units and enums of the network that are identical to the pasted ones (see duplicates.go),
+// so a paste does not add duplicates. The caller must hold the mutex.
+func (m *serviceManager) reuseSignalDefinitions(messages []*acmelib.Message) error {
+	sigTypes := groupIdentical(m.signalTypeCtr.list(), getSignalTypeDuplicateKey)
+	sigUnits := groupIdentical(m.signalUnitCtr.list(), getSignalUnitDuplicateKey)
+	sigEnums := groupIdentical(m.signalEnumCtr.list(), getSignalEnumDuplicateKey)
+
+	for _, msg := range messages {
+		for _, sig := range msg.Signals() {
+			switch sig.Kind() {
+			case acmelib.SignalKindStandard:
+				stdSig, err := sig.ToStandard()
+				if err != nil {
+					return err
+				}
+
+				if sigType, ok := findIdentical(sigTypes, stdSig.Type(), getSignalTypeDuplicateKey); ok {
+					if err := stdSig.SetType(sigType); err != nil {
+						return err
+					}
+				}
+
+				if stdSig.Unit() != nil {
+					if sigUnit, ok := findIdentical(sigUnits, stdSig.Unit(), getSignalUnitDuplicateKey); ok {
+						stdSig.SetUnit(sigUnit)
+					}
+				}
+
+			case acmelib.SignalKindEnum:
+				enumSig, err := sig.ToEnum()
+				if err != nil {
+					return err
+				}
+
+				if sigEnum, ok := findIdentical(sigEnums, enumSig.Enum(), getSignalEnumDuplicateKey); ok {
+					if err := enumSig.SetEnum(sigEnum); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// groupIdentical groups the entities with the same key, the oldest first.
+// The entities with an empty key are never grouped.
+func groupIdentical[E entity](entities []E, keyFn func(E) string) map[string][]E {
+	groups := make(map[string][]E)
+	for _, ent := range entities {
+		key := keyFn(ent)
+		if key == "" {
+			continue
+		}
+		groups[key] = append(groups[key], ent)
+	}
+
+	for _, group := range groups {
+		slices.SortFunc(group, func(a, b E) int {
+			return a.CreateTime().Compare(b.CreateTime())
+		})
+	}
+
+	return groups
+}
+
+// findIdentical returns the entity of the groups identical to the given one,
+// the one with the same name is preferred.
+func findIdentical[E entity](groups map[string][]E, ent E, keyFn func(E) string) (E, bool) {
+	group := groups[keyFn(ent)]
+	if len(group) == 0 {
+		return ent, false
+	}
+
+	for _, tmpEnt := range group {
+		if tmpEnt.Name() == ent.Name() {
+			return tmpEnt, true
+		}
+	}
+
+	return group[0], true
+}
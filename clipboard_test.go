@@ -0,0 +1,199 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/squadracorsepolito/acmelib"
+)
+
+func TestPasteMessageReusesSignalDefinitions(t *testing.T) {
+	m, _ := newTestManager(t)
+
+	loadDuplicateSignalTypes(t, m)
+
+	// the message is pasted into a node on another bus, so its id is not taken
+	node := acmelib.NewNode("other_node", 2, 1)
+	bus := acmelib.NewBus("other_bus")
+	if err := bus.AddNodeInterface(node.Interfaces()[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	m.mux.Lock()
+	err := m.network.AddBus(bus)
+	m.mux.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.initNetwork(m.network)
+	waitServices(m)
+
+	m.mux.RLock()
+	msg := m.messageSrv.listEntities()[0]
+	m.mux.RUnlock()
+
+	countEntities := func() (int, int, int, int) {
+		m.mux.RLock()
+		defer m.mux.RUnlock()
+
+		return len(m.messageSrv.listEntities()), len(m.signalTypeSrv.listEntities()),
+			len(m.signalUnitSrv.listEntities()), len(m.signalEnumSrv.listEntities())
+	}
+
+	msgCount, sigTypeCount, sigUnitCount, sigEnumCount := countEntities()
+
+	if err := m.copyEntity(msg.EntityID().String()); err != nil {
+		t.Fatal(err)
+	}
+
+	before := dumpServices(m)
+
+	if err := m.pasteMessage(PasteMessageReq{
+		NodeEntityID:    node.EntityID().String(),
+		InterfaceNumber: 0,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	waitServices(m)
+	waitHistory(t, m, 1)
+
+	newMsgCount, newSigTypeCount, newSigUnitCount, newSigEnumCount := countEntities()
+	if newMsgCount != msgCount+1 {
+		t.Errorf("expected %d messages, got %d", msgCount+1, newMsgCount)
+	}
+	if newSigTypeCount != sigTypeCount || newSigUnitCount != sigUnitCount || newSigEnumCount != sigEnumCount {
+		t.Errorf("the pasted signals must reuse the identical types, units and enums: got %d types, %d units, %d enums",
+			newSigTypeCount, newSigUnitCount, newSigEnumCount)
+	}
+
+	if _, err := m.historySrv.Undo(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	waitServices(m)
+
+	if after := dumpServices(m); after != before {
+		t.Errorf("the undo must remove all the pasted entities, got:\n%s\nwant:\n%s", after, before)
+	}
+}
+
+func TestPasteBusUndo(t *testing.T) {
+	m, _ := newTestManager(t)
+
+	if err := m.openNetwork(filepath.Join("testdata", "simple.binpb")); err != nil {
+		t.Fatal(err)
+	}
+	waitServices(m)
+
+	m.mux.RLock()
+	bus := m.network.Buses()[0]
+	m.mux.RUnlock()
+
+	before := dumpServices(m)
+
+	if err := m.copyEntity(bus.EntityID().String()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.pasteBus(); err != nil {
+		t.Fatal(err)
+	}
+	waitServices(m)
+	waitHistory(t, m, 1)
+
+	if dumpServices(m) == before {
+		t.Fatal("the bus has not been pasted")
+	}
+
+	if _, err := m.historySrv.Undo(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	waitServices(m)
+
+	if after := dumpServices(m); after != before {
+		t.Errorf("the undo must remove all the pasted entities, got:\n%s\nwant:\n%s", after, before)
+	}
+
+	if _, err := m.historySrv.Redo(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	waitServices(m)
+
+	m.mux.RLock()
+	busCount := len(m.busSrv.listEntities())
+	m.mux.RUnlock()
+
+	if busCount != 2 {
+		t.Errorf("expected 2 buses after the redo, got %d", busCount)
+	}
+}
+
+func TestReplaceClipboardEntityIDs(t *testing.T) {
+	newIDs := map[string]string{"sig_id": "new_sig_id", "type_id": "new_type_id"}
+
+	data := map[string]any{
+		"entityId":             "sig_id",
+		"name":                 "sig_id",
+		"desc":                 "type_id",
+		"typeEntityId":         "type_id",
+		"fixedSignalEntityIds": []any{"sig_id"},
+	}
+
+	got := replaceClipboardEntityIDs(data, newIDs).(map[string]any)
+
+	if got["entityId"] != "new_sig_id" || got["typeEntityId"] != "new_type_id" {
+		t.Errorf("the entity ids are not replaced: %v", got)
+	}
+
+	if fixedIDs := got["fixedSignalEntityIds"].([]any); fixedIDs[0] != "new_sig_id" {
+		t.Errorf("the list of entity ids is not replaced: %v", fixedIDs)
+	}
+
+	if got["name"] != "sig_id" || got["desc"] != "type_id" {
+		t.Errorf("the values that are not entity ids must be kept: %v", got)
+	}
+}
+
+func TestPasteBusRenamesNodes(t *testing.T) {
+	m, _ := newTestManager(t)
+
+	if err := m.openNetwork(filepath.Join("testdata", "simple.binpb")); err != nil {
+		t.Fatal(err)
+	}
+	waitServices(m)
+
+	m.mux.RLock()
+	bus := m.network.Buses()[0]
+	m.mux.RUnlock()
+
+	if err := m.copyEntity(bus.EntityID().String()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.pasteBus(); err != nil {
+		t.Fatal(err)
+	}
+	waitServices(m)
+
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	nodes := m.nodeSrv.listEntities()
+	if len(nodes) == 0 {
+		t.Fatal("expected some nodes")
+	}
+
+	names := make(map[string]struct{})
+	nodeIDs := make(map[acmelib.NodeID]struct{})
+	for _, node := range nodes {
+		if _, ok := names[node.Name()]; ok {
+			t.Errorf("the node name %s is duplicated", node.Name())
+		}
+		names[node.Name()] = struct{}{}
+
+		if _, ok := nodeIDs[node.ID()]; ok {
+			t.Errorf("the node id %d is duplicated", node.ID())
+		}
+		nodeIDs[node.ID()] = struct{}{}
+	}
+}
@@ -0,0 +1,401 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/wailsapp/wails/v3/pkg/application"
+)
+
+type CommandCategory string
+
+const (
+	CommandCategoryFile   CommandCategory = "file"
+	CommandCategoryEdit   CommandCategory = "edit"
+	CommandCategoryTools  CommandCategory = "tools"
+	CommandCategoryPlugin CommandCategory = "plugin"
+)
+
+const (
+	CommandNewNetwork             = "network.new"
+	CommandNewWindow              = "window.new"
+	CommandOpenNetwork            = "network.open"
+	CommandOpenNetworkInNewWindow = "network.open-in-new-window"
+	CommandSaveNetwork            = "network.save"
+	CommandSaveNetworkAs          = "network.save-as"
+	CommandImportDBC              = "dbc.import"
+	CommandExportDBC              = "dbc.export"
+	CommandExportLibrary          = "library.export"
+	CommandImportLibrary          = "library.import"
+	CommandLinkLibrary            = "library.link"
+	CommandUpdateLibraries        = "library.update"
+	CommandExportTopology         = "topology.export"
+	CommandReload                 = "network.reload"
+	CommandUndo                   = "history.undo"
+	CommandRedo                   = "history.redo"
+	CommandSearch                 = "search"
+	CommandBulkRename             = "rename.bulk"
+	CommandValidate               = "lint.validate"
+	CommandPalette                = "command.palette"
+	CommandRunScript              = "script.run"
+	CommandDryRunScript           = "script.dry-run"
+)
+
+// command is an action that can be triggered from the menu,
+// from a keybinding or from the command palette.
+type command struct {
+	id          string
+	title       string
+	category    CommandCategory
+	defaultKeys string
+
+	// run executes the command in the backend, if it is nil the command
+	// is handled by the frontend, which is notified with a CommandRun event.
+	run func() error
+}
+
+type Command struct {
+	ID       string          `json:"id"`
+	Title    string          `json:"title"`
+	Category CommandCategory `json:"category"`
+
+	DefaultKeys string `json:"defaultKeys"`
+	// Keys is the keybinding in use, it is empty if the command is not bound.
+	Keys       string `json:"keys"`
+	Overridden bool   `json:"overridden"`
+}
+
+type CommandRunEvent struct {
+	CommandID string `json:"commandId"`
+}
+
+type commandRegistry struct {
+	mux sync.RWMutex
+
+	commands []*command
+	byID     map[string]*command
+
+	settingsSrv *SettingsService
+
+	// emitter notifies the frontend of the commands it handles
+	emitter eventEmitter
+
+	// keysListener is called every time a keybinding changes
+	keysListener func()
+}
+
+func newCommandRegistry(settingsSrv *SettingsService, h *menuHandler, pluginHost *pluginHost, emitter eventEmitter) *commandRegistry {
+	r := &commandRegistry{
+		byID: make(map[string]*command),
+
+		settingsSrv: settingsSrv,
+
+		emitter: emitter,
+	}
+
+	ctxFn := func(fn func(*application.Context) error) func() error {
+		return func() error { return fn(nil) }
+	}
+
+	r.add(CommandNewNetwork, "New Network", CommandCategoryFile, "CmdOrCtrl+N", ctxFn(h.newNetwork))
+	r.add(CommandNewWindow, "New Window", CommandCategoryFile, "CmdOrCtrl+Shift+N", ctxFn(h.newWindow))
+	r.add(CommandOpenNetwork, "Open Network", CommandCategoryFile, "CmdOrCtrl+O", ctxFn(h.openNetwork))
+	r.add(CommandOpenNetworkInNewWindow, "Open Network in New Window", CommandCategoryFile, "CmdOrCtrl+Shift+O", ctxFn(h.openNetworkInNewWindow))
+	r.add(CommandSaveNetwork, "Save Network", CommandCategoryFile, "CmdOrCtrl+S", ctxFn(h.saveNetwork))
+	r.add(CommandSaveNetworkAs, "Save Network As", CommandCategoryFile, "CmdOrCtrl+Shift+S", ctxFn(h.saveNetworkAs))
+	r.add(CommandImportDBC, "Import DBC", CommandCategoryFile, "CmdOrCtrl+I", ctxFn(h.importDBC))
+	r.add(CommandExportDBC, "Export DBC", CommandCategoryFile, "CmdOrCtrl+E", ctxFn(h.exportDBC))
+	r.add(CommandExportLibrary, "Export Library", CommandCategoryFile, "", ctxFn(h.exportLibrary))
+	r.add(CommandImportLibrary, "Import Library", CommandCategoryFile, "", ctxFn(h.importLibrary))
+	r.add(CommandLinkLibrary, "Link Library", CommandCategoryFile, "", ctxFn(h.linkLibrary))
+	r.add(CommandUpdateLibraries, "Update Linked Libraries", CommandCategoryFile, "", ctxFn(h.updateLibraries))
+	r.add(CommandExportTopology, "Export Topology", CommandCategoryFile, "", ctxFn(h.exportTopology))
+	r.add(CommandReload, "Reload", CommandCategoryFile, "CmdOrCtrl+R", ctxFn(h.reload))
+
+	r.add(CommandUndo, "Undo", CommandCategoryEdit, "CmdOrCtrl+Z", ctxFn(h.undo))
+	r.add(CommandRedo, "Redo", CommandCategoryEdit, "CmdOrCtrl+Y", ctxFn(h.redo))
+	r.add(CommandSearch, "Search", CommandCategoryEdit, "CmdOrCtrl+F", nil)
+	r.add(CommandBulkRename, "Bulk Rename", CommandCategoryEdit, "CmdOrCtrl+Shift+H", nil)
+	r.add(CommandValidate, "Validate Network", CommandCategoryEdit, "CmdOrCtrl+Shift+V", nil)
+	r.add(CommandPalette, "Command Palette", CommandCategoryEdit, "CmdOrCtrl+Shift+P", nil)
+
+	r.add(CommandRunScript, "Run Script", CommandCategoryTools, "", ctxFn(h.runScript))
+	r.add(CommandDryRunScript, "Dry Run Script", CommandCategoryTools, "", ctxFn(h.dryRunScript))
+
+	for _, p := range pluginHost.plugins {
+		for _, pluginCmd := range p.desc.Commands {
+			r.add(p.commandID(pluginCmd), pluginCmd.Title, CommandCategoryPlugin, "", func() error {
+				return h.runPluginCommand(pluginHost, p, pluginCmd)
+			})
+		}
+	}
+
+	return r
+}
+
+func (r *commandRegistry) add(id, title string, category CommandCategory, defaultKeys string, run func() error) {
+	cmd := &command{
+		id:          id,
+		title:       title,
+		category:    category,
+		defaultKeys: defaultKeys,
+		run:         run,
+	}
+
+	r.commands = append(r.commands, cmd)
+	r.byID[id] = cmd
+}
+
+func (r *commandRegistry) setKeysListener(listener func()) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.keysListener = listener
+}
+
+func (r *commandRegistry) get(commandID string) (*command, error) {
+	cmd, ok := r.byID[commandID]
+	if !ok {
+		return nil, fmt.Errorf("command %s not found", commandID)
+	}
+	return cmd, nil
+}
+
+// getKeys returns the keybinding of the command, the user override if present,
+// otherwise the default one.
+func (r *commandRegistry) getKeys(cmd *command) (string, bool) {
+	keys, ok := r.settingsSrv.getKeybinding(cmd.id)
+	if ok {
+		return keys, true
+	}
+	return cmd.defaultKeys, false
+}
+
+// getCommandByKeys returns the command bound to the given keys.
+func (r *commandRegistry) getCommandByKeys(keys string) (*command, bool) {
+	normKeys, err := normalizeKeys(keys)
+	if err != nil {
+		return nil, false
+	}
+
+	for _, cmd := range r.commands {
+		cmdKeys, _ := r.getKeys(cmd)
+		if len(cmdKeys) == 0 {
+			continue
+		}
+
+		if tmpKeys, err := normalizeKeys(cmdKeys); err == nil && tmpKeys == normKeys {
+			return cmd, true
+		}
+	}
+
+	return nil, false
+}
+
+func (r *commandRegistry) runCommand(cmd *command) error {
+	if cmd.run == nil {
+		r.emitter.emitEvent(CommandRun, CommandRunEvent{CommandID: cmd.id})
+		return nil
+	}
+
+	return cmd.run()
+}
+
+func (r *commandRegistry) toResponse(cmd *command) Command {
+	keys, overridden := r.getKeys(cmd)
+
+	return Command{
+		ID:       cmd.id,
+		Title:    cmd.title,
+		Category: cmd.category,
+
+		DefaultKeys: cmd.defaultKeys,
+		Keys:        keys,
+		Overridden:  overridden,
+	}
+}
+
+func (r *commandRegistry) list() []Command {
+	res := []Command{}
+	for _, cmd := range r.commands {
+		res = append(res, r.toResponse(cmd))
+	}
+	return res
+}
+
+func (r *commandRegistry) notifyKeysChange() {
+	r.mux.RLock()
+	listener := r.keysListener
+	r.mux.RUnlock()
+
+	if listener != nil {
+		listener()
+	}
+}
+
+var keyModifierNames = map[string]string{
+	"ctrl":        "ctrl",
+	"control":     "ctrl",
+	"alt":         "alt",
+	"option":      "alt",
+	"optionoralt": "alt",
+	"shift":       "shift",
+	"super":       "super",
+	"win":         "super",
+}
+
+// keyModifierOrder is the order of the modifiers in the normalized keys
+var keyModifierOrder = []string{"cmd", "ctrl", "alt", "shift", "super"}
+
+var namedKeys = []string{
+	"backspace", "tab", "return", "enter", "escape", "left", "right", "up", "down",
+	"space", "delete", "home", "end", "page up", "page down", "plus", "numlock",
+}
+
+func isValidKey(key string) bool {
+	if utf8.RuneCountInString(key) == 1 {
+		return true
+	}
+
+	if slices.Contains(namedKeys, key) {
+		return true
+	}
+
+	var fNum int
+	if _, err := fmt.Sscanf(key, "f%d", &fNum); err == nil && fNum >= 1 && fNum <= 35 {
+		return fmt.Sprintf("f%d", fNum) == key
+	}
+
+	return false
+}
+
+// normalizeKeys returns the keys in a canonical form, so that two keybindings
+// that are triggered by the same keys are equal. CmdOrCtrl is resolved
+// to the modifier used by the current platform.
+func normalizeKeys(keys string) (string, error) {
+	parts := strings.Split(strings.ToLower(strings.TrimSpace(keys)), "+")
+	if len(parts) == 0 || len(parts[len(parts)-1]) == 0 {
+		return "", fmt.Errorf("invalid keybinding %q", keys)
+	}
+
+	key := strings.TrimSpace(parts[len(parts)-1])
+	if !isValidKey(key) {
+		return "", fmt.Errorf("invalid key %q", key)
+	}
+
+	modifiers := make(map[string]struct{})
+	for _, part := range parts[:len(parts)-1] {
+		part = strings.TrimSpace(part)
+
+		var modifier string
+		switch part {
+		case "cmdorctrl", "cmd", "command":
+			modifier = metaKey()
+		default:
+			name, ok := keyModifierNames[part]
+			if !ok {
+				return "", fmt.Errorf("invalid modifier %q", part)
+			}
+			modifier = name
+		}
+
+		modifiers[modifier] = struct{}{}
+	}
+
+	res := []string{}
+	for _, modifier := range keyModifierOrder {
+		if _, ok := modifiers[modifier]; ok {
+			res = append(res, modifier)
+		}
+	}
+	res = append(res, key)
+
+	return strings.Join(res, "+"), nil
+}
+
+type UpdateKeybindingReq struct {
+	CommandID string `json:"commandId"`
+	// Keys is the new keybinding, an empty string removes the keybinding from the command.
+	Keys string `json:"keys"`
+}
+
+// CommandService exposes the command registry to the frontend,
+// it is used to build the command palette and the keybindings editor.
+type CommandService struct {
+	registry *commandRegistry
+}
+
+func newCommandService(registry *commandRegistry) *CommandService {
+	return &CommandService{
+		registry: registry,
+	}
+}
+
+// ListCommands returns all the commands with their keybindings.
+func (s *CommandService) ListCommands() []Command {
+	return s.registry.list()
+}
+
+func (s *CommandService) RunCommand(commandID string) error {
+	cmd, err := s.registry.get(commandID)
+	if err != nil {
+		return err
+	}
+
+	return s.registry.runCommand(cmd)
+}
+
+// UpdateKeybinding overrides the keybinding of a command.
+// It returns an error if the keys are already bound to another command.
+// New keys are handled by the menu and by the frontend immediately,
+// while the window keybindings are registered when the app starts.
+func (s *CommandService) UpdateKeybinding(req UpdateKeybindingReq) ([]Command, error) {
+	cmd, err := s.registry.get(req.CommandID)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := strings.TrimSpace(req.Keys)
+	if len(keys) > 0 {
+		normKeys, err := normalizeKeys(keys)
+		if err != nil {
+			return nil, err
+		}
+
+		if other, ok := s.registry.getCommandByKeys(normKeys); ok && other != cmd {
+			return nil, fmt.Errorf("the keybinding %s is already used by %s", keys, other.title)
+		}
+
+		keys = normKeys
+	}
+
+	if defaultKeys, err := normalizeKeys(cmd.defaultKeys); err == nil && keys == defaultKeys {
+		s.registry.settingsSrv.removeKeybinding(cmd.id)
+	} else {
+		s.registry.settingsSrv.setKeybinding(cmd.id, keys)
+	}
+
+	s.registry.notifyKeysChange()
+
+	return s.registry.list(), nil
+}
+
+// ResetKeybinding restores the default keybinding of a command.
+func (s *CommandService) ResetKeybinding(commandID string) ([]Command, error) {
+	cmd, err := s.registry.get(commandID)
+	if err != nil {
+		return nil, err
+	}
+
+	if other, ok := s.registry.getCommandByKeys(cmd.defaultKeys); ok && other != cmd {
+		return nil, fmt.Errorf("the default keybinding %s is used by %s", cmd.defaultKeys, other.title)
+	}
+
+	s.registry.settingsSrv.removeKeybinding(cmd.id)
+	s.registry.notifyKeysChange()
+
+	return s.registry.list(), nil
+}
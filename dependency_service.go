@@ -0,0 +1,409 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+
+	"github.com/squadracorsepolito/acmelib"
+	"github.com/wailsapp/wails/v3/pkg/application"
+)
+
+type DependencyDirection string
+
+const (
+	// DependencyDirectionDependents selects the entities that depend on the given one.
+	DependencyDirectionDependents DependencyDirection = "dependents"
+	// DependencyDirectionDependencies selects the entities the given one depends on.
+	DependencyDirectionDependencies DependencyDirection = "dependencies"
+)
+
+type Dependency struct {
+	EntityPath
+
+	// ParentEntityID is the entity id of the dependency that lead to this one.
+	ParentEntityID string `json:"parentEntityId"`
+	Depth          int    `json:"depth"`
+
+	// Interfaces contains the numbers of the node interfaces involved in the
+	// relation, it is populated only when the dependency is a node.
+	Interfaces []int `json:"interfaces"`
+}
+
+// dependencyEdge represents a relation between two entities of the graph.
+// When one of the two sides is a node, intNums contains the numbers
+// of the node interfaces involved.
+type dependencyEdge struct {
+	ent     entity
+	intNums []int
+}
+
+// getDependencyEdges returns the edges of the given entity in the selected direction.
+//
+// The dependencies of an entity are:
+//   - network: buses
+//   - bus: attached nodes and messages sent on the bus
+//   - node: attached buses
+//   - message: sender node, receiver nodes and signals
+//   - signal: signal type, unit and enum
+//
+// The dependents are the same relations walked in the opposite direction.
+// Edges are always computed from the live network model, so they stay
+// correct even after operations that do not add or delete any entity
+// (e.g. changing the type of a signal).
+func getDependencyEdges(ent entity, direction DependencyDirection) []dependencyEdge {
+	if direction == DependencyDirectionDependents {
+		return getDependentEdges(ent)
+	}
+
+	edges := []dependencyEdge{}
+
+	switch ent.EntityKind() {
+	case acmelib.EntityKindNetwork:
+		net := ent.(*acmelib.Network)
+
+		for _, bus := range net.Buses() {
+			edges = append(edges, dependencyEdge{ent: bus})
+		}
+
+	case acmelib.EntityKindBus:
+		bus := ent.(*acmelib.Bus)
+
+		for _, nodeInt := range bus.NodeInterfaces() {
+			edges = appendNodeDependencyEdge(edges, nodeInt)
+		}
+
+		for _, nodeInt := range bus.NodeInterfaces() {
+			for _, msg := range nodeInt.SentMessages() {
+				edges = append(edges, dependencyEdge{ent: msg})
+			}
+		}
+
+	case acmelib.EntityKindNode:
+		node := ent.(*acmelib.Node)
+
+		for _, nodeInt := range node.Interfaces() {
+			if bus := nodeInt.ParentBus(); bus != nil {
+				edges = append(edges, dependencyEdge{ent: bus, intNums: []int{nodeInt.Number()}})
+			}
+		}
+
+	case acmelib.EntityKindMessage:
+		msg := ent.(*acmelib.Message)
+
+		if nodeInt := msg.SenderNodeInterface(); nodeInt != nil {
+			edges = appendNodeDependencyEdge(edges, nodeInt)
+		}
+
+		for _, nodeInt := range msg.Receivers() {
+			edges = appendNodeDependencyEdge(edges, nodeInt)
+		}
+
+		for _, sig := range msg.Signals() {
+			edges = append(edges, dependencyEdge{ent: sig})
+		}
+
+	case acmelib.EntityKindSignal:
+		sig := ent.(acmelib.Signal)
+
+		switch sig.Kind() {
+		case acmelib.SignalKindStandard:
+			stdSig, err := sig.ToStandard()
+			if err != nil {
+				panic(err)
+			}
+
+			edges = append(edges, dependencyEdge{ent: stdSig.Type()})
+
+			if stdSig.Unit() != nil {
+				edges = append(edges, dependencyEdge{ent: stdSig.Unit()})
+			}
+
+		case acmelib.SignalKindEnum:
+			enumSig, err := sig.ToEnum()
+			if err != nil {
+				panic(err)
+			}
+
+			edges = append(edges, dependencyEdge{ent: enumSig.Enum()})
+		}
+	}
+
+	return edges
+}
+
+func getDependentEdges(ent entity) []dependencyEdge {
+	edges := []dependencyEdge{}
+
+	switch ent.EntityKind() {
+	case acmelib.EntityKindBus:
+		bus := ent.(*acmelib.Bus)
+
+		if net := bus.ParentNetwork(); net != nil {
+			edges = append(edges, dependencyEdge{ent: net})
+		}
+
+		for _, nodeInt := range bus.NodeInterfaces() {
+			edges = appendNodeDependencyEdge(edges, nodeInt)
+		}
+
+	case acmelib.EntityKindNode:
+		node := ent.(*acmelib.Node)
+
+		for _, nodeInt := range node.Interfaces() {
+			if bus := nodeInt.ParentBus(); bus != nil {
+				edges = append(edges, dependencyEdge{ent: bus, intNums: []int{nodeInt.Number()}})
+			}
+
+			for _, msg := range nodeInt.SentMessages() {
+				edges = append(edges, dependencyEdge{ent: msg, intNums: []int{nodeInt.Number()}})
+			}
+
+			for _, msg := range nodeInt.ReceivedMessages() {
+				edges = append(edges, dependencyEdge{ent: msg, intNums: []int{nodeInt.Number()}})
+			}
+		}
+
+	case acmelib.EntityKindMessage:
+		msg := ent.(*acmelib.Message)
+
+		if nodeInt := msg.SenderNodeInterface(); nodeInt != nil {
+			if bus := nodeInt.ParentBus(); bus != nil {
+				edges = append(edges, dependencyEdge{ent: bus})
+			}
+		}
+
+	case acmelib.EntityKindSignal:
+		sig := ent.(acmelib.Signal)
+
+		if msg := sig.ParentMessage(); msg != nil {
+			edges = append(edges, dependencyEdge{ent: msg})
+		}
+
+	case acmelib.EntityKindSignalType:
+		for _, stdSig := range ent.(*acmelib.SignalType).References() {
+			edges = append(edges, dependencyEdge{ent: stdSig})
+		}
+
+	case acmelib.EntityKindSignalUnit:
+		for _, stdSig := range ent.(*acmelib.SignalUnit).References() {
+			edges = append(edges, dependencyEdge{ent: stdSig})
+		}
+
+	case acmelib.EntityKindSignalEnum:
+		for _, enumSig := range ent.(*acmelib.SignalEnum).References() {
+			edges = append(edges, dependencyEdge{ent: enumSig})
+		}
+	}
+
+	return edges
+}
+
+// appendNodeDependencyEdge appends the node of the given interface to the edges,
+// merging the interface number if the node is already present.
+func appendNodeDependencyEdge(edges []dependencyEdge, nodeInt *acmelib.NodeInterface) []dependencyEdge {
+	node := nodeInt.Node()
+
+	for idx, edge := range edges {
+		if edge.ent.EntityID() == node.EntityID() {
+			edges[idx].intNums = append(edges[idx].intNums, nodeInt.Number())
+			return edges
+		}
+	}
+
+	return append(edges, dependencyEdge{ent: node, intNums: []int{nodeInt.Number()}})
+}
+
+type DependencyService struct {
+	windowRouted
+
+	// netMux is the mutex that protects the network model,
+	// it is shared with all the entity services.
+	netMux *sync.RWMutex
+
+	network  *acmelib.Network
+	entities map[acmelib.EntityID]entity
+
+	mux sync.RWMutex
+
+	loadCh   chan *acmelib.Network
+	addCh    chan entity
+	deleteCh chan entity
+	clearCh  chan struct{}
+}
+
+func newDependencyService(netMux *sync.RWMutex) *DependencyService {
+	return &DependencyService{
+		netMux: netMux,
+
+		network:  nil,
+		entities: make(map[acmelib.EntityID]entity),
+
+		loadCh:   make(chan *acmelib.Network),
+		addCh:    make(chan entity),
+		deleteCh: make(chan entity),
+		clearCh:  make(chan struct{}),
+	}
+}
+
+func (s *DependencyService) OnStartup(ctx context.Context, _ application.ServiceOptions) error {
+	go s.run(ctx)
+	return nil
+}
+
+func (s *DependencyService) OnShutdown() {}
+
+func (s *DependencyService) run(ctx context.Context) {
+	for {
+		select {
+		case net := <-s.loadCh:
+			s.handleLoad(net)
+
+		case ent := <-s.addCh:
+			s.handleAdd(ent)
+
+		case ent := <-s.deleteCh:
+			s.handleDelete(ent)
+
+		case <-s.clearCh:
+			s.handleClear()
+
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *DependencyService) handleLoad(net *acmelib.Network) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.network = net
+	s.entities[net.EntityID()] = net
+}
+
+func (s *DependencyService) handleAdd(ent entity) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.entities[ent.EntityID()] = ent
+}
+
+func (s *DependencyService) handleDelete(ent entity) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	delete(s.entities, ent.EntityID())
+}
+
+func (s *DependencyService) handleClear() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.network = nil
+	clear(s.entities)
+}
+
+// forWindow returns the service of the window that made the call.
+func (s *DependencyService) forWindow(ctx context.Context) *DependencyService {
+	return s.resolve(ctx).dependencySrv
+}
+
+// GetDependencies returns the entities that depend on (direction "dependents")
+// or that are required by (direction "dependencies") the entity with the given id.
+// The graph is visited breadth first up to the given depth,
+// a depth lower than 1 means that there is no limit.
+// Every entity is returned only once, at the shallowest depth it is reached.
+func (s *DependencyService) GetDependencies(ctx context.Context, entityID string, direction DependencyDirection, depth int) ([]Dependency, error) {
+	s = s.forWindow(ctx)
+
+	// the network mutex must be acquired before the service one,
+	// since the entity services hold it while sending add and delete events
+	s.netMux.RLock()
+	defer s.netMux.RUnlock()
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	if direction != DependencyDirectionDependents && direction != DependencyDirectionDependencies {
+		return nil, errors.New("get dependencies: invalid direction")
+	}
+
+	root, ok := s.entities[acmelib.EntityID(entityID)]
+	if !ok {
+		return nil, errors.New("get dependencies: entity not found")
+	}
+
+	res := []Dependency{}
+
+	visited := map[acmelib.EntityID]struct{}{root.EntityID(): {}}
+	currLevel := []entity{root}
+
+	for currDepth := 1; len(currLevel) > 0 && (depth < 1 || currDepth <= depth); currDepth++ {
+		nextLevel := []entity{}
+
+		for _, ent := range currLevel {
+			for _, edge := range getDependencyEdges(ent, direction) {
+				edgeEntID := edge.ent.EntityID()
+				if _, ok := visited[edgeEntID]; ok {
+					continue
+				}
+				visited[edgeEntID] = struct{}{}
+
+				intNums := slices.Clone(edge.intNums)
+				if intNums == nil {
+					intNums = []int{}
+				}
+				slices.Sort(intNums)
+
+				res = append(res, Dependency{
+					EntityPath: newEntityPath(edge.ent),
+
+					ParentEntityID: ent.EntityID().String(),
+					Depth:          currDepth,
+
+					Interfaces: intNums,
+				})
+
+				nextLevel = append(nextLevel, edge.ent)
+			}
+		}
+
+		currLevel = nextLevel
+	}
+
+	return res, nil
+}
+
+func (s *DependencyService) getController() *dependencyController {
+	return &dependencyController{
+		loadCh:   s.loadCh,
+		addCh:    s.addCh,
+		deleteCh: s.deleteCh,
+		clearCh:  s.clearCh,
+	}
+}
+
+type dependencyController struct {
+	loadCh   chan<- *acmelib.Network
+	addCh    chan<- entity
+	deleteCh chan<- entity
+	clearCh  chan<- struct{}
+}
+
+func (dc *dependencyController) sendLoad(net *acmelib.Network) {
+	dc.loadCh <- net
+}
+
+func (dc *dependencyController) sendAdd(ent entity) {
+	dc.addCh <- ent
+}
+
+func (dc *dependencyController) sendDelete(ent entity) {
+	dc.deleteCh <- ent
+}
+
+func (dc *dependencyController) sendClear() {
+	dc.clearCh <- struct{}{}
+}
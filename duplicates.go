@@ -0,0 +1,228 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/squadracorsepolito/acmelib"
+)
+
+// Two signal types, units or enums are duplicates when they are structurally identical,
+// i.e. when they differ only by name and description. The DBC imports create
+// many of them, since every signal definition gets its own signal type.
+// Merging a group of duplicates retargets all the references to a survivor
+// and deletes the others as a single history operation.
+
+type referencedEntity interface {
+	entity
+	ReferenceCount() int
+}
+
+type DuplicateEntity struct {
+	BaseEntity
+
+	ReferenceCount int `json:"referenceCount"`
+}
+
+// DuplicateGroup holds structurally identical entities. The first one is the suggested
+// survivor of the merge: the most referenced one, or the oldest one on the same count.
+type DuplicateGroup struct {
+	Entities []DuplicateEntity `json:"entities"`
+}
+
+// findDuplicateGroups groups the entities with the same key,
+// the groups with a single entity are discarded.
+// The entities with an empty key are never grouped.
+func findDuplicateGroups[E referencedEntity](entities []E, keyFn func(E) string) []DuplicateGroup {
+	groups := make(map[string][]E)
+	for _, ent := range entities {
+		key := keyFn(ent)
+		if key == "" {
+			continue
+		}
+		groups[key] = append(groups[key], ent)
+	}
+
+	res := []DuplicateGroup{}
+	for _, group := range groups {
+		if len(group) < 2 {
+			continue
+		}
+
+		slices.SortFunc(group, func(a, b E) int {
+			if a.ReferenceCount() != b.ReferenceCount() {
+				return b.ReferenceCount() - a.ReferenceCount()
+			}
+			return a.CreateTime().Compare(b.CreateTime())
+		})
+
+		dupGroup := DuplicateGroup{}
+		for _, ent := range group {
+			dupGroup.Entities = append(dupGroup.Entities, DuplicateEntity{
+				BaseEntity:     newBaseEntity(ent),
+				ReferenceCount: ent.ReferenceCount(),
+			})
+		}
+
+		res = append(res, dupGroup)
+	}
+
+	slices.SortFunc(res, func(a, b DuplicateGroup) int {
+		return strings.Compare(a.Entities[0].Name, b.Entities[0].Name)
+	})
+
+	return res
+}
+
+// getMergeEntities returns the survivor and the duplicates of the merge request.
+// It fails if an entity is not structurally identical to the survivor.
+func (s *service[E, R, H]) getMergeEntities(req MergeDuplicatesReq, keyFn func(E) string) (E, []E, error) {
+	survivor, err := s.getEntity(req.SurvivorEntityID)
+	if err != nil {
+		return survivor, nil, err
+	}
+
+	if len(req.EntityIDs) == 0 {
+		return survivor, nil, errors.New("merge duplicates: no entities to merge")
+	}
+
+	survivorKey := keyFn(survivor)
+
+	duplicates := []E{}
+	seen := make(map[string]struct{})
+	for _, entID := range req.EntityIDs {
+		if entID == req.SurvivorEntityID {
+			continue
+		}
+
+		if _, ok := seen[entID]; ok {
+			continue
+		}
+		seen[entID] = struct{}{}
+
+		dup, err := s.getEntity(entID)
+		if err != nil {
+			return survivor, nil, err
+		}
+
+		if s.checkEditable != nil {
+			if err := s.checkEditable(dup); err != nil {
+				return survivor, nil, err
+			}
+		}
+
+		if survivorKey == "" || keyFn(dup) != survivorKey {
+			return survivor, nil, fmt.Errorf("merge duplicates: %s is not identical to %s", dup.Name(), survivor.Name())
+		}
+
+		duplicates = append(duplicates, dup)
+	}
+
+	if len(duplicates) == 0 {
+		return survivor, nil, errors.New("merge duplicates: no entities to merge")
+	}
+
+	return survivor, duplicates, nil
+}
+
+// merge retargets the references of the duplicates to the survivor, then it removes
+// the duplicates and records the operation. The retarget function moves the references
+// of a duplicate to the survivor, or back to the duplicate when undo is true.
+// If a duplicate is kept in the library, the survivor is kept in its place.
+func (s *service[E, R, H]) merge(survivor E, duplicates []E, library *networkLibrary, retarget func(dup E, undo bool) error) error {
+	survivorKept := library.isKept(survivor.EntityID())
+
+	keptDuplicates := []E{}
+	for _, dup := range duplicates {
+		if library.isKept(dup.EntityID()) {
+			keptDuplicates = append(keptDuplicates, dup)
+		}
+	}
+
+	apply := func() error {
+		for idx, dup := range duplicates {
+			if err := retarget(dup, false); err != nil {
+				for _, tmpDup := range duplicates[:idx] {
+					retarget(tmpDup, true)
+				}
+
+				return err
+			}
+		}
+
+		for _, dup := range duplicates {
+			library.release(dup)
+
+			s.removeEntity(dup.EntityID().String())
+			s.sidebarCtr.sendDelete(dup)
+		}
+
+		if len(keptDuplicates) > 0 {
+			library.keep(survivor)
+		}
+
+		return nil
+	}
+
+	if err := apply(); err != nil {
+		return err
+	}
+
+	s.sendHistoryOp(
+		func() (E, error) {
+			for _, dup := range duplicates {
+				s.addEntity(dup)
+				s.sidebarCtr.sendAdd(dup)
+
+				if err := retarget(dup, true); err != nil {
+					return survivor, err
+				}
+			}
+
+			for _, dup := range keptDuplicates {
+				library.keep(dup)
+			}
+			library.setKept(survivor, survivorKept)
+
+			return survivor, nil
+		},
+		func() (E, error) {
+			if err := apply(); err != nil {
+				return survivor, err
+			}
+
+			return survivor, nil
+		},
+	)
+
+	return nil
+}
+
+func getSignalTypeDuplicateKey(sigType *acmelib.SignalType) string {
+	return fmt.Sprintf("%s|%d|%t|%g|%g|%g|%g",
+		getSignalTypeKind(sigType), sigType.Size(), sigType.Signed(),
+		sigType.Min(), sigType.Max(), sigType.Scale(), sigType.Offset())
+}
+
+// getSignalUnitDuplicateKey returns an empty key for the units without a symbol,
+// since nothing tells whether they measure the same quantity.
+func getSignalUnitDuplicateKey(sigUnit *acmelib.SignalUnit) string {
+	if sigUnit.Symbol() == "" {
+		return ""
+	}
+
+	return fmt.Sprintf("%s|%s", newSignalUnitKind(sigUnit.Kind()), sigUnit.Symbol())
+}
+
+func getSignalEnumDuplicateKey(sigEnum *acmelib.SignalEnum) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d", sigEnum.MinSize())
+	// the values are sorted by index
+	for _, val := range sigEnum.Values() {
+		fmt.Fprintf(&b, "|%d=%s", val.Index(), val.Name())
+	}
+
+	return b.String()
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/squadracorsepolito/acmelib"
+)
+
+// loadDuplicateSignalTypes loads a network with a message whose signals
+// use structurally identical signal types with different names.
+func loadDuplicateSignalTypes(t *testing.T, m *serviceManager) {
+	t.Helper()
+
+	if err := m.createNetwork(); err != nil {
+		t.Fatal(err)
+	}
+
+	node := acmelib.NewNode("node", 1, 1)
+	bus := acmelib.NewBus("bus")
+	if err := bus.AddNodeInterface(node.Interfaces()[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := acmelib.NewMessage("msg", 1, 8)
+	for idx, name := range []string{"a", "b", "c"} {
+		sigType, err := acmelib.NewIntegerSignalType("type_"+name, 8, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sig, err := acmelib.NewStandardSignal("sig_"+name, sigType)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := msg.InsertSignal(sig, idx*8); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := node.Interfaces()[0].AddSentMessage(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	m.mux.Lock()
+	err := m.network.AddBus(bus)
+	m.mux.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.initNetwork(m.network)
+	waitServices(m)
+}
+
+func TestMergeDuplicateSignalTypes(t *testing.T) {
+	m, _ := newTestManager(t)
+
+	loadDuplicateSignalTypes(t, m)
+
+	groups := m.signalTypeSrv.FindDuplicates(t.Context())
+	if len(groups) != 1 {
+		t.Fatalf("expected one group of duplicate signal types, got %d", len(groups))
+	}
+
+	group := groups[0]
+	typeCount := len(m.signalTypeSrv.ListBrief(t.Context()))
+
+	req := MergeDuplicatesReq{SurvivorEntityID: group.Entities[0].EntityID}
+	refCount := 0
+	for _, ent := range group.Entities {
+		req.EntityIDs = append(req.EntityIDs, ent.EntityID)
+		refCount += ent.ReferenceCount
+	}
+
+	survivor, err := m.signalTypeSrv.MergeDuplicates(t.Context(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitHistory(t, m, 1)
+
+	if survivor.ReferenceCount != refCount {
+		t.Errorf("expected %d references to the survivor, got %d", refCount, survivor.ReferenceCount)
+	}
+
+	if count := len(m.signalTypeSrv.ListBrief(t.Context())); count != typeCount-len(group.Entities)+1 {
+		t.Errorf("expected %d signal types after the merge, got %d", typeCount-len(group.Entities)+1, count)
+	}
+
+	if _, err := m.historySrv.Undo(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	if count := len(m.signalTypeSrv.ListBrief(t.Context())); count != typeCount {
+		t.Errorf("expected %d signal types after the undo, got %d", typeCount, count)
+	}
+
+	for _, ent := range group.Entities {
+		sigType, err := m.signalTypeSrv.Get(t.Context(), ent.EntityID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if sigType.ReferenceCount != ent.ReferenceCount {
+			t.Errorf("%s: expected %d references after the undo, got %d", ent.Name, ent.ReferenceCount, sigType.ReferenceCount)
+		}
+	}
+}
+
+// loadDuplicateSignalDefinitions loads a network with a message whose signals
+// use signal units with the same symbol, signal units without a symbol,
+// and structurally identical signal enums with different names.
+func loadDuplicateSignalDefinitions(t *testing.T, m *serviceManager) {
+	t.Helper()
+
+	if err := m.createNetwork(); err != nil {
+		t.Fatal(err)
+	}
+
+	node := acmelib.NewNode("node", 1, 1)
+	bus := acmelib.NewBus("bus")
+	if err := bus.AddNodeInterface(node.Interfaces()[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := acmelib.NewMessage("msg", 1, 8)
+
+	sigUnits := []*acmelib.SignalUnit{
+		acmelib.NewSignalUnit("volt_a", acmelib.SignalUnitKindElectrical, "V"),
+		acmelib.NewSignalUnit("volt_b", acmelib.SignalUnitKindElectrical, "V"),
+		acmelib.NewSignalUnit("custom_a", acmelib.SignalUnitKindCustom, ""),
+		acmelib.NewSignalUnit("custom_b", acmelib.SignalUnitKindCustom, ""),
+	}
+	for idx, sigUnit := range sigUnits {
+		sigType, err := acmelib.NewIntegerSignalType(sigUnit.Name()+"_type", 8, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sig, err := acmelib.NewStandardSignal(sigUnit.Name()+"_sig", sigType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig.SetUnit(sigUnit)
+
+		if err := msg.InsertSignal(sig, idx*8); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for idx, name := range []string{"enum_a", "enum_b"} {
+		sigEnum := acmelib.NewSignalEnum(name)
+		for valIdx, valName := range []string{"OFF", "ON"} {
+			if err := sigEnum.AddValue(acmelib.NewSignalEnumValue(valName, valIdx)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		sig, err := acmelib.NewEnumSignal(name+"_sig", sigEnum)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := msg.InsertSignal(sig, (len(sigUnits)+idx)*8); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := node.Interfaces()[0].AddSentMessage(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	m.mux.Lock()
+	err := m.network.AddBus(bus)
+	m.mux.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.initNetwork(m.network)
+	waitServices(m)
+}
+
+// getMergeRequest returns the request that merges the whole group into its suggested survivor.
+func getMergeRequest(group DuplicateGroup) MergeDuplicatesReq {
+	req := MergeDuplicatesReq{SurvivorEntityID: group.Entities[0].EntityID}
+	for _, ent := range group.Entities {
+		req.EntityIDs = append(req.EntityIDs, ent.EntityID)
+	}
+	return req
+}
+
+func TestMergeDuplicateSignalUnits(t *testing.T) {
+	m, _ := newTestManager(t)
+
+	loadDuplicateSignalDefinitions(t, m)
+
+	// the units without a symbol are not identical
+	groups := m.signalUnitSrv.FindDuplicates(t.Context())
+	if len(groups) != 1 {
+		t.Fatalf("expected one group of duplicate signal units, got %d", len(groups))
+	}
+
+	group := groups[0]
+	for _, ent := range group.Entities {
+		if ent.Name != "volt_a" && ent.Name != "volt_b" {
+			t.Errorf("the signal unit %s must not be a duplicate", ent.Name)
+		}
+	}
+
+	unitCount := len(m.signalUnitSrv.ListBrief(t.Context()))
+
+	survivor, err := m.signalUnitSrv.MergeDuplicates(t.Context(), getMergeRequest(group))
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitHistory(t, m, 1)
+
+	if survivor.ReferenceCount != 2 {
+		t.Errorf("expected 2 references to the survivor, got %d", survivor.ReferenceCount)
+	}
+
+	if count := len(m.signalUnitSrv.ListBrief(t.Context())); count != unitCount-1 {
+		t.Errorf("expected %d signal units after the merge, got %d", unitCount-1, count)
+	}
+
+	if _, err := m.historySrv.Undo(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	if count := len(m.signalUnitSrv.ListBrief(t.Context())); count != unitCount {
+		t.Errorf("expected %d signal units after the undo, got %d", unitCount, count)
+	}
+
+	// the units without a symbol cannot be merged either
+	customIDs := []string{}
+	for _, brief := range m.signalUnitSrv.ListBrief(t.Context()) {
+		if brief.Name == "custom_a" || brief.Name == "custom_b" {
+			customIDs = append(customIDs, brief.EntityID)
+		}
+	}
+
+	if len(customIDs) != 2 {
+		t.Fatalf("expected 2 signal units without a symbol, got %d", len(customIDs))
+	}
+
+	req := MergeDuplicatesReq{SurvivorEntityID: customIDs[0], EntityIDs: customIDs}
+	if _, err := m.signalUnitSrv.MergeDuplicates(t.Context(), req); err == nil {
+		t.Error("expected an error merging the signal units without a symbol")
+	}
+}
+
+func TestMergeDuplicateSignalEnums(t *testing.T) {
+	m, _ := newTestManager(t)
+
+	loadDuplicateSignalDefinitions(t, m)
+
+	groups := m.signalEnumSrv.FindDuplicates(t.Context())
+	if len(groups) != 1 {
+		t.Fatalf("expected one group of duplicate signal enums, got %d", len(groups))
+	}
+
+	enumCount := len(m.signalEnumSrv.ListBrief(t.Context()))
+
+	survivor, err := m.signalEnumSrv.MergeDuplicates(t.Context(), getMergeRequest(groups[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitHistory(t, m, 1)
+
+	m.mux.RLock()
+	sigEnum, err := m.signalEnumCtr.get(survivor.EntityID)
+	m.mux.RUnlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if refCount := sigEnum.ReferenceCount(); refCount != 2 {
+		t.Errorf("expected 2 references to the survivor, got %d", refCount)
+	}
+
+	if count := len(m.signalEnumSrv.ListBrief(t.Context())); count != enumCount-1 {
+		t.Errorf("expected %d signal enums after the merge, got %d", enumCount-1, count)
+	}
+
+	if _, err := m.historySrv.Undo(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	if count := len(m.signalEnumSrv.ListBrief(t.Context())); count != enumCount {
+		t.Errorf("expected %d signal enums after the undo, got %d", enumCount, count)
+	}
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/wailsapp/wails/v3/pkg/application"
+)
+
+// The services never call the Wails runtime directly to emit events, to log
+// or to ask the user, they use the emitter, the logger and the prompter given
+// to the service manager. In the app they are backed by the Wails runtime,
+// while the in-memory ones are used when the services run without the app
+// (e.g. in the tests).
+
+type eventEmitter interface {
+	emitEvent(name string, data ...any)
+}
+
+type logger interface {
+	info(msg string, args ...any)
+	error(err error)
+}
+
+// prompter asks the user what to do when an operation needs a choice.
+// Its methods block until the choice is made.
+type prompter interface {
+	promptUnsavedChanges(networkName string) unsavedChangesChoice
+	promptFileChanged(networkName string, saved bool) fileChangedChoice
+	promptOverwriteChangedFile(networkName string) bool
+	// promptSavePath returns the path of the file where the network is saved,
+	// or an empty string if the user does not choose one.
+	promptSavePath() (string, error)
+}
+
+// multiEventEmitter emits every event with all its emitters.
+type multiEventEmitter struct {
+	emitters []eventEmitter
+}
+
+func newMultiEventEmitter(emitters ...eventEmitter) *multiEventEmitter {
+	return &multiEventEmitter{
+		emitters: emitters,
+	}
+}
+
+func (e *multiEventEmitter) emitEvent(name string, data ...any) {
+	for _, emitter := range e.emitters {
+		emitter.emitEvent(name, data...)
+	}
+}
+
+// eventSubscribers is an emitter that passes a copy of every event to its subscribers,
+// it is used to forward the events outside of the app (e.g. by the API server).
+type eventSubscribers struct {
+	mux    sync.RWMutex
+	nextID int
+	subs   map[int]func(name string, data []any)
+}
+
+func newEventSubscribers() *eventSubscribers {
+	return &eventSubscribers{
+		subs: make(map[int]func(name string, data []any)),
+	}
+}
+
+// subscribe registers a function that is called every time an event is emitted,
+// it must not block. It returns the function that removes the subscription.
+func (es *eventSubscribers) subscribe(fn func(name string, data []any)) func() {
+	es.mux.Lock()
+	defer es.mux.Unlock()
+
+	id := es.nextID
+	es.nextID++
+	es.subs[id] = fn
+
+	return func() {
+		es.mux.Lock()
+		defer es.mux.Unlock()
+
+		delete(es.subs, id)
+	}
+}
+
+func (es *eventSubscribers) emitEvent(name string, data ...any) {
+	es.mux.RLock()
+	defer es.mux.RUnlock()
+
+	for _, fn := range es.subs {
+		fn(name, data)
+	}
+}
+
+type wailsEventEmitter struct{}
+
+func newWailsEventEmitter() *wailsEventEmitter {
+	return &wailsEventEmitter{}
+}
+
+func (e *wailsEventEmitter) emitEvent(name string, data ...any) {
+	application.Get().EmitEvent(name, data...)
+}
+
+// windowEventEmitter sends the events only to the frontend of a window.
+type windowEventEmitter struct {
+	window *application.WebviewWindow
+}
+
+func newWindowEventEmitter(window *application.WebviewWindow) *windowEventEmitter {
+	return &windowEventEmitter{
+		window: window,
+	}
+}
+
+func (e *windowEventEmitter) emitEvent(name string, data ...any) {
+	e.window.DispatchWailsEvent(&application.CustomEvent{
+		Name: name,
+		Data: data,
+	})
+}
+
+type wailsLogger struct{}
+
+func newWailsLogger() *wailsLogger {
+	return &wailsLogger{}
+}
+
+func (l *wailsLogger) info(msg string, args ...any) {
+	application.Get().Logger.Info(msg, args...)
+}
+
+func (l *wailsLogger) error(err error) {
+	application.Get().Logger.Error(err.Error())
+}
+
+// dialogPrompter asks the user with the dialogs of the Wails runtime.
+type dialogPrompter struct{}
+
+func newDialogPrompter() *dialogPrompter {
+	return &dialogPrompter{}
+}
+
+func (p *dialogPrompter) promptUnsavedChanges(networkName string) unsavedChangesChoice {
+	return promptUnsavedChanges(networkName)
+}
+
+func (p *dialogPrompter) promptFileChanged(networkName string, saved bool) fileChangedChoice {
+	return promptFileChanged(networkName, saved)
+}
+
+func (p *dialogPrompter) promptOverwriteChangedFile(networkName string) bool {
+	return promptOverwriteChangedFile(networkName)
+}
+
+func (p *dialogPrompter) promptSavePath() (string, error) {
+	return newSaveNetworkDialog().PromptForSingleSelection()
+}
+
+// discardEventEmitter drops the emitted events, it is used
+// when nothing listens to them (e.g. by the command line).
+type discardEventEmitter struct{}
+
+func newDiscardEventEmitter() *discardEventEmitter {
+	return &discardEventEmitter{}
+}
+
+func (e *discardEventEmitter) emitEvent(_ string, _ ...any) {}
+
+// writerLogger writes the logged lines to a writer (e.g. the standard error).
+type writerLogger struct {
+	mux sync.Mutex
+	w   io.Writer
+}
+
+func newWriterLogger(w io.Writer) *writerLogger {
+	return &writerLogger{w: w}
+}
+
+func (l *writerLogger) info(msg string, args ...any) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	if len(args) > 0 {
+		fmt.Fprintln(l.w, append([]any{"INFO", msg}, args...)...)
+		return
+	}
+	fmt.Fprintln(l.w, "INFO", msg)
+}
+
+func (l *writerLogger) error(err error) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	fmt.Fprintln(l.w, "ERROR", err)
+}
+
+// fixedPrompter gives the same answers without asking, it is used
+// when nobody can answer (e.g. by the command line and in the tests).
+// By default, it cancels the operations that would lose or overwrite some changes.
+type fixedPrompter struct {
+	unsavedChanges       unsavedChangesChoice
+	fileChanged          fileChangedChoice
+	overwriteChangedFile bool
+	savePath             string
+}
+
+func newFixedPrompter() *fixedPrompter {
+	return &fixedPrompter{
+		unsavedChanges:       unsavedChangesChoiceCancel,
+		fileChanged:          fileChangedChoiceKeep,
+		overwriteChangedFile: false,
+		savePath:             "",
+	}
+}
+
+func (p *fixedPrompter) promptUnsavedChanges(_ string) unsavedChangesChoice {
+	return p.unsavedChanges
+}
+
+func (p *fixedPrompter) promptFileChanged(_ string, _ bool) fileChangedChoice {
+	return p.fileChanged
+}
+
+func (p *fixedPrompter) promptOverwriteChangedFile(_ string) bool {
+	return p.overwriteChangedFile
+}
+
+func (p *fixedPrompter) promptSavePath() (string, error) {
+	return p.savePath, nil
+}
+
+type emittedEvent struct {
+	name string
+	data []any
+}
+
+// memoryEventEmitter stores the emitted events.
+type memoryEventEmitter struct {
+	mux    sync.Mutex
+	events []emittedEvent
+}
+
+func newMemoryEventEmitter() *memoryEventEmitter {
+	return &memoryEventEmitter{
+		events: []emittedEvent{},
+	}
+}
+
+func (e *memoryEventEmitter) emitEvent(name string, data ...any) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	e.events = append(e.events, emittedEvent{name: name, data: data})
+}
+
+// getEvents returns the emitted events with the given name,
+// or all of them if the name is empty.
+func (e *memoryEventEmitter) getEvents(name string) []emittedEvent {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	res := []emittedEvent{}
+	for _, evt := range e.events {
+		if name == "" || evt.name == name {
+			res = append(res, evt)
+		}
+	}
+
+	return res
+}
+
+func (e *memoryEventEmitter) clear() {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	e.events = []emittedEvent{}
+}
+
+// memoryLogger stores the logged lines.
+type memoryLogger struct {
+	mux   sync.Mutex
+	lines []string
+}
+
+func newMemoryLogger() *memoryLogger {
+	return &memoryLogger{
+		lines: []string{},
+	}
+}
+
+func (l *memoryLogger) info(msg string, args ...any) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	line := "INFO " + msg
+	if len(args) > 0 {
+		line += " " + fmt.Sprint(args...)
+	}
+
+	l.lines = append(l.lines, line)
+}
+
+func (l *memoryLogger) error(err error) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	l.lines = append(l.lines, "ERROR "+err.Error())
+}
+
+func (l *memoryLogger) getLines() []string {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+
+	return append([]string{}, l.lines...)
+}
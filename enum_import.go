@@ -0,0 +1,796 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"math/bits"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/squadracorsepolito/acmelib"
+	"github.com/squadracorsepolito/acmelib/dbc"
+)
+
+// The values of a signal enum can be imported in bulk from the sources
+// where they are usually defined: the enum declarations of a C header,
+// a CSV table (name, index and an optional description) or the VAL_TABLE_
+// definitions of a DBC file. A source may hold many enums, the one to import
+// is selected by name. An enum can be exported back to a C declaration.
+
+type EnumSourceFormat string
+
+const (
+	EnumSourceFormatC   EnumSourceFormat = "c"
+	EnumSourceFormatCSV EnumSourceFormat = "csv"
+	EnumSourceFormatDBC EnumSourceFormat = "dbc"
+)
+
+type EnumSourceValue struct {
+	Name  string `json:"name"`
+	Index int    `json:"index"`
+	Desc  string `json:"desc"`
+}
+
+// enumSource is an enum found in a source, the name of a CSV source is empty.
+type enumSource struct {
+	name   string
+	values []EnumSourceValue
+}
+
+func parseEnumSources(format EnumSourceFormat, source string) ([]enumSource, error) {
+	var sources []enumSource
+	var err error
+
+	switch format {
+	case EnumSourceFormatC:
+		sources, err = parseCEnums(source)
+	case EnumSourceFormatCSV:
+		sources, err = parseCSVEnum(source)
+	case EnumSourceFormatDBC:
+		sources, err = parseDBCValueTables(source)
+	default:
+		return nil, fmt.Errorf("unknown enum source format %q", format)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(sources) == 0 {
+		return nil, errors.New("no enum found in the source")
+	}
+
+	return sources, nil
+}
+
+// selectEnumSource returns the enum with the given name,
+// the name can be empty if there is only one enum.
+func selectEnumSource(sources []enumSource, name string) (enumSource, error) {
+	if name == "" {
+		if len(sources) == 1 {
+			return sources[0], nil
+		}
+
+		return enumSource{}, fmt.Errorf("the source holds %d enums (%s), select one of them",
+			len(sources), strings.Join(getEnumSourceNames(sources), ", "))
+	}
+
+	for _, src := range sources {
+		if src.name == name {
+			return src, nil
+		}
+	}
+
+	return enumSource{}, fmt.Errorf("enum %s not found in the source", name)
+}
+
+func getEnumSourceNames(sources []enumSource) []string {
+	names := []string{}
+	for _, src := range sources {
+		if src.name != "" {
+			names = append(names, src.name)
+		}
+	}
+	return names
+}
+
+// checkEnumSourceValues returns an error if the values have a negative index,
+// or if a name or an index is used twice.
+func checkEnumSourceValues(values []EnumSourceValue) error {
+	names := make(map[string]struct{})
+	indexes := make(map[int]string)
+
+	for _, val := range values {
+		if val.Name == "" {
+			return fmt.Errorf("the value with index %d has no name", val.Index)
+		}
+
+		if val.Index < 0 {
+			return fmt.Errorf("the value %s has the negative index %d", val.Name, val.Index)
+		}
+
+		if _, ok := names[val.Name]; ok {
+			return fmt.Errorf("the value %s is defined twice", val.Name)
+		}
+		names[val.Name] = struct{}{}
+
+		if other, ok := indexes[val.Index]; ok {
+			return fmt.Errorf("the values %s and %s have the same index %d", other, val.Name, val.Index)
+		}
+		indexes[val.Index] = val.Name
+	}
+
+	return nil
+}
+
+// getEnumSizeFromIndex returns the number of bits needed by the given index.
+func getEnumSizeFromIndex(index int) int {
+	return max(1, bits.Len(uint(index)))
+}
+
+/////////
+//  C  //
+/////////
+
+type cTokenKind int
+
+const (
+	cTokenIdent cTokenKind = iota
+	cTokenNumber
+	cTokenPunct
+	cTokenComment
+)
+
+type cToken struct {
+	kind cTokenKind
+	text string
+	line int
+}
+
+// tokenizeC splits a C source into identifiers, numbers, punctuation and comments.
+// The preprocessor directives and the string literals are skipped.
+func tokenizeC(src string) ([]cToken, error) {
+	tokens := []cToken{}
+
+	line := 1
+	lineStart := true
+	for i := 0; i < len(src); {
+		ch := src[i]
+
+		switch {
+		case ch == '\n':
+			line++
+			lineStart = true
+			i++
+			continue
+
+		case ch == ' ' || ch == '\t' || ch == '\r' || ch == '\f' || ch == '\v':
+			i++
+			continue
+
+		case ch == '#' && lineStart:
+			// skip the directive, including the continued lines
+			for i < len(src) && src[i] != '\n' {
+				if src[i] == '\\' && i+1 < len(src) && src[i+1] == '\n' {
+					line++
+					i++
+				}
+				i++
+			}
+			continue
+		}
+
+		lineStart = false
+
+		switch {
+		case strings.HasPrefix(src[i:], "//"):
+			end := strings.IndexByte(src[i:], '\n')
+			if end < 0 {
+				end = len(src) - i
+			}
+			tokens = append(tokens, cToken{kind: cTokenComment, text: cleanCComment(src[i+2 : i+end]), line: line})
+			i += end
+
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("line %d: unterminated comment", line)
+			}
+			text := src[i+2 : i+2+end]
+			tokens = append(tokens, cToken{kind: cTokenComment, text: cleanCComment(text), line: line})
+			line += strings.Count(text, "\n")
+			i += end + 4
+
+		case ch == '"' || ch == '\'':
+			j := i + 1
+			for j < len(src) && src[j] != ch && src[j] != '\n' {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			i = j + 1
+
+		case ch == '_' || unicode.IsLetter(rune(ch)):
+			j := i
+			for j < len(src) && (src[j] == '_' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			tokens = append(tokens, cToken{kind: cTokenIdent, text: src[i:j], line: line})
+			i = j
+
+		case unicode.IsDigit(rune(ch)):
+			j := i
+			for j < len(src) && (src[j] == '_' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			tokens = append(tokens, cToken{kind: cTokenNumber, text: src[i:j], line: line})
+			i = j
+
+		case strings.HasPrefix(src[i:], "<<") || strings.HasPrefix(src[i:], ">>"):
+			tokens = append(tokens, cToken{kind: cTokenPunct, text: src[i : i+2], line: line})
+			i += 2
+
+		default:
+			tokens = append(tokens, cToken{kind: cTokenPunct, text: string(ch), line: line})
+			i++
+		}
+	}
+
+	return tokens, nil
+}
+
+// cleanCComment removes the comment decorations (e.g. the leading stars of a block comment).
+func cleanCComment(text string) string {
+	lines := []string{}
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		line = strings.TrimLeft(line, "*/!<")
+		line = strings.TrimSpace(line)
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, " ")
+}
+
+// cEnumParser parses the enum declarations of a C source.
+type cEnumParser struct {
+	tokens []cToken
+	pos    int
+
+	// constants holds the enumerators already parsed,
+	// since they can be used in the following expressions
+	constants map[string]int
+
+	// exprComment is the last comment found inside an expression
+	exprComment string
+}
+
+// parseCEnums returns the enums declared in a C source. An enum is named after its typedef,
+// or after its tag. The comment before an enumerator, or the one on the same line,
+// becomes the description of the value.
+func parseCEnums(src string) ([]enumSource, error) {
+	tokens, err := tokenizeC(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &cEnumParser{
+		tokens:    tokens,
+		constants: make(map[string]int),
+	}
+
+	res := []enumSource{}
+	anonymousCount := 0
+
+	for p.pos < len(p.tokens) {
+		tok := p.tokens[p.pos]
+		if tok.kind != cTokenIdent || tok.text != "enum" {
+			p.pos++
+			continue
+		}
+
+		isTypedef := false
+		for back := p.pos - 1; back >= 0; back-- {
+			if p.tokens[back].kind == cTokenComment {
+				continue
+			}
+			isTypedef = p.tokens[back].kind == cTokenIdent && p.tokens[back].text == "typedef"
+			break
+		}
+
+		p.pos++
+
+		tag := ""
+		if next, ok := p.peek(); ok && next.kind == cTokenIdent {
+			tag = next.text
+			p.pos++
+		}
+
+		if next, ok := p.peek(); !ok || next.text != "{" {
+			// a variable or a forward declaration
+			continue
+		}
+		p.pos++
+
+		values, err := p.parseEnumerators()
+		if err != nil {
+			return nil, err
+		}
+
+		name := tag
+		if isTypedef {
+			if next, ok := p.peek(); ok && next.kind == cTokenIdent {
+				name = next.text
+				p.pos++
+			}
+		}
+
+		if name == "" {
+			anonymousCount++
+			name = fmt.Sprintf("anonymous_%d", anonymousCount)
+		}
+
+		res = append(res, enumSource{name: name, values: values})
+	}
+
+	return res, nil
+}
+
+// peek returns the next token that is not a comment.
+func (p *cEnumParser) peek() (cToken, bool) {
+	for p.pos < len(p.tokens) {
+		if p.tokens[p.pos].kind != cTokenComment {
+			return p.tokens[p.pos], true
+		}
+		p.pos++
+	}
+	return cToken{}, false
+}
+
+func (p *cEnumParser) parseEnumerators() ([]EnumSourceValue, error) {
+	values := []EnumSourceValue{}
+
+	nextIndex := 0
+	docComment := ""
+	for p.pos < len(p.tokens) {
+		tok := p.tokens[p.pos]
+
+		switch {
+		case tok.kind == cTokenComment:
+			// a trailing comment describes the previous value
+			if len(values) > 0 && tok.line == p.tokens[p.pos-1].line && docComment == "" {
+				if values[len(values)-1].Desc == "" {
+					values[len(values)-1].Desc = tok.text
+				}
+			} else {
+				docComment = tok.text
+			}
+			p.pos++
+
+		case tok.text == ",":
+			p.pos++
+
+		case tok.text == "}":
+			p.pos++
+			return values, nil
+
+		case tok.kind == cTokenIdent:
+			p.pos++
+
+			desc := docComment
+			index := nextIndex
+			if next, ok := p.peek(); ok && next.text == "=" {
+				p.pos++
+
+				exprTokens := p.collectExpression()
+				if len(exprTokens) == 0 {
+					return nil, fmt.Errorf("line %d: missing value of %s", tok.line, tok.text)
+				}
+
+				val, err := evalCExpression(exprTokens, p.constants)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %s: %w", tok.line, tok.text, err)
+				}
+				index = val
+
+				if desc == "" {
+					desc = p.exprComment
+				}
+			}
+
+			p.constants[tok.text] = index
+			values = append(values, EnumSourceValue{Name: tok.text, Index: index, Desc: desc})
+
+			docComment = ""
+			nextIndex = index + 1
+
+		default:
+			return nil, fmt.Errorf("line %d: unexpected %q in enum", tok.line, tok.text)
+		}
+	}
+
+	return nil, errors.New("unterminated enum")
+}
+
+// collectExpression returns the tokens of the expression of an enumerator,
+// the trailing comments are left to the caller.
+func (p *cEnumParser) collectExpression() []cToken {
+	res := []cToken{}
+	depth := 0
+	p.exprComment = ""
+	for p.pos < len(p.tokens) {
+		tok := p.tokens[p.pos]
+
+		if tok.kind == cTokenComment {
+			p.exprComment = tok.text
+			p.pos++
+			continue
+		}
+
+		if depth == 0 && (tok.text == "," || tok.text == "}") {
+			break
+		}
+
+		switch tok.text {
+		case "(":
+			depth++
+		case ")":
+			depth--
+		}
+
+		res = append(res, tok)
+		p.pos++
+	}
+	return res
+}
+
+// cExprEvaluator evaluates the constant expressions of the enumerators,
+// following the precedence of the C operators.
+type cExprEvaluator struct {
+	tokens    []cToken
+	pos       int
+	constants map[string]int
+}
+
+func evalCExpression(tokens []cToken, constants map[string]int) (int, error) {
+	e := &cExprEvaluator{tokens: tokens, constants: constants}
+
+	val, err := e.parseBinary(0)
+	if err != nil {
+		return 0, err
+	}
+
+	if e.pos < len(e.tokens) {
+		return 0, fmt.Errorf("unexpected %q", e.tokens[e.pos].text)
+	}
+
+	return val, nil
+}
+
+// cBinaryOperators holds the binary operators from the lowest precedence.
+var cBinaryOperators = [][]string{
+	{"|"},
+	{"^"},
+	{"&"},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (e *cExprEvaluator) parseBinary(level int) (int, error) {
+	if level == len(cBinaryOperators) {
+		return e.parseUnary()
+	}
+
+	left, err := e.parseBinary(level + 1)
+	if err != nil {
+		return 0, err
+	}
+
+	for e.pos < len(e.tokens) && slices.Contains(cBinaryOperators[level], e.tokens[e.pos].text) {
+		op := e.tokens[e.pos].text
+		e.pos++
+
+		right, err := e.parseBinary(level + 1)
+		if err != nil {
+			return 0, err
+		}
+
+		switch op {
+		case "|":
+			left |= right
+		case "^":
+			left ^= right
+		case "&":
+			left &= right
+		case "<<", ">>":
+			// a negative shift count panics and a greater one overflows the index
+			if right < 0 || right >= 63 {
+				return 0, fmt.Errorf("invalid shift count %d", right)
+			}
+			if op == "<<" {
+				left <<= right
+			} else {
+				left >>= right
+			}
+		case "+":
+			left += right
+		case "-":
+			left -= right
+		case "*":
+			left *= right
+		case "/", "%":
+			if right == 0 {
+				return 0, errors.New("division by zero")
+			}
+			if op == "/" {
+				left /= right
+			} else {
+				left %= right
+			}
+		}
+	}
+
+	return left, nil
+}
+
+func (e *cExprEvaluator) parseUnary() (int, error) {
+	if e.pos >= len(e.tokens) {
+		return 0, errors.New("incomplete expression")
+	}
+
+	tok := e.tokens[e.pos]
+	e.pos++
+
+	switch {
+	case tok.text == "-":
+		val, err := e.parseUnary()
+		return -val, err
+
+	case tok.text == "+":
+		return e.parseUnary()
+
+	case tok.text == "~":
+		val, err := e.parseUnary()
+		return ^val, err
+
+	case tok.text == "(":
+		// a cast like (uint8_t) is skipped
+		if e.pos+1 < len(e.tokens) && e.tokens[e.pos].kind == cTokenIdent && e.tokens[e.pos+1].text == ")" {
+			if _, ok := e.constants[e.tokens[e.pos].text]; !ok {
+				e.pos += 2
+				return e.parseUnary()
+			}
+		}
+
+		val, err := e.parseBinary(0)
+		if err != nil {
+			return 0, err
+		}
+
+		if e.pos >= len(e.tokens) || e.tokens[e.pos].text != ")" {
+			return 0, errors.New("missing )")
+		}
+		e.pos++
+
+		return val, nil
+
+	case tok.kind == cTokenNumber:
+		return parseCNumber(tok.text)
+
+	case tok.kind == cTokenIdent:
+		val, ok := e.constants[tok.text]
+		if !ok {
+			return 0, fmt.Errorf("unknown constant %s", tok.text)
+		}
+		return val, nil
+	}
+
+	return 0, fmt.Errorf("unexpected %q", tok.text)
+}
+
+// parseCNumber parses a C integer literal, with its base prefix and its suffixes.
+func parseCNumber(text string) (int, error) {
+	num := strings.TrimRight(text, "uUlL")
+
+	base := 10
+	switch {
+	case strings.HasPrefix(num, "0x") || strings.HasPrefix(num, "0X"):
+		base = 16
+		num = num[2:]
+	case strings.HasPrefix(num, "0b") || strings.HasPrefix(num, "0B"):
+		base = 2
+		num = num[2:]
+	case len(num) > 1 && num[0] == '0':
+		base = 8
+		num = num[1:]
+	}
+
+	val, err := strconv.ParseInt(num, base, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %s", text)
+	}
+
+	return int(val), nil
+}
+
+// exportCEnum returns the C declaration of the signal enum.
+// The names that are not valid C identifiers are fixed.
+func exportCEnum(sigEnum *acmelib.SignalEnum) string {
+	var b strings.Builder
+
+	if desc := strings.TrimSpace(sigEnum.Desc()); desc != "" {
+		fmt.Fprintf(&b, "/* %s */\n", strings.ReplaceAll(desc, "*/", "* /"))
+	}
+
+	b.WriteString("typedef enum {\n")
+	for _, val := range sigEnum.Values() {
+		fmt.Fprintf(&b, "\t%s = %d,", toCIdentifier(val.Name()), val.Index())
+
+		if desc := strings.TrimSpace(val.Desc()); desc != "" {
+			fmt.Fprintf(&b, " /* %s */", strings.ReplaceAll(strings.ReplaceAll(desc, "\n", " "), "*/", "* /"))
+		}
+
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "} %s;\n", toCIdentifier(sigEnum.Name()))
+
+	return b.String()
+}
+
+func toCIdentifier(name string) string {
+	var b strings.Builder
+	for idx, ch := range strings.TrimSpace(name) {
+		switch {
+		case ch == '_' || (ch < unicode.MaxASCII && unicode.IsLetter(ch)):
+			b.WriteRune(ch)
+		case ch < unicode.MaxASCII && unicode.IsDigit(ch):
+			if idx == 0 {
+				b.WriteRune('_')
+			}
+			b.WriteRune(ch)
+		default:
+			b.WriteRune('_')
+		}
+	}
+
+	if b.Len() == 0 {
+		return "_"
+	}
+
+	return b.String()
+}
+
+/////////
+// CSV //
+/////////
+
+// parseCSVEnum parses a table of values. The columns are recognized by the header
+// (name, index or value, desc or description). Without a header, the columns are
+// the name, the index and the description, or the index first if the first cell is a number.
+// The cells can be separated by commas or semicolons.
+func parseCSVEnum(src string) ([]enumSource, error) {
+	reader := csv.NewReader(strings.NewReader(src))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+
+	firstLine, _, _ := strings.Cut(src, "\n")
+	if strings.Contains(firstLine, ";") && !strings.Contains(firstLine, ",") {
+		reader.Comma = ';'
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	records = slices.DeleteFunc(records, func(record []string) bool {
+		return len(record) == 0 || (len(record) == 1 && strings.TrimSpace(record[0]) == "")
+	})
+
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	nameCol, indexCol, descCol := 0, 1, 2
+
+	header := records[0]
+	hasHeader := false
+	for col, cell := range header {
+		switch strings.ToLower(strings.TrimSpace(cell)) {
+		case "name":
+			nameCol = col
+			hasHeader = true
+		case "index", "value":
+			indexCol = col
+			hasHeader = true
+		case "desc", "description":
+			descCol = col
+			hasHeader = true
+		}
+	}
+
+	if hasHeader {
+		records = records[1:]
+	} else if len(header) > 1 {
+		if _, err := parseCSVIndex(header[0]); err == nil {
+			if _, err := parseCSVIndex(header[1]); err != nil {
+				nameCol, indexCol = 1, 0
+			}
+		}
+	}
+
+	values := []EnumSourceValue{}
+	for row, record := range records {
+		if nameCol >= len(record) || indexCol >= len(record) {
+			return nil, fmt.Errorf("row %d: missing name or index", row+1)
+		}
+
+		index, err := parseCSVIndex(record[indexCol])
+		if err != nil {
+			return nil, fmt.Errorf("row %d: invalid index %q", row+1, record[indexCol])
+		}
+
+		val := EnumSourceValue{
+			Name:  strings.TrimSpace(record[nameCol]),
+			Index: index,
+		}
+
+		if descCol < len(record) {
+			val.Desc = strings.TrimSpace(record[descCol])
+		}
+
+		values = append(values, val)
+	}
+
+	return []enumSource{{values: values}}, nil
+}
+
+func parseCSVIndex(cell string) (int, error) {
+	val, err := strconv.ParseInt(strings.TrimSpace(cell), 0, 64)
+	return int(val), err
+}
+
+/////////
+// DBC //
+/////////
+
+// parseDBCValueTables returns the VAL_TABLE_ definitions of a DBC source,
+// the source can be a whole DBC file or only the definitions.
+func parseDBCValueTables(src string) ([]enumSource, error) {
+	dbcFile, err := dbc.Parse("value_tables.dbc", strings.NewReader(src), false)
+	if err != nil {
+		return nil, err
+	}
+
+	res := []enumSource{}
+	for _, valTable := range dbcFile.ValueTables {
+		values := []EnumSourceValue{}
+		for _, valDesc := range valTable.Values {
+			val := EnumSourceValue{
+				Name:  clearDBCName(valDesc.Name),
+				Index: int(valDesc.ID),
+			}
+
+			// the description keeps the original text if the name has been fixed
+			if val.Name != valDesc.Name {
+				val.Desc = valDesc.Name
+			}
+
+			values = append(values, val)
+		}
+
+		// the definitions usually list the values from the highest
+		slices.SortFunc(values, func(a, b EnumSourceValue) int { return a.Index - b.Index })
+
+		res = append(res, enumSource{name: valTable.Name, values: values})
+	}
+
+	return res, nil
+}
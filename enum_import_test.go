@@ -0,0 +1,195 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/squadracorsepolito/acmelib"
+)
+
+const testCHeader = `
+#ifndef STATES_H
+#define STATES_H \
+	1
+
+/* states of the inverter */
+typedef enum {
+	INV_OFF,          /**< the inverter is off */
+	INV_PRECHARGE = 0x2,
+	/* running with torque */
+	INV_RUN,
+	INV_FAULT = (1 << 3) | INV_OFF, // latched fault
+} inverter_state_t;
+
+enum bms_mode {
+	BMS_IDLE = 1u,
+	BMS_CHARGE = BMS_IDLE + 1,
+};
+
+#endif
+`
+
+func TestParseCEnums(t *testing.T) {
+	sources, err := parseCEnums(testCHeader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if names := getEnumSourceNames(sources); !slices.Equal(names, []string{"inverter_state_t", "bms_mode"}) {
+		t.Fatalf("unexpected enums %v", names)
+	}
+
+	expected := []EnumSourceValue{
+		{Name: "INV_OFF", Index: 0, Desc: "the inverter is off"},
+		{Name: "INV_PRECHARGE", Index: 2},
+		{Name: "INV_RUN", Index: 3, Desc: "running with torque"},
+		{Name: "INV_FAULT", Index: 8, Desc: "latched fault"},
+	}
+	if !slices.Equal(sources[0].values, expected) {
+		t.Errorf("expected %v, got %v", expected, sources[0].values)
+	}
+
+	bmsMode, err := selectEnumSource(sources, "bms_mode")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = []EnumSourceValue{
+		{Name: "BMS_IDLE", Index: 1},
+		{Name: "BMS_CHARGE", Index: 2},
+	}
+	if !slices.Equal(bmsMode.values, expected) {
+		t.Errorf("expected %v, got %v", expected, bmsMode.values)
+	}
+
+	if _, err := selectEnumSource(sources, ""); err == nil {
+		t.Error("an enum must be selected when the source holds more than one")
+	}
+
+	for _, shift := range []string{"-1", "63"} {
+		if _, err := parseCEnums("enum shifted { A = 1 << " + shift + " };"); err == nil {
+			t.Errorf("expected an error for the shift count %s", shift)
+		}
+	}
+}
+
+func TestParseCSVEnum(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{"header", "index,name,description\n0,OFF,\n1,ON,switched on\n"},
+		{"semicolons", "OFF;0\nON;1;switched on\n"},
+		{"index first", "0,OFF\n1,ON,switched on\n"},
+	}
+
+	expected := []EnumSourceValue{
+		{Name: "OFF", Index: 0},
+		{Name: "ON", Index: 1, Desc: "switched on"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sources, err := parseCSVEnum(tt.source)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(sources) != 1 || !slices.Equal(sources[0].values, expected) {
+				t.Errorf("expected %v, got %v", expected, sources)
+			}
+		})
+	}
+}
+
+func TestParseDBCValueTables(t *testing.T) {
+	sources, err := parseDBCValueTables(`VAL_TABLE_ Gear 2 "Drive" 1 "Neutral" 0 "Park Lock" ;`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []EnumSourceValue{
+		{Name: "Park_Lock", Index: 0, Desc: "Park Lock"},
+		{Name: "Neutral", Index: 1},
+		{Name: "Drive", Index: 2},
+	}
+	if len(sources) != 1 || sources[0].name != "Gear" || !slices.Equal(sources[0].values, expected) {
+		t.Errorf("expected Gear %v, got %v", expected, sources)
+	}
+}
+
+func TestExportCEnum(t *testing.T) {
+	sigEnum := acmelib.NewSignalEnum("gear mode")
+	for idx, name := range []string{"PARK", "2WD"} {
+		val := acmelib.NewSignalEnumValue(name, idx*4)
+		val.SetDesc("value " + name)
+		if err := sigEnum.AddValue(val); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sources, err := parseCEnums(exportCEnum(sigEnum))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []EnumSourceValue{
+		{Name: "PARK", Index: 0, Desc: "value PARK"},
+		{Name: "_2WD", Index: 4, Desc: "value 2WD"},
+	}
+	if len(sources) != 1 || sources[0].name != "gear_mode" || !slices.Equal(sources[0].values, expected) {
+		t.Errorf("expected gear_mode %v, got %v", expected, sources)
+	}
+}
+
+func TestImportValuesMerge(t *testing.T) {
+	sigEnum := acmelib.NewSignalEnum("state")
+	if err := sigEnum.AddValue(acmelib.NewSignalEnumValue("OFF", 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	sources := []enumSource{{values: []EnumSourceValue{{Name: "OFF", Index: 0}, {Name: "ON", Index: 5}}}}
+
+	values, err := getImportedValues(sigEnum, sources, &ImportValuesReq{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(values, []EnumSourceValue{{Name: "ON", Index: 5}}) {
+		t.Errorf("the values already in the enum must be skipped, got %v", values)
+	}
+
+	if size := getEnumSizeFromIndex(5); size != 3 {
+		t.Errorf("expected size 3, got %d", size)
+	}
+
+	sources = []enumSource{{values: []EnumSourceValue{{Name: "IDLE", Index: 0}}}}
+	if _, err := getImportedValues(sigEnum, sources, &ImportValuesReq{}); err == nil {
+		t.Error("a taken index must be rejected")
+	}
+	if _, err := getImportedValues(sigEnum, sources, &ImportValuesReq{Replace: true}); err != nil {
+		t.Errorf("a replacing import must ignore the current values: %v", err)
+	}
+}
+
+func TestSwapSignalEnumValuesRollback(t *testing.T) {
+	sigEnum := acmelib.NewSignalEnum("state")
+	for idx, name := range []string{"OFF", "ON"} {
+		if err := sigEnum.AddValue(acmelib.NewSignalEnumValue(name, idx)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	values := sigEnum.Values()
+	// the last value is not in the enum, so its removal fails
+	remValues := append(slices.Clone(values), acmelib.NewSignalEnumValue("IDLE", 2))
+	addValues := []*acmelib.SignalEnumValue{acmelib.NewSignalEnumValue("READY", 0)}
+
+	if err := swapSignalEnumValues(sigEnum, remValues, addValues); err == nil {
+		t.Fatal("expected an error removing a value that is not in the enum")
+	}
+
+	if got := sigEnum.Values(); !slices.Equal(got, values) {
+		t.Errorf("the removed values have not been restored, got %d values", len(got))
+	}
+}
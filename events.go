@@ -1,7 +1,14 @@
 package main
 
 const (
-	NetworkLoaded = "network-loaded"
+	NetworkLoaded      = "network-loaded"
+	NetworkFileChanged = "network-file-changed"
+
+	SettingsUpdated = "settings-updated"
+
+	CommandRun = "command-run"
+
+	ScriptRun = "script-run"
 
 	SidebarLoad       = "sidebar-load"
 	SidebarUpdateName = "sidebar-update-name"
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/squadracorsepolito/acmelib"
+)
+
+// The network file is polled to detect the changes made by other programs
+// (e.g. a teammate editing the same file or a git checkout).
+// The state of the file is stored every time the network is loaded or saved,
+// so the changes made by canturin itself are never reported.
+
+// fileWatchInterval is the interval between two checks of the network file.
+const fileWatchInterval = 2 * time.Second
+
+// maxDiffLines is the maximum number of changed lines compared line by line,
+// bigger changes are reported as a whole block removed and added.
+const maxDiffLines = 2000
+
+type networkFileState struct {
+	path    string
+	modTime time.Time
+	size    int64
+	hash    [sha256.Size]byte
+}
+
+func readNetworkFileState(path string) (networkFileState, error) {
+	state := networkFileState{path: path}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return state, err
+	}
+
+	fileBuf, err := os.ReadFile(path)
+	if err != nil {
+		return state, err
+	}
+
+	state.modTime = info.ModTime()
+	state.size = info.Size()
+	state.hash = sha256.Sum256(fileBuf)
+
+	return state, nil
+}
+
+type NetworkDiffLineKind string
+
+const (
+	NetworkDiffLineKindEqual   NetworkDiffLineKind = "equal"
+	NetworkDiffLineKindAdded   NetworkDiffLineKind = "added"
+	NetworkDiffLineKindRemoved NetworkDiffLineKind = "removed"
+)
+
+// NetworkDiffLine is a line of the text encoding of the network.
+// Added lines are in the file on disk, removed lines are in the current network.
+type NetworkDiffLine struct {
+	Kind NetworkDiffLineKind `json:"kind"`
+	Text string              `json:"text"`
+}
+
+type NetworkFileChangedEvent struct {
+	Path string            `json:"path"`
+	Diff []NetworkDiffLine `json:"diff"`
+}
+
+// storeFileState saves the state of the network file,
+// it must be called after the file is loaded or written.
+func (m *serviceManager) storeFileState() {
+	path := m.getFilePath()
+
+	m.fileMux.Lock()
+	defer m.fileMux.Unlock()
+
+	m.storeFileStateLocked(path)
+}
+
+// storeFileStateLocked saves the state of the file at the given path,
+// the caller must hold the file mutex.
+// A change waiting for the choice of the user is dropped, since the file
+// has just been written or loaded. Otherwise, the file watcher would stay stopped
+// if the frontend never answers to the differences it has been sent.
+func (m *serviceManager) storeFileStateLocked(path string) {
+	m.fileChangePending.Store(false)
+
+	if path == "" {
+		m.fileState = networkFileState{}
+		return
+	}
+
+	state, err := readNetworkFileState(path)
+	if err != nil {
+		m.log.error(err)
+		return
+	}
+
+	m.fileState = state
+}
+
+// hasFileChanged returns true if the network file has been changed
+// since it was loaded or saved. The caller must hold the file mutex.
+func (m *serviceManager) hasFileChanged() (bool, error) {
+	path := m.getFilePath()
+	if path == "" || m.fileState.path != path {
+		return false, nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// the file will be recreated by the next save
+			return false, nil
+		}
+		return false, err
+	}
+
+	if info.ModTime().Equal(m.fileState.modTime) && info.Size() == m.fileState.size {
+		return false, nil
+	}
+
+	// the file may have been touched without changing its content
+	state, err := readNetworkFileState(path)
+	if err != nil {
+		return false, err
+	}
+
+	return state.hash != m.fileState.hash, nil
+}
+
+// runFileWatcher periodically checks if the network file has been changed
+// by another program and asks the user what to do.
+func (m *serviceManager) runFileWatcher(ctx context.Context) {
+	for {
+		select {
+		case <-time.After(fileWatchInterval):
+		case <-ctx.Done():
+			return
+		}
+
+		if m.fileChangePending.Load() {
+			continue
+		}
+
+		m.fileMux.Lock()
+		changed, err := m.hasFileChanged()
+		m.fileMux.Unlock()
+
+		if err != nil {
+			m.log.error(err)
+			continue
+		}
+
+		if changed {
+			m.handleFileChange()
+		}
+	}
+}
+
+// handleFileChange asks the user to reload the network from disk, to keep the current one,
+// or to show the differences. In the last case, the choice is made by the frontend
+// by calling reloadFromDisk or keepCurrentNetwork.
+func (m *serviceManager) handleFileChange() {
+	if !m.fileChangePending.CompareAndSwap(false, true) {
+		return
+	}
+
+	m.mux.RLock()
+	netName := m.network.Name()
+	m.mux.RUnlock()
+
+	switch m.prompter.promptFileChanged(netName, m.historySrv.isSaved()) {
+	case fileChangedChoiceReload:
+		if err := m.reloadFromDisk(); err != nil {
+			m.log.error(err)
+		}
+
+	case fileChangedChoiceKeep:
+		m.keepCurrentNetwork()
+
+	case fileChangedChoiceDiff:
+		diff, err := m.getFileDiff()
+		if err != nil {
+			m.fileChangePending.Store(false)
+			m.log.error(err)
+			return
+		}
+
+		m.emitter.emitEvent(NetworkFileChanged, NetworkFileChangedEvent{
+			Path: m.getFilePath(),
+			Diff: diff,
+		})
+	}
+}
+
+// loadNetworkFile loads the network file and returns the network with its library.
+func (m *serviceManager) loadNetworkFile(path string) (*acmelib.Network, *networkLibrary, error) {
+	fileBuf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return decodeNetwork(fileBuf, m.getEncoding(path), filepath.Dir(path))
+}
+
+// reloadFromDisk replaces the network with the one in the file,
+// the unsaved changes are lost.
+func (m *serviceManager) reloadFromDisk() error {
+	defer m.fileChangePending.Store(false)
+
+	path := m.getFilePath()
+	if path == "" {
+		return errors.New("reload: the network has never been saved")
+	}
+
+	net, library, err := m.loadNetworkFile(path)
+	if err != nil {
+		return err
+	}
+
+	m.clearServices()
+	m.loadLibrary(library)
+	m.initNetwork(net)
+	m.historySrv.setSaved(true)
+
+	m.storeFileState()
+
+	return nil
+}
+
+// keepCurrentNetwork ignores the changes made to the file.
+// The network is marked as not saved, since it differs from the file,
+// and the next save overwrites the file without asking.
+func (m *serviceManager) keepCurrentNetwork() {
+	defer m.fileChangePending.Store(false)
+
+	m.storeFileState()
+	m.historySrv.setSaved(false)
+}
+
+// getFileDiff compares the text encoding of the current network
+// with the one of the network in the file.
+func (m *serviceManager) getFileDiff() ([]NetworkDiffLine, error) {
+	path := m.getFilePath()
+	if path == "" {
+		return nil, errors.New("diff: the network has never been saved")
+	}
+
+	diskNet, _, err := m.loadNetworkFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	diskLines, err := getNetworkTextLines(diskNet)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mux.RLock()
+	currLines, err := getNetworkTextLines(m.network)
+	m.mux.RUnlock()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return diffLines(currLines, diskLines), nil
+}
+
+func getNetworkTextLines(net *acmelib.Network) ([]string, error) {
+	buf := new(bytes.Buffer)
+	if err := acmelib.SaveNetwork(net, acmelib.SaveEncodingText, nil, nil, buf); err != nil {
+		return nil, err
+	}
+
+	return strings.Split(strings.TrimRight(buf.String(), "\n"), "\n"), nil
+}
+
+// diffLines returns the line diff that turns the old lines into the new ones.
+// The common prefix and suffix are skipped and the remaining lines
+// are compared with the longest common subsequence.
+func diffLines(oldLines, newLines []string) []NetworkDiffLine {
+	prefix := 0
+	for prefix < len(oldLines) && prefix < len(newLines) && oldLines[prefix] == newLines[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(oldLines)-prefix && suffix < len(newLines)-prefix &&
+		oldLines[len(oldLines)-1-suffix] == newLines[len(newLines)-1-suffix] {
+		suffix++
+	}
+
+	res := []NetworkDiffLine{}
+	for _, line := range oldLines[:prefix] {
+		res = append(res, NetworkDiffLine{Kind: NetworkDiffLineKindEqual, Text: line})
+	}
+
+	oldMid := oldLines[prefix : len(oldLines)-suffix]
+	newMid := newLines[prefix : len(newLines)-suffix]
+
+	if len(oldMid) > maxDiffLines || len(newMid) > maxDiffLines {
+		for _, line := range oldMid {
+			res = append(res, NetworkDiffLine{Kind: NetworkDiffLineKindRemoved, Text: line})
+		}
+		for _, line := range newMid {
+			res = append(res, NetworkDiffLine{Kind: NetworkDiffLineKindAdded, Text: line})
+		}
+	} else {
+		res = append(res, diffLinesLCS(oldMid, newMid)...)
+	}
+
+	for _, line := range oldLines[len(oldLines)-suffix:] {
+		res = append(res, NetworkDiffLine{Kind: NetworkDiffLineKindEqual, Text: line})
+	}
+
+	return res
+}
+
+func diffLinesLCS(oldLines, newLines []string) []NetworkDiffLine {
+	// lcs[i][j] is the length of the longest common subsequence
+	// of oldLines[i:] and newLines[j:]
+	lcs := make([][]int, len(oldLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(newLines)+1)
+	}
+
+	for i := len(oldLines) - 1; i >= 0; i-- {
+		for j := len(newLines) - 1; j >= 0; j-- {
+			if oldLines[i] == newLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	res := []NetworkDiffLine{}
+	i, j := 0, 0
+	for i < len(oldLines) && j < len(newLines) {
+		switch {
+		case oldLines[i] == newLines[j]:
+			res = append(res, NetworkDiffLine{Kind: NetworkDiffLineKindEqual, Text: oldLines[i]})
+			i++
+			j++
+
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			res = append(res, NetworkDiffLine{Kind: NetworkDiffLineKindRemoved, Text: oldLines[i]})
+			i++
+
+		default:
+			res = append(res, NetworkDiffLine{Kind: NetworkDiffLineKindAdded, Text: newLines[j]})
+			j++
+		}
+	}
+
+	for ; i < len(oldLines); i++ {
+		res = append(res, NetworkDiffLine{Kind: NetworkDiffLineKindRemoved, Text: oldLines[i]})
+	}
+	for ; j < len(newLines); j++ {
+		res = append(res, NetworkDiffLine{Kind: NetworkDiffLineKindAdded, Text: newLines[j]})
+	}
+
+	return res
+}
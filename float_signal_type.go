@@ -0,0 +1,284 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/squadracorsepolito/acmelib"
+	"github.com/squadracorsepolito/acmelib/dbc"
+)
+
+// acmelib has no kind for the IEEE-754 signal types, so a float signal type
+// is stored as a decimal signal type with the size of the float (32 or 64 bits)
+// and with the min and the max set to the limits of the float.
+// The size, the sign and the limits of a float signal type cannot be changed,
+// while the scale and the offset can. In the DBC files the float signals are
+// marked by the SIG_VALTYPE_ entries.
+
+func isFloatSignalTypeKind(kind SignalTypeKind) bool {
+	return kind == SignalTypeKindFloat32 || kind == SignalTypeKindFloat64
+}
+
+// getFloatLimit returns the size and the max value of a float signal type kind.
+func getFloatLimit(kind SignalTypeKind) (int, float64) {
+	if kind == SignalTypeKindFloat64 {
+		return 64, math.MaxFloat64
+	}
+	return 32, math.MaxFloat32
+}
+
+func newFloatSignalType(name string, kind SignalTypeKind, scale, offset float64) (*acmelib.SignalType, error) {
+	size, limit := getFloatLimit(kind)
+
+	sigType, err := acmelib.NewDecimalSignalType(name, size, true)
+	if err != nil {
+		return nil, err
+	}
+
+	sigType.SetMin(-limit)
+	sigType.SetMax(limit)
+	sigType.SetScale(scale)
+	sigType.SetOffset(offset)
+
+	return sigType, nil
+}
+
+// getFloatSignalTypeKind returns the float kind of the signal type,
+// it returns false if the signal type is not a float one.
+func getFloatSignalTypeKind(sigType *acmelib.SignalType) (SignalTypeKind, bool) {
+	if sigType.Kind() != acmelib.SignalTypeKindDecimal || !sigType.Signed() {
+		return "", false
+	}
+
+	for _, kind := range []SignalTypeKind{SignalTypeKindFloat32, SignalTypeKindFloat64} {
+		size, limit := getFloatLimit(kind)
+		if sigType.Size() == size && sigType.Min() == -limit && sigType.Max() == limit {
+			return kind, true
+		}
+	}
+
+	return "", false
+}
+
+func isFloatSignalType(sigType *acmelib.SignalType) bool {
+	_, ok := getFloatSignalTypeKind(sigType)
+	return ok
+}
+
+// getSignalTypeKind returns the kind of the signal type, including the float ones.
+func getSignalTypeKind(sigType *acmelib.SignalType) SignalTypeKind {
+	if kind, ok := getFloatSignalTypeKind(sigType); ok {
+		return kind
+	}
+	return newSignalTypeKind(sigType.Kind())
+}
+
+func clearDBCName(name string) string {
+	return strings.ReplaceAll(strings.TrimSpace(name), " ", "_")
+}
+
+// getMessageSignals returns the signals of the message, including the ones
+// in the layouts of its multiplexer signals.
+func getMessageSignals(msg *acmelib.Message) []acmelib.Signal {
+	res := []acmelib.Signal{}
+	seen := make(map[acmelib.EntityID]struct{})
+
+	var addSignals func(signals []acmelib.Signal)
+	addSignals = func(signals []acmelib.Signal) {
+		for _, sig := range signals {
+			// the fixed signals are in all the layouts
+			if _, ok := seen[sig.EntityID()]; ok {
+				continue
+			}
+			seen[sig.EntityID()] = struct{}{}
+
+			res = append(res, sig)
+
+			muxSig, err := sig.ToMultiplexer()
+			if err != nil {
+				continue
+			}
+
+			for _, layout := range muxSig.GetSignalGroups() {
+				addSignals(layout)
+			}
+		}
+	}
+
+	addSignals(msg.Signals())
+
+	return res
+}
+
+// getDBCFloatValueTypes returns the SIG_VALTYPE_ entries of the float signals of the bus.
+func getDBCFloatValueTypes(bus *acmelib.Bus) []*dbc.SignalExtValueType {
+	res := []*dbc.SignalExtValueType{}
+
+	for _, nodeInt := range bus.NodeInterfaces() {
+		for _, msg := range nodeInt.SentMessages() {
+			for _, sig := range getMessageSignals(msg) {
+				stdSig, err := sig.ToStandard()
+				if err != nil {
+					continue
+				}
+
+				kind, ok := getFloatSignalTypeKind(stdSig.Type())
+				if !ok {
+					continue
+				}
+
+				valType := dbc.SignalExtValueTypeFloat
+				if kind == SignalTypeKindFloat64 {
+					valType = dbc.SignalExtValueTypeDouble
+				}
+
+				res = append(res, &dbc.SignalExtValueType{
+					MessageID:    uint32(msg.GetCANID()),
+					SignalName:   clearDBCName(sig.Name()),
+					ExtValueType: valType,
+				})
+			}
+		}
+	}
+
+	return res
+}
+
+// addDBCFloatValueTypes adds the SIG_VALTYPE_ entries to the DBC files
+// exported by acmelib into the given folder.
+func addDBCFloatValueTypes(net *acmelib.Network, basePath string) error {
+	dirPath := filepath.Join(basePath, clearDBCName(net.Name()))
+
+	for _, bus := range net.Buses() {
+		valTypes := getDBCFloatValueTypes(bus)
+		if len(valTypes) == 0 {
+			continue
+		}
+
+		path := filepath.Join(dirPath, clearDBCName(bus.Name())+dbc.FileExtension)
+
+		fileBuf, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		dbcFile, err := dbc.Parse(path, bytes.NewReader(fileBuf), false)
+		if err != nil {
+			return err
+		}
+
+		dbcFile.SignalExtValueTypes = append(dbcFile.SignalExtValueTypes, valTypes...)
+
+		outBuf := new(bytes.Buffer)
+		dbc.Write(outBuf, dbcFile, false)
+
+		if err := os.WriteFile(path, outBuf.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// applyDBCFloatValueTypes sets a float signal type to the signals of the imported bus
+// that are marked as float or double by the SIG_VALTYPE_ entries of the DBC file.
+// The given signal types (e.g. the ones of the network) identical to a float one
+// are reused, and the new float signal types never take their names.
+func applyDBCFloatValueTypes(bus *acmelib.Bus, dbcFile *dbc.File, sigTypes []*acmelib.SignalType) error {
+	if len(dbcFile.SignalExtValueTypes) == 0 {
+		return nil
+	}
+
+	messages := make(map[uint32]*acmelib.Message)
+	for _, nodeInt := range bus.NodeInterfaces() {
+		for _, msg := range nodeInt.SentMessages() {
+			messages[uint32(msg.GetCANID())] = msg
+		}
+	}
+
+	identicalTypes := groupIdentical(sigTypes, getSignalTypeDuplicateKey)
+
+	takenNames := make(map[string]struct{})
+	for _, sigType := range sigTypes {
+		takenNames[sigType.Name()] = struct{}{}
+	}
+
+	floatTypes := make(map[string]*acmelib.SignalType)
+
+	for _, valType := range dbcFile.SignalExtValueTypes {
+		var kind SignalTypeKind
+		switch valType.ExtValueType {
+		case dbc.SignalExtValueTypeFloat:
+			kind = SignalTypeKindFloat32
+		case dbc.SignalExtValueTypeDouble:
+			kind = SignalTypeKindFloat64
+		default:
+			continue
+		}
+
+		msg, ok := messages[valType.MessageID]
+		if !ok {
+			continue
+		}
+
+		var stdSig *acmelib.StandardSignal
+		for _, sig := range getMessageSignals(msg) {
+			if sig.Name() != valType.SignalName {
+				continue
+			}
+
+			tmpStdSig, err := sig.ToStandard()
+			if err != nil {
+				return fmt.Errorf("signal %s: only the standard signals can be float", sig.Name())
+			}
+			stdSig = tmpStdSig
+		}
+
+		if stdSig == nil {
+			continue
+		}
+
+		size, _ := getFloatLimit(kind)
+		if stdSig.GetSize() != size {
+			return fmt.Errorf("signal %s: a %s signal must be %d bits, got %d", stdSig.Name(), kind, size, stdSig.GetSize())
+		}
+
+		oldSigType := stdSig.Type()
+
+		key := fmt.Sprintf("%s_%g_%g", kind, oldSigType.Scale(), oldSigType.Offset())
+		sigType, ok := floatTypes[key]
+		if !ok {
+			name := string(kind) + "_t"
+			if oldSigType.Scale() != 1 || oldSigType.Offset() != 0 {
+				name = fmt.Sprintf("%s_%g_%g_t", kind, oldSigType.Scale(), oldSigType.Offset())
+			}
+			if _, ok := takenNames[name]; ok {
+				name = getNewName(name, takenNames)
+			}
+
+			tmpSigType, err := newFloatSignalType(name, kind, oldSigType.Scale(), oldSigType.Offset())
+			if err != nil {
+				return err
+			}
+
+			if identicalType, ok := findIdentical(identicalTypes, tmpSigType, getSignalTypeDuplicateKey); ok {
+				tmpSigType = identicalType
+			} else {
+				takenNames[name] = struct{}{}
+			}
+
+			sigType = tmpSigType
+			floatTypes[key] = sigType
+		}
+
+		if err := stdSig.SetType(sigType); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
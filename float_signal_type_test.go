@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/squadracorsepolito/acmelib"
+	"github.com/squadracorsepolito/acmelib/dbc"
+)
+
+func TestDBCFloatRoundTrip(t *testing.T) {
+	net := acmelib.NewNetwork("float_net")
+	bus := acmelib.NewBus("imu")
+	if err := net.AddBus(bus); err != nil {
+		t.Fatal(err)
+	}
+
+	node := acmelib.NewNode("IMU", 1, 1)
+	if err := bus.AddNodeInterface(node.Interfaces()[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := acmelib.NewMessage("IMU_ACCEL", 1, 8)
+	if err := node.Interfaces()[0].AddSentMessage(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	sigType, err := newFloatSignalType("float32_t", SignalTypeKindFloat32, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig, err := acmelib.NewStandardSignal("ACCEL_X", sigType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := msg.AppendSignal(sig); err != nil {
+		t.Fatal(err)
+	}
+
+	importedBus := exportImportDBCFloat(t, net)
+
+	importedSig, err := importedBus.NodeInterfaces()[0].SentMessages()[0].Signals()[0].ToStandard()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if kind := getSignalTypeKind(importedSig.Type()); kind != SignalTypeKindFloat32 {
+		t.Errorf("got kind %s, want %s", kind, SignalTypeKindFloat32)
+	}
+}
+
+func TestDBCFloatMultiplexedRoundTrip(t *testing.T) {
+	net := acmelib.NewNetwork("float_net")
+	bus := acmelib.NewBus("imu")
+	if err := net.AddBus(bus); err != nil {
+		t.Fatal(err)
+	}
+
+	node := acmelib.NewNode("IMU", 1, 1)
+	if err := bus.AddNodeInterface(node.Interfaces()[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := acmelib.NewMessage("IMU_DATA", 1, 8)
+	if err := node.Interfaces()[0].AddSentMessage(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	muxSig, err := acmelib.NewMultiplexerSignal("IMU_MUX", 2, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := msg.AppendSignal(muxSig); err != nil {
+		t.Fatal(err)
+	}
+
+	sigType, err := newFloatSignalType("float32_t", SignalTypeKindFloat32, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig, err := acmelib.NewStandardSignal("GYRO_Z", sigType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := muxSig.InsertSignal(sig, 0, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	importedBus := exportImportDBCFloat(t, net)
+
+	importedMsg := importedBus.NodeInterfaces()[0].SentMessages()[0]
+
+	var importedSig *acmelib.StandardSignal
+	for _, tmpSig := range getMessageSignals(importedMsg) {
+		if tmpSig.Name() == "GYRO_Z" {
+			importedSig, err = tmpSig.ToStandard()
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	if importedSig == nil {
+		t.Fatal("the multiplexed signal is not imported")
+	}
+
+	if kind := getSignalTypeKind(importedSig.Type()); kind != SignalTypeKindFloat32 {
+		t.Errorf("got kind %s, want %s", kind, SignalTypeKindFloat32)
+	}
+}
+
+func TestDBCFloatImportReusesSignalTypes(t *testing.T) {
+	net := acmelib.NewNetwork("float_net")
+	bus := acmelib.NewBus("imu")
+	if err := net.AddBus(bus); err != nil {
+		t.Fatal(err)
+	}
+
+	node := acmelib.NewNode("IMU", 1, 1)
+	if err := bus.AddNodeInterface(node.Interfaces()[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := acmelib.NewMessage("IMU_ACCEL", 1, 8)
+	if err := node.Interfaces()[0].AddSentMessage(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	sigType, err := newFloatSignalType("float32_t", SignalTypeKindFloat32, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig, err := acmelib.NewStandardSignal("ACCEL_X", sigType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := msg.AppendSignal(sig); err != nil {
+		t.Fatal(err)
+	}
+
+	fileBuf := exportDBCFloat(t, net)
+
+	getSignalType := func(importedBus *acmelib.Bus) *acmelib.SignalType {
+		importedSig, err := importedBus.NodeInterfaces()[0].SentMessages()[0].Signals()[0].ToStandard()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return importedSig.Type()
+	}
+
+	// the float type of the network is reused
+	if got := getSignalType(importDBCFloat(t, fileBuf, []*acmelib.SignalType{sigType})); got != sigType {
+		t.Errorf("expected the signal type %s to be reused, got %s", sigType.Name(), got.Name())
+	}
+
+	// a different type with the same name is not reused and its name is not taken
+	otherType, err := acmelib.NewIntegerSignalType("float32_t", 8, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := getSignalType(importDBCFloat(t, fileBuf, []*acmelib.SignalType{otherType}))
+	if got == otherType {
+		t.Fatal("the integer signal type must not be reused")
+	}
+	if got.Name() == otherType.Name() {
+		t.Errorf("the new float signal type must not take the name %s", otherType.Name())
+	}
+}
+
+// exportImportDBCFloat exports the network with its SIG_VALTYPE_ entries
+// and imports its first bus back with the float signal types.
+func exportImportDBCFloat(t *testing.T, net *acmelib.Network) *acmelib.Bus {
+	t.Helper()
+
+	return importDBCFloat(t, exportDBCFloat(t, net), nil)
+}
+
+// exportDBCFloat exports the network with its SIG_VALTYPE_ entries
+// and returns the DBC file of its first bus.
+func exportDBCFloat(t *testing.T, net *acmelib.Network) []byte {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := acmelib.ExportNetwork(net, dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := addDBCFloatValueTypes(net, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	fileBuf, err := os.ReadFile(filepath.Join(dir, net.Name(), net.Buses()[0].Name()+dbc.FileExtension))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(fileBuf), "SIG_VALTYPE_") {
+		t.Fatal("the exported file does not contain SIG_VALTYPE_")
+	}
+
+	return fileBuf
+}
+
+// importDBCFloat imports the DBC file with the float signal types,
+// the identical ones among the given signal types are reused.
+func importDBCFloat(t *testing.T, fileBuf []byte, sigTypes []*acmelib.SignalType) *acmelib.Bus {
+	t.Helper()
+
+	importedBus, err := acmelib.ImportDBCFile("imu", bytes.NewReader(fileBuf))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dbcFile, err := dbc.Parse("imu.dbc", bytes.NewReader(fileBuf), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := applyDBCFloatValueTypes(importedBus, dbcFile, sigTypes); err != nil {
+		t.Fatal(err)
+	}
+
+	return importedBus
+}
@@ -7,7 +7,9 @@ toolchain go1.24.0
 require (
 	github.com/squadracorsepolito/acmelib v1.10.2
 	github.com/wailsapp/wails/v3 v3.0.0-alpha.9
+	go.starlark.net v0.0.0-20240725214946-42030a7cedce
 	golang.org/x/exp v0.0.0-20240719175910-8a7402abbf56
+	google.golang.org/protobuf v1.34.1
 )
 
 require (
@@ -55,7 +57,6 @@ require (
 	golang.org/x/sync v0.9.0 // indirect
 	golang.org/x/sys v0.28.0 // indirect
 	golang.org/x/tools v0.23.0 // indirect
-	google.golang.org/protobuf v1.34.1 // indirect
 	gopkg.in/ini.v1 v1.67.0 // indirect
 	gopkg.in/warnings.v0 v0.1.2 // indirect
 )
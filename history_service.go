@@ -22,6 +22,8 @@ type operation struct {
 }
 
 type HistoryService struct {
+	windowRouted
+
 	operations []*operation
 	currOpIdx  int
 
@@ -31,9 +33,11 @@ type HistoryService struct {
 
 	operationCh chan *operation
 	stopCh      chan struct{}
+
+	emitter eventEmitter
 }
 
-func newHistoryService() *HistoryService {
+func newHistoryService(emitter eventEmitter) *HistoryService {
 	return &HistoryService{
 		operations: []*operation{},
 		currOpIdx:  -1,
@@ -42,6 +46,8 @@ func newHistoryService() *HistoryService {
 
 		operationCh: make(chan *operation),
 		stopCh:      make(chan struct{}),
+
+		emitter: emitter,
 	}
 }
 
@@ -76,7 +82,7 @@ func (s *HistoryService) getState() History {
 }
 
 func (s *HistoryService) emitHistoryChange() {
-	app.EmitEvent(HistoryChange, s.getState())
+	s.emitter.emitEvent(HistoryChange, s.getState())
 }
 
 func (s *HistoryService) handleOperation(op *operation) {
@@ -97,7 +103,14 @@ func (s *HistoryService) handleOperation(op *operation) {
 	s.emitHistoryChange()
 }
 
-func (s *HistoryService) Undo() (History, error) {
+// forWindow returns the service of the window that made the call.
+func (s *HistoryService) forWindow(ctx context.Context) *HistoryService {
+	return s.resolve(ctx).historySrv
+}
+
+func (s *HistoryService) Undo(ctx context.Context) (History, error) {
+	s = s.forWindow(ctx)
+
 	s.mux.Lock()
 	defer s.mux.Unlock()
 
@@ -124,7 +137,9 @@ func (s *HistoryService) Undo() (History, error) {
 	return s.getState(), nil
 }
 
-func (s *HistoryService) Redo() (History, error) {
+func (s *HistoryService) Redo(ctx context.Context) (History, error) {
+	s = s.forWindow(ctx)
+
 	s.mux.Lock()
 	defer s.mux.Unlock()
 
@@ -167,7 +182,7 @@ func (s *HistoryService) sendModifyEvent(opDomain serviceKind, res any) {
 		eventName = HistorySignalEnumModify
 	}
 
-	application.Get().EmitEvent(eventName, res)
+	s.emitter.emitEvent(eventName, res)
 }
 
 func (s *HistoryService) setSaved(saved bool) {
@@ -6,20 +6,42 @@ import (
 	"github.com/wailsapp/wails/v3/pkg/application"
 )
 
+// keybindingsHandler registers the window keybindings of the commands.
+// The window keybindings are set when the app is created, so a command
+// is looked up at call time: a keybinding changed by the user is dispatched
+// to the right command, while new keys are registered on the next start.
 type keybindingsHandler struct {
 	m map[string]func(window *application.WebviewWindow)
+
+	registry *commandRegistry
+
+	log logger
 }
 
-func newKeybindingsHandler() *keybindingsHandler {
+func newKeybindingsHandler(registry *commandRegistry, log logger) *keybindingsHandler {
 	return &keybindingsHandler{
 		m: make(map[string]func(window *application.WebviewWindow)),
+
+		registry: registry,
+
+		log: log,
 	}
 }
 
 func (kh *keybindingsHandler) init() {
-	kh.registerWindow(kh.appendKeys(kh.metaKey(), "s"), kh.saveHandler)
-	kh.registerWindow(kh.appendKeys(kh.metaKey(), "z"), kh.undoHandler)
-	kh.registerWindow(kh.appendKeys(kh.metaKey(), "y"), kh.redoHandler)
+	for _, cmd := range kh.registry.commands {
+		keys, _ := kh.registry.getKeys(cmd)
+		if len(keys) == 0 {
+			continue
+		}
+
+		normKeys, err := normalizeKeys(keys)
+		if err != nil {
+			continue
+		}
+
+		kh.registerWindow(normKeys, kh.dispatch(normKeys))
+	}
 }
 
 func (kh *keybindingsHandler) getWindowKeybindings() map[string]func(window *application.WebviewWindow) {
@@ -30,38 +52,23 @@ func (kh *keybindingsHandler) registerWindow(key string, cb func(*application.We
 	kh.m[key] = cb
 }
 
-func (kh *keybindingsHandler) appendKeys(keys ...string) string {
-	if len(keys) == 0 {
-		return ""
-	}
+func (kh *keybindingsHandler) dispatch(keys string) func(*application.WebviewWindow) {
+	return func(_ *application.WebviewWindow) {
+		cmd, ok := kh.registry.getCommandByKeys(keys)
+		if !ok {
+			return
+		}
 
-	res := keys[0]
-	for _, key := range keys {
-		res += "+" + key
+		if err := kh.registry.runCommand(cmd); err != nil {
+			kh.log.error(err)
+		}
 	}
-
-	return res
 }
 
-func (kh *keybindingsHandler) metaKey() string {
+// metaKey returns the modifier used by the shortcuts of the current platform.
+func metaKey() string {
 	if runtime.GOOS == "darwin" {
-		return "CMD"
+		return "cmd"
 	}
 	return "ctrl"
 }
-
-func (kh *keybindingsHandler) saveHandler(_ *application.WebviewWindow) {
-	if err := manager.trySaveNetwork(); err != nil {
-		application.Get().Logger.Error(err.Error())
-	}
-}
-
-func (kh *keybindingsHandler) undoHandler(_ *application.WebviewWindow) {
-	manager.historySrv.Undo()
-	manager.historySrv.emitHistoryChange()
-}
-
-func (kh *keybindingsHandler) redoHandler(_ *application.WebviewWindow) {
-	manager.historySrv.Redo()
-	manager.historySrv.emitHistoryChange()
-}
@@ -0,0 +1,667 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/squadracorsepolito/acmelib"
+	acmelibv1 "github.com/squadracorsepolito/acmelib/proto/gen/go/acmelib/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/prototext"
+	"google.golang.org/protobuf/proto"
+)
+
+// acmelib saves only the signal types, units and enums referenced by a signal,
+// so the ones that are not referenced are lost when the network is saved.
+// The entities flagged as kept in the library are added to the saved file:
+// the ones not referenced are appended to the lists of the network, and the IDs
+// of all of them are stored in a string attribute that is never assigned,
+// which acmelib loads and ignores. When the file is loaded, acmelib drops
+// the kept entities not referenced by any signal, so they are loaded from
+// a placeholder network where a signal references each of them, which keeps
+// their entity IDs, and then detached from it (see loadUnreferencedEntities).
+//
+// The entities linked to a library file (see library_file.go) are always kept,
+// and their links are stored in another attribute as JSON.
+
+const (
+	libraryAttributeID   = "canturin_library"
+	libraryAttributeName = "canturin_library"
+	libraryIDSeparator   = ","
+
+	libraryLinksAttributeID   = "canturin_library_links"
+	libraryLinksAttributeName = "canturin_library_links"
+)
+
+// libraryLink links an entity of the network to the entity of a library file it was created from.
+type libraryLink struct {
+	// Path is the absolute path of the library file, in the network file
+	// it is relative to the folder of the network file.
+	Path     string `json:"path"`
+	EntityID string `json:"entityId"`
+}
+
+// networkLibrary holds the signal types, units and enums kept in the library of the network.
+// It is protected by the mutex shared by the services.
+type networkLibrary struct {
+	kept  map[acmelib.EntityID]entity
+	links map[acmelib.EntityID]libraryLink
+}
+
+func newNetworkLibrary() *networkLibrary {
+	return &networkLibrary{
+		kept:  make(map[acmelib.EntityID]entity),
+		links: make(map[acmelib.EntityID]libraryLink),
+	}
+}
+
+func (l *networkLibrary) isKept(entID acmelib.EntityID) bool {
+	_, ok := l.kept[entID]
+	return ok
+}
+
+func (l *networkLibrary) keep(ent entity) {
+	l.kept[ent.EntityID()] = ent
+}
+
+func (l *networkLibrary) release(ent entity) {
+	delete(l.kept, ent.EntityID())
+}
+
+func (l *networkLibrary) setKept(ent entity, kept bool) {
+	if kept {
+		l.keep(ent)
+		return
+	}
+	l.release(ent)
+}
+
+func (l *networkLibrary) getLink(entID acmelib.EntityID) (libraryLink, bool) {
+	link, ok := l.links[entID]
+	return link, ok
+}
+
+// link links the entity to a library file, a linked entity is always kept.
+func (l *networkLibrary) link(ent entity, link libraryLink) {
+	l.keep(ent)
+	l.links[ent.EntityID()] = link
+}
+
+func (l *networkLibrary) unlink(ent entity) {
+	delete(l.links, ent.EntityID())
+}
+
+// remove releases and unlinks the entity, it returns the function that restores it.
+func (l *networkLibrary) remove(ent entity) func() {
+	kept := l.isKept(ent.EntityID())
+	link, linked := l.getLink(ent.EntityID())
+
+	l.release(ent)
+	l.unlink(ent)
+
+	return func() {
+		l.setKept(ent, kept)
+		if linked {
+			l.link(ent, link)
+		}
+	}
+}
+
+// checkEditable returns an error if the entity is owned by a library file.
+func (l *networkLibrary) checkEditable(ent entity) error {
+	link, ok := l.getLink(ent.EntityID())
+	if !ok {
+		return nil
+	}
+
+	return fmt.Errorf("%s is read-only, it is owned by the library %s", ent.Name(), filepath.Base(link.Path))
+}
+
+// load replaces the kept entities and the links with the ones of the given library.
+func (l *networkLibrary) load(other *networkLibrary) {
+	clear(l.kept)
+	for entID, ent := range other.kept {
+		l.kept[entID] = ent
+	}
+
+	clear(l.links)
+	for entID, link := range other.links {
+		l.links[entID] = link
+	}
+}
+
+func (l *networkLibrary) clear() {
+	clear(l.kept)
+	clear(l.links)
+}
+
+// addTo adds the kept entities that are not referenced by any signal to the entities
+// of the network. The kept entities found in the network replace the stored ones,
+// since a network loaded again holds new instances with the same IDs.
+func (l *networkLibrary) addTo(entities *networkEntities) {
+	found := make(map[acmelib.EntityID]struct{})
+
+	for _, sigType := range entities.sigTypes {
+		if l.isKept(sigType.EntityID()) {
+			l.keep(sigType)
+			found[sigType.EntityID()] = struct{}{}
+		}
+	}
+	for _, sigUnit := range entities.sigUnits {
+		if l.isKept(sigUnit.EntityID()) {
+			l.keep(sigUnit)
+			found[sigUnit.EntityID()] = struct{}{}
+		}
+	}
+	for _, sigEnum := range entities.sigEnums {
+		if l.isKept(sigEnum.EntityID()) {
+			l.keep(sigEnum)
+			found[sigEnum.EntityID()] = struct{}{}
+		}
+	}
+
+	for entID, ent := range l.kept {
+		if _, ok := found[entID]; ok {
+			continue
+		}
+
+		switch tmpEnt := ent.(type) {
+		case *acmelib.SignalType:
+			entities.sigTypes = append(entities.sigTypes, tmpEnt)
+		case *acmelib.SignalUnit:
+			entities.sigUnits = append(entities.sigUnits, tmpEnt)
+		case *acmelib.SignalEnum:
+			entities.sigEnums = append(entities.sigEnums, tmpEnt)
+		}
+	}
+}
+
+// encodeNetwork encodes the network with the kept entities of the library.
+// The paths of the linked library files are made relative to the base folder.
+func encodeNetwork(net *acmelib.Network, library *networkLibrary, enc acmelib.SaveEncoding, baseDir string) ([]byte, error) {
+	buf := new(bytes.Buffer)
+
+	if len(library.kept) == 0 {
+		var err error
+		switch enc {
+		case acmelib.SaveEncodingWire:
+			err = acmelib.SaveNetwork(net, enc, buf, nil, nil)
+		case acmelib.SaveEncodingJSON:
+			err = acmelib.SaveNetwork(net, enc, nil, buf, nil)
+		case acmelib.SaveEncodingText:
+			err = acmelib.SaveNetwork(net, enc, nil, nil, buf)
+		}
+		return buf.Bytes(), err
+	}
+
+	if err := acmelib.SaveNetwork(net, acmelib.SaveEncodingWire, buf, nil, nil); err != nil {
+		return nil, err
+	}
+
+	pNet := new(acmelibv1.Network)
+	if err := proto.Unmarshal(buf.Bytes(), pNet); err != nil {
+		return nil, err
+	}
+
+	keptIDs := []string{}
+	for entID, ent := range library.kept {
+		keptIDs = append(keptIDs, entID.String())
+
+		// the referenced entities are already saved by acmelib
+		switch tmpEnt := ent.(type) {
+		case *acmelib.SignalType:
+			if tmpEnt.ReferenceCount() == 0 {
+				pNet.SignalTypes = append(pNet.SignalTypes, saveLibrarySignalType(tmpEnt))
+			}
+		case *acmelib.SignalUnit:
+			if tmpEnt.ReferenceCount() == 0 {
+				pNet.SignalUnits = append(pNet.SignalUnits, saveLibrarySignalUnit(tmpEnt))
+			}
+		case *acmelib.SignalEnum:
+			if tmpEnt.ReferenceCount() == 0 {
+				pNet.SignalEnums = append(pNet.SignalEnums, saveLibrarySignalEnum(tmpEnt))
+			}
+		}
+	}
+
+	// the IDs are sorted, so saving the same network produces the same file
+	slices.Sort(keptIDs)
+
+	pNet.Attributes = append(pNet.Attributes, newLibraryAttribute(libraryAttributeID, libraryAttributeName, strings.Join(keptIDs, libraryIDSeparator)))
+
+	if len(library.links) > 0 {
+		links := make(map[string]libraryLink)
+		for entID, link := range library.links {
+			if baseDir != "" {
+				if relPath, err := filepath.Rel(baseDir, link.Path); err == nil {
+					link.Path = filepath.ToSlash(relPath)
+				}
+			}
+			links[entID.String()] = link
+		}
+
+		// the keys of the map are sorted by the encoder
+		linksBuf, err := json.Marshal(links)
+		if err != nil {
+			return nil, err
+		}
+
+		pNet.Attributes = append(pNet.Attributes, newLibraryAttribute(libraryLinksAttributeID, libraryLinksAttributeName, string(linksBuf)))
+	}
+
+	switch enc {
+	case acmelib.SaveEncodingJSON:
+		return protojson.MarshalOptions{Multiline: true}.Marshal(pNet)
+	case acmelib.SaveEncodingText:
+		return prototext.MarshalOptions{Multiline: true}.Marshal(pNet)
+	}
+
+	return proto.Marshal(pNet)
+}
+
+func newLibraryAttribute(entityID, name, value string) *acmelibv1.Attribute {
+	return &acmelibv1.Attribute{
+		Entity: &acmelibv1.Entity{
+			EntityId:   entityID,
+			EntityKind: acmelibv1.EntityKind_ENTITY_KIND_ATTRIBUTE,
+			Name:       name,
+		},
+		Type: acmelibv1.AttributeType_ATTRIBUTE_TYPE_STRING,
+		Attribute: &acmelibv1.Attribute_StringAttribute{
+			StringAttribute: &acmelibv1.StringAttribute{
+				DefValue: value,
+			},
+		},
+	}
+}
+
+// decodeNetwork decodes the network and returns it with its library.
+// The relative paths of the linked library files are resolved from the base folder.
+func decodeNetwork(buf []byte, enc acmelib.SaveEncoding, baseDir string) (*acmelib.Network, *networkLibrary, error) {
+	net, err := acmelib.LoadNetwork(bytes.NewReader(buf), enc)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	library := newNetworkLibrary()
+
+	// acmelib has already checked the file
+	pNet := new(acmelibv1.Network)
+	switch enc {
+	case acmelib.SaveEncodingWire:
+		err = proto.Unmarshal(buf, pNet)
+	case acmelib.SaveEncodingJSON:
+		err = protojson.Unmarshal(buf, pNet)
+	case acmelib.SaveEncodingText:
+		err = prototext.Unmarshal(buf, pNet)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keptIDs := make(map[string]struct{})
+	links := make(map[string]libraryLink)
+	for _, pAtt := range pNet.Attributes {
+		switch pAtt.Entity.GetEntityId() {
+		case libraryAttributeID:
+			for _, entID := range strings.Split(pAtt.GetStringAttribute().GetDefValue(), libraryIDSeparator) {
+				if entID != "" {
+					keptIDs[entID] = struct{}{}
+				}
+			}
+
+		case libraryLinksAttributeID:
+			if err := json.Unmarshal([]byte(pAtt.GetStringAttribute().GetDefValue()), &links); err != nil {
+				return nil, nil, fmt.Errorf("invalid library links: %w", err)
+			}
+		}
+	}
+
+	if len(keptIDs) == 0 {
+		return net, library, nil
+	}
+
+	// fileEntities maps the IDs in the file to the kept entities
+	fileEntities := make(map[string]entity)
+
+	entities := collectNetworkEntities(net)
+	isKept := func(entID string) bool {
+		_, ok := keptIDs[entID]
+		delete(keptIDs, entID)
+		return ok
+	}
+
+	for _, sigType := range entities.sigTypes {
+		if isKept(sigType.EntityID().String()) {
+			fileEntities[sigType.EntityID().String()] = sigType
+		}
+	}
+	for _, sigUnit := range entities.sigUnits {
+		if isKept(sigUnit.EntityID().String()) {
+			fileEntities[sigUnit.EntityID().String()] = sigUnit
+		}
+	}
+	for _, sigEnum := range entities.sigEnums {
+		if isKept(sigEnum.EntityID().String()) {
+			fileEntities[sigEnum.EntityID().String()] = sigEnum
+		}
+	}
+
+	// the remaining ones are not referenced by any signal
+	unrefEntities := &acmelibv1.Network{}
+	for _, pSigType := range pNet.SignalTypes {
+		if isKept(pSigType.Entity.GetEntityId()) {
+			unrefEntities.SignalTypes = append(unrefEntities.SignalTypes, pSigType)
+		}
+	}
+	for _, pSigUnit := range pNet.SignalUnits {
+		if isKept(pSigUnit.Entity.GetEntityId()) {
+			unrefEntities.SignalUnits = append(unrefEntities.SignalUnits, pSigUnit)
+		}
+	}
+	for _, pSigEnum := range pNet.SignalEnums {
+		if isKept(pSigEnum.Entity.GetEntityId()) {
+			unrefEntities.SignalEnums = append(unrefEntities.SignalEnums, pSigEnum)
+		}
+	}
+
+	unrefLoaded, err := loadUnreferencedEntities(unrefEntities)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, ent := range unrefLoaded {
+		fileEntities[ent.EntityID().String()] = ent
+	}
+
+	for _, ent := range fileEntities {
+		library.keep(ent)
+	}
+
+	for fileEntID, link := range links {
+		ent, ok := fileEntities[fileEntID]
+		if !ok {
+			continue
+		}
+
+		if !filepath.IsAbs(link.Path) && baseDir != "" {
+			link.Path = filepath.Join(baseDir, filepath.FromSlash(link.Path))
+		}
+
+		library.link(ent, link)
+	}
+
+	return net, library, nil
+}
+
+// loadUnreferencedEntities loads the signal types, units and enums of the given network,
+// which are not referenced by any signal. acmelib keeps the entity id only when an entity
+// is loaded, so they are loaded from a network where a placeholder signal references
+// each of them, and then they are detached from the placeholder signals.
+func loadUnreferencedEntities(pEntities *acmelibv1.Network) ([]entity, error) {
+	placeholderType := acmelib.NewFlagSignalType("placeholder")
+	pPlaceholderType := saveLibrarySignalType(placeholderType)
+
+	pNodeInt := &acmelibv1.NodeInterface{}
+
+	addSignal := func(pSig *acmelibv1.Signal) error {
+		msgID := len(pNodeInt.Messages) + 1
+		msg := acmelib.NewMessage(fmt.Sprintf("placeholder_%d", msgID), acmelib.MessageID(msgID), 8)
+
+		sig, err := acmelib.NewStandardSignal("placeholder", placeholderType)
+		if err != nil {
+			return err
+		}
+		pSig.Entity = saveProtoEntity(sig, acmelibv1.EntityKind_ENTITY_KIND_SIGNAL)
+
+		pNodeInt.Messages = append(pNodeInt.Messages, &acmelibv1.Message{
+			Entity:    saveProtoEntity(msg, acmelibv1.EntityKind_ENTITY_KIND_MESSAGE),
+			MessageId: uint32(msgID),
+			SizeByte:  8,
+			Signals:   []*acmelibv1.Signal{pSig},
+			Payload: &acmelibv1.SignalPayload{
+				Refs: []*acmelibv1.SignalPayloadRef{{SignalEntityId: pSig.Entity.EntityId}},
+			},
+		})
+
+		return nil
+	}
+
+	for _, pSigType := range pEntities.SignalTypes {
+		err := addSignal(&acmelibv1.Signal{
+			Kind: acmelibv1.SignalKind_SIGNAL_KIND_STANDARD,
+			Signal: &acmelibv1.Signal_Standard{
+				Standard: &acmelibv1.StandardSignal{TypeEntityId: pSigType.Entity.GetEntityId()},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, pSigUnit := range pEntities.SignalUnits {
+		err := addSignal(&acmelibv1.Signal{
+			Kind: acmelibv1.SignalKind_SIGNAL_KIND_STANDARD,
+			Signal: &acmelibv1.Signal_Standard{
+				Standard: &acmelibv1.StandardSignal{
+					TypeEntityId: pPlaceholderType.Entity.EntityId,
+					UnitEntityId: pSigUnit.Entity.GetEntityId(),
+				},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, pSigEnum := range pEntities.SignalEnums {
+		err := addSignal(&acmelibv1.Signal{
+			Kind: acmelibv1.SignalKind_SIGNAL_KIND_ENUM,
+			Signal: &acmelibv1.Signal_Enum{
+				Enum: &acmelibv1.EnumSignal{EnumEntityId: pSigEnum.Entity.GetEntityId()},
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(pNodeInt.Messages) == 0 {
+		return nil, nil
+	}
+
+	pNode := &acmelibv1.Node{
+		Entity:         saveProtoEntity(acmelib.NewNode("placeholder", 0, 1), acmelibv1.EntityKind_ENTITY_KIND_NODE),
+		InterfaceCount: 1,
+	}
+	pNodeInt.NodeEntityId = pNode.Entity.EntityId
+
+	pNet := &acmelibv1.Network{
+		Entity:      saveProtoEntity(acmelib.NewNetwork("placeholder"), acmelibv1.EntityKind_ENTITY_KIND_NETWORK),
+		SignalTypes: append(slices.Clone(pEntities.SignalTypes), pPlaceholderType),
+		SignalUnits: pEntities.SignalUnits,
+		SignalEnums: pEntities.SignalEnums,
+		Nodes:       []*acmelibv1.Node{pNode},
+		Buses: []*acmelibv1.Bus{{
+			Entity:         saveProtoEntity(acmelib.NewBus("placeholder"), acmelibv1.EntityKind_ENTITY_KIND_BUS),
+			NodeInterfaces: []*acmelibv1.NodeInterface{pNodeInt},
+		}},
+	}
+
+	buf, err := proto.Marshal(pNet)
+	if err != nil {
+		return nil, err
+	}
+
+	net, err := acmelib.LoadNetwork(bytes.NewReader(buf), acmelib.SaveEncodingWire)
+	if err != nil {
+		return nil, err
+	}
+
+	placeholderEnum := acmelib.NewSignalEnum("placeholder")
+
+	res := []entity{}
+	for _, sig := range collectNetworkEntities(net).signals {
+		switch sig.Kind() {
+		case acmelib.SignalKindStandard:
+			stdSig, err := sig.ToStandard()
+			if err != nil {
+				return nil, err
+			}
+
+			if sigUnit := stdSig.Unit(); sigUnit != nil {
+				res = append(res, sigUnit)
+				stdSig.SetUnit(nil)
+				continue
+			}
+
+			res = append(res, stdSig.Type())
+			if err := stdSig.SetType(placeholderType); err != nil {
+				return nil, err
+			}
+
+		case acmelib.SignalKindEnum:
+			enumSig, err := sig.ToEnum()
+			if err != nil {
+				return nil, err
+			}
+
+			res = append(res, enumSig.Enum())
+			if err := enumSig.SetEnum(placeholderEnum); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return res, nil
+}
+
+func saveLibrarySignalType(sigType *acmelib.SignalType) *acmelibv1.SignalType {
+	pKind := acmelibv1.SignalTypeKind_SIGNAL_TYPE_KIND_UNSPECIFIED
+	switch sigType.Kind() {
+	case acmelib.SignalTypeKindCustom:
+		pKind = acmelibv1.SignalTypeKind_SIGNAL_TYPE_KIND_CUSTOM
+	case acmelib.SignalTypeKindFlag:
+		pKind = acmelibv1.SignalTypeKind_SIGNAL_TYPE_KIND_FLAG
+	case acmelib.SignalTypeKindInteger:
+		pKind = acmelibv1.SignalTypeKind_SIGNAL_TYPE_KIND_INTEGER
+	case acmelib.SignalTypeKindDecimal:
+		pKind = acmelibv1.SignalTypeKind_SIGNAL_TYPE_KIND_DECIMAL
+	}
+
+	return &acmelibv1.SignalType{
+		Entity: saveProtoEntity(sigType, acmelibv1.EntityKind_ENTITY_KIND_SIGNAL_TYPE),
+		Kind:   pKind,
+		Size:   uint32(sigType.Size()),
+		Signed: sigType.Signed(),
+		Min:    sigType.Min(),
+		Max:    sigType.Max(),
+		Scale:  sigType.Scale(),
+		Offset: sigType.Offset(),
+	}
+}
+
+func saveLibrarySignalUnit(sigUnit *acmelib.SignalUnit) *acmelibv1.SignalUnit {
+	pKind := acmelibv1.SignalUnitKind_SIGNAL_UNIT_KIND_UNSPECIFIED
+	switch sigUnit.Kind() {
+	case acmelib.SignalUnitKindCustom:
+		pKind = acmelibv1.SignalUnitKind_SIGNAL_UNIT_KIND_CUSTOM
+	case acmelib.SignalUnitKindTemperature:
+		pKind = acmelibv1.SignalUnitKind_SIGNAL_UNIT_KIND_TEMPERATURE
+	case acmelib.SignalUnitKindElectrical:
+		pKind = acmelibv1.SignalUnitKind_SIGNAL_UNIT_KIND_ELECTRICAL
+	case acmelib.SignalUnitKindPower:
+		pKind = acmelibv1.SignalUnitKind_SIGNAL_UNIT_KIND_POWER
+	}
+
+	return &acmelibv1.SignalUnit{
+		Entity: saveProtoEntity(sigUnit, acmelibv1.EntityKind_ENTITY_KIND_SIGNAL_UNIT),
+		Kind:   pKind,
+		Symbol: sigUnit.Symbol(),
+	}
+}
+
+func saveLibrarySignalEnum(sigEnum *acmelib.SignalEnum) *acmelibv1.SignalEnum {
+	pSigEnum := &acmelibv1.SignalEnum{
+		Entity:  saveProtoEntity(sigEnum, acmelibv1.EntityKind_ENTITY_KIND_SIGNAL_ENUM),
+		MinSize: uint32(sigEnum.MinSize()),
+	}
+
+	for _, val := range sigEnum.Values() {
+		pSigEnum.Values = append(pSigEnum.Values, &acmelibv1.SignalEnumValue{
+			Entity: saveProtoEntity(val, acmelibv1.EntityKind_ENTITY_KIND_SIGNAL_ENUM_VALUE),
+			Index:  uint32(val.Index()),
+		})
+	}
+
+	return pSigEnum
+}
+
+func loadLibrarySignalType(pSigType *acmelibv1.SignalType) (*acmelib.SignalType, error) {
+	name := pSigType.Entity.GetName()
+	size := int(pSigType.Size)
+
+	var sigType *acmelib.SignalType
+	var err error
+	switch pSigType.Kind {
+	case acmelibv1.SignalTypeKind_SIGNAL_TYPE_KIND_FLAG:
+		sigType = acmelib.NewFlagSignalType(name)
+	case acmelibv1.SignalTypeKind_SIGNAL_TYPE_KIND_INTEGER:
+		sigType, err = acmelib.NewIntegerSignalType(name, size, pSigType.Signed)
+	case acmelibv1.SignalTypeKind_SIGNAL_TYPE_KIND_DECIMAL:
+		sigType, err = acmelib.NewDecimalSignalType(name, size, pSigType.Signed)
+	default:
+		sigType, err = acmelib.NewCustomSignalType(name, size, pSigType.Signed,
+			pSigType.Min, pSigType.Max, pSigType.Scale, pSigType.Offset)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	sigType.SetMin(pSigType.Min)
+	sigType.SetMax(pSigType.Max)
+	sigType.SetScale(pSigType.Scale)
+	sigType.SetOffset(pSigType.Offset)
+	sigType.SetDesc(pSigType.Entity.GetDesc())
+
+	return sigType, nil
+}
+
+func loadLibrarySignalUnit(pSigUnit *acmelibv1.SignalUnit) *acmelib.SignalUnit {
+	kind := acmelib.SignalUnitKindCustom
+	switch pSigUnit.Kind {
+	case acmelibv1.SignalUnitKind_SIGNAL_UNIT_KIND_TEMPERATURE:
+		kind = acmelib.SignalUnitKindTemperature
+	case acmelibv1.SignalUnitKind_SIGNAL_UNIT_KIND_ELECTRICAL:
+		kind = acmelib.SignalUnitKindElectrical
+	case acmelibv1.SignalUnitKind_SIGNAL_UNIT_KIND_POWER:
+		kind = acmelib.SignalUnitKindPower
+	}
+
+	sigUnit := acmelib.NewSignalUnit(pSigUnit.Entity.GetName(), kind, pSigUnit.Symbol)
+	sigUnit.SetDesc(pSigUnit.Entity.GetDesc())
+
+	return sigUnit
+}
+
+func loadLibrarySignalEnum(pSigEnum *acmelibv1.SignalEnum) (*acmelib.SignalEnum, error) {
+	sigEnum := acmelib.NewSignalEnum(pSigEnum.Entity.GetName())
+	sigEnum.SetDesc(pSigEnum.Entity.GetDesc())
+
+	for _, pVal := range pSigEnum.Values {
+		val := acmelib.NewSignalEnumValue(pVal.Entity.GetName(), int(pVal.Index))
+		val.SetDesc(pVal.Entity.GetDesc())
+
+		if err := sigEnum.AddValue(val); err != nil {
+			return nil, err
+		}
+	}
+
+	if pSigEnum.MinSize != 0 {
+		sigEnum.SetMinSize(int(pSigEnum.MinSize))
+	}
+
+	return sigEnum, nil
+}
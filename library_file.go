@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/squadracorsepolito/acmelib"
+	acmelibv1 "github.com/squadracorsepolito/acmelib/proto/gen/go/acmelib/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// A library file holds signal types, units and enums shared by many networks
+// (e.g. the temperature and voltage units or the state enums used by every car).
+// It is a network without buses encoded in JSON, so it can be reviewed
+// and versioned like a network file. The entities of a library file can be
+// imported into a network as independent copies, or linked to it: the linked
+// entities are read-only and they are updated only from the file.
+//
+// The entities get a new ID every time they are loaded, so a linked entity
+// stores the ID it has in the file. When the library file has been exported
+// again from another network, the IDs change and the entities are matched by name.
+
+const libraryFileExtension = ".canlib"
+
+// libraryFileEntity is an entity loaded from a library file.
+type libraryFileEntity struct {
+	fileID string
+	ent    entity
+}
+
+func readLibraryFile(path string) ([]libraryFileEntity, error) {
+	fileBuf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pNet := new(acmelibv1.Network)
+	if err := protojson.Unmarshal(fileBuf, pNet); err != nil {
+		return nil, fmt.Errorf("invalid library file %s: %w", filepath.Base(path), err)
+	}
+
+	res := []libraryFileEntity{}
+
+	for _, pSigType := range pNet.SignalTypes {
+		sigType, err := loadLibrarySignalType(pSigType)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, libraryFileEntity{fileID: pSigType.Entity.GetEntityId(), ent: sigType})
+	}
+
+	for _, pSigUnit := range pNet.SignalUnits {
+		res = append(res, libraryFileEntity{fileID: pSigUnit.Entity.GetEntityId(), ent: loadLibrarySignalUnit(pSigUnit)})
+	}
+
+	for _, pSigEnum := range pNet.SignalEnums {
+		sigEnum, err := loadLibrarySignalEnum(pSigEnum)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, libraryFileEntity{fileID: pSigEnum.Entity.GetEntityId(), ent: sigEnum})
+	}
+
+	return res, nil
+}
+
+// writeLibraryFile writes the given signal types, units and enums to a library file,
+// the library is named after the file.
+func writeLibraryFile(path string, entities []entity) error {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+
+	pNet := &acmelibv1.Network{
+		Entity: saveProtoEntity(acmelib.NewNetwork(name), acmelibv1.EntityKind_ENTITY_KIND_NETWORK),
+	}
+
+	// the entities are sorted by name, so exporting the same entities produces the same file
+	entities = slices.Clone(entities)
+	slices.SortFunc(entities, func(a, b entity) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+
+	for _, ent := range entities {
+		switch tmpEnt := ent.(type) {
+		case *acmelib.SignalType:
+			pNet.SignalTypes = append(pNet.SignalTypes, saveLibrarySignalType(tmpEnt))
+		case *acmelib.SignalUnit:
+			pNet.SignalUnits = append(pNet.SignalUnits, saveLibrarySignalUnit(tmpEnt))
+		case *acmelib.SignalEnum:
+			pNet.SignalEnums = append(pNet.SignalEnums, saveLibrarySignalEnum(tmpEnt))
+		}
+	}
+
+	fileBuf, err := protojson.MarshalOptions{Multiline: true}.Marshal(pNet)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, fileBuf, 0644)
+}
+
+// findLibraryFileEntity returns the entity of the library file the given link points to.
+// If the ID is not found, the entity of the same kind with the given name is returned.
+func findLibraryFileEntity(fileEntities []libraryFileEntity, link libraryLink, kind acmelib.EntityKind, name string) (libraryFileEntity, bool) {
+	for _, fileEnt := range fileEntities {
+		if fileEnt.fileID == link.EntityID {
+			return fileEnt, true
+		}
+	}
+
+	for _, fileEnt := range fileEntities {
+		if fileEnt.ent.EntityKind() == kind && fileEnt.ent.Name() == name {
+			return fileEnt, true
+		}
+	}
+
+	return libraryFileEntity{}, false
+}
+
+type LibraryChangeKind string
+
+const (
+	LibraryChangeKindAdd    LibraryChangeKind = "add"
+	LibraryChangeKindUpdate LibraryChangeKind = "update"
+	LibraryChangeKindRemove LibraryChangeKind = "remove"
+)
+
+// LibraryChange is a change that pulling the updates of a linked library file
+// makes to the network. A removed entity is not deleted, it is only unlinked,
+// so it becomes an editable entity of the network.
+type LibraryChange struct {
+	LibraryPath string            `json:"libraryPath"`
+	Kind        LibraryChangeKind `json:"kind"`
+	EntityKind  EntityKind        `json:"entityKind"`
+	// EntityID is the ID of the entity in the network, it is empty for an added entity.
+	EntityID string `json:"entityId"`
+	Name     string `json:"name"`
+	// Fields are the fields changed by an update.
+	Fields []string `json:"fields"`
+}
+
+func (c LibraryChange) String() string {
+	kind := strings.ReplaceAll(string(c.EntityKind), "-", " ")
+
+	switch c.Kind {
+	case LibraryChangeKindAdd:
+		return fmt.Sprintf("add %s %s", kind, c.Name)
+	case LibraryChangeKindRemove:
+		return fmt.Sprintf("unlink %s %s", kind, c.Name)
+	}
+
+	return fmt.Sprintf("update %s %s (%s)", kind, c.Name, strings.Join(c.Fields, ", "))
+}
+
+// getLibraryChangedFields returns the names of the fields that differ between
+// the entity of the network and the one of the library file.
+func getLibraryChangedFields(curr, next entity) []string {
+	fields := []string{}
+
+	if curr.Name() != next.Name() {
+		fields = append(fields, "name")
+	}
+	if curr.Desc() != next.Desc() {
+		fields = append(fields, "desc")
+	}
+
+	switch tmpCurr := curr.(type) {
+	case *acmelib.SignalType:
+		tmpNext := next.(*acmelib.SignalType)
+
+		if getSignalTypeKind(tmpCurr) != getSignalTypeKind(tmpNext) {
+			fields = append(fields, "kind")
+		}
+		if tmpCurr.Size() != tmpNext.Size() {
+			fields = append(fields, "size")
+		}
+		if tmpCurr.Signed() != tmpNext.Signed() {
+			fields = append(fields, "signed")
+		}
+		if tmpCurr.Min() != tmpNext.Min() {
+			fields = append(fields, "min")
+		}
+		if tmpCurr.Max() != tmpNext.Max() {
+			fields = append(fields, "max")
+		}
+		if tmpCurr.Scale() != tmpNext.Scale() {
+			fields = append(fields, "scale")
+		}
+		if tmpCurr.Offset() != tmpNext.Offset() {
+			fields = append(fields, "offset")
+		}
+
+	case *acmelib.SignalUnit:
+		tmpNext := next.(*acmelib.SignalUnit)
+
+		if tmpCurr.Kind() != tmpNext.Kind() {
+			fields = append(fields, "kind")
+		}
+		if tmpCurr.Symbol() != tmpNext.Symbol() {
+			fields = append(fields, "symbol")
+		}
+
+	case *acmelib.SignalEnum:
+		tmpNext := next.(*acmelib.SignalEnum)
+
+		if tmpCurr.MinSize() != tmpNext.MinSize() {
+			fields = append(fields, "min size")
+		}
+		if getSignalEnumValuesKey(tmpCurr) != getSignalEnumValuesKey(tmpNext) {
+			fields = append(fields, "values")
+		}
+	}
+
+	return fields
+}
+
+func getSignalEnumValuesKey(sigEnum *acmelib.SignalEnum) string {
+	var b strings.Builder
+	for _, val := range sigEnum.Values() {
+		fmt.Fprintf(&b, "%d=%s:%s|", val.Index(), val.Name(), val.Desc())
+	}
+	return b.String()
+}
@@ -0,0 +1,802 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/squadracorsepolito/acmelib"
+)
+
+// UnusedEntity is a signal type, unit or enum not referenced by any signal.
+type UnusedEntity struct {
+	BaseEntity
+
+	Kind EntityKind `json:"kind"`
+	// KeepInLibrary is set if the entity is kept in the library,
+	// so it is saved with the network and it is never purged.
+	KeepInLibrary bool `json:"keepInLibrary"`
+}
+
+type PurgeUnusedReq struct {
+	// EntityIDs are the unused entities to purge, all of them are purged if it is empty.
+	EntityIDs []string `json:"entityIds"`
+}
+
+// LibraryService analyzes the signal types, units and enums of the network
+// as a whole, they are edited one by one by their services.
+type LibraryService struct {
+	windowRouted
+
+	mux     *sync.RWMutex
+	library *networkLibrary
+
+	networkSrv    *NetworkService
+	signalTypeSrv *SignalTypeService
+	signalUnitSrv *SignalUnitService
+	signalEnumSrv *SignalEnumService
+
+	historyCtr *historyController
+}
+
+func newLibraryService(mux *sync.RWMutex, library *networkLibrary, networkSrv *NetworkService, signalTypeSrv *SignalTypeService,
+	signalUnitSrv *SignalUnitService, signalEnumSrv *SignalEnumService, historyCtr *historyController) *LibraryService {
+
+	return &LibraryService{
+		mux:     mux,
+		library: library,
+
+		networkSrv:    networkSrv,
+		signalTypeSrv: signalTypeSrv,
+		signalUnitSrv: signalUnitSrv,
+		signalEnumSrv: signalEnumSrv,
+
+		historyCtr: historyCtr,
+	}
+}
+
+// forWindow returns the service of the window that made the call.
+func (s *LibraryService) forWindow(ctx context.Context) *LibraryService {
+	return s.resolve(ctx).librarySrv
+}
+
+// ListUnused returns the signal types, units and enums not referenced by any signal.
+// The ones that are not kept in the library are lost when the network is saved.
+func (s *LibraryService) ListUnused(ctx context.Context) []UnusedEntity {
+	s = s.forWindow(ctx)
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	res := []UnusedEntity{}
+	res = appendUnusedEntities(res, s.library, getUnusedEntities(s.signalTypeSrv.listEntities()))
+	res = appendUnusedEntities(res, s.library, getUnusedEntities(s.signalUnitSrv.listEntities()))
+	res = appendUnusedEntities(res, s.library, getUnusedEntities(s.signalEnumSrv.listEntities()))
+
+	slices.SortFunc(res, func(a, b UnusedEntity) int {
+		if a.Kind == b.Kind {
+			return strings.Compare(a.Name, b.Name)
+		}
+		return strings.Compare(string(a.Kind), string(b.Kind))
+	})
+
+	return res
+}
+
+// PurgeUnused deletes the unused signal types, units and enums that are not kept in the library.
+// The whole operation is recorded as a single history entry and the purged entities are returned.
+func (s *LibraryService) PurgeUnused(ctx context.Context, req PurgeUnusedReq) ([]UnusedEntity, error) {
+	s = s.forWindow(ctx)
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	selected := make(map[string]struct{})
+	for _, entID := range req.EntityIDs {
+		selected[entID] = struct{}{}
+	}
+
+	sigTypes := getPurgeableEntities(s.signalTypeSrv.listEntities(), s.library, selected)
+	sigUnits := getPurgeableEntities(s.signalUnitSrv.listEntities(), s.library, selected)
+	sigEnums := getPurgeableEntities(s.signalEnumSrv.listEntities(), s.library, selected)
+
+	if len(sigTypes)+len(sigUnits)+len(sigEnums) == 0 {
+		return nil, errors.New("purge unused: nothing to purge")
+	}
+
+	purge := func() {
+		s.signalTypeSrv.removeEntities(sigTypes)
+		s.signalUnitSrv.removeEntities(sigUnits)
+		s.signalEnumSrv.removeEntities(sigEnums)
+	}
+
+	purge()
+
+	s.historyCtr.sendOperation(
+		serviceKindNetwork,
+		func() (any, error) {
+			s.mux.Lock()
+			defer s.mux.Unlock()
+
+			s.signalTypeSrv.addEntities(sigTypes)
+			s.signalUnitSrv.addEntities(sigUnits)
+			s.signalEnumSrv.addEntities(sigEnums)
+
+			return newNetwork(s.networkSrv.network), nil
+		},
+		func() (any, error) {
+			s.mux.Lock()
+			defer s.mux.Unlock()
+
+			purge()
+
+			return newNetwork(s.networkSrv.network), nil
+		},
+	)
+
+	res := []UnusedEntity{}
+	res = appendUnusedEntities(res, s.library, sigTypes)
+	res = appendUnusedEntities(res, s.library, sigUnits)
+	res = appendUnusedEntities(res, s.library, sigEnums)
+
+	return res, nil
+}
+
+func getUnusedEntities[E referencedEntity](entities []E) []E {
+	res := []E{}
+	for _, ent := range entities {
+		if ent.ReferenceCount() == 0 {
+			res = append(res, ent)
+		}
+	}
+	return res
+}
+
+// getPurgeableEntities returns the unused entities that are not kept in the library.
+// If some entities are selected, only the selected ones are returned.
+func getPurgeableEntities[E referencedEntity](entities []E, library *networkLibrary, selected map[string]struct{}) []E {
+	res := []E{}
+	for _, ent := range getUnusedEntities(entities) {
+		if library.isKept(ent.EntityID()) {
+			continue
+		}
+
+		if len(selected) > 0 {
+			if _, ok := selected[ent.EntityID().String()]; !ok {
+				continue
+			}
+		}
+
+		res = append(res, ent)
+	}
+	return res
+}
+
+func appendUnusedEntities[E referencedEntity](res []UnusedEntity, library *networkLibrary, entities []E) []UnusedEntity {
+	for _, ent := range entities {
+		res = append(res, UnusedEntity{
+			BaseEntity: newBaseEntity(ent),
+
+			Kind:          newEntityKind(ent.EntityKind()),
+			KeepInLibrary: library.isKept(ent.EntityID()),
+		})
+	}
+	return res
+}
+
+func (s *service[E, R, H]) addEntities(entities []E) {
+	for _, ent := range entities {
+		s.addEntity(ent)
+		s.sidebarCtr.sendAdd(ent)
+	}
+}
+
+func (s *service[E, R, H]) removeEntities(entities []E) {
+	for _, ent := range entities {
+		s.removeEntity(ent.EntityID().String())
+		s.sidebarCtr.sendDelete(ent)
+	}
+}
+
+// updateKeepInLibrary is the handler that sets whether a signal type,
+// unit or enum is kept in the library of the network.
+func updateKeepInLibrary[E entity](library *networkLibrary, ent E, req *request, res *response[E]) error {
+	keep := req.toUpdateKeepInLibrary().Keep
+
+	if library.isKept(ent.EntityID()) == keep {
+		return nil
+	}
+
+	library.setKept(ent, keep)
+
+	res.setUndo(
+		func() (E, error) {
+			library.setKept(ent, !keep)
+			return ent, nil
+		},
+	)
+
+	res.setRedo(
+		func() (E, error) {
+			library.setKept(ent, keep)
+			return ent, nil
+		},
+	)
+
+	return nil
+}
+
+type ExportLibraryReq struct {
+	Path string `json:"path"`
+	// EntityIDs are the signal types, units and enums to export, all of them are exported if it is empty.
+	EntityIDs []string `json:"entityIds"`
+}
+
+type LibraryFileReq struct {
+	Path string `json:"path"`
+}
+
+// ExportLibrary writes the given signal types, units and enums to a library file.
+func (s *LibraryService) ExportLibrary(ctx context.Context, req ExportLibraryReq) error {
+	s = s.forWindow(ctx)
+
+	if req.Path == "" {
+		return errors.New("export library: the path is empty")
+	}
+
+	path := req.Path
+	if filepath.Ext(path) == "" {
+		path += libraryFileExtension
+	}
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	entities := []entity{}
+	if len(req.EntityIDs) == 0 {
+		for _, sigType := range s.signalTypeSrv.listEntities() {
+			entities = append(entities, sigType)
+		}
+		for _, sigUnit := range s.signalUnitSrv.listEntities() {
+			entities = append(entities, sigUnit)
+		}
+		for _, sigEnum := range s.signalEnumSrv.listEntities() {
+			entities = append(entities, sigEnum)
+		}
+	} else {
+		for _, entID := range req.EntityIDs {
+			ent, err := s.getEntity(entID)
+			if err != nil {
+				return err
+			}
+			entities = append(entities, ent)
+		}
+	}
+
+	if len(entities) == 0 {
+		return errors.New("export library: no signal types, units or enums to export")
+	}
+
+	return writeLibraryFile(path, entities)
+}
+
+// ImportLibrary adds a copy of the signal types, units and enums of a library file
+// to the network. The copies are independent from the file and they are kept in the library
+// of the network. The copies with a taken name are renamed.
+func (s *LibraryService) ImportLibrary(ctx context.Context, req LibraryFileReq) ([]LibraryChange, error) {
+	s = s.forWindow(ctx)
+
+	path, err := filepath.Abs(req.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	fileEntities, err := readLibraryFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	added := []linkedEntity{}
+	for _, fileEnt := range fileEntities {
+		takenNames := s.getTakenNames(fileEnt.ent.EntityKind())
+		if _, ok := takenNames[fileEnt.ent.Name()]; ok {
+			setLibraryEntityName(fileEnt.ent, getNewName(fileEnt.ent.Name(), takenNames))
+		}
+
+		// the names are taken one by one, since the file may hold the same name twice
+		s.addEntity(fileEnt.ent)
+		added = append(added, linkedEntity{ent: fileEnt.ent})
+	}
+
+	return s.addLibraryEntities(path, added), nil
+}
+
+// LinkLibrary adds the signal types, units and enums of a library file to the network.
+// They are linked to the file, so they are read-only and they are updated
+// only from the file by UpdateLinkedLibraries.
+func (s *LibraryService) LinkLibrary(ctx context.Context, req LibraryFileReq) ([]LibraryChange, error) {
+	s = s.forWindow(ctx)
+
+	path, err := filepath.Abs(req.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	fileEntities, err := readLibraryFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for _, link := range s.library.links {
+		if link.Path == path {
+			return nil, fmt.Errorf("link library: %s is already linked", filepath.Base(path))
+		}
+	}
+
+	for _, fileEnt := range fileEntities {
+		if _, ok := s.getTakenNames(fileEnt.ent.EntityKind())[fileEnt.ent.Name()]; ok {
+			return nil, fmt.Errorf("link library: the network already has a %s named %s, rename it or import the library instead",
+				strings.ReplaceAll(string(newEntityKind(fileEnt.ent.EntityKind())), "-", " "), fileEnt.ent.Name())
+		}
+	}
+
+	added := []linkedEntity{}
+	for _, fileEnt := range fileEntities {
+		s.addEntity(fileEnt.ent)
+		added = append(added, linkedEntity{
+			ent:    fileEnt.ent,
+			link:   libraryLink{Path: path, EntityID: fileEnt.fileID},
+			linked: true,
+		})
+	}
+
+	return s.addLibraryEntities(path, added), nil
+}
+
+// linkedEntity is an entity with the link to its library file, if any.
+type linkedEntity struct {
+	ent    entity
+	link   libraryLink
+	linked bool
+}
+
+func (e linkedEntity) addTo(library *networkLibrary) {
+	if e.linked {
+		library.link(e.ent, e.link)
+		return
+	}
+	library.keep(e.ent)
+}
+
+// addLibraryEntities adds the already added entities of a library file to the library
+// of the network and records the operation.
+func (s *LibraryService) addLibraryEntities(path string, added []linkedEntity) []LibraryChange {
+	for _, ent := range added {
+		ent.addTo(s.library)
+	}
+
+	s.historyCtr.sendOperation(
+		serviceKindNetwork,
+		func() (any, error) {
+			s.mux.Lock()
+			defer s.mux.Unlock()
+
+			for _, ent := range added {
+				s.library.remove(ent.ent)
+				s.removeEntity(ent.ent)
+			}
+
+			return newNetwork(s.networkSrv.network), nil
+		},
+		func() (any, error) {
+			s.mux.Lock()
+			defer s.mux.Unlock()
+
+			for _, ent := range added {
+				s.addEntity(ent.ent)
+				ent.addTo(s.library)
+			}
+
+			return newNetwork(s.networkSrv.network), nil
+		},
+	)
+
+	res := []LibraryChange{}
+	for _, ent := range added {
+		res = append(res, newLibraryChange(path, LibraryChangeKindAdd, ent.ent))
+	}
+	return res
+}
+
+// libraryReplacement replaces a linked entity with its new version in the library file.
+type libraryReplacement struct {
+	curr linkedEntity
+	next linkedEntity
+
+	// retarget moves the references of the current entity to the next one,
+	// or back to the current one when undo is true
+	retarget func(undo bool) error
+}
+
+// libraryUpdate holds the changes of the linked library files.
+type libraryUpdate struct {
+	changes []LibraryChange
+
+	replaced []libraryReplacement
+	// unlinked are the entities removed from their library file
+	unlinked []linkedEntity
+	// added are the entities added to a linked library file
+	added []linkedEntity
+}
+
+// PreviewLibraryUpdates returns the changes that UpdateLinkedLibraries
+// would make to the network, without applying them.
+func (s *LibraryService) PreviewLibraryUpdates(ctx context.Context) ([]LibraryChange, error) {
+	s = s.forWindow(ctx)
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	update, err := s.getLibraryUpdate()
+	if err != nil {
+		return nil, err
+	}
+
+	return update.changes, nil
+}
+
+// UpdateLinkedLibraries pulls the changes of the linked library files into the network.
+// The updated entities are replaced by a new version and their references are retargeted,
+// the entities removed from a file are unlinked and the ones added to it are linked.
+// The whole operation is recorded as a single history entry and the changes are returned.
+func (s *LibraryService) UpdateLinkedLibraries(ctx context.Context) ([]LibraryChange, error) {
+	s = s.forWindow(ctx)
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	update, err := s.getLibraryUpdate()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(update.changes) == 0 {
+		return update.changes, nil
+	}
+
+	if err := s.applyLibraryUpdate(update); err != nil {
+		return nil, err
+	}
+
+	s.historyCtr.sendOperation(
+		serviceKindNetwork,
+		func() (any, error) {
+			s.mux.Lock()
+			defer s.mux.Unlock()
+
+			if err := s.revertLibraryUpdate(update); err != nil {
+				return nil, err
+			}
+
+			return newNetwork(s.networkSrv.network), nil
+		},
+		func() (any, error) {
+			s.mux.Lock()
+			defer s.mux.Unlock()
+
+			if err := s.applyLibraryUpdate(update); err != nil {
+				return nil, err
+			}
+
+			return newNetwork(s.networkSrv.network), nil
+		},
+	)
+
+	return update.changes, nil
+}
+
+// getLibraryUpdate reads the linked library files and compares them with the linked entities.
+func (s *LibraryService) getLibraryUpdate() (*libraryUpdate, error) {
+	linkedByPath := make(map[string][]linkedEntity)
+	for entID, link := range s.library.links {
+		ent, ok := s.library.kept[entID]
+		if !ok {
+			continue
+		}
+		linkedByPath[link.Path] = append(linkedByPath[link.Path], linkedEntity{ent: ent, link: link, linked: true})
+	}
+
+	paths := slices.Sorted(maps.Keys(linkedByPath))
+
+	update := &libraryUpdate{changes: []LibraryChange{}}
+	for _, path := range paths {
+		fileEntities, err := readLibraryFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("update library %s: %w", filepath.Base(path), err)
+		}
+
+		linked := linkedByPath[path]
+		slices.SortFunc(linked, func(a, b linkedEntity) int {
+			return strings.Compare(a.ent.Name(), b.ent.Name())
+		})
+
+		matched := make(map[string]struct{})
+		for _, curr := range linked {
+			fileEnt, ok := findLibraryFileEntity(fileEntities, curr.link, curr.ent.EntityKind(), curr.ent.Name())
+			if !ok {
+				update.unlinked = append(update.unlinked, curr)
+				update.changes = append(update.changes, newLibraryChange(path, LibraryChangeKindRemove, curr.ent))
+				continue
+			}
+
+			matched[fileEnt.fileID] = struct{}{}
+
+			fields := getLibraryChangedFields(curr.ent, fileEnt.ent)
+			if len(fields) == 0 {
+				continue
+			}
+
+			update.replaced = append(update.replaced, libraryReplacement{
+				curr: curr,
+				next: linkedEntity{
+					ent:    fileEnt.ent,
+					link:   libraryLink{Path: path, EntityID: fileEnt.fileID},
+					linked: true,
+				},
+				retarget: getLibraryRetarget(curr.ent, fileEnt.ent),
+			})
+
+			change := newLibraryChange(path, LibraryChangeKindUpdate, curr.ent)
+			change.Fields = fields
+			update.changes = append(update.changes, change)
+		}
+
+		for _, fileEnt := range fileEntities {
+			if _, ok := matched[fileEnt.fileID]; ok {
+				continue
+			}
+
+			update.added = append(update.added, linkedEntity{
+				ent:    fileEnt.ent,
+				link:   libraryLink{Path: path, EntityID: fileEnt.fileID},
+				linked: true,
+			})
+			update.changes = append(update.changes, newLibraryChange(path, LibraryChangeKindAdd, fileEnt.ent))
+		}
+	}
+
+	if err := s.checkLibraryUpdateNames(update); err != nil {
+		return nil, err
+	}
+
+	return update, nil
+}
+
+// checkLibraryUpdateNames returns an error if an added or renamed entity
+// takes the name of another entity of the network.
+func (s *LibraryService) checkLibraryUpdateNames(update *libraryUpdate) error {
+	takenNames := make(map[acmelib.EntityKind]map[string]struct{})
+	getTakenNames := func(kind acmelib.EntityKind) map[string]struct{} {
+		if _, ok := takenNames[kind]; !ok {
+			takenNames[kind] = s.getTakenNames(kind)
+		}
+		return takenNames[kind]
+	}
+
+	for _, repl := range update.replaced {
+		delete(getTakenNames(repl.curr.ent.EntityKind()), repl.curr.ent.Name())
+	}
+
+	check := func(ent entity) error {
+		names := getTakenNames(ent.EntityKind())
+		if _, ok := names[ent.Name()]; ok {
+			return fmt.Errorf("update library: the network already has a %s named %s",
+				strings.ReplaceAll(string(newEntityKind(ent.EntityKind())), "-", " "), ent.Name())
+		}
+		names[ent.Name()] = struct{}{}
+		return nil
+	}
+
+	for _, repl := range update.replaced {
+		if err := check(repl.next.ent); err != nil {
+			return err
+		}
+	}
+	for _, added := range update.added {
+		if err := check(added.ent); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (s *LibraryService) applyLibraryUpdate(update *libraryUpdate) error {
+	for idx, repl := range update.replaced {
+		if err := repl.retarget(false); err != nil {
+			for _, tmpRepl := range update.replaced[:idx] {
+				tmpRepl.retarget(true)
+			}
+
+			return fmt.Errorf("update library: %s: %w", repl.curr.ent.Name(), err)
+		}
+	}
+
+	for _, repl := range update.replaced {
+		s.library.remove(repl.curr.ent)
+		s.removeEntity(repl.curr.ent)
+
+		s.addEntity(repl.next.ent)
+		repl.next.addTo(s.library)
+	}
+
+	// an unlinked entity is still kept, so it is not lost
+	for _, unlinked := range update.unlinked {
+		s.library.unlink(unlinked.ent)
+	}
+
+	for _, added := range update.added {
+		s.addEntity(added.ent)
+		added.addTo(s.library)
+	}
+
+	return nil
+}
+
+func (s *LibraryService) revertLibraryUpdate(update *libraryUpdate) error {
+	for _, added := range update.added {
+		s.library.remove(added.ent)
+		s.removeEntity(added.ent)
+	}
+
+	for _, unlinked := range update.unlinked {
+		unlinked.addTo(s.library)
+	}
+
+	for idx, repl := range update.replaced {
+		if err := repl.retarget(true); err != nil {
+			for _, tmpRepl := range update.replaced[:idx] {
+				tmpRepl.retarget(false)
+			}
+
+			return err
+		}
+	}
+
+	for _, repl := range update.replaced {
+		s.library.remove(repl.next.ent)
+		s.removeEntity(repl.next.ent)
+
+		s.addEntity(repl.curr.ent)
+		repl.curr.addTo(s.library)
+	}
+
+	return nil
+}
+
+// getLibraryRetarget returns the function that moves the references
+// of the current entity to the next one, or back when undo is true.
+func getLibraryRetarget(curr, next entity) func(undo bool) error {
+	switch tmpCurr := curr.(type) {
+	case *acmelib.SignalType:
+		tmpNext := next.(*acmelib.SignalType)
+		refSignals := tmpCurr.References()
+
+		return func(undo bool) error {
+			if undo {
+				return setSignalsType(refSignals, tmpNext, tmpCurr)
+			}
+			return setSignalsType(refSignals, tmpCurr, tmpNext)
+		}
+
+	case *acmelib.SignalUnit:
+		tmpNext := next.(*acmelib.SignalUnit)
+		refSignals := tmpCurr.References()
+
+		return func(undo bool) error {
+			if undo {
+				setSignalsUnit(refSignals, tmpCurr)
+			} else {
+				setSignalsUnit(refSignals, tmpNext)
+			}
+			return nil
+		}
+
+	case *acmelib.SignalEnum:
+		tmpNext := next.(*acmelib.SignalEnum)
+		refSignals := tmpCurr.References()
+
+		return func(undo bool) error {
+			if undo {
+				return setSignalsEnum(refSignals, tmpNext, tmpCurr)
+			}
+			return setSignalsEnum(refSignals, tmpCurr, tmpNext)
+		}
+	}
+
+	return func(bool) error { return nil }
+}
+
+func newLibraryChange(path string, kind LibraryChangeKind, ent entity) LibraryChange {
+	change := LibraryChange{
+		LibraryPath: path,
+		Kind:        kind,
+		EntityKind:  newEntityKind(ent.EntityKind()),
+		Name:        ent.Name(),
+		Fields:      []string{},
+	}
+
+	// an added entity is not in the network yet
+	if kind != LibraryChangeKindAdd {
+		change.EntityID = ent.EntityID().String()
+	}
+
+	return change
+}
+
+// getEntity returns the signal type, unit or enum with the given ID.
+func (s *LibraryService) getEntity(entityID string) (entity, error) {
+	if sigType, err := s.signalTypeSrv.getEntity(entityID); err == nil {
+		return sigType, nil
+	}
+	if sigUnit, err := s.signalUnitSrv.getEntity(entityID); err == nil {
+		return sigUnit, nil
+	}
+	return s.signalEnumSrv.getEntity(entityID)
+}
+
+func (s *LibraryService) getTakenNames(kind acmelib.EntityKind) map[string]struct{} {
+	switch kind {
+	case acmelib.EntityKindSignalType:
+		return s.signalTypeSrv.getTakenNames()
+	case acmelib.EntityKindSignalUnit:
+		return s.signalUnitSrv.getTakenNames()
+	case acmelib.EntityKindSignalEnum:
+		return s.signalEnumSrv.getTakenNames()
+	}
+	return make(map[string]struct{})
+}
+
+func (s *LibraryService) addEntity(ent entity) {
+	switch tmpEnt := ent.(type) {
+	case *acmelib.SignalType:
+		s.signalTypeSrv.addEntities([]*acmelib.SignalType{tmpEnt})
+	case *acmelib.SignalUnit:
+		s.signalUnitSrv.addEntities([]*acmelib.SignalUnit{tmpEnt})
+	case *acmelib.SignalEnum:
+		s.signalEnumSrv.addEntities([]*acmelib.SignalEnum{tmpEnt})
+	}
+}
+
+func (s *LibraryService) removeEntity(ent entity) {
+	switch tmpEnt := ent.(type) {
+	case *acmelib.SignalType:
+		s.signalTypeSrv.removeEntities([]*acmelib.SignalType{tmpEnt})
+	case *acmelib.SignalUnit:
+		s.signalUnitSrv.removeEntities([]*acmelib.SignalUnit{tmpEnt})
+	case *acmelib.SignalEnum:
+		s.signalEnumSrv.removeEntities([]*acmelib.SignalEnum{tmpEnt})
+	}
+}
+
+func setLibraryEntityName(ent entity, name string) {
+	switch tmpEnt := ent.(type) {
+	case *acmelib.SignalType:
+		tmpEnt.SetName(name)
+	case *acmelib.SignalUnit:
+		tmpEnt.SetName(name)
+	case *acmelib.SignalEnum:
+		tmpEnt.UpdateName(name)
+	}
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/squadracorsepolito/acmelib"
+)
+
+func TestLibraryRoundTrip(t *testing.T) {
+	net := acmelib.NewNetwork("library_net")
+	bus := acmelib.NewBus("bus")
+	if err := net.AddBus(bus); err != nil {
+		t.Fatal(err)
+	}
+
+	node := acmelib.NewNode("node", 1, 1)
+	if err := bus.AddNodeInterface(node.Interfaces()[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := acmelib.NewMessage("msg", 1, 8)
+	if err := node.Interfaces()[0].AddSentMessage(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	usedType, err := acmelib.NewIntegerSignalType("used_t", 8, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig, err := acmelib.NewStandardSignal("sig", usedType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := msg.AppendSignal(sig); err != nil {
+		t.Fatal(err)
+	}
+
+	unusedType, err := acmelib.NewDecimalSignalType("unused_t", 16, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unusedType.SetScale(0.1)
+	unusedType.SetDesc("kept type")
+
+	unusedUnit := acmelib.NewSignalUnit("volt", acmelib.SignalUnitKindElectrical, "V")
+
+	unusedEnum := acmelib.NewSignalEnum("state")
+	for idx, name := range []string{"OFF", "ON"} {
+		if err := unusedEnum.AddValue(acmelib.NewSignalEnumValue(name, idx)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	library := newNetworkLibrary()
+	for _, ent := range []entity{usedType, unusedType, unusedEnum} {
+		library.keep(ent)
+	}
+
+	baseDir := t.TempDir()
+	libPath := filepath.Join(baseDir, "shared", "units.canlib")
+	library.link(unusedUnit, libraryLink{Path: libPath, EntityID: "file_volt"})
+
+	for _, enc := range []acmelib.SaveEncoding{acmelib.SaveEncodingWire, acmelib.SaveEncodingJSON, acmelib.SaveEncodingText} {
+		buf, err := encodeNetwork(net, library, enc, baseDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if bytes.Contains(buf, []byte(baseDir)) {
+			t.Error("the path of a linked library must be relative to the network folder")
+		}
+
+		loadedNet, loadedLibrary, err := decodeNetwork(buf, enc, baseDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(loadedNet.Buses()) != 1 {
+			t.Errorf("expected 1 bus, got %d", len(loadedNet.Buses()))
+		}
+
+		keptByName := make(map[string]entity)
+		for _, ent := range loadedLibrary.kept {
+			keptByName[ent.Name()] = ent
+		}
+
+		if len(keptByName) != 4 {
+			t.Fatalf("expected 4 kept entities, got %d", len(keptByName))
+		}
+
+		loadedUsedType, ok := keptByName["used_t"].(*acmelib.SignalType)
+		if !ok || loadedUsedType.EntityID() != usedType.EntityID() {
+			t.Error("the referenced signal type must keep its entity id")
+		}
+
+		loadedType, ok := keptByName["unused_t"].(*acmelib.SignalType)
+		if !ok || loadedType.Scale() != 0.1 || loadedType.Size() != 16 || loadedType.Desc() != "kept type" {
+			t.Error("the unreferenced signal type has not been restored")
+		}
+
+		loadedUnit, ok := keptByName["volt"].(*acmelib.SignalUnit)
+		if !ok || loadedUnit.Symbol() != "V" {
+			t.Fatal("the unreferenced signal unit has not been restored")
+		}
+
+		link, ok := loadedLibrary.getLink(loadedUnit.EntityID())
+		if !ok || link.Path != libPath || link.EntityID != "file_volt" {
+			t.Errorf("the link of the signal unit has not been restored, got %+v", link)
+		}
+
+		if loadedLibrary.checkEditable(loadedUnit) == nil {
+			t.Error("a linked signal unit must be read-only")
+		}
+
+		loadedEnum, ok := keptByName["state"].(*acmelib.SignalEnum)
+		if !ok || len(loadedEnum.Values()) != 2 {
+			t.Fatal("the unreferenced signal enum has not been restored")
+		}
+
+		for _, pair := range [][2]entity{{unusedType, loadedType}, {unusedUnit, loadedUnit}, {unusedEnum, loadedEnum}} {
+			if pair[1].EntityID() != pair[0].EntityID() {
+				t.Errorf("the unreferenced %s must keep its entity id", pair[0].Name())
+			}
+		}
+
+		if loadedType.ReferenceCount() != 0 || loadedUnit.ReferenceCount() != 0 || loadedEnum.ReferenceCount() != 0 {
+			t.Error("the unreferenced entities must not be referenced after the load")
+		}
+	}
+}
+
+func TestLibraryEmptyEncoding(t *testing.T) {
+	net := acmelib.NewNetwork("empty_net")
+
+	buf, err := encodeNetwork(net, newNetworkLibrary(), acmelib.SaveEncodingText, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := new(bytes.Buffer)
+	if err := acmelib.SaveNetwork(net, acmelib.SaveEncodingText, nil, nil, expected); err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(buf, expected.Bytes()) {
+		t.Error("a network without a library must be encoded by acmelib")
+	}
+}
+
+func TestLibraryFileChanges(t *testing.T) {
+	sigType, err := acmelib.NewDecimalSignalType("temp_t", 12, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigType.SetScale(0.1)
+
+	sigUnit := acmelib.NewSignalUnit("celsius", acmelib.SignalUnitKindTemperature, "degC")
+
+	sigEnum := acmelib.NewSignalEnum("state")
+	for idx, name := range []string{"OFF", "ON"} {
+		if err := sigEnum.AddValue(acmelib.NewSignalEnumValue(name, idx)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "shared"+libraryFileExtension)
+	if err := writeLibraryFile(path, []entity{sigType, sigUnit, sigEnum}); err != nil {
+		t.Fatal(err)
+	}
+
+	fileEntities, err := readLibraryFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fileEntities) != 3 {
+		t.Fatalf("expected 3 entities, got %d", len(fileEntities))
+	}
+
+	for _, ent := range []entity{sigType, sigUnit, sigEnum} {
+		fileEnt, ok := findLibraryFileEntity(fileEntities, libraryLink{Path: path, EntityID: ent.EntityID().String()}, ent.EntityKind(), "")
+		if !ok {
+			t.Fatalf("%s not found by its id", ent.Name())
+		}
+
+		if fields := getLibraryChangedFields(ent, fileEnt.ent); len(fields) > 0 {
+			t.Errorf("%s: expected no changes, got %v", ent.Name(), fields)
+		}
+	}
+
+	// a library exported again gets new ids, the entities are matched by name
+	fileEnt, ok := findLibraryFileEntity(fileEntities, libraryLink{Path: path, EntityID: "unknown"}, acmelib.EntityKindSignalType, "temp_t")
+	if !ok {
+		t.Fatal("temp_t not found by its name")
+	}
+
+	changedType := fileEnt.ent.(*acmelib.SignalType)
+	changedType.SetScale(0.5)
+	changedType.SetDesc("new desc")
+
+	fields := getLibraryChangedFields(sigType, changedType)
+	if !slices.Equal(fields, []string{"desc", "scale"}) {
+		t.Errorf("expected desc and scale changes, got %v", fields)
+	}
+}
+
+func TestLinkedSignalTypeNotConverted(t *testing.T) {
+	m, _ := newTestManager(t)
+
+	if err := m.createNetwork(); err != nil {
+		t.Fatal(err)
+	}
+
+	celsius := acmelib.NewSignalUnit("celsius", acmelib.SignalUnitKindTemperature, "°C")
+	kelvin := acmelib.NewSignalUnit("kelvin", acmelib.SignalUnitKindTemperature, "K")
+
+	msg := acmelib.NewMessage("msg", 1, 8)
+	signals := []*acmelib.StandardSignal{}
+	for idx, sigUnit := range []*acmelib.SignalUnit{celsius, kelvin} {
+		sigType, err := acmelib.NewDecimalSignalType(fmt.Sprintf("temp_t_%d", idx), 16, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sig, err := acmelib.NewStandardSignal(fmt.Sprintf("temp_%d", idx), sigType)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig.SetUnit(sigUnit)
+
+		if err := msg.InsertSignal(sig, idx*16); err != nil {
+			t.Fatal(err)
+		}
+
+		signals = append(signals, sig)
+	}
+
+	node := acmelib.NewNode("node", 1, 1)
+	if err := node.Interfaces()[0].AddSentMessage(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	bus := acmelib.NewBus("bus")
+	if err := bus.AddNodeInterface(node.Interfaces()[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	m.mux.Lock()
+	err := m.network.AddBus(bus)
+	m.mux.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.initNetwork(m.network)
+	waitServices(m)
+
+	sig := signals[0]
+	sigType := sig.Type()
+
+	m.mux.Lock()
+	m.library.link(sigType, libraryLink{Path: "shared" + libraryFileExtension, EntityID: "file_temp_t"})
+	m.mux.Unlock()
+
+	_, err = m.signalSrv.UpdateSignalUnit(t.Context(), sig.EntityID().String(), UpdateSignalUnitReq{
+		SignalUnitEntityID: kelvin.EntityID().String(),
+		ConvertType:        true,
+	})
+	if err == nil {
+		t.Fatal("expected an error converting a signal type linked to a library file")
+	}
+
+	if sig.Unit() != celsius {
+		t.Errorf("the unit of the signal has changed to %s", sig.Unit().Name())
+	}
+
+	if sigType.Offset() != 0 {
+		t.Errorf("the linked signal type has been converted, got offset %g", sigType.Offset())
+	}
+}
+
+func TestLinkedSignalEnumNotFixed(t *testing.T) {
+	m, _ := newTestManager(t)
+
+	if err := m.createNetwork(); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := acmelib.NewMessage("msg", 1, 8)
+	enums := []*acmelib.SignalEnum{}
+	for idx, name := range []string{"linked", "local"} {
+		sigEnum := acmelib.NewSignalEnum(name)
+		if err := sigEnum.AddValue(acmelib.NewSignalEnumValue("OFF", 0)); err != nil {
+			t.Fatal(err)
+		}
+
+		sig, err := acmelib.NewEnumSignal("SIG_"+name, sigEnum)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := msg.InsertSignal(sig, idx*8); err != nil {
+			t.Fatal(err)
+		}
+
+		enums = append(enums, sigEnum)
+	}
+
+	node := acmelib.NewNode("node", 1, 1)
+	if err := node.Interfaces()[0].AddSentMessage(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	bus := acmelib.NewBus("bus")
+	if err := bus.AddNodeInterface(node.Interfaces()[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	m.mux.Lock()
+	err := m.network.AddBus(bus)
+	m.mux.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m.initNetwork(m.network)
+	waitServices(m)
+
+	linkedEnum, localEnum := enums[0], enums[1]
+
+	m.mux.Lock()
+	m.library.link(linkedEnum, libraryLink{Path: "shared" + libraryFileExtension, EntityID: "file_linked"})
+	m.mux.Unlock()
+
+	if _, err := m.lintSrv.ApplyAllFixes(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	if name := linkedEnum.Values()[0].Name(); name != "OFF" {
+		t.Errorf("the value of the linked signal enum has been renamed to %s", name)
+	}
+
+	if name := localEnum.Values()[0].Name(); name != "local_OFF" {
+		t.Errorf("expected the value of the local signal enum to be renamed to local_OFF, got %s", name)
+	}
+}
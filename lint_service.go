@@ -0,0 +1,393 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/squadracorsepolito/acmelib"
+)
+
+type LintFixKind string
+
+const (
+	// LintFixKindName renames the entity.
+	LintFixKindName LintFixKind = "name"
+	// LintFixKindEnumValueName renames a value of a signal enum.
+	LintFixKindEnumValueName LintFixKind = "enum-value-name"
+)
+
+// LintFix describes the change that fixes a violation.
+type LintFix struct {
+	Kind LintFixKind `json:"kind"`
+
+	EntityKind    EntityKind `json:"entityKind"`
+	EntityID      string     `json:"entityId"`
+	ValueEntityID string     `json:"valueEntityId"`
+
+	Name string `json:"name"`
+}
+
+type LintViolation struct {
+	EntityPath
+
+	Paths []EntityPath `json:"paths"`
+
+	Rule    LintRuleKind `json:"rule"`
+	Message string       `json:"message"`
+
+	// ValueEntityID is set when the violation refers to a value of a signal enum.
+	ValueEntityID string `json:"valueEntityId"`
+
+	// Fix is nil when the violation cannot be fixed automatically.
+	Fix *LintFix `json:"fix"`
+}
+
+type LintService struct {
+	windowRouted
+
+	mux *sync.RWMutex
+
+	settingsSrv *SettingsService
+
+	busSrv        *BusService
+	nodeSrv       *NodeService
+	messageSrv    *MessageService
+	signalSrv     *SignalService
+	signalTypeSrv *SignalTypeService
+	signalUnitSrv *SignalUnitService
+	signalEnumSrv *SignalEnumService
+}
+
+func newLintService(mux *sync.RWMutex, settingsSrv *SettingsService, busSrv *BusService, nodeSrv *NodeService, messageSrv *MessageService,
+	signalSrv *SignalService, signalTypeSrv *SignalTypeService, signalUnitSrv *SignalUnitService, signalEnumSrv *SignalEnumService) *LintService {
+
+	return &LintService{
+		mux: mux,
+
+		settingsSrv: settingsSrv,
+
+		busSrv:        busSrv,
+		nodeSrv:       nodeSrv,
+		messageSrv:    messageSrv,
+		signalSrv:     signalSrv,
+		signalTypeSrv: signalTypeSrv,
+		signalUnitSrv: signalUnitSrv,
+		signalEnumSrv: signalEnumSrv,
+	}
+}
+
+// forWindow returns the service of the window that made the call.
+func (s *LintService) forWindow(ctx context.Context) *LintService {
+	return s.resolve(ctx).lintSrv
+}
+
+// Lint checks all the entities of the network against the enabled lint rules
+// stored in the settings and returns the violations found.
+func (s *LintService) Lint(ctx context.Context) []LintViolation {
+	s = s.forWindow(ctx)
+
+	rules := s.settingsSrv.getLintRules()
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	res := []LintViolation{}
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+
+		switch rule.Kind {
+		case LintRuleMessageSenderPrefix:
+			res = append(res, s.lintMessageSenderPrefix()...)
+		case LintRuleNameCase:
+			res = append(res, s.lintNameCase(rule)...)
+		case LintRuleEnumValuePrefix:
+			res = append(res, s.lintEnumValuePrefix(rule)...)
+		case LintRuleRequiredDesc:
+			res = append(res, s.lintRequiredDesc(rule)...)
+		case LintRuleUnitDimension:
+			res = append(res, s.lintUnitDimension()...)
+		}
+	}
+
+	slices.SortStableFunc(res, func(a, b LintViolation) int {
+		if a.Kind == b.Kind {
+			return strings.Compare(a.Name, b.Name)
+		}
+		return strings.Compare(string(a.Kind), string(b.Kind))
+	})
+
+	return res
+}
+
+// ApplyFix applies the fix of a violation through the update methods of the
+// entity services, so every fix is recorded in the history and can be undone.
+func (s *LintService) ApplyFix(ctx context.Context, fix LintFix) error {
+	s = s.forWindow(ctx)
+
+	if fix.Kind == LintFixKindEnumValueName {
+		req := UpdateValueNameReq{}
+		req.ValueEntityID = fix.ValueEntityID
+		req.Name = fix.Name
+
+		_, err := s.signalEnumSrv.UpdateValueName(ctx, fix.EntityID, req)
+		return err
+	}
+
+	if fix.Kind != LintFixKindName {
+		return errors.New("apply fix: invalid fix kind")
+	}
+
+	req := UpdateNameReq{Name: fix.Name}
+
+	var err error
+	switch fix.EntityKind {
+	case EntityKindBus:
+		_, err = s.busSrv.UpdateName(ctx, fix.EntityID, req)
+	case EntityKindNode:
+		_, err = s.nodeSrv.UpdateName(ctx, fix.EntityID, req)
+	case EntityKindMessage:
+		_, err = s.messageSrv.UpdateName(ctx, fix.EntityID, req)
+	case EntityKindSignal:
+		_, err = s.signalSrv.UpdateName(ctx, fix.EntityID, req)
+	case EntityKindSignalType:
+		_, err = s.signalTypeSrv.UpdateName(ctx, fix.EntityID, req)
+	case EntityKindSignalUnit:
+		_, err = s.signalUnitSrv.UpdateName(ctx, fix.EntityID, req)
+	case EntityKindSignalEnum:
+		_, err = s.signalEnumSrv.UpdateName(ctx, fix.EntityID, req)
+	default:
+		return fmt.Errorf("apply fix: cannot rename entity of kind %s", fix.EntityKind)
+	}
+
+	return err
+}
+
+// ApplyAllFixes applies all the available fixes and returns the number of the applied ones.
+// The fixes that fail (e.g. because the new name is already taken) are skipped
+// and the first error is returned. The read-only entities are left out.
+func (s *LintService) ApplyAllFixes(ctx context.Context) (int, error) {
+	s = s.forWindow(ctx)
+
+	violations := s.Lint(ctx)
+
+	s.mux.RLock()
+	fixes := []LintFix{}
+	for _, violation := range violations {
+		// the entities linked to a library file are read-only
+		if violation.Fix != nil && s.isFixEditable(violation.Fix) {
+			fixes = append(fixes, *violation.Fix)
+		}
+	}
+	s.mux.RUnlock()
+
+	var firstErr error
+
+	count := 0
+	for _, fix := range fixes {
+		if err := s.ApplyFix(ctx, fix); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+
+		count++
+	}
+
+	return count, firstErr
+}
+
+// isFixEditable reports whether the entity changed by the fix is not read-only.
+// The caller must hold the mutex.
+func (s *LintService) isFixEditable(fix *LintFix) bool {
+	switch fix.EntityKind {
+	case EntityKindSignalType:
+		return s.signalTypeSrv.isEditable(fix.EntityID)
+	case EntityKindSignalUnit:
+		return s.signalUnitSrv.isEditable(fix.EntityID)
+	case EntityKindSignalEnum:
+		return s.signalEnumSrv.isEditable(fix.EntityID)
+	}
+
+	return true
+}
+
+func newLintViolation(ent entity, rule LintRuleKind, msg string) LintViolation {
+	return LintViolation{
+		EntityPath: newEntityPath(ent),
+
+		Paths: getEntityPaths(ent),
+
+		Rule:    rule,
+		Message: msg,
+	}
+}
+
+func newLintNameFix(ent entity, name string) *LintFix {
+	return &LintFix{
+		Kind: LintFixKindName,
+
+		EntityKind: newEntityKind(ent.EntityKind()),
+		EntityID:   ent.EntityID().String(),
+
+		Name: name,
+	}
+}
+
+func (s *LintService) lintMessageSenderPrefix() []LintViolation {
+	res := []LintViolation{}
+
+	for _, msg := range s.messageSrv.listEntities() {
+		nodeInt := msg.SenderNodeInterface()
+		if nodeInt == nil {
+			continue
+		}
+
+		nodeName := nodeInt.Node().Name()
+		if strings.HasPrefix(msg.Name(), nodeName) {
+			continue
+		}
+
+		violation := newLintViolation(msg, LintRuleMessageSenderPrefix,
+			fmt.Sprintf("the name does not start with the name of the sender node %s", nodeName))
+		violation.Fix = newLintNameFix(msg, nodeName+"_"+msg.Name())
+
+		res = append(res, violation)
+	}
+
+	return res
+}
+
+// getLintEntities returns the entities of the given kinds.
+// The caller must hold the mutex.
+func (s *LintService) getLintEntities(kinds []EntityKind) []entity {
+	entities := []entity{}
+
+	for _, kind := range kinds {
+		switch kind {
+		case EntityKindBus:
+			entities = append(entities, toEntities(s.busSrv.listEntities())...)
+		case EntityKindNode:
+			entities = append(entities, toEntities(s.nodeSrv.listEntities())...)
+		case EntityKindMessage:
+			entities = append(entities, toEntities(s.messageSrv.listEntities())...)
+		case EntityKindSignal:
+			entities = append(entities, toEntities(s.signalSrv.listEntities())...)
+		case EntityKindSignalType:
+			entities = append(entities, toEntities(s.signalTypeSrv.listEntities())...)
+		case EntityKindSignalUnit:
+			entities = append(entities, toEntities(s.signalUnitSrv.listEntities())...)
+		case EntityKindSignalEnum:
+			entities = append(entities, toEntities(s.signalEnumSrv.listEntities())...)
+		}
+	}
+
+	return entities
+}
+
+func (s *LintService) lintNameCase(rule LintRule) []LintViolation {
+	res := []LintViolation{}
+
+	for _, ent := range s.getLintEntities(rule.Kinds) {
+		name := ent.Name()
+
+		fixedName := convertNameCase(name, rule.Case)
+		if fixedName == name {
+			continue
+		}
+
+		violation := newLintViolation(ent, LintRuleNameCase,
+			fmt.Sprintf("the name is not in %s case", rule.Case))
+		if len(fixedName) > 0 {
+			violation.Fix = newLintNameFix(ent, fixedName)
+		}
+
+		res = append(res, violation)
+	}
+
+	return res
+}
+
+func (s *LintService) lintEnumValuePrefix(rule LintRule) []LintViolation {
+	res := []LintViolation{}
+
+	for _, sigEnum := range s.signalEnumSrv.listEntities() {
+		prefix := rule.Prefix
+		if len(prefix) == 0 {
+			prefix = sigEnum.Name() + "_"
+		}
+
+		for _, val := range sigEnum.Values() {
+			if strings.HasPrefix(val.Name(), prefix) {
+				continue
+			}
+
+			violation := newLintViolation(sigEnum, LintRuleEnumValuePrefix,
+				fmt.Sprintf("the name of the value %s does not start with %s", val.Name(), prefix))
+			violation.ValueEntityID = val.EntityID().String()
+			violation.Fix = &LintFix{
+				Kind: LintFixKindEnumValueName,
+
+				EntityKind:    EntityKindSignalEnum,
+				EntityID:      sigEnum.EntityID().String(),
+				ValueEntityID: val.EntityID().String(),
+
+				Name: prefix + val.Name(),
+			}
+
+			res = append(res, violation)
+		}
+	}
+
+	return res
+}
+
+func (s *LintService) lintRequiredDesc(rule LintRule) []LintViolation {
+	res := []LintViolation{}
+
+	for _, ent := range s.getLintEntities(rule.Kinds) {
+		if len(strings.TrimSpace(ent.Desc())) > 0 {
+			continue
+		}
+
+		res = append(res, newLintViolation(ent, LintRuleRequiredDesc, "the description is missing"))
+	}
+
+	return res
+}
+
+func (s *LintService) lintUnitDimension() []LintViolation {
+	res := []LintViolation{}
+
+	for _, sig := range s.signalSrv.listEntities() {
+		if sig.Kind() != acmelib.SignalKindStandard {
+			continue
+		}
+
+		stdSig, err := sig.ToStandard()
+		if err != nil || stdSig.Unit() == nil {
+			continue
+		}
+
+		nameDims := getNameDimensions(sig.Name())
+		if nameDims == nil {
+			continue
+		}
+
+		unitDim := getUnitDimension(stdSig.Unit())
+		if unitDim == UnitDimensionNone || slices.Contains(nameDims, unitDim) {
+			continue
+		}
+
+		res = append(res, newLintViolation(sig, LintRuleUnitDimension,
+			fmt.Sprintf("the name suggests a %s, but the unit %s measures a %s", nameDims[0], stdSig.Unit().Name(), unitDim)))
+	}
+
+	return res
+}
@@ -4,8 +4,10 @@ import (
 	"embed"
 	"log"
 	"log/slog"
+	"os"
 
 	"github.com/wailsapp/wails/v3/pkg/application"
+	"github.com/wailsapp/wails/v3/pkg/events"
 )
 
 // Wails uses Go's `embed` package to embed the frontend files into the binary.
@@ -18,21 +20,46 @@ var assets embed.FS
 
 var app *application.App
 
-var manager *serviceManager
-
-const testdataPath = "./testdata/SC24.binpb"
-
 // main function serves as the application's entry point.
 // Main initializes the application, creates a window,
 // and starts a goroutine that emits a time-based event every second.
 // It subsequently runs the application and logs any error that might occur.
 func main() {
-	manager = newServiceManager()
+	// The commands given as arguments (e.g. canturin script) run without the app.
+	if exitCode, handled := runCLI(os.Args[1:]); handled {
+		os.Exit(exitCode)
+	}
+
+	// The events are sent to the frontend and to the clients of the API server.
+	apiEvents := newEventSubscribers()
+	wailsLog := newWailsLogger()
+
+	// The settings are shared by all the windows, so their changes are sent to all of them.
+	// They are not sent to the clients of the API server, since they contain its token.
+	settingsSrv := newConfigService(newWailsEventEmitter(), wailsLog)
+
+	// Load the settings before creating the app, since the keybindings
+	// customized by the user are needed to configure it.
+	if err := settingsSrv.initFolder(); err != nil {
+		log.Print(err)
+	}
+
+	windows := newWindowManagers(settingsSrv, apiEvents, wailsLog)
 
-	menuHandler := newMenuHandler()
-	kbHandler := newKeybindingsHandler()
+	// The plugins add their commands to the menu, so they are loaded before creating it.
+	pluginHost := newPluginHost(settingsSrv.getPluginsDir(), wailsLog)
+
+	menuHandler := newMenuHandler(windows, wailsLog)
+	cmdRegistry := newCommandRegistry(settingsSrv, menuHandler, pluginHost, windows)
+
+	kbHandler := newKeybindingsHandler(cmdRegistry, wailsLog)
 	kbHandler.init()
 
+	services := append(windows.getServices(), application.NewService(newCommandService(cmdRegistry)))
+
+	// The local automation API exposes the same services bound to the frontend.
+	apiSrv := newAPIServer(services, apiEvents, wailsLog)
+
 	// Create a new Wails application by providing the necesvar (sary options.
 	// Variables 'Name' and 'Description' are for application metadata.
 	// 'Assets' configures the asset server with the 'FS' variable pointing to the frontend files.
@@ -42,9 +69,9 @@ func main() {
 		Name:        "canturin",
 		Description: "",
 
-		Services: manager.getServices(),
+		Services: services,
 
-		// Key bindings for undo/redo actions, triggering functions on specific key combinations.
+		// Key bindings of the commands, triggering functions on specific key combinations.
 		KeyBindings: kbHandler.getWindowKeybindings(),
 
 		// Configure the asset handler to serve embedded frontend files.
@@ -57,31 +84,35 @@ func main() {
 			ApplicationShouldTerminateAfterLastWindowClosed: true,
 		},
 
+		// Ask what to do with the unsaved changes before quitting from the OS (e.g. Cmd+Q).
+		ShouldQuit: windows.shouldQuit,
+
+		OnShutdown: apiSrv.shutdown,
+
 		LogLevel: slog.LevelError,
 	})
 
-	// Create a new window with the necessary options.
-	// 'Title' is the title of the window.
-	// 'Mac' options tailor the window when running on macOS.
-	// 'BackgroundColour' is the background colour of the window.
-	// 'URL' is the URL that will be loaded into the webview.
-	app.NewWebviewWindowWithOptions(application.WebviewWindowOptions{
-		Title: "canturin",
-		Mac: application.MacWindow{
-			InvisibleTitleBarHeight: 50,
-			Backdrop:                application.MacBackdropTranslucent,
-		},
-		BackgroundColour:       application.NewRGB(27, 38, 54),
-		URL:                    "/",
-		OpenInspectorOnStartup: true,
+	// Start the API server and open the first window with the startup network.
+	// A network path can be passed as argument (e.g. when the app is opened
+	// from a network file). Otherwise, the last network is reopened
+	// if the user enabled it in the settings.
+	app.OnApplicationEvent(events.Common.ApplicationStarted, func(_ *application.ApplicationEvent) {
+		settingsSrv.setAPIServerListener(apiSrv.update)
+		go apiSrv.update(settingsSrv.getAPIServerSettings())
+
+		startupPath := ""
+		if len(os.Args) > 1 {
+			startupPath = os.Args[1]
+		} else if settingsSrv.shouldReopenLastNetwork() {
+			startupPath = settingsSrv.getLastNetworkPath()
+		}
+
+		if err := windows.open(startupPath); err != nil {
+			wailsLog.error(err)
+		}
 	})
 
-	// TODO! remove this event in production
-	// app.OnApplicationEvent(events.Common.ApplicationStarted, func(_ *application.ApplicationEvent) {
-	// 	manager.openNetwork(testdataPath)
-	// })
-
-	menuHandler.init()
+	menuHandler.init(cmdRegistry)
 
 	// Run the application. This blocks until the application has been exited.
 	// If an error occurred while running the application, log it and exit.
@@ -1,44 +1,235 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
 	"github.com/wailsapp/wails/v3/pkg/application"
 )
 
-type menuHandler struct{}
+type menuHandler struct {
+	mux sync.Mutex
+
+	menu       *application.Menu
+	recentMenu *application.Menu
+
+	registry     *commandRegistry
+	commandItems map[string]*application.MenuItem
+
+	// windows holds the managers of the windows,
+	// the commands are run on the network of the focused one
+	windows *windowManagers
+
+	log logger
+}
+
+func newMenuHandler(windows *windowManagers, log logger) *menuHandler {
+	return &menuHandler{
+		commandItems: make(map[string]*application.MenuItem),
 
-func newMenuHandler() *menuHandler {
-	return &menuHandler{}
+		windows: windows,
+
+		log: log,
+	}
 }
 
-func (h *menuHandler) init() {
+func (h *menuHandler) init(registry *commandRegistry) {
+	h.registry = registry
+
 	app := application.Get()
 
 	menu := app.NewMenu()
+	h.menu = menu
 	menu.AddRole(application.AppMenu)
 
 	fileMenu := menu.AddSubmenu("File")
 
-	h.register(fileMenu, "New Network", h.newNetwork)
+	h.registerCommand(fileMenu, CommandNewNetwork)
+	h.registerCommand(fileMenu, CommandNewWindow)
 
 	fileMenu.AddSeparator()
 
-	h.register(fileMenu, "Open Network", h.openNetwork)
+	h.registerCommand(fileMenu, CommandOpenNetwork)
+	h.registerCommand(fileMenu, CommandOpenNetworkInNewWindow)
+	h.recentMenu = fileMenu.AddSubmenu("Open Recent")
+	h.buildRecentMenu(h.windows.settingsSrv.Get().RecentNetworks)
 
 	fileMenu.AddSeparator()
 
-	h.register(fileMenu, "Save Network", h.saveNetwork)
-	h.register(fileMenu, "Save Network As", h.saveNetworkAs)
+	h.registerCommand(fileMenu, CommandSaveNetwork)
+	h.registerCommand(fileMenu, CommandSaveNetworkAs)
 
 	fileMenu.AddSeparator()
 
-	h.register(fileMenu, "Import DBC", h.importDBC)
-	h.register(fileMenu, "Export DBC", h.exportDBC)
+	h.registerCommand(fileMenu, CommandImportDBC)
+	h.registerCommand(fileMenu, CommandExportDBC)
 
 	fileMenu.AddSeparator()
 
-	h.register(fileMenu, "Reload", h.reload)
+	h.registerCommand(fileMenu, CommandExportLibrary)
+	h.registerCommand(fileMenu, CommandImportLibrary)
+	h.registerCommand(fileMenu, CommandLinkLibrary)
+	h.registerCommand(fileMenu, CommandUpdateLibraries)
+
+	fileMenu.AddSeparator()
+
+	h.registerCommand(fileMenu, CommandExportTopology)
+
+	fileMenu.AddSeparator()
+
+	// commands provided by the plugins
+	hasPluginCommands := false
+	for _, cmd := range registry.commands {
+		if cmd.category == CommandCategoryPlugin {
+			h.registerCommand(fileMenu, cmd.id)
+			hasPluginCommands = true
+		}
+	}
+	if hasPluginCommands {
+		fileMenu.AddSeparator()
+	}
+
+	h.registerCommand(fileMenu, CommandReload)
+
+	editMenu := menu.AddSubmenu("Edit")
+
+	h.registerCommand(editMenu, CommandUndo)
+	h.registerCommand(editMenu, CommandRedo)
+
+	editMenu.AddSeparator()
+
+	h.registerCommand(editMenu, CommandSearch)
+	h.registerCommand(editMenu, CommandBulkRename)
+	h.registerCommand(editMenu, CommandValidate)
+
+	editMenu.AddSeparator()
+
+	h.registerCommand(editMenu, CommandPalette)
+
+	toolsMenu := menu.AddSubmenu("Tools")
+
+	h.registerCommand(toolsMenu, CommandRunScript)
+	h.registerCommand(toolsMenu, CommandDryRunScript)
 
 	app.SetMenu(menu)
+
+	h.windows.settingsSrv.setRecentNetworksListener(h.updateRecentMenu)
+	registry.setKeysListener(h.updateAccelerators)
+}
+
+// registerCommand adds the menu item of the command, with its keybinding as accelerator.
+func (h *menuHandler) registerCommand(menu *application.Menu, commandID string) {
+	cmd, err := h.registry.get(commandID)
+	if err != nil {
+		panic(err)
+	}
+
+	item := menu.Add(cmd.title).OnClick(func(_ *application.Context) {
+		if err := h.registry.runCommand(cmd); err != nil {
+			application.ErrorDialog().SetMessage(err.Error()).Show()
+		}
+	})
+
+	if keys, _ := h.registry.getKeys(cmd); len(keys) > 0 {
+		item.SetAccelerator(keys)
+	}
+
+	h.commandItems[commandID] = item
+}
+
+// updateAccelerators sets the accelerators of the menu items
+// to the keybindings of the commands.
+func (h *menuHandler) updateAccelerators() {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	for commandID, item := range h.commandItems {
+		cmd, err := h.registry.get(commandID)
+		if err != nil {
+			continue
+		}
+
+		keys, _ := h.registry.getKeys(cmd)
+		if len(keys) == 0 {
+			item.RemoveAccelerator()
+			continue
+		}
+
+		item.SetAccelerator(keys)
+	}
+
+	h.menu.Update()
+}
+
+// updateRecentMenu rebuilds the Open Recent submenu with the given recent networks.
+func (h *menuHandler) updateRecentMenu(recentNets []RecentNetwork) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	for item := h.recentMenu.ItemAt(0); item != nil; item = h.recentMenu.ItemAt(0) {
+		h.recentMenu.RemoveMenuItem(item)
+	}
+
+	h.buildRecentMenu(recentNets)
+
+	h.menu.Update()
+}
+
+func (h *menuHandler) buildRecentMenu(recentNets []RecentNetwork) {
+	hasPinned := false
+	for _, recNet := range recentNets {
+		if recNet.Pinned {
+			h.registerRecentNetwork(recNet)
+			hasPinned = true
+		}
+	}
+
+	hasRecent := false
+	for _, recNet := range recentNets {
+		if recNet.Pinned {
+			continue
+		}
+
+		if hasPinned && !hasRecent {
+			h.recentMenu.AddSeparator()
+		}
+
+		h.registerRecentNetwork(recNet)
+		hasRecent = true
+	}
+
+	if len(recentNets) == 0 {
+		h.recentMenu.Add("No Recent Networks").SetEnabled(false)
+	}
+
+	h.recentMenu.AddSeparator()
+
+	reopenLast := h.windows.settingsSrv.shouldReopenLastNetwork()
+	h.recentMenu.AddCheckbox("Reopen Last Network on Startup", reopenLast).OnClick(func(_ *application.Context) {
+		h.windows.settingsSrv.UpdateReopenLastNetwork(!h.windows.settingsSrv.shouldReopenLastNetwork())
+	})
+
+	h.register(h.recentMenu, "Clear Recent Networks", h.clearRecentNetworks)
+}
+
+func (h *menuHandler) registerRecentNetwork(recNet RecentNetwork) {
+	label := fmt.Sprintf("%s (%s)", recNet.Name, recNet.Path)
+	if recNet.Pinned {
+		label += " [pinned]"
+	}
+
+	h.register(h.recentMenu, label, func(_ *application.Context) error {
+		return h.windows.current().openNetwork(recNet.Path)
+	})
+}
+
+func (h *menuHandler) clearRecentNetworks(_ *application.Context) error {
+	h.windows.settingsSrv.ClearRecentNetworks()
+	return nil
 }
 
 func (h *menuHandler) register(menu *application.Menu, name string, cb func(*application.Context) error) {
@@ -50,34 +241,52 @@ func (h *menuHandler) register(menu *application.Menu, name string, cb func(*app
 }
 
 func (h *menuHandler) newNetwork(_ *application.Context) error {
-	manager.createNetwork()
-	return nil
+	return h.windows.current().createNetwork()
 }
 
 func (h *menuHandler) openNetwork(_ *application.Context) error {
 	dialog := newOpenNetworkDialog()
 	filename, err := dialog.PromptForSingleSelection()
 	if err != nil {
-		printError(err)
+		h.log.error(err)
 		return nil
 	}
 
-	return manager.openNetwork(filename)
+	return h.windows.current().openNetwork(filename)
+}
+
+func (h *menuHandler) newWindow(_ *application.Context) error {
+	return h.windows.current().openWindow("")
+}
+
+func (h *menuHandler) openNetworkInNewWindow(_ *application.Context) error {
+	dialog := newOpenNetworkDialog()
+	filename, err := dialog.PromptForSingleSelection()
+	if err != nil {
+		h.log.error(err)
+		return nil
+	}
+
+	if filename == "" {
+		return nil
+	}
+
+	return h.windows.current().openWindow(filename)
 }
 
 func (h *menuHandler) saveNetwork(_ *application.Context) error {
-	return manager.trySaveNetwork()
+	return h.windows.current().trySaveNetwork()
 }
 
 func (h *menuHandler) saveNetworkAs(_ *application.Context) error {
 	dialog := newSaveNetworkDialog()
 	filename, err := dialog.PromptForSingleSelection()
 	if err != nil {
-		printError(err)
+		h.log.error(err)
 		return nil
 	}
 
-	return manager.saveNetworkAs(filename)
+	return h.windows.current().saveNetworkAs(filename)
 }
 
 func (h *menuHandler) importDBC(_ *application.Context) error {
@@ -87,11 +296,11 @@ func (h *menuHandler) importDBC(_ *application.Context) error {
 
 	path, err := dialog.PromptForSingleSelection()
 	if err != nil {
-		printError(err)
+		h.log.error(err)
 		return nil
 	}
 
-	return manager.importDBC(path)
+	return h.windows.current().importDBC(path)
 }
 
 func (h *menuHandler) exportDBC(_ *application.Context) error {
@@ -101,14 +310,241 @@ func (h *menuHandler) exportDBC(_ *application.Context) error {
 
 	path, err := dialog.PromptForSingleSelection()
 	if err != nil {
-		printError(err)
+		h.log.error(err)
+		return nil
+	}
+
+	return h.windows.current().exportDBC(path)
+}
+
+func (h *menuHandler) exportLibrary(_ *application.Context) error {
+	m := h.windows.current()
+
+	dialog := application.SaveFileDialog()
+
+	dialog.AddFilter("canturin library file", "*"+libraryFileExtension)
+
+	path, err := dialog.PromptForSingleSelection()
+	if err != nil {
+		h.log.error(err)
+		return nil
+	}
+
+	if path == "" {
 		return nil
 	}
 
-	return manager.exportDBC(path)
+	return m.librarySrv.ExportLibrary(context.Background(), ExportLibraryReq{Path: path})
+}
+
+func (h *menuHandler) importLibrary(_ *application.Context) error {
+	m := h.windows.current()
+
+	path, err := newLibraryFileDialog().PromptForSingleSelection()
+	if err != nil {
+		h.log.error(err)
+		return nil
+	}
+
+	if path == "" {
+		return nil
+	}
+
+	_, err = m.librarySrv.ImportLibrary(context.Background(), LibraryFileReq{Path: path})
+	return err
+}
+
+func (h *menuHandler) linkLibrary(_ *application.Context) error {
+	m := h.windows.current()
+
+	path, err := newLibraryFileDialog().PromptForSingleSelection()
+	if err != nil {
+		h.log.error(err)
+		return nil
+	}
+
+	if path == "" {
+		return nil
+	}
+
+	_, err = m.librarySrv.LinkLibrary(context.Background(), LibraryFileReq{Path: path})
+	return err
+}
+
+// updateLibraries shows the changes of the linked library files
+// and pulls them into the network if the user confirms.
+func (h *menuHandler) updateLibraries(_ *application.Context) error {
+	m := h.windows.current()
+
+	changes, err := m.librarySrv.PreviewLibraryUpdates(context.Background())
+	if err != nil {
+		return err
+	}
+
+	if len(changes) == 0 {
+		application.InfoDialog().
+			SetTitle("Update libraries").
+			SetMessage("The linked libraries are up to date.").
+			Show()
+		return nil
+	}
+
+	if !promptLibraryUpdate(changes) {
+		return nil
+	}
+
+	_, err = m.librarySrv.UpdateLinkedLibraries(context.Background())
+	return err
+}
+
+func (h *menuHandler) exportTopology(_ *application.Context) error {
+	m := h.windows.current()
+
+	dialog := application.SaveFileDialog()
+
+	dialog.AddFilter("SVG image", "*.svg")
+	dialog.AddFilter("Graphviz DOT file", "*.dot;*.gv")
+	dialog.AddFilter("Mermaid file", "*.mmd")
+
+	path, err := dialog.PromptForSingleSelection()
+	if err != nil {
+		h.log.error(err)
+		return nil
+	}
+
+	if path == "" {
+		return nil
+	}
+
+	return m.networkSrv.ExportTopology(context.Background(), ExportTopologyReq{
+		Path:       path,
+		EdgeLabels: promptTopologyEdgeLabels(),
+	})
 }
 
 func (h *menuHandler) reload(_ *application.Context) error {
-	manager.reloadNetwork()
+	h.windows.current().reloadNetwork()
+	return nil
+}
+
+func (h *menuHandler) undo(_ *application.Context) error {
+	m := h.windows.current()
+
+	m.historySrv.Undo(context.Background())
+	m.historySrv.emitHistoryChange()
+	return nil
+}
+
+func (h *menuHandler) redo(_ *application.Context) error {
+	m := h.windows.current()
+
+	m.historySrv.Redo(context.Background())
+	m.historySrv.emitHistoryChange()
+	return nil
+}
+
+func (h *menuHandler) runScript(_ *application.Context) error {
+	return h.promptRunScript(false)
+}
+
+func (h *menuHandler) dryRunScript(_ *application.Context) error {
+	return h.promptRunScript(true)
+}
+
+// promptRunScript asks for a script file, runs it and shows the changes it made.
+func (h *menuHandler) promptRunScript(dryRun bool) error {
+	m := h.windows.current()
+
+	dialog := application.OpenFileDialog()
+
+	dialog.AddFilter("Starlark script", "*.star")
+
+	path, err := dialog.PromptForSingleSelection()
+	if err != nil {
+		h.log.error(err)
+		return nil
+	}
+
+	if path == "" {
+		return nil
+	}
+
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	res, err := m.scriptSrv.Run(context.Background(), RunScriptReq{
+		Name:   filepath.Base(path),
+		Source: string(source),
+		DryRun: dryRun,
+	})
+	if err != nil {
+		return fmt.Errorf("%s\n\n%s", err.Error(), res.String())
+	}
+
+	m.emitter.emitEvent(ScriptRun, res)
+
+	title := "Script"
+	if dryRun {
+		title = "Script (dry run)"
+	}
+	application.InfoDialog().SetTitle(title).SetMessage(res.String()).Show()
+
+	return nil
+}
+
+// runPluginCommand runs the command of a plugin, asking for the input file of an import
+// and for the path of a returned file. The messages of the plugin are shown at the end.
+func (h *menuHandler) runPluginCommand(pluginHost *pluginHost, p *plugin, cmd PluginCommand) error {
+	m := h.windows.current()
+
+	inputPath := ""
+	if cmd.Kind == PluginCommandKindImport {
+		dialog := application.OpenFileDialog()
+		dialog.AddFilter(cmd.Title, cmd.filePattern())
+
+		path, err := dialog.PromptForSingleSelection()
+		if err != nil {
+			h.log.error(err)
+			return nil
+		}
+
+		if path == "" {
+			return nil
+		}
+
+		inputPath = path
+	}
+
+	out, err := pluginHost.runCommand(m, p, cmd, inputPath)
+	if err != nil {
+		return err
+	}
+
+	if out.file != nil {
+		dialog := application.SaveFileDialog()
+		dialog.SetFilename(out.file.Name)
+
+		path, err := dialog.PromptForSingleSelection()
+		if err != nil {
+			h.log.error(err)
+			return nil
+		}
+
+		if path != "" {
+			if err := os.WriteFile(path, out.file.Content, 0644); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(out.messages) > 0 {
+		application.InfoDialog().
+			SetTitle(p.desc.Name).
+			SetMessage(strings.Join(out.messages, "\n")).
+			Show()
+	}
+
 	return nil
 }
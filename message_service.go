@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"slices"
 	"strings"
 	"sync"
@@ -179,13 +181,20 @@ type MessageService struct {
 	*service[*acmelib.Message, Message, *messageHandler]
 }
 
-func newMessageService(mux *sync.RWMutex, sidebarCtr *sidebarController, signalCtr *signalController) *MessageService {
+func newMessageService(mux *sync.RWMutex, emitter eventEmitter, sidebarCtr *sidebarController, signalCtr *signalController) *MessageService {
 	return &MessageService{
-		service: newService(serviceKindMessage, newMessageHandler(sidebarCtr, signalCtr), mux, sidebarCtr),
+		service: newService(serviceKindMessage, newMessageHandler(sidebarCtr, signalCtr), mux, emitter, sidebarCtr),
 	}
 }
 
-func (s *MessageService) GetInvalidMessageIDs(entityID string) []uint {
+// forWindow returns the service of the window that made the call.
+func (s *MessageService) forWindow(ctx context.Context) *MessageService {
+	return s.resolve(ctx).messageSrv
+}
+
+func (s *MessageService) GetInvalidMessageIDs(ctx context.Context, entityID string) []uint {
+	s = s.forWindow(ctx)
+
 	s.mux.RLock()
 	defer s.mux.RUnlock()
 
@@ -212,7 +221,35 @@ func (s *MessageService) GetInvalidMessageIDs(entityID string) []uint {
 	return messageIDs
 }
 
-func (s *MessageService) GetInvalidCANIDs(entityID string, busEntityID string) []uint {
+// GetByCANID returns the message of the bus with the given CAN ID,
+// it is used by the tools that read the frames from the bus.
+func (s *MessageService) GetByCANID(ctx context.Context, busEntityID string, canID uint) (Message, error) {
+	s = s.forWindow(ctx)
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	for _, tmpMsg := range s.entities {
+		if uint(tmpMsg.GetCANID()) != canID {
+			continue
+		}
+
+		nodeInt := tmpMsg.SenderNodeInterface()
+		if nodeInt == nil || nodeInt.ParentBus() == nil {
+			continue
+		}
+
+		if nodeInt.ParentBus().EntityID().String() == busEntityID {
+			return s.handler.toResponse(tmpMsg), nil
+		}
+	}
+
+	return Message{}, fmt.Errorf("get by can id: message with can id %d not found", canID)
+}
+
+func (s *MessageService) GetInvalidCANIDs(ctx context.Context, entityID string, busEntityID string) []uint {
+	s = s.forWindow(ctx)
+
 	s.mux.RLock()
 	defer s.mux.RUnlock()
 
@@ -240,7 +277,9 @@ func (s *MessageService) GetInvalidCANIDs(entityID string, busEntityID string) [
 	return canIDs
 }
 
-func (s *MessageService) GetSpaceLeft(entityID string) int {
+func (s *MessageService) GetSpaceLeft(ctx context.Context, entityID string) int {
+	s = s.forWindow(ctx)
+
 	s.mux.RLock()
 	defer s.mux.RUnlock()
 
@@ -257,59 +296,79 @@ func (s *MessageService) GetSpaceLeft(entityID string) int {
 	return spaceLeft
 }
 
-func (s *MessageService) UpdateName(entityID string, req UpdateNameReq) (Message, error) {
+func (s *MessageService) UpdateName(ctx context.Context, entityID string, req UpdateNameReq) (Message, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateName)
 }
 
-func (s *MessageService) UpdateDesc(entityID string, req UpdateDescReq) (Message, error) {
+func (s *MessageService) UpdateDesc(ctx context.Context, entityID string, req UpdateDescReq) (Message, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateDesc)
 }
 
-func (s *MessageService) UpdateMessageID(entityID string, req UpdateMessageIDReq) (Message, error) {
+func (s *MessageService) UpdateMessageID(ctx context.Context, entityID string, req UpdateMessageIDReq) (Message, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateMessageID)
 }
 
-func (s *MessageService) UpdateStaticCANID(entityID string, req UpdateStaticCANIDReq) (Message, error) {
+func (s *MessageService) UpdateStaticCANID(ctx context.Context, entityID string, req UpdateStaticCANIDReq) (Message, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateStaticCANID)
 }
 
-func (s *MessageService) UpdateSizeByte(entityID string, req UpdateSizeByteReq) (Message, error) {
+func (s *MessageService) UpdateSizeByte(ctx context.Context, entityID string, req UpdateSizeByteReq) (Message, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateSizeByte)
 }
 
-func (s *MessageService) UpdateByteOrder(entityID string, req UpdateByteOrderReq) (Message, error) {
+func (s *MessageService) UpdateByteOrder(ctx context.Context, entityID string, req UpdateByteOrderReq) (Message, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateByteOrder)
 }
 
-func (s *MessageService) UpdateCycleTime(entityID string, req UpdateCycleTimeReq) (Message, error) {
+func (s *MessageService) UpdateCycleTime(ctx context.Context, entityID string, req UpdateCycleTimeReq) (Message, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateCycleTime)
 }
 
-func (s *MessageService) UpdateSendType(entityID string, req UpdateSendTypeReq) (Message, error) {
+func (s *MessageService) UpdateSendType(ctx context.Context, entityID string, req UpdateSendTypeReq) (Message, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateSendType)
 }
 
-func (s *MessageService) UpdateDelayTime(entityID string, req UpdateDelayTimeReq) (Message, error) {
+func (s *MessageService) UpdateDelayTime(ctx context.Context, entityID string, req UpdateDelayTimeReq) (Message, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateDelayTime)
 }
 
-func (s *MessageService) UpdateStartDelayTime(entityID string, req UpdateStartDelayTimeReq) (Message, error) {
+func (s *MessageService) UpdateStartDelayTime(ctx context.Context, entityID string, req UpdateStartDelayTimeReq) (Message, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateStartDelayTime)
 }
 
-func (s *MessageService) AddSignal(entityID string, req AddSignalReq) (Message, error) {
+func (s *MessageService) AddSignal(ctx context.Context, entityID string, req AddSignalReq) (Message, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.addSignal)
 }
 
-func (s *MessageService) DeleteSignals(entityID string, req DeleteSignalsReq) (Message, error) {
+func (s *MessageService) DeleteSignals(ctx context.Context, entityID string, req DeleteSignalsReq) (Message, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.deleteSignals)
 }
 
-func (s *MessageService) CompactSignals(entityID string) (Message, error) {
+// MoveSignal moves a signal of another message to the message, keeping the signal entity.
+func (s *MessageService) MoveSignal(ctx context.Context, entityID string, req MoveSignalReq) (Message, error) {
+	s = s.forWindow(ctx)
+	return s.handle(entityID, &req, s.handler.moveSignal)
+}
+
+func (s *MessageService) CompactSignals(ctx context.Context, entityID string) (Message, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, nil, s.handler.compactSignals)
 }
 
-func (s *MessageService) ReorderSignal(entityID string, req ReorderSignalReq) (Message, error) {
+func (s *MessageService) ReorderSignal(ctx context.Context, entityID string, req ReorderSignalReq) (Message, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.reorderSignalHandler)
 }
 
@@ -825,6 +884,116 @@ func (h *messageHandler) deleteSignals(msg *acmelib.Message, req *request, res *
 	return nil
 }
 
+func (h *messageHandler) moveSignal(msg *acmelib.Message, req *request, res *messageRes) error {
+	parsedReq := req.toMoveSignal()
+
+	sig, err := h.signalCtr.get(parsedReq.SignalEntityID)
+	if err != nil {
+		return err
+	}
+
+	oldMsg := sig.ParentMessage()
+	if oldMsg == nil {
+		return fmt.Errorf("move signal: signal %s has no parent message", sig.Name())
+	}
+
+	if oldMsg == msg {
+		return nil
+	}
+
+	oldStartPos := sig.GetStartBit()
+
+	startPos, err := getSignalStartPos(msg, sig.GetSize(), oldStartPos)
+	if err != nil {
+		return err
+	}
+
+	if err := h.moveSignalTo(sig, oldMsg, msg, startPos); err != nil {
+		return err
+	}
+
+	res.setUndo(
+		func() (*acmelib.Message, error) {
+			if err := h.moveSignalTo(sig, msg, oldMsg, oldStartPos); err != nil {
+				return nil, err
+			}
+			return msg, nil
+		},
+	)
+
+	res.setRedo(
+		func() (*acmelib.Message, error) {
+			if err := h.moveSignalTo(sig, oldMsg, msg, startPos); err != nil {
+				return nil, err
+			}
+			return msg, nil
+		},
+	)
+
+	return nil
+}
+
+// moveSignalTo moves the signal from its message to the target one at the given start bit.
+// If the signal cannot be inserted into the target message, it is left unchanged.
+func (h *messageHandler) moveSignalTo(sig acmelib.Signal, from, to *acmelib.Message, startPos int) error {
+	oldStartPos := sig.GetStartBit()
+
+	if err := from.RemoveSignal(sig.EntityID()); err != nil {
+		return err
+	}
+
+	if err := to.InsertSignal(sig, startPos); err != nil {
+		// the signal has just been removed from its position,
+		// so inserting it back cannot fail
+		_ = from.InsertSignal(sig, oldStartPos)
+		return err
+	}
+
+	h.sidebarCtr.sendDelete(sig)
+	h.sidebarCtr.sendAdd(sig)
+
+	return nil
+}
+
+// getSignalStartPos returns the start bit of a signal of the given size moved to the message.
+// The signal keeps its start bit if it is free, otherwise it is placed
+// in the first hole of the payload that fits it.
+func getSignalStartPos(msg *acmelib.Message, size, startPos int) (int, error) {
+	type payloadHole struct {
+		startPos int
+		size     int
+	}
+
+	payloadHoles := []payloadHole{}
+	currPos := 0
+
+	for _, sig := range msg.Signals() {
+		if currPos < sig.GetStartBit() {
+			payloadHoles = append(payloadHoles, payloadHole{startPos: currPos, size: sig.GetStartBit() - currPos})
+		}
+
+		currPos = sig.GetStartBit() + sig.GetSize()
+	}
+
+	if currPos < msg.SizeByte()*8 {
+		payloadHoles = append(payloadHoles, payloadHole{startPos: currPos, size: msg.SizeByte()*8 - currPos})
+	}
+
+	for _, hole := range payloadHoles {
+		if startPos >= hole.startPos && startPos+size <= hole.startPos+hole.size {
+			return startPos, nil
+		}
+	}
+
+	for _, hole := range payloadHoles {
+		if size <= hole.size {
+			return hole.startPos, nil
+		}
+	}
+
+	return 0, fmt.Errorf("move signal: message %s has no space for %d bits", msg.Name(), size)
+}
+
 func (h *messageHandler) compactSignals(msg *acmelib.Message, _ *request, res *messageRes) error {
 	signals := []acmelib.Signal{}
 	startPos := make(map[acmelib.EntityID]int)
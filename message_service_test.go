@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/squadracorsepolito/acmelib"
+)
+
+func TestGetSignalStartPos(t *testing.T) {
+	sigType, err := acmelib.NewIntegerSignalType("uint8_t", 8, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg := acmelib.NewMessage("msg", 1, 3)
+	for idx, name := range []string{"sig_0", "sig_1"} {
+		sig, err := acmelib.NewStandardSignal(name, sigType)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := msg.InsertSignal(sig, idx*12); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// the payload holes are the bits 8-11 and 20-23
+	tests := []struct {
+		name     string
+		size     int
+		startPos int
+		expected int
+	}{
+		{"free start bit", 4, 8, 8},
+		{"taken start bit", 4, 0, 8},
+		{"too small hole", 4, 10, 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			startPos, err := getSignalStartPos(msg, tt.size, tt.startPos)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if startPos != tt.expected {
+				t.Errorf("expected start bit %d, got %d", tt.expected, startPos)
+			}
+		})
+	}
+
+	if _, err := getSignalStartPos(msg, 5, 0); err == nil {
+		t.Error("a signal bigger than every hole must be rejected")
+	}
+}
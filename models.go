@@ -1,6 +1,7 @@
 package main
 
 import (
+	"strings"
 	"time"
 
 	"github.com/squadracorsepolito/acmelib"
@@ -193,3 +194,18 @@ func newSignalEntityPaths(sig acmelib.Signal) []EntityPath {
 
 	return res
 }
+
+// formatEntityPaths returns the given paths as a slash separated string
+// of entity names, the network is omitted.
+func formatEntityPaths(paths []EntityPath) string {
+	names := []string{}
+	for _, path := range paths {
+		if path.Kind == EntityKindNetwork {
+			continue
+		}
+
+		names = append(names, path.Name)
+	}
+
+	return strings.Join(names, "/")
+}
@@ -1,11 +1,12 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"os"
 	"sync"
 
 	"github.com/squadracorsepolito/acmelib"
-	"github.com/wailsapp/wails/v3/pkg/application"
 )
 
 type Network struct {
@@ -33,6 +34,8 @@ func newNetwork(net *acmelib.Network) Network {
 }
 
 type NetworkService struct {
+	windowRouted
+
 	handler *networkHandler
 
 	mux     *sync.RWMutex
@@ -40,9 +43,14 @@ type NetworkService struct {
 
 	sidebarCtr *sidebarController
 	historyCtr *historyController
+
+	emitter eventEmitter
+
+	// manager handles the operations on the network file (e.g. open and save)
+	manager *serviceManager
 }
 
-func newNetworkService(handler *networkHandler, mux *sync.RWMutex, sidebarCtr *sidebarController, historyCtr *historyController) *NetworkService {
+func newNetworkService(handler *networkHandler, mux *sync.RWMutex, emitter eventEmitter, sidebarCtr *sidebarController, historyCtr *historyController) *NetworkService {
 	return &NetworkService{
 		handler: handler,
 
@@ -51,9 +59,16 @@ func newNetworkService(handler *networkHandler, mux *sync.RWMutex, sidebarCtr *s
 
 		sidebarCtr: sidebarCtr,
 		historyCtr: historyCtr,
+
+		emitter: emitter,
 	}
 }
 
+func (s *NetworkService) setManager(manager *serviceManager) {
+	s.manager = manager
+	s.handler.manager = manager
+}
+
 func (s *NetworkService) load(net *acmelib.Network) {
 	s.mux.Lock()
 	defer s.mux.Unlock()
@@ -61,7 +76,7 @@ func (s *NetworkService) load(net *acmelib.Network) {
 	s.network = net
 	s.sidebarCtr.sendLoad(net)
 
-	application.Get().EmitEvent(NetworkLoaded)
+	s.emitter.emitEvent(NetworkLoaded)
 }
 
 func (s *NetworkService) clear() {
@@ -113,40 +128,131 @@ func (s *NetworkService) handle(reqDataPtr any, handlerFn func(*acmelib.Network,
 	return s.handler.toResponse(s.network), nil
 }
 
-func (s *NetworkService) Create() {
-	manager.createNetwork()
+// forWindow returns the service of the window that made the call.
+func (s *NetworkService) forWindow(ctx context.Context) *NetworkService {
+	return s.resolve(ctx).networkSrv
+}
+
+func (s *NetworkService) Create(ctx context.Context) {
+	s = s.forWindow(ctx)
+
+	if err := s.manager.createNetwork(); err != nil {
+		s.manager.log.error(err)
+	}
+}
+
+func (s *NetworkService) Load(ctx context.Context, path string) error {
+	s = s.forWindow(ctx)
+	return s.manager.openNetwork(path)
+}
+
+// OpenInNewWindow opens the network in a new window,
+// a new empty window is opened if the path is empty.
+func (s *NetworkService) OpenInNewWindow(ctx context.Context, path string) error {
+	s = s.forWindow(ctx)
+	return s.manager.openWindow(path)
+}
+
+// Copy copies a bus or a message into the clipboard shared by all the windows.
+func (s *NetworkService) Copy(ctx context.Context, entityID string) error {
+	s = s.forWindow(ctx)
+	return s.manager.copyEntity(entityID)
+}
+
+func (s *NetworkService) PasteBus(ctx context.Context) error {
+	s = s.forWindow(ctx)
+	return s.manager.pasteBus()
+}
+
+func (s *NetworkService) PasteMessage(ctx context.Context, req PasteMessageReq) error {
+	s = s.forWindow(ctx)
+	return s.manager.pasteMessage(req)
+}
+
+// ReloadFromDisk replaces the network with the one in its file,
+// it is used after the file has been changed by another program.
+func (s *NetworkService) ReloadFromDisk(ctx context.Context) error {
+	s = s.forWindow(ctx)
+	return s.manager.reloadFromDisk()
 }
 
-func (s *NetworkService) Load(path string) error {
-	return manager.openNetwork(path)
+// KeepCurrent ignores the changes made to the file by another program,
+// the next save overwrites them.
+func (s *NetworkService) KeepCurrent(ctx context.Context) {
+	s = s.forWindow(ctx)
+	s.manager.keepCurrentNetwork()
 }
 
-func (s *NetworkService) Get() Network {
+// GetFileDiff returns the differences between the network and its file.
+func (s *NetworkService) GetFileDiff(ctx context.Context) ([]NetworkDiffLine, error) {
+	s = s.forWindow(ctx)
+	return s.manager.getFileDiff()
+}
+
+// ExportTopology writes the wiring diagram of the network to the file of the request,
+// the format (Graphviz DOT, Mermaid or SVG) is chosen by the file extension.
+func (s *NetworkService) ExportTopology(ctx context.Context, req ExportTopologyReq) error {
+	s = s.forWindow(ctx)
+
+	format, err := getTopologyFormat(req.Path)
+	if err != nil {
+		return err
+	}
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	if s.network == nil {
+		return errors.New("network not loaded")
+	}
+
+	topology, err := newTopology(s.network)
+	if err != nil {
+		return err
+	}
+
+	content, err := topology.render(format, req.EdgeLabels)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(req.Path, []byte(content), 0644)
+}
+
+func (s *NetworkService) Get(ctx context.Context) Network {
+	s = s.forWindow(ctx)
+
 	s.mux.RLock()
 	defer s.mux.RUnlock()
 
 	return s.handler.toResponse(s.network)
 }
 
-func (s *NetworkService) UpdateName(req UpdateNameReq) (Network, error) {
+func (s *NetworkService) UpdateName(ctx context.Context, req UpdateNameReq) (Network, error) {
+	s = s.forWindow(ctx)
 	return s.handle(&req, s.handler.updateName)
 }
 
-func (s *NetworkService) UpdateDesc(req UpdateDescReq) (Network, error) {
+func (s *NetworkService) UpdateDesc(ctx context.Context, req UpdateDescReq) (Network, error) {
+	s = s.forWindow(ctx)
 	return s.handle(&req, s.handler.updateDesc)
 }
 
-func (s *NetworkService) AddBus() (Network, error) {
+func (s *NetworkService) AddBus(ctx context.Context) (Network, error) {
+	s = s.forWindow(ctx)
 	return s.handle(nil, s.handler.addBus)
 }
 
-func (s *NetworkService) DeleteBuses(req DeleteBusesReq) (Network, error) {
+func (s *NetworkService) DeleteBuses(ctx context.Context, req DeleteBusesReq) (Network, error) {
+	s = s.forWindow(ctx)
 	return s.handle(&req, s.handler.deleteBuses)
 }
 
 type networkHandler struct {
 	sidebarCtr *sidebarController
 	busCtr     *busController
+
+	manager *serviceManager
 }
 
 func newNetworkHandler(sidebarCtr *sidebarController, busCtr *busController) *networkHandler {
@@ -173,15 +279,16 @@ func (h *networkHandler) updateName(net *acmelib.Network, req *request, res *net
 
 	h.sidebarCtr.sendUpdateName(net)
 
-	netPath := manager.filePath
-	manager.settingsSrv.renameRecentNetwork(netPath, name)
+	// the file path is protected by the mutex held by the handler
+	netPath := h.manager.filePath
+	h.manager.settingsSrv.renameRecentNetwork(netPath, name)
 
 	res.setUndo(
 		func() error {
 			net.UpdateName(oldName)
 
 			h.sidebarCtr.sendUpdateName(net)
-			manager.settingsSrv.renameRecentNetwork(netPath, oldName)
+			h.manager.settingsSrv.renameRecentNetwork(netPath, oldName)
 
 			return nil
 		},
@@ -192,7 +299,7 @@ func (h *networkHandler) updateName(net *acmelib.Network, req *request, res *net
 			net.UpdateName(name)
 
 			h.sidebarCtr.sendUpdateName(net)
-			manager.settingsSrv.renameRecentNetwork(netPath, name)
+			h.manager.settingsSrv.renameRecentNetwork(netPath, name)
 
 			return nil
 		},
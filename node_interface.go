@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+
+	"github.com/squadracorsepolito/acmelib"
+	acmelibv1 "github.com/squadracorsepolito/acmelib/proto/gen/go/acmelib/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// The interfaces of a node cannot be removed in place, since acmelib renumbers
+// the remaining interfaces without dropping the removed one from the node.
+// So the interfaces are changed by replacing the node with a copy that has
+// the same entity and the new count of interfaces. The buses, the messages and
+// the attributes of the interfaces are moved to the copy, and they are moved back
+// to the original node by the undo. An interface is added in the same way,
+// so that the undo can give back the original node.
+
+// nodeInterfaceState is what is attached to a node interface.
+type nodeInterfaceState struct {
+	bus              *acmelib.Bus
+	sentMessages     []*acmelib.Message
+	receivedMessages []*acmelib.Message
+}
+
+// detachNodeInterface removes the messages and the bus from the node interface
+// and returns them. If it fails, the node interface is left unchanged.
+func detachNodeInterface(nodeInt *acmelib.NodeInterface) (nodeInterfaceState, error) {
+	state := nodeInterfaceState{
+		bus:              nodeInt.ParentBus(),
+		sentMessages:     nodeInt.SentMessages(),
+		receivedMessages: nodeInt.ReceivedMessages(),
+	}
+
+	// the removed messages are added back if a removal fails
+	detached := nodeInterfaceState{}
+	rollback := func() {
+		_ = detached.attachTo(nodeInt)
+	}
+
+	for _, tmpMsg := range state.sentMessages {
+		if err := nodeInt.RemoveSentMessage(tmpMsg.EntityID()); err != nil {
+			rollback()
+			return state, err
+		}
+		detached.sentMessages = append(detached.sentMessages, tmpMsg)
+	}
+
+	for _, tmpMsg := range state.receivedMessages {
+		if err := nodeInt.RemoveReceivedMessage(tmpMsg.EntityID()); err != nil {
+			rollback()
+			return state, err
+		}
+		detached.receivedMessages = append(detached.receivedMessages, tmpMsg)
+	}
+
+	if state.bus != nil {
+		if err := state.bus.RemoveNodeInterface(nodeInt.Node().EntityID()); err != nil {
+			rollback()
+			return state, err
+		}
+	}
+
+	return state, nil
+}
+
+func (s nodeInterfaceState) attachTo(nodeInt *acmelib.NodeInterface) error {
+	if s.bus != nil {
+		if err := s.bus.AddNodeInterface(nodeInt); err != nil {
+			return err
+		}
+	}
+
+	for _, tmpMsg := range s.sentMessages {
+		if err := nodeInt.AddSentMessage(tmpMsg); err != nil {
+			return err
+		}
+	}
+
+	for _, tmpMsg := range s.receivedMessages {
+		if err := nodeInt.AddReceivedMessage(tmpMsg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rebuildNode returns a node with the same entity and ID of the given one,
+// but with the given count of interfaces. The attributes are not copied,
+// they are moved by moveNodeInterfaces.
+func rebuildNode(node *acmelib.Node, interfaceCount int) (*acmelib.Node, error) {
+	if interfaceCount < 1 {
+		return nil, errors.New("a node must have at least one interface")
+	}
+
+	// acmelib keeps the entity id only when an entity is loaded,
+	// so the node is loaded from a network where it is attached to a placeholder bus
+	pNode := &acmelibv1.Node{
+		Entity:         saveProtoEntity(node, acmelibv1.EntityKind_ENTITY_KIND_NODE),
+		NodeId:         uint32(node.ID()),
+		InterfaceCount: uint32(interfaceCount),
+	}
+
+	pBus := &acmelibv1.Bus{
+		Entity:         saveProtoEntity(acmelib.NewBus("placeholder"), acmelibv1.EntityKind_ENTITY_KIND_BUS),
+		NodeInterfaces: []*acmelibv1.NodeInterface{{NodeEntityId: pNode.Entity.EntityId}},
+	}
+
+	pNet := &acmelibv1.Network{
+		Entity: saveProtoEntity(acmelib.NewNetwork("placeholder"), acmelibv1.EntityKind_ENTITY_KIND_NETWORK),
+		Buses:  []*acmelibv1.Bus{pBus},
+		Nodes:  []*acmelibv1.Node{pNode},
+	}
+
+	buf, err := proto.Marshal(pNet)
+	if err != nil {
+		return nil, err
+	}
+
+	net, err := acmelib.LoadNetwork(bytes.NewReader(buf), acmelib.SaveEncodingWire)
+	if err != nil {
+		return nil, err
+	}
+
+	bus := net.Buses()[0]
+	res := bus.NodeInterfaces()[0].Node()
+
+	if err := bus.RemoveNodeInterface(res.EntityID()); err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// moveNodeInterfaces moves the interfaces and the attributes of the node to the other one.
+// The interfaces are detached from the node and the mapStates function returns
+// the states to attach to the interfaces of the other node, ordered by interface number.
+// If it fails, the interfaces already moved are given back to the node.
+func moveNodeInterfaces(from, to *acmelib.Node, mapStates func(states []nodeInterfaceState) []nodeInterfaceState) error {
+	fromInterfaces := from.Interfaces()
+	toInterfaces := to.Interfaces()
+
+	states := []nodeInterfaceState{}
+	attachedCount := 0
+
+	// the interfaces were attached to the node before, so they can be attached back
+	rollback := func() {
+		for _, nodeInt := range toInterfaces[:attachedCount] {
+			_, _ = detachNodeInterface(nodeInt)
+		}
+
+		for idx, state := range states {
+			_ = state.attachTo(fromInterfaces[idx])
+		}
+	}
+
+	for _, nodeInt := range fromInterfaces {
+		state, err := detachNodeInterface(nodeInt)
+		if err != nil {
+			rollback()
+			return err
+		}
+		states = append(states, state)
+	}
+
+	for idx, state := range mapStates(slices.Clone(states)) {
+		// the interface is detached by the rollback even if it is attached only in part
+		attachedCount = idx + 1
+
+		if err := state.attachTo(toInterfaces[idx]); err != nil {
+			rollback()
+			return err
+		}
+	}
+
+	assignedAttributes := []acmelib.EntityID{}
+	for _, attAss := range from.AttributeAssignments() {
+		if err := to.AssignAttribute(attAss.Attribute(), attAss.Value()); err != nil {
+			for _, attEntID := range assignedAttributes {
+				_ = to.RemoveAttributeAssignment(attEntID)
+			}
+			rollback()
+			return err
+		}
+		assignedAttributes = append(assignedAttributes, attAss.Attribute().EntityID())
+	}
+	from.RemoveAllAttributeAssignments()
+
+	return nil
+}
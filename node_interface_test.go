@@ -0,0 +1,194 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/squadracorsepolito/acmelib"
+)
+
+func TestRemoveNodeInterface(t *testing.T) {
+	busA := acmelib.NewBus("bus_a")
+	busB := acmelib.NewBus("bus_b")
+
+	node := acmelib.NewNode("node", 1, 3)
+	other := acmelib.NewNode("other", 2, 1)
+
+	if err := busA.AddNodeInterface(node.Interfaces()[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := busB.AddNodeInterface(node.Interfaces()[2]); err != nil {
+		t.Fatal(err)
+	}
+	if err := busB.AddNodeInterface(other.Interfaces()[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	sentMsg := acmelib.NewMessage("sent", 1, 8)
+	if err := node.Interfaces()[2].AddSentMessage(sentMsg); err != nil {
+		t.Fatal(err)
+	}
+
+	receivedMsg := acmelib.NewMessage("received", 1, 8)
+	if err := other.Interfaces()[0].AddSentMessage(receivedMsg); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.Interfaces()[2].AddReceivedMessage(receivedMsg); err != nil {
+		t.Fatal(err)
+	}
+
+	newNode, err := rebuildNode(node, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if newNode.EntityID() != node.EntityID() || newNode.ID() != node.ID() || len(newNode.Interfaces()) != 2 {
+		t.Fatal("the rebuilt node must keep the entity and get the new count of interfaces")
+	}
+
+	var removedState nodeInterfaceState
+	err = moveNodeInterfaces(node, newNode, func(states []nodeInterfaceState) []nodeInterfaceState {
+		removedState = states[1]
+		return slices.Delete(states, 1, 2)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	movedInt := newNode.Interfaces()[1]
+	if movedInt.ParentBus() != busB || sentMsg.SenderNodeInterface() != movedInt {
+		t.Error("the last interface must be renumbered and keep its bus and messages")
+	}
+	if !slices.Contains(movedInt.ReceivedMessages(), receivedMsg) {
+		t.Error("the renumbered interface must keep its received messages")
+	}
+	if newNode.Interfaces()[0].ParentBus() != busA {
+		t.Error("the first interface must keep its bus")
+	}
+
+	for _, nodeInt := range node.Interfaces() {
+		if nodeInt.ParentBus() != nil {
+			t.Error("the replaced node must be detached from the buses")
+		}
+	}
+
+	// the undo moves the interfaces back to the original node
+	err = moveNodeInterfaces(newNode, node, func(states []nodeInterfaceState) []nodeInterfaceState {
+		return slices.Insert(states, 1, removedState)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if node.Interfaces()[2].ParentBus() != busB || sentMsg.SenderNodeInterface() != node.Interfaces()[2] {
+		t.Error("the undo must restore the interface of the original node")
+	}
+}
+
+func TestMoveNodeInterfacesRollback(t *testing.T) {
+	busA := acmelib.NewBus("bus_a")
+	busB := acmelib.NewBus("bus_b")
+
+	node := acmelib.NewNode("node", 1, 2)
+	other := acmelib.NewNode("other", 2, 1)
+
+	if err := busA.AddNodeInterface(node.Interfaces()[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := busB.AddNodeInterface(node.Interfaces()[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := busB.AddNodeInterface(other.Interfaces()[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	sentMsg := acmelib.NewMessage("sent", 1, 8)
+	if err := node.Interfaces()[0].AddSentMessage(sentMsg); err != nil {
+		t.Fatal(err)
+	}
+
+	// the second interface cannot be attached to bus_b, since the node id is taken
+	to := acmelib.NewNode("to", 2, 2)
+
+	err := moveNodeInterfaces(node, to, func(states []nodeInterfaceState) []nodeInterfaceState {
+		return states
+	})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if node.Interfaces()[0].ParentBus() != busA || node.Interfaces()[1].ParentBus() != busB {
+		t.Error("the interfaces must be attached back to their buses")
+	}
+	if sentMsg.SenderNodeInterface() != node.Interfaces()[0] {
+		t.Error("the sent messages must be given back to the node")
+	}
+
+	for _, nodeInt := range to.Interfaces() {
+		if nodeInt.ParentBus() != nil || len(nodeInt.SentMessages()) > 0 {
+			t.Error("the other node must be left unchanged")
+		}
+	}
+}
+
+func TestNodeInterfaceUndo(t *testing.T) {
+	m, _ := newTestManager(t)
+
+	loadDuplicateSignalTypes(t, m)
+
+	m.mux.RLock()
+	node := m.nodeSrv.listEntities()[0]
+	bus := m.network.Buses()[0]
+	m.mux.RUnlock()
+
+	nodeEntID := node.EntityID().String()
+	before := dumpServices(m)
+
+	// the node is renamed before it is rebuilt by the interface changes
+	if _, err := m.nodeSrv.UpdateName(t.Context(), nodeEntID, UpdateNameReq{Name: "renamed"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.nodeSrv.AddInterface(t.Context(), nodeEntID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.nodeSrv.RemoveInterface(t.Context(), nodeEntID, RemoveInterfaceReq{InterfaceNumber: 0}); err != nil {
+		t.Fatal(err)
+	}
+	waitHistory(t, m, 3)
+	waitServices(m)
+
+	m.mux.RLock()
+	msgCount := len(m.messageSrv.listEntities())
+	sigCount := len(m.signalSrv.listEntities())
+	m.mux.RUnlock()
+
+	if msgCount != 0 || sigCount != 0 {
+		t.Errorf("the sent messages of the removed interface must be deleted, got %d messages and %d signals", msgCount, sigCount)
+	}
+
+	for range 3 {
+		if _, err := m.historySrv.Undo(t.Context()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitServices(m)
+
+	if after := dumpServices(m); after != before {
+		t.Errorf("the undo must restore the node, got:\n%s\nwant:\n%s", after, before)
+	}
+
+	m.mux.RLock()
+	netNode := bus.NodeInterfaces()[0].Node()
+	srvNode, err := m.nodeSrv.getEntity(nodeEntID)
+	m.mux.RUnlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if netNode != srvNode {
+		t.Error("the node of the service must be the one attached to the bus")
+	}
+	if netNode.Name() != "node" {
+		t.Errorf("the rename must be undone on the node attached to the bus, got %s", netNode.Name())
+	}
+}
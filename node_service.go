@@ -1,6 +1,9 @@
 package main
 
 import (
+	"context"
+	"fmt"
+	"slices"
 	"strings"
 	"sync"
 
@@ -38,6 +41,16 @@ func getNodeInterface(nodeInt *acmelib.NodeInterface) NodeInterface {
 	return res
 }
 
+// NodeInterfaceImpact is what is lost by removing a node interface:
+// its bus attachment, its sent messages, that are deleted, and its received messages.
+type NodeInterfaceImpact struct {
+	NodeInterface
+
+	// RenumberedInterfaces are the numbers of the interfaces
+	// that are decreased by one after the removal.
+	RenumberedInterfaces []int `json:"renumberedInterfaces"`
+}
+
 type Node struct {
 	base
 
@@ -49,13 +62,20 @@ type NodeService struct {
 	*service[*acmelib.Node, Node, *nodeHandler]
 }
 
-func newNodeService(mux *sync.RWMutex, sidebar *sidebarController, bus *BusService, messageCtr *messageController) *NodeService {
+func newNodeService(mux *sync.RWMutex, emitter eventEmitter, sidebar *sidebarController, bus *BusService, messageCtr *messageController, signalCtr *signalController) *NodeService {
 	return &NodeService{
-		service: newService(serviceKindNode, newNodeHandler(sidebar, bus, messageCtr), mux, sidebar),
+		service: newService(serviceKindNode, newNodeHandler(sidebar, bus, messageCtr, signalCtr), mux, emitter, sidebar),
 	}
 }
 
-func (s *NodeService) GetInvalidNodeIDs(entityID string) []uint {
+// forWindow returns the service of the window that made the call.
+func (s *NodeService) forWindow(ctx context.Context) *NodeService {
+	return s.resolve(ctx).nodeSrv
+}
+
+func (s *NodeService) GetInvalidNodeIDs(ctx context.Context, entityID string) []uint {
+	s = s.forWindow(ctx)
+
 	s.mux.RLock()
 	defer s.mux.RUnlock()
 
@@ -72,7 +92,9 @@ func (s *NodeService) GetInvalidNodeIDs(entityID string) []uint {
 	return nodeIDs
 }
 
-func (s *NodeService) Create(req CreateNodeReq) (Node, error) {
+func (s *NodeService) Create(ctx context.Context, req CreateNodeReq) (Node, error) {
+	s = s.forWindow(ctx)
+
 	s.mux.Lock()
 	defer s.mux.Unlock()
 
@@ -112,7 +134,9 @@ func (s *NodeService) Create(req CreateNodeReq) (Node, error) {
 	return s.handler.toResponse(node), nil
 }
 
-func (s *NodeService) Delete(entityID string) error {
+func (s *NodeService) Delete(ctx context.Context, entityID string) error {
+	s = s.forWindow(ctx)
+
 	s.mux.Lock()
 	defer s.mux.Unlock()
 
@@ -175,31 +199,211 @@ func (s *NodeService) Delete(entityID string) error {
 	return nil
 }
 
-func (s *NodeService) UpdateName(entityID string, req UpdateNameReq) (Node, error) {
+// AddInterface adds an interface to the node, it gets the next interface number.
+func (s *NodeService) AddInterface(ctx context.Context, entityID string) (Node, error) {
+	s = s.forWindow(ctx)
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	node, err := s.getEntity(entityID)
+	if err != nil {
+		return Node{}, err
+	}
+
+	intCount := len(node.Interfaces())
+
+	newNode, err := rebuildNode(node, intCount+1)
+	if err != nil {
+		return Node{}, err
+	}
+
+	addInterface := func(states []nodeInterfaceState) []nodeInterfaceState {
+		return append(states, nodeInterfaceState{})
+	}
+
+	removeInterface := func(states []nodeInterfaceState) []nodeInterfaceState {
+		return states[:intCount]
+	}
+
+	if err := s.replaceNode(node, newNode, addInterface); err != nil {
+		return Node{}, err
+	}
+
+	s.sendHistoryOp(
+		func() (*acmelib.Node, error) {
+			if err := s.replaceNode(newNode, node, removeInterface); err != nil {
+				return nil, err
+			}
+			return node, nil
+		},
+		func() (*acmelib.Node, error) {
+			if err := s.replaceNode(node, newNode, addInterface); err != nil {
+				return nil, err
+			}
+			return newNode, nil
+		},
+	)
+
+	return s.handler.toResponse(newNode), nil
+}
+
+// PreviewRemoveInterface returns the impact of removing the interface from the node.
+func (s *NodeService) PreviewRemoveInterface(ctx context.Context, entityID string, req RemoveInterfaceReq) (NodeInterfaceImpact, error) {
+	s = s.forWindow(ctx)
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	node, err := s.getEntity(entityID)
+	if err != nil {
+		return NodeInterfaceImpact{}, err
+	}
+
+	nodeInt, err := node.GetInterface(req.InterfaceNumber)
+	if err != nil {
+		return NodeInterfaceImpact{}, err
+	}
+
+	res := NodeInterfaceImpact{
+		NodeInterface:        getNodeInterface(nodeInt),
+		RenumberedInterfaces: []int{},
+	}
+
+	for _, tmpNodeInt := range node.Interfaces() {
+		if tmpNodeInt.Number() > req.InterfaceNumber {
+			res.RenumberedInterfaces = append(res.RenumberedInterfaces, tmpNodeInt.Number())
+		}
+	}
+
+	return res, nil
+}
+
+// RemoveInterface removes the interface from the node. The interface is detached from its bus,
+// its sent messages are deleted and the interfaces that follow it are renumbered.
+func (s *NodeService) RemoveInterface(ctx context.Context, entityID string, req RemoveInterfaceReq) (Node, error) {
+	s = s.forWindow(ctx)
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	node, err := s.getEntity(entityID)
+	if err != nil {
+		return Node{}, err
+	}
+
+	intNum := req.InterfaceNumber
+	if _, err := node.GetInterface(intNum); err != nil {
+		return Node{}, err
+	}
+
+	newNode, err := rebuildNode(node, len(node.Interfaces())-1)
+	if err != nil {
+		return Node{}, err
+	}
+
+	// the removed interface is restored by the undo
+	var removedState nodeInterfaceState
+
+	removeInterface := func(states []nodeInterfaceState) []nodeInterfaceState {
+		removedState = states[intNum]
+		return slices.Delete(states, intNum, intNum+1)
+	}
+
+	restoreInterface := func(states []nodeInterfaceState) []nodeInterfaceState {
+		return slices.Insert(states, intNum, removedState)
+	}
+
+	if err := s.replaceNode(node, newNode, removeInterface); err != nil {
+		return Node{}, err
+	}
+
+	// the sent messages of the removed interface are deleted with it
+	deletedMessages := s.handler.deleteMessages(removedState.sentMessages)
+
+	s.sendHistoryOp(
+		func() (*acmelib.Node, error) {
+			// the messages are restored while they have no sender,
+			// so they are added to the sidebar with the node
+			s.handler.restoreMessages(deletedMessages)
+
+			if err := s.replaceNode(newNode, node, restoreInterface); err != nil {
+				return nil, err
+			}
+			return node, nil
+		},
+		func() (*acmelib.Node, error) {
+			if err := s.replaceNode(node, newNode, removeInterface); err != nil {
+				return nil, err
+			}
+
+			s.handler.deleteMessages(deletedMessages)
+
+			return newNode, nil
+		},
+	)
+
+	return s.handler.toResponse(newNode), nil
+}
+
+// replaceNode replaces the node with the other one, which has the same entity,
+// by moving the interfaces as returned by the mapStates function.
+// If it fails, the node is left unchanged.
+func (s *NodeService) replaceNode(from, to *acmelib.Node, mapStates func(states []nodeInterfaceState) []nodeInterfaceState) error {
+	s.sidebarCtr.sendDelete(from)
+
+	if err := moveNodeInterfaces(from, to, mapStates); err != nil {
+		s.sidebarCtr.sendAdd(from)
+		return err
+	}
+
+	s.removeEntity(from.EntityID().String())
+	s.addEntity(to)
+
+	s.sidebarCtr.sendAdd(to)
+
+	return nil
+}
+
+func (s *NodeService) UpdateName(ctx context.Context, entityID string, req UpdateNameReq) (Node, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateName)
 }
 
-func (s *NodeService) UpdateDesc(entityID string, req UpdateDescReq) (Node, error) {
+func (s *NodeService) UpdateDesc(ctx context.Context, entityID string, req UpdateDescReq) (Node, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateDesc)
 }
 
-func (s *NodeService) UpdateNodeID(entityID string, req UpdateNodeIDReq) (Node, error) {
+func (s *NodeService) UpdateNodeID(ctx context.Context, entityID string, req UpdateNodeIDReq) (Node, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateNodeID)
 }
 
-func (s *NodeService) UpdateAttachedBus(entityID string, req UpdateAttachedBusReq) (Node, error) {
+func (s *NodeService) UpdateAttachedBus(ctx context.Context, entityID string, req UpdateAttachedBusReq) (Node, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateAttachedBus)
 }
 
-func (s *NodeService) AddSentMessage(entityID string, req AddSentMessageReq) (Node, error) {
+func (s *NodeService) AddSentMessage(ctx context.Context, entityID string, req AddSentMessageReq) (Node, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.addSentMessage)
 }
 
-func (s *NodeService) RemoveSentMessages(entityID string, req RemoveSentMessagesReq) (Node, error) {
+func (s *NodeService) RemoveSentMessages(ctx context.Context, entityID string, req RemoveSentMessagesReq) (Node, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.removeSentMessages)
 }
 
-func (s *NodeService) RemoveReceivedMessages(entityID string, req RemoveReceivedMessagesReq) (Node, error) {
+// MoveSentMessage moves a message sent by another node interface
+// to the given interface of the node, keeping the message entity.
+func (s *NodeService) MoveSentMessage(ctx context.Context, entityID string, req MoveSentMessageReq) (Node, error) {
+	s = s.forWindow(ctx)
+	return s.handle(entityID, &req, s.handler.moveSentMessage)
+}
+
+func (s *NodeService) RemoveReceivedMessages(ctx context.Context, entityID string, req RemoveReceivedMessagesReq) (Node, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.removeReceivedMessages)
 }
 
@@ -210,14 +414,16 @@ type nodeHandler struct {
 
 	bus        *BusService
 	messageCtr *messageController
+	signalCtr  *signalController
 }
 
-func newNodeHandler(sidebar *sidebarController, bus *BusService, messageCtr *messageController) *nodeHandler {
+func newNodeHandler(sidebar *sidebarController, bus *BusService, messageCtr *messageController, signalCtr *signalController) *nodeHandler {
 	return &nodeHandler{
 		commonServiceHandler: newCommonServiceHandler(sidebar),
 
 		bus:        bus,
 		messageCtr: messageCtr,
+		signalCtr:  signalCtr,
 	}
 }
 
@@ -450,7 +656,13 @@ func (h *nodeHandler) addSentMessage(node *acmelib.Node, req *request, res *node
 		}
 	}
 
-	msg := acmelib.NewMessage(getNewName("message", takenNames), msgID, 8)
+	prefs := getPreferences()
+
+	msg := acmelib.NewMessage(getNewName("message", takenNames), msgID, prefs.DefaultMessageSizeByte)
+	msg.SetByteOrder(prefs.DefaultByteOrder.parse())
+	if prefs.DefaultCycleTime > 0 {
+		msg.SetCycleTime(prefs.DefaultCycleTime)
+	}
 
 	if err := nodeInt.AddSentMessage(msg); err != nil {
 		return err
@@ -551,6 +763,133 @@ func (h *nodeHandler) removeSentMessages(node *acmelib.Node, req *request, res *
 	return nil
 }
 
+func (h *nodeHandler) moveSentMessage(node *acmelib.Node, req *request, res *nodeRes) error {
+	parsedReq := req.toMoveSentMessage()
+
+	nodeInt, err := node.GetInterface(parsedReq.InterfaceNumber)
+	if err != nil {
+		return err
+	}
+
+	msg, err := h.messageCtr.get(parsedReq.MessageEntityID)
+	if err != nil {
+		return err
+	}
+
+	oldNodeInt := msg.SenderNodeInterface()
+	if oldNodeInt == nil {
+		return fmt.Errorf("move sent message: message %s has no sender node", msg.Name())
+	}
+
+	if oldNodeInt == nodeInt {
+		return nil
+	}
+
+	if err := h.moveMessage(msg, oldNodeInt, nodeInt); err != nil {
+		return err
+	}
+
+	res.setUndo(
+		func() (*acmelib.Node, error) {
+			if err := h.moveMessage(msg, nodeInt, oldNodeInt); err != nil {
+				return nil, err
+			}
+			return node, nil
+		},
+	)
+
+	res.setRedo(
+		func() (*acmelib.Node, error) {
+			if err := h.moveMessage(msg, oldNodeInt, nodeInt); err != nil {
+				return nil, err
+			}
+			return node, nil
+		},
+	)
+
+	return nil
+}
+
+// moveMessage moves the message from the sender interface to the target one.
+// If the message cannot be sent by the target interface, it is left unchanged.
+func (h *nodeHandler) moveMessage(msg *acmelib.Message, from, to *acmelib.NodeInterface) error {
+	for _, recNodeInt := range msg.Receivers() {
+		if recNodeInt == to {
+			return fmt.Errorf("move sent message: message %s is received by node %s", msg.Name(), to.Node().Name())
+		}
+	}
+
+	if err := from.RemoveSentMessage(msg.EntityID()); err != nil {
+		return err
+	}
+
+	// the message has just been removed from the sender interface,
+	// so adding it back cannot fail
+	rollback := func() {
+		_ = to.RemoveSentMessage(msg.EntityID())
+		_ = from.AddSentMessage(msg)
+	}
+
+	if err := to.AddSentMessage(msg); err != nil {
+		_ = from.AddSentMessage(msg)
+		return err
+	}
+
+	// the message ids are unique only within the interface,
+	// but the can id must be unique within the bus
+	if bus := to.ParentBus(); bus != nil {
+		canID := msg.GetCANID()
+
+		for _, tmpNodeInt := range bus.NodeInterfaces() {
+			for _, tmpMsg := range tmpNodeInt.SentMessages() {
+				if tmpMsg != msg && tmpMsg.GetCANID() == canID {
+					rollback()
+					return fmt.Errorf("move sent message: can id %d is already used by message %s", canID, tmpMsg.Name())
+				}
+			}
+		}
+	}
+
+	h.sidebarCtr.sendDelete(msg)
+	h.sidebarCtr.sendAdd(msg)
+
+	return nil
+}
+
+// deleteMessages removes the messages and their signals from the services and the sidebar,
+// it returns the messages that were held by the message service.
+// The caller must hold the service mutex.
+func (h *nodeHandler) deleteMessages(messages []*acmelib.Message) []*acmelib.Message {
+	deleted := []*acmelib.Message{}
+
+	for _, tmpMsg := range messages {
+		if _, err := h.messageCtr.get(tmpMsg.EntityID().String()); err != nil {
+			continue
+		}
+
+		for _, sig := range tmpMsg.Signals() {
+			h.signalCtr.remove(sig)
+		}
+		h.messageCtr.remove(tmpMsg)
+
+		deleted = append(deleted, tmpMsg)
+	}
+
+	return deleted
+}
+
+// restoreMessages adds back the messages removed by deleteMessages.
+// The caller must hold the service mutex.
+func (h *nodeHandler) restoreMessages(messages []*acmelib.Message) {
+	for _, tmpMsg := range messages {
+		h.messageCtr.add(tmpMsg)
+
+		for _, sig := range tmpMsg.Signals() {
+			h.signalCtr.add(sig)
+		}
+	}
+}
+
 func (h *nodeHandler) removeReceivedMessages(node *acmelib.Node, req *request, res *nodeRes) error {
 	parsedRes := req.toRemoveReceivedMessages()
 
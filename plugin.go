@@ -0,0 +1,376 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/squadracorsepolito/acmelib"
+)
+
+// Plugins are executables placed in the plugins folder of the settings folder.
+// They implement the formats and the checks that are not built into the app.
+// A plugin is started for every call and receives JSON-RPC 2.0 requests on its stdin,
+// one per line, and writes the responses on its stdout, one per line.
+// What the plugin writes on its stderr is logged.
+//
+// The describe method returns the commands of the plugin, they are added to the File menu:
+//
+//	{"jsonrpc": "2.0", "id": 1, "method": "describe"}
+//	{"jsonrpc": "2.0", "id": 1, "result": {"name": "ARXML", "commands": [{"id": "import", "title": "Import ARXML", "kind": "import", "extensions": ["*.arxml"]}]}}
+//
+// The run method executes a command. The network is encoded as JSON protobuf,
+// the input path is set only for the import commands:
+//
+//	{"jsonrpc": "2.0", "id": 1, "method": "run", "params": {"command": "import", "network": {...}, "inputPath": "/path/to/file.arxml"}}
+//	{"jsonrpc": "2.0", "id": 1, "result": {"network": {...}, "file": {"name": "out.arxml", "content": "<base64>"}, "messages": ["..."]}}
+//
+// All the fields of the result are optional: a returned network replaces the current one
+// as a single history operation, a returned file is saved where the user chooses,
+// and the messages are shown to the user.
+
+const (
+	pluginsDirName = "plugins"
+
+	pluginDescribeTimeout = 5 * time.Second
+	pluginRunTimeout      = 2 * time.Minute
+
+	// pluginMaxLineSize is the maximum size of a response, it must fit a whole network.
+	pluginMaxLineSize = 256 << 20
+)
+
+type PluginCommandKind string
+
+const (
+	// PluginCommandKindImport commands read a file chosen by the user.
+	PluginCommandKindImport PluginCommandKind = "import"
+	// PluginCommandKindExport commands return a file.
+	PluginCommandKindExport PluginCommandKind = "export"
+	// PluginCommandKindCheck commands return messages about the network.
+	PluginCommandKindCheck PluginCommandKind = "check"
+	// PluginCommandKindTransform commands return a modified network.
+	PluginCommandKindTransform PluginCommandKind = "transform"
+)
+
+type PluginCommand struct {
+	ID    string            `json:"id"`
+	Title string            `json:"title"`
+	Kind  PluginCommandKind `json:"kind"`
+	// Extensions are the patterns of the files accepted by an import command (e.g. *.arxml).
+	Extensions []string `json:"extensions"`
+}
+
+type PluginDescription struct {
+	Name     string          `json:"name"`
+	Version  string          `json:"version"`
+	Commands []PluginCommand `json:"commands"`
+}
+
+type pluginRunParams struct {
+	Command   string          `json:"command"`
+	Network   json.RawMessage `json:"network"`
+	InputPath string          `json:"inputPath,omitempty"`
+}
+
+type PluginFile struct {
+	Name string `json:"name"`
+	// Content is encoded in base64.
+	Content []byte `json:"content"`
+}
+
+type pluginRunResult struct {
+	Network  json.RawMessage `json:"network"`
+	File     *PluginFile     `json:"file"`
+	Messages []string        `json:"messages"`
+}
+
+type pluginRPCResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  json.RawMessage `json:"result"`
+	Error   *rpcError       `json:"error"`
+}
+
+type plugin struct {
+	path string
+	desc PluginDescription
+
+	log logger
+}
+
+// pluginRunOutput is what a plugin command produced.
+type pluginRunOutput struct {
+	network  *acmelib.Network
+	file     *PluginFile
+	messages []string
+}
+
+// loadPlugins describes all the executables in the given folder.
+// The plugins that fail to describe themselves are skipped.
+func loadPlugins(dir string, log logger) []*plugin {
+	plugins := []*plugin{}
+
+	if dir == "" {
+		return plugins
+	}
+
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.error(fmt.Errorf("load plugins: %w", err))
+		}
+		return plugins
+	}
+
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() || !isPluginExecutable(dirEntry) {
+			continue
+		}
+
+		p := &plugin{path: filepath.Join(dir, dirEntry.Name()), log: log}
+		if err := p.describe(); err != nil {
+			log.error(fmt.Errorf("load plugin %s: %w", dirEntry.Name(), err))
+			continue
+		}
+
+		plugins = append(plugins, p)
+	}
+
+	return plugins
+}
+
+func isPluginExecutable(dirEntry os.DirEntry) bool {
+	if runtime.GOOS == "windows" {
+		ext := strings.ToLower(filepath.Ext(dirEntry.Name()))
+		return ext == ".exe" || ext == ".bat" || ext == ".cmd"
+	}
+
+	info, err := dirEntry.Info()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&0111 != 0
+}
+
+func (p *plugin) describe() error {
+	ctx, cancel := context.WithTimeout(context.Background(), pluginDescribeTimeout)
+	defer cancel()
+
+	if err := p.call(ctx, "describe", nil, &p.desc); err != nil {
+		return err
+	}
+
+	if p.desc.Name == "" {
+		p.desc.Name = strings.TrimSuffix(filepath.Base(p.path), filepath.Ext(p.path))
+	}
+
+	for _, cmd := range p.desc.Commands {
+		if cmd.ID == "" || cmd.Title == "" {
+			return errors.New("every command must have an id and a title")
+		}
+
+		switch cmd.Kind {
+		case PluginCommandKindImport, PluginCommandKindExport, PluginCommandKindCheck, PluginCommandKindTransform:
+		default:
+			return fmt.Errorf("command %s: invalid kind %q", cmd.ID, cmd.Kind)
+		}
+	}
+
+	return nil
+}
+
+// commandID returns the ID of the plugin command in the command registry.
+func (p *plugin) commandID(cmd PluginCommand) string {
+	return fmt.Sprintf("plugin.%s.%s", filepath.Base(p.path), cmd.ID)
+}
+
+// run executes the command of the plugin on the network encoded as JSON protobuf.
+func (p *plugin) run(cmd PluginCommand, netJSON []byte, inputPath string) (*pluginRunOutput, error) {
+	params := pluginRunParams{
+		Command:   cmd.ID,
+		Network:   netJSON,
+		InputPath: inputPath,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pluginRunTimeout)
+	defer cancel()
+
+	result := pluginRunResult{}
+	if err := p.call(ctx, "run", params, &result); err != nil {
+		return nil, err
+	}
+
+	out := &pluginRunOutput{
+		file:     result.File,
+		messages: result.Messages,
+	}
+
+	if len(result.Network) > 0 && string(result.Network) != "null" {
+		resNet, err := acmelib.LoadNetwork(bytes.NewReader(result.Network), acmelib.SaveEncodingJSON)
+		if err != nil {
+			return nil, fmt.Errorf("invalid network: %w", err)
+		}
+		out.network = resNet
+	}
+
+	if out.file != nil && out.file.Name == "" {
+		return nil, errors.New("the returned file must have a name")
+	}
+
+	return out, nil
+}
+
+// call starts the plugin, sends the request and decodes the result of the response.
+func (p *plugin) call(ctx context.Context, method string, params, result any) error {
+	req := rpcRequest{
+		JSONRPC: rpcVersion,
+		ID:      json.RawMessage("1"),
+		Method:  method,
+	}
+
+	if params != nil {
+		paramsBuf, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		req.Params = paramsBuf
+	}
+
+	reqBuf, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	stderr := new(bytes.Buffer)
+
+	cmd := exec.CommandContext(ctx, p.path)
+	cmd.Dir = filepath.Dir(p.path)
+	cmd.Stdin = bytes.NewReader(append(reqBuf, '\n'))
+	cmd.Stderr = stderr
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	res, readErr := readPluginResponse(stdout)
+
+	// the remaining output is discarded, so the plugin is not blocked while exiting
+	io.Copy(io.Discard, stdout)
+	waitErr := cmd.Wait()
+
+	if stderr.Len() > 0 {
+		p.log.info("PLUGIN OUTPUT", "plugin", filepath.Base(p.path), "stderr", stderr.String())
+	}
+
+	if readErr != nil {
+		if waitErr != nil {
+			return fmt.Errorf("%w: %w", readErr, waitErr)
+		}
+		return readErr
+	}
+
+	if res.Error != nil {
+		return fmt.Errorf("%s (code %d)", res.Error.Message, res.Error.Code)
+	}
+
+	if err := json.Unmarshal(res.Result, result); err != nil {
+		return fmt.Errorf("invalid result: %w", err)
+	}
+
+	return nil
+}
+
+func readPluginResponse(r io.Reader) (pluginRPCResponse, error) {
+	res := pluginRPCResponse{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), pluginMaxLineSize)
+
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		if err := json.Unmarshal(line, &res); err != nil {
+			return res, fmt.Errorf("invalid response: %w", err)
+		}
+
+		if res.JSONRPC != rpcVersion {
+			return res, errors.New("invalid response: missing jsonrpc version")
+		}
+
+		return res, nil
+	}
+
+	if err := scanner.Err(); err != nil {
+		return res, err
+	}
+
+	return res, errors.New("the plugin exited without a response")
+}
+
+// pluginHost holds the plugins found at startup.
+type pluginHost struct {
+	plugins []*plugin
+}
+
+func newPluginHost(dir string, log logger) *pluginHost {
+	return &pluginHost{
+		plugins: loadPlugins(dir, log),
+	}
+}
+
+// runCommand runs a plugin command on the network of the given m. A returned network
+// replaces the open one as a single history operation.
+func (ph *pluginHost) runCommand(m *serviceManager, p *plugin, cmd PluginCommand, inputPath string) (*pluginRunOutput, error) {
+	m.mux.RLock()
+	net := m.network
+	if net == nil {
+		m.mux.RUnlock()
+		return nil, errors.New("run plugin: no network is open")
+	}
+
+	// the network is encoded while holding the lock, the plugin works on its own copy
+	netBuf := new(bytes.Buffer)
+	err := acmelib.SaveNetwork(net, acmelib.SaveEncodingJSON, nil, netBuf, nil)
+	m.mux.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := p.run(cmd, netBuf.Bytes(), inputPath)
+	if err != nil {
+		return nil, fmt.Errorf("plugin %s: %w", p.desc.Name, err)
+	}
+
+	if out.network != nil {
+		m.replaceNetwork(out.network)
+	}
+
+	return out, nil
+}
+
+// filePattern returns the pattern of the files accepted by an import command.
+func (cmd PluginCommand) filePattern() string {
+	if len(cmd.Extensions) == 0 {
+		return "*.*"
+	}
+	return strings.Join(cmd.Extensions, ";")
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"github.com/squadracorsepolito/acmelib"
+)
+
+type SaveEncoding string
+
+const (
+	SaveEncodingWire SaveEncoding = "wire"
+	SaveEncodingJSON SaveEncoding = "json"
+	SaveEncodingText SaveEncoding = "text"
+)
+
+func (e SaveEncoding) parse() acmelib.SaveEncoding {
+	switch e {
+	case SaveEncodingJSON:
+		return acmelib.SaveEncodingJSON
+	case SaveEncodingText:
+		return acmelib.SaveEncodingText
+	default:
+		return acmelib.SaveEncodingWire
+	}
+}
+
+const (
+	namingTemplateName  = "{name}"
+	namingTemplateIndex = "{n}"
+)
+
+type Preferences struct {
+	// DefaultByteOrder, DefaultMessageSizeByte and DefaultCycleTime
+	// are applied to the new messages.
+	DefaultByteOrder       MessageByteOrder `json:"defaultByteOrder"`
+	DefaultMessageSizeByte int              `json:"defaultMessageSizeByte"`
+	DefaultCycleTime       int              `json:"defaultCycleTime"`
+
+	// AutosaveInterval is the interval in seconds between two automatic saves,
+	// 0 disables the autosave.
+	AutosaveInterval int `json:"autosaveInterval"`
+	// DefaultSaveEncoding is used when the encoding cannot be deduced by the file extension.
+	DefaultSaveEncoding SaveEncoding `json:"defaultSaveEncoding"`
+
+	// BusLoadWarningThreshold is the bus load percentage over which a warning is shown.
+	BusLoadWarningThreshold float64 `json:"busLoadWarningThreshold"`
+
+	// NamingTemplate is used to generate the names of the new entities,
+	// {name} is replaced by the kind of the entity and {n} by a counter.
+	NamingTemplate string `json:"namingTemplate"`
+}
+
+func newDefaultPreferences() Preferences {
+	return Preferences{
+		DefaultByteOrder:       MessageByteOrderLittleEndian,
+		DefaultMessageSizeByte: 8,
+		DefaultCycleTime:       0,
+
+		AutosaveInterval:    0,
+		DefaultSaveEncoding: SaveEncodingWire,
+
+		BusLoadWarningThreshold: 70,
+
+		NamingTemplate: "new_{name}_{n}",
+	}
+}
+
+func (p *Preferences) validate() error {
+	switch p.DefaultByteOrder {
+	case MessageByteOrderLittleEndian, MessageByteOrderBigEndian:
+	default:
+		return errors.New("invalid default byte order")
+	}
+
+	if p.DefaultMessageSizeByte < 1 || p.DefaultMessageSizeByte > 64 {
+		return errors.New("the default message size must be between 1 and 64 bytes")
+	}
+
+	if p.DefaultCycleTime < 0 {
+		return errors.New("the default cycle time cannot be negative")
+	}
+
+	if p.AutosaveInterval < 0 {
+		return errors.New("the autosave interval cannot be negative")
+	}
+
+	switch p.DefaultSaveEncoding {
+	case SaveEncodingWire, SaveEncodingJSON, SaveEncodingText:
+	default:
+		return errors.New("invalid default save encoding")
+	}
+
+	if p.BusLoadWarningThreshold <= 0 || p.BusLoadWarningThreshold > 100 {
+		return errors.New("the bus load warning threshold must be between 0 and 100")
+	}
+
+	if !strings.Contains(p.NamingTemplate, namingTemplateIndex) {
+		return errors.New("the naming template must contain " + namingTemplateIndex)
+	}
+
+	return nil
+}
+
+// currentPreferences holds the preferences in use, it is updated by the settings service
+// and read by the parts of the app that are not linked to it (e.g. getNewName).
+var currentPreferences atomic.Pointer[Preferences]
+
+func setPreferences(prefs Preferences) {
+	currentPreferences.Store(&prefs)
+}
+
+// getPreferences returns the preferences in use,
+// or the default ones if the settings are not loaded yet.
+func getPreferences() Preferences {
+	prefs := currentPreferences.Load()
+	if prefs == nil {
+		return newDefaultPreferences()
+	}
+	return *prefs
+}
+
+// currentSettingsVersion is the version of the settings written by this version of the app.
+const currentSettingsVersion = 6
+
+// settingsMigrations contains the migration steps of the settings file,
+// the step at index i migrates the settings from version i+1 to version i+2.
+// A new step must be appended every time currentSettingsVersion is incremented.
+var settingsMigrations = []func(*Settings){
+	// 1 -> 2: lint rules
+	func(s *Settings) {
+		if s.LintRules == nil {
+			s.LintRules = getSettingsV2LintRules()
+		}
+	},
+
+	// 2 -> 3: preferences
+	func(s *Settings) {
+		s.Preferences = newDefaultPreferences()
+	},
+
+	// 3 -> 4: keybindings
+	func(s *Settings) {
+		if s.Keybindings == nil {
+			s.Keybindings = make(map[string]string)
+		}
+	},
+
+	// 4 -> 5: api server
+	func(s *Settings) {
+		s.APIServer = newDefaultAPIServerSettings()
+	},
+
+	// 5 -> 6: unit dimension lint rule
+	func(s *Settings) {
+		hasRule := slices.ContainsFunc(s.LintRules, func(rule LintRule) bool {
+			return rule.Kind == LintRuleUnitDimension
+		})
+
+		if !hasRule {
+			s.LintRules = append(s.LintRules, LintRule{Kind: LintRuleUnitDimension, Enabled: true, Kinds: []EntityKind{}})
+		}
+	},
+}
+
+// getSettingsV2LintRules returns the default lint rules of the version 2 of the settings.
+// It must not follow newDefaultLintRules, the rules added later are appended by their own step.
+func getSettingsV2LintRules() []LintRule {
+	return []LintRule{
+		{Kind: LintRuleMessageSenderPrefix, Enabled: true, Kinds: []EntityKind{}},
+		{Kind: LintRuleNameCase, Enabled: true, Kinds: []EntityKind{EntityKindSignal}, Case: NameCaseUpperSnake},
+		{Kind: LintRuleEnumValuePrefix, Enabled: true, Kinds: []EntityKind{}},
+		{Kind: LintRuleRequiredDesc, Enabled: true, Kinds: []EntityKind{EntityKindMessage, EntityKindSignal}},
+	}
+}
+
+// migrateSettings applies the migration steps needed to bring
+// the settings to the current version. It returns true if
+// at least one step has been applied.
+func migrateSettings(s *Settings) bool {
+	if s.Version < 1 {
+		s.Version = 1
+	}
+
+	migrated := false
+	for s.Version < currentSettingsVersion {
+		settingsMigrations[s.Version-1](s)
+		s.Version++
+		migrated = true
+	}
+
+	return migrated
+}
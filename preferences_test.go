@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMigrateSettings(t *testing.T) {
+	customRules := []LintRule{
+		{Kind: LintRuleNameCase, Enabled: false, Kinds: []EntityKind{EntityKindMessage}, Case: NameCaseCamel},
+	}
+
+	tests := []struct {
+		name      string
+		settings  Settings
+		wantRules []LintRule
+	}{
+		{
+			name:      "version 0",
+			settings:  Settings{},
+			wantRules: newDefaultLintRules(),
+		},
+		{
+			name:      "version 1",
+			settings:  Settings{Version: 1},
+			wantRules: newDefaultLintRules(),
+		},
+		{
+			name:     "version 5 without the unit dimension rule",
+			settings: Settings{Version: 5, LintRules: customRules},
+			wantRules: append(customRules[:len(customRules):len(customRules)],
+				LintRule{Kind: LintRuleUnitDimension, Enabled: true, Kinds: []EntityKind{}}),
+		},
+		{
+			name: "version 5 with the unit dimension rule",
+			settings: Settings{Version: 5, LintRules: []LintRule{
+				{Kind: LintRuleUnitDimension, Enabled: false, Kinds: []EntityKind{}},
+			}},
+			wantRules: []LintRule{
+				{Kind: LintRuleUnitDimension, Enabled: false, Kinds: []EntityKind{}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.settings
+
+			if !migrateSettings(&s) {
+				t.Fatal("expected the settings to be migrated")
+			}
+
+			if s.Version != currentSettingsVersion {
+				t.Errorf("got version %d, want %d", s.Version, currentSettingsVersion)
+			}
+
+			if !reflect.DeepEqual(s.LintRules, tt.wantRules) {
+				t.Errorf("got lint rules %+v, want %+v", s.LintRules, tt.wantRules)
+			}
+		})
+	}
+
+	s := newDefaultSettings()
+	if migrateSettings(s) {
+		t.Error("the current settings must not be migrated")
+	}
+}
+
+func TestLoadSettingsEmptyAPIToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "settings.json")
+	data := `{"version": 6, "apiServer": {"enabled": true, "port": 50321, "token": ""}}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cs := newConfigService(newMemoryEventEmitter(), newMemoryLogger())
+	if err := cs.load(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if cs.settings.APIServer.Token == "" {
+		t.Error("expected a new api token")
+	}
+
+	if !cs.settingsTained {
+		t.Error("expected the new api token to be saved")
+	}
+}
@@ -0,0 +1,20 @@
+package main
+
+import (
+	acmelibv1 "github.com/squadracorsepolito/acmelib/proto/gen/go/acmelib/v1"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// The library files and the rebuilt nodes are built as acmelib protobuf messages,
+// so the entities keep their ids when they are loaded by acmelib.
+
+// saveProtoEntity returns the protobuf message of the common fields of the entity.
+func saveProtoEntity(ent entity, kind acmelibv1.EntityKind) *acmelibv1.Entity {
+	return &acmelibv1.Entity{
+		EntityId:   ent.EntityID().String(),
+		EntityKind: kind,
+		Name:       ent.Name(),
+		Desc:       ent.Desc(),
+		CreateTime: timestamppb.New(ent.CreateTime()),
+	}
+}
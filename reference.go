@@ -1,6 +1,12 @@
 package main
 
-import "github.com/squadracorsepolito/acmelib"
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/squadracorsepolito/acmelib"
+)
 
 type ReferenceKind string
 
@@ -74,17 +80,20 @@ func (r *reference) toResponse() Reference {
 	return res
 }
 
-/*
-func getReferencesFromSignals(signals []acmelib.Signal) []Reference {
+// newSignalReferences returns the bus -> node -> message -> signal
+// reference trees of the given signals.
+func newSignalReferences[S acmelib.Signal](signals []S) []Reference {
 	rootRefs := []*reference{}
 	refs := make(map[acmelib.EntityID]*reference)
 	for _, sig := range signals {
 		sigRef := newReference(sig)
 		refs[sig.EntityID()] = sigRef
 
-		var msgRef *reference
 		msg := sig.ParentMessage()
-		sigRef.entityID = msg.EntityID()
+		if msg == nil {
+			continue
+		}
+
 		msgRef, ok := refs[msg.EntityID()]
 		if !ok {
 			msgRef = newReference(msg)
@@ -92,18 +101,25 @@ func getReferencesFromSignals(signals []acmelib.Signal) []Reference {
 		}
 		msgRef.addChild(sigRef)
 
-		var nodeRef *reference
-		node := msg.SenderNodeInterface().Node()
-		nodeRef, ok = refs[node.EntityID()]
+		nodeInt := msg.SenderNodeInterface()
+		if nodeInt == nil {
+			continue
+		}
+
+		node := nodeInt.Node()
+		nodeRef, ok := refs[node.EntityID()]
 		if !ok {
 			nodeRef = newReference(node)
 			refs[node.EntityID()] = nodeRef
 		}
 		nodeRef.addChild(msgRef)
 
-		var busRef *reference
-		bus := msg.SenderNodeInterface().ParentBus()
-		busRef, ok = refs[bus.EntityID()]
+		bus := nodeInt.ParentBus()
+		if bus == nil {
+			continue
+		}
+
+		busRef, ok := refs[bus.EntityID()]
 		if !ok {
 			busRef = newReference(bus)
 			refs[bus.EntityID()] = busRef
@@ -113,11 +129,22 @@ func getReferencesFromSignals(signals []acmelib.Signal) []Reference {
 	}
 
 	res := []Reference{}
-
 	for _, tmpRef := range rootRefs {
 		res = append(res, tmpRef.toResponse())
 	}
 
 	return res
 }
-*/
+
+// newReferencedError returns the error used when an entity cannot be deleted
+// because it is still referenced by the given signals.
+// The error lists the paths of all the blocking signals.
+func newReferencedError[S acmelib.Signal](kindName string, ent entity, signals []S) error {
+	paths := []string{}
+	for _, sig := range signals {
+		paths = append(paths, formatEntityPaths(newSignalEntityPaths(sig)))
+	}
+	slices.Sort(paths)
+
+	return fmt.Errorf("%s %s is referenced by %d signals: %s", kindName, ent.Name(), len(paths), strings.Join(paths, ", "))
+}
@@ -0,0 +1,630 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/squadracorsepolito/acmelib"
+)
+
+type BulkRenameMode string
+
+const (
+	// BulkRenameModeRegex matches the names with a regular expression and
+	// replaces them with a template that can reference the groups ($1, ${name}).
+	BulkRenameModeRegex BulkRenameMode = "regex"
+	// BulkRenameModePattern matches the whole name with a pattern where * matches
+	// any text, each * of the replacement is the text matched by the corresponding * of the pattern.
+	// For example the pattern "*" with the replacement "BMS_*" prefixes every name.
+	BulkRenameModePattern BulkRenameMode = "pattern"
+)
+
+type NameCase string
+
+const (
+	NameCaseKeep       NameCase = ""
+	NameCaseLower      NameCase = "lower"
+	NameCaseUpper      NameCase = "upper"
+	NameCaseSnake      NameCase = "snake"
+	NameCaseUpperSnake NameCase = "upper-snake"
+	NameCaseCamel      NameCase = "camel"
+	NameCasePascal     NameCase = "pascal"
+)
+
+// splitNameWords splits a name into its words,
+// it handles underscores, dashes, spaces and camel case names.
+func splitNameWords(name string) []string {
+	words := []string{}
+
+	currWord := []rune{}
+	flush := func() {
+		if len(currWord) > 0 {
+			words = append(words, string(currWord))
+			currWord = []rune{}
+		}
+	}
+
+	runes := []rune(name)
+	for idx, r := range runes {
+		if r == '_' || r == '-' || unicode.IsSpace(r) {
+			flush()
+			continue
+		}
+
+		if idx > 0 && unicode.IsUpper(r) {
+			prev := runes[idx-1]
+			nextIsLower := idx+1 < len(runes) && unicode.IsLower(runes[idx+1])
+
+			// split "fooBar" and the last upper letter of an acronym ("HTTPServer")
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextIsLower) {
+				flush()
+			}
+		}
+
+		currWord = append(currWord, r)
+	}
+	flush()
+
+	return words
+}
+
+func capitalizeWord(word string) string {
+	runes := []rune(strings.ToLower(word))
+	if len(runes) > 0 {
+		runes[0] = unicode.ToUpper(runes[0])
+	}
+	return string(runes)
+}
+
+// convertNameCase returns the name converted to the given case.
+func convertNameCase(name string, nameCase NameCase) string {
+	switch nameCase {
+	case NameCaseLower:
+		return strings.ToLower(name)
+
+	case NameCaseUpper:
+		return strings.ToUpper(name)
+
+	case NameCaseSnake:
+		return strings.ToLower(strings.Join(splitNameWords(name), "_"))
+
+	case NameCaseUpperSnake:
+		return strings.ToUpper(strings.Join(splitNameWords(name), "_"))
+
+	case NameCaseCamel, NameCasePascal:
+		words := splitNameWords(name)
+		for idx, word := range words {
+			if idx == 0 && nameCase == NameCaseCamel {
+				words[idx] = strings.ToLower(word)
+				continue
+			}
+			words[idx] = capitalizeWord(word)
+		}
+		return strings.Join(words, "")
+	}
+
+	return name
+}
+
+// renameEntity updates the name of the given entity.
+func renameEntity(ent entity, name string) error {
+	switch tmpEnt := ent.(type) {
+	case *acmelib.Bus:
+		return tmpEnt.UpdateName(name)
+	case *acmelib.Node:
+		return tmpEnt.UpdateName(name)
+	case *acmelib.Message:
+		return tmpEnt.UpdateName(name)
+	case acmelib.Signal:
+		return tmpEnt.UpdateName(name)
+	case *acmelib.SignalType:
+		tmpEnt.SetName(name)
+	case *acmelib.SignalUnit:
+		tmpEnt.SetName(name)
+	case *acmelib.SignalEnum:
+		tmpEnt.UpdateName(name)
+	default:
+		return fmt.Errorf("cannot rename entity of kind %s", ent.EntityKind())
+	}
+
+	return nil
+}
+
+// getEntityPaths returns the entity paths of the given entity.
+func getEntityPaths(ent entity) []EntityPath {
+	switch tmpEnt := ent.(type) {
+	case *acmelib.Bus:
+		return newBusEntityPaths(tmpEnt)
+	case *acmelib.Message:
+		return newMessageEntityPaths(tmpEnt)
+	case acmelib.Signal:
+		return newSignalEntityPaths(tmpEnt)
+	}
+
+	return []EntityPath{newEntityPath(ent)}
+}
+
+type BulkRenameReq struct {
+	Mode    BulkRenameMode `json:"mode"`
+	Find    string         `json:"find"`
+	Replace string         `json:"replace"`
+	Case    NameCase       `json:"case"`
+
+	// Kinds filters the kinds of the entities to rename, all kinds if empty.
+	Kinds []EntityKind `json:"kinds"`
+	// ScopeEntityID is the entity id of a bus, a node or a message,
+	// if set only the entities inside its subtree are renamed.
+	ScopeEntityID string `json:"scopeEntityId"`
+}
+
+type BulkRenameItem struct {
+	EntityPath
+
+	Paths []EntityPath `json:"paths"`
+
+	OldName string `json:"oldName"`
+	NewName string `json:"newName"`
+
+	// Conflict describes why the entity cannot be renamed, it is empty if there is no conflict.
+	Conflict string `json:"conflict"`
+}
+
+type BulkRenamePreview struct {
+	Items        []BulkRenameItem `json:"items"`
+	HasConflicts bool             `json:"hasConflicts"`
+}
+
+type bulkRenameItem struct {
+	ent     entity
+	oldName string
+	newName string
+}
+
+type RenameService struct {
+	windowRouted
+
+	mux *sync.RWMutex
+
+	networkSrv *NetworkService
+
+	busCtr        *busController
+	nodeCtr       *nodeController
+	messageCtr    *messageController
+	signalCtr     *signalController
+	signalTypeCtr *signalTypeController
+	signalUnitCtr *signalUnitController
+	signalEnumCtr *signalEnumController
+
+	sidebarCtr *sidebarController
+	historyCtr *historyController
+}
+
+func newRenameService(mux *sync.RWMutex, networkSrv *NetworkService, busCtr *busController, nodeCtr *nodeController, messageCtr *messageController,
+	signalCtr *signalController, signalTypeCtr *signalTypeController, signalUnitCtr *signalUnitController, signalEnumCtr *signalEnumController,
+	sidebarCtr *sidebarController, historyCtr *historyController) *RenameService {
+
+	return &RenameService{
+		mux: mux,
+
+		networkSrv: networkSrv,
+
+		busCtr:        busCtr,
+		nodeCtr:       nodeCtr,
+		messageCtr:    messageCtr,
+		signalCtr:     signalCtr,
+		signalTypeCtr: signalTypeCtr,
+		signalUnitCtr: signalUnitCtr,
+		signalEnumCtr: signalEnumCtr,
+
+		sidebarCtr: sidebarCtr,
+		historyCtr: historyCtr,
+	}
+}
+
+// forWindow returns the service of the window that made the call.
+func (s *RenameService) forWindow(ctx context.Context) *RenameService {
+	return s.resolve(ctx).renameSrv
+}
+
+// Preview returns the result of the bulk rename without applying it.
+func (s *RenameService) Preview(ctx context.Context, req BulkRenameReq) (BulkRenamePreview, error) {
+	s = s.forWindow(ctx)
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	items, err := s.getItems(req)
+	if err != nil {
+		return BulkRenamePreview{}, err
+	}
+
+	return s.checkItems(items), nil
+}
+
+// Apply renames all the matching entities as a single history operation.
+// Nothing is renamed if there is at least one conflict.
+func (s *RenameService) Apply(ctx context.Context, req BulkRenameReq) (BulkRenamePreview, error) {
+	s = s.forWindow(ctx)
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	items, err := s.getItems(req)
+	if err != nil {
+		return BulkRenamePreview{}, err
+	}
+
+	preview := s.checkItems(items)
+	if preview.HasConflicts {
+		return preview, errors.New("bulk rename: the new names have conflicts")
+	}
+
+	if len(items) == 0 {
+		return preview, nil
+	}
+
+	if err := s.renameItems(items, false); err != nil {
+		return preview, err
+	}
+
+	s.historyCtr.sendOperation(
+		serviceKindNetwork,
+		func() (any, error) {
+			s.mux.Lock()
+			defer s.mux.Unlock()
+
+			if err := s.renameItems(items, true); err != nil {
+				return nil, err
+			}
+
+			return newNetwork(s.networkSrv.network), nil
+		},
+		func() (any, error) {
+			s.mux.Lock()
+			defer s.mux.Unlock()
+
+			if err := s.renameItems(items, false); err != nil {
+				return nil, err
+			}
+
+			return newNetwork(s.networkSrv.network), nil
+		},
+	)
+
+	return preview, nil
+}
+
+// renameItems renames the items to the new names or, if undo is set, back to the old ones.
+// The entities are first renamed to temporary names so that swapping names
+// between entities does not fail. If an error occurs, all the names are restored.
+func (s *RenameService) renameItems(items []*bulkRenameItem, undo bool) error {
+	restore := func() {
+		for _, item := range items {
+			if undo {
+				renameEntity(item.ent, item.newName)
+			} else {
+				renameEntity(item.ent, item.oldName)
+			}
+		}
+	}
+
+	for idx, item := range items {
+		if err := renameEntity(item.ent, fmt.Sprintf("bulk_rename_tmp_%d_%s", idx, item.ent.EntityID())); err != nil {
+			restore()
+			return err
+		}
+	}
+
+	for _, item := range items {
+		name := item.newName
+		if undo {
+			name = item.oldName
+		}
+
+		if err := renameEntity(item.ent, name); err != nil {
+			restore()
+			return err
+		}
+	}
+
+	for _, item := range items {
+		s.sidebarCtr.sendUpdateName(item.ent)
+	}
+
+	return nil
+}
+
+func (s *RenameService) getNameReplacer(req BulkRenameReq) (func(string) (string, bool), error) {
+	switch req.Mode {
+	case BulkRenameModeRegex:
+		if len(req.Find) == 0 {
+			return nil, errors.New("bulk rename: empty regular expression")
+		}
+
+		regex, err := regexp.Compile(req.Find)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(name string) (string, bool) {
+			if !regex.MatchString(name) {
+				return "", false
+			}
+			return regex.ReplaceAllString(name, req.Replace), true
+		}, nil
+
+	case BulkRenameModePattern:
+		find := req.Find
+		if len(find) == 0 {
+			find = "*"
+		}
+
+		parts := strings.Split(find, "*")
+		for idx, part := range parts {
+			parts[idx] = regexp.QuoteMeta(part)
+		}
+		regex := regexp.MustCompile("^" + strings.Join(parts, "(.*)") + "$")
+
+		replParts := strings.Split(req.Replace, "*")
+		for idx, part := range replParts {
+			replParts[idx] = strings.ReplaceAll(part, "$", "$$")
+		}
+		var builder strings.Builder
+		for idx, part := range replParts {
+			if idx > 0 {
+				builder.WriteString(fmt.Sprintf("${%d}", idx))
+			}
+			builder.WriteString(part)
+		}
+		template := builder.String()
+
+		return func(name string) (string, bool) {
+			if !regex.MatchString(name) {
+				return "", false
+			}
+			return regex.ReplaceAllString(name, template), true
+		}, nil
+	}
+
+	return nil, errors.New("bulk rename: invalid mode")
+}
+
+func (s *RenameService) getScopeFilter(scopeEntityID string) (func(entity) bool, error) {
+	if len(scopeEntityID) == 0 {
+		return func(entity) bool { return true }, nil
+	}
+
+	var msgInScope func(msg *acmelib.Message) bool
+	var nodeInScope func(node *acmelib.Node) bool
+	var busInScope func(bus *acmelib.Bus) bool
+
+	if bus, err := s.busCtr.get(scopeEntityID); err == nil {
+		busInScope = func(tmpBus *acmelib.Bus) bool { return tmpBus == bus }
+
+		nodeInScope = func(node *acmelib.Node) bool {
+			for _, nodeInt := range node.Interfaces() {
+				if nodeInt.ParentBus() == bus {
+					return true
+				}
+			}
+			return false
+		}
+
+		msgInScope = func(msg *acmelib.Message) bool {
+			nodeInt := msg.SenderNodeInterface()
+			return nodeInt != nil && nodeInt.ParentBus() == bus
+		}
+
+	} else if node, err := s.nodeCtr.get(scopeEntityID); err == nil {
+		busInScope = func(*acmelib.Bus) bool { return false }
+
+		nodeInScope = func(tmpNode *acmelib.Node) bool { return tmpNode == node }
+
+		msgInScope = func(msg *acmelib.Message) bool {
+			nodeInt := msg.SenderNodeInterface()
+			return nodeInt != nil && nodeInt.Node() == node
+		}
+
+	} else if msg, err := s.messageCtr.get(scopeEntityID); err == nil {
+		busInScope = func(*acmelib.Bus) bool { return false }
+		nodeInScope = func(*acmelib.Node) bool { return false }
+		msgInScope = func(tmpMsg *acmelib.Message) bool { return tmpMsg == msg }
+
+	} else {
+		return nil, errors.New("bulk rename: the scope must be a bus, a node or a message")
+	}
+
+	return func(ent entity) bool {
+		switch tmpEnt := ent.(type) {
+		case *acmelib.Bus:
+			return busInScope(tmpEnt)
+		case *acmelib.Node:
+			return nodeInScope(tmpEnt)
+		case *acmelib.Message:
+			return msgInScope(tmpEnt)
+		case acmelib.Signal:
+			parMsg := tmpEnt.ParentMessage()
+			return parMsg != nil && msgInScope(parMsg)
+		}
+
+		// signal types, units and enums do not belong to any subtree
+		return false
+	}, nil
+}
+
+func (s *RenameService) getItems(req BulkRenameReq) ([]*bulkRenameItem, error) {
+	replacer, err := s.getNameReplacer(req)
+	if err != nil {
+		return nil, err
+	}
+
+	inScope, err := s.getScopeFilter(req.ScopeEntityID)
+	if err != nil {
+		return nil, err
+	}
+
+	kinds := make(map[EntityKind]struct{})
+	for _, kind := range req.Kinds {
+		kinds[kind] = struct{}{}
+	}
+
+	entities := []entity{}
+	addEntities := func(kind EntityKind, list func() []entity) {
+		if _, ok := kinds[kind]; len(kinds) > 0 && !ok {
+			return
+		}
+		entities = append(entities, list()...)
+	}
+
+	addEntities(EntityKindBus, func() []entity { return toEntities(s.busCtr.list()) })
+	addEntities(EntityKindNode, func() []entity { return toEntities(s.nodeCtr.list()) })
+	addEntities(EntityKindMessage, func() []entity { return toEntities(s.messageCtr.list()) })
+	addEntities(EntityKindSignal, func() []entity { return toEntities(s.signalCtr.list()) })
+	// the entities linked to a library file are read-only
+	addEntities(EntityKindSignalType, func() []entity { return toEntities(s.signalTypeCtr.listEditable()) })
+	addEntities(EntityKindSignalUnit, func() []entity { return toEntities(s.signalUnitCtr.listEditable()) })
+	addEntities(EntityKindSignalEnum, func() []entity { return toEntities(s.signalEnumCtr.listEditable()) })
+
+	items := []*bulkRenameItem{}
+	for _, ent := range entities {
+		if !inScope(ent) {
+			continue
+		}
+
+		oldName := ent.Name()
+
+		newName, ok := replacer(oldName)
+		if !ok {
+			continue
+		}
+		newName = strings.TrimSpace(convertNameCase(newName, req.Case))
+
+		if newName == oldName {
+			continue
+		}
+
+		items = append(items, &bulkRenameItem{
+			ent:     ent,
+			oldName: oldName,
+			newName: newName,
+		})
+	}
+
+	slices.SortFunc(items, func(a, b *bulkRenameItem) int {
+		if a.ent.EntityKind() == b.ent.EntityKind() {
+			return strings.Compare(a.oldName, b.oldName)
+		}
+		return int(a.ent.EntityKind()) - int(b.ent.EntityKind())
+	})
+
+	return items, nil
+}
+
+// getNameScope returns the key of the namespace in which the name of the entity must be unique,
+// following the same rules of the GetInvalidNames methods: signals must have a unique name
+// inside their message, all the other entities inside their kind.
+func getNameScope(ent entity) string {
+	if sig, ok := ent.(acmelib.Signal); ok {
+		if parMsg := sig.ParentMessage(); parMsg != nil {
+			return "message:" + parMsg.EntityID().String()
+		}
+	}
+
+	return "kind:" + ent.EntityKind().String()
+}
+
+func (s *RenameService) checkItems(items []*bulkRenameItem) BulkRenamePreview {
+	renamed := make(map[acmelib.EntityID]*bulkRenameItem)
+	for _, item := range items {
+		renamed[item.ent.EntityID()] = item
+	}
+
+	// collect the final names of all the entities
+	// that could collide with the renamed ones
+	takenNames := make(map[string]map[string]int)
+	addTakenName := func(ent entity, name string) {
+		scope := getNameScope(ent)
+		if _, ok := takenNames[scope]; !ok {
+			takenNames[scope] = make(map[string]int)
+		}
+		takenNames[scope][name]++
+	}
+
+	addTakenNames := func(entities []entity) {
+		for _, ent := range entities {
+			if item, ok := renamed[ent.EntityID()]; ok {
+				addTakenName(ent, item.newName)
+				continue
+			}
+			addTakenName(ent, ent.Name())
+		}
+	}
+
+	kinds := make(map[acmelib.EntityKind]struct{})
+	for _, item := range items {
+		kinds[item.ent.EntityKind()] = struct{}{}
+	}
+
+	for kind := range kinds {
+		switch kind {
+		case acmelib.EntityKindBus:
+			addTakenNames(toEntities(s.busCtr.list()))
+		case acmelib.EntityKindNode:
+			addTakenNames(toEntities(s.nodeCtr.list()))
+		case acmelib.EntityKindMessage:
+			addTakenNames(toEntities(s.messageCtr.list()))
+		case acmelib.EntityKindSignal:
+			addTakenNames(toEntities(s.signalCtr.list()))
+		case acmelib.EntityKindSignalType:
+			addTakenNames(toEntities(s.signalTypeCtr.list()))
+		case acmelib.EntityKindSignalUnit:
+			addTakenNames(toEntities(s.signalUnitCtr.list()))
+		case acmelib.EntityKindSignalEnum:
+			addTakenNames(toEntities(s.signalEnumCtr.list()))
+		}
+	}
+
+	res := BulkRenamePreview{
+		Items: []BulkRenameItem{},
+	}
+
+	for _, item := range items {
+		conflict := ""
+		if len(item.newName) == 0 {
+			conflict = "the new name is empty"
+		} else if takenNames[getNameScope(item.ent)][item.newName] > 1 {
+			conflict = fmt.Sprintf("the name %s is already taken", item.newName)
+		}
+
+		if len(conflict) > 0 {
+			res.HasConflicts = true
+		}
+
+		res.Items = append(res.Items, BulkRenameItem{
+			EntityPath: newEntityPath(item.ent),
+
+			Paths: getEntityPaths(item.ent),
+
+			OldName: item.oldName,
+			NewName: item.newName,
+
+			Conflict: conflict,
+		})
+	}
+
+	return res
+}
+
+func toEntities[E entity](entities []E) []entity {
+	res := make([]entity, 0, len(entities))
+	for _, ent := range entities {
+		res = append(res, ent)
+	}
+	return res
+}
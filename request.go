@@ -39,6 +39,29 @@ func (r *request) toUpdateDesc() *UpdateDescReq {
 	return req
 }
 
+type DeleteWithReplacementReq struct {
+	ReplacementEntityID string `json:"replacementEntityId"`
+}
+
+// MergeDuplicatesReq merges the entities into the survivor,
+// they must be structurally identical to it.
+type MergeDuplicatesReq struct {
+	SurvivorEntityID string   `json:"survivorEntityId"`
+	EntityIDs        []string `json:"entityIds"`
+}
+
+type UpdateKeepInLibraryReq struct {
+	Keep bool `json:"keep"`
+}
+
+func (r *request) toUpdateKeepInLibrary() *UpdateKeepInLibraryReq {
+	req, ok := r.data.(*UpdateKeepInLibraryReq)
+	if !ok {
+		panic("cannot convert to UpdateKeepInLibraryReq")
+	}
+	return req
+}
+
 //////////////////////
 // NETWORK REQUESTS //
 //////////////////////
@@ -154,6 +177,23 @@ func (r *request) toRemoveReceivedMessages() *RemoveReceivedMessagesReq {
 	return req
 }
 
+type RemoveInterfaceReq struct {
+	InterfaceNumber int `json:"interfaceNumber"`
+}
+
+type MoveSentMessageReq struct {
+	InterfaceNumber int    `json:"interfaceNumber"`
+	MessageEntityID string `json:"messageEntityId"`
+}
+
+func (r *request) toMoveSentMessage() *MoveSentMessageReq {
+	req, ok := r.data.(*MoveSentMessageReq)
+	if !ok {
+		panic("cannot convert to MoveSentMessageReq")
+	}
+	return req
+}
+
 //////////////////////
 // MESSAGE REQUESTS //
 //////////////////////
@@ -297,6 +337,18 @@ func (r *request) toReorderSignal() *ReorderSignalReq {
 	return req
 }
 
+type MoveSignalReq struct {
+	commondMessageSignalReq
+}
+
+func (r *request) toMoveSignal() *MoveSignalReq {
+	req, ok := r.data.(*MoveSignalReq)
+	if !ok {
+		panic("cannot convert to MoveSignalReq")
+	}
+	return req
+}
+
 /////////////////////
 // SIGNAL REQUESTS //
 /////////////////////
@@ -315,6 +367,9 @@ func (r *request) toUpdateSignalType() *UpdateSignalTypeReq {
 
 type UpdateSignalUnitReq struct {
 	SignalUnitEntityID string `json:"signalUnitEntityId"`
+	// ConvertType rescales the signal type to the new unit (e.g. from °C to K),
+	// so that the signal keeps the same physical values.
+	ConvertType bool `json:"convertType"`
 }
 
 func (r *request) toUpdateSignalUnit() *UpdateSignalUnitReq {
@@ -508,3 +563,22 @@ func (r *request) toUpdateValueIndex() *UpdateValueIndexReq {
 	}
 	return req
 }
+
+type ImportValuesReq struct {
+	Format EnumSourceFormat `json:"format"`
+	Source string           `json:"source"`
+	// SourceEnumName selects the enum of the source to import,
+	// it can be empty if the source holds only one enum.
+	SourceEnumName string `json:"sourceEnumName"`
+	// Replace removes the current values before the import,
+	// otherwise the imported values are added to them.
+	Replace bool `json:"replace"`
+}
+
+func (r *request) toImportValues() *ImportValuesReq {
+	req, ok := r.data.(*ImportValuesReq)
+	if !ok {
+		panic("cannot convert to ImportValuesReq")
+	}
+	return req
+}
@@ -0,0 +1,756 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/squadracorsepolito/acmelib"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+)
+
+// Scripts are written in Starlark and are used for the batch edits
+// that are too specific to become features, e.g.
+//
+//	for msg in messages():
+//	    if msg.name.endswith("_Fast"):
+//	        set_cycle_time(msg, 10)
+//
+// The entities are read with buses(), nodes(), messages(), signals(),
+// signal_types(), signal_units() and signal_enums(), and are edited
+// with the set_* functions, which call the same handlers of the services.
+// All the edits of a run are a single history operation. If the script fails,
+// or if it is a dry run, the edits are reverted and only reported.
+
+// scriptMaxSteps limits the execution of a script, so a script
+// with an endless loop does not block the app.
+const scriptMaxSteps = 100_000_000
+
+type RunScriptReq struct {
+	// Name is the name of the script shown in the errors (e.g. the file name).
+	Name   string `json:"name"`
+	Source string `json:"source"`
+	DryRun bool   `json:"dryRun"`
+}
+
+// ScriptChange is an edit made by a script.
+type ScriptChange struct {
+	EntityID string     `json:"entityId"`
+	Kind     EntityKind `json:"kind"`
+	Name     string     `json:"name"`
+	Field    string     `json:"field"`
+	OldValue string     `json:"oldValue"`
+	NewValue string     `json:"newValue"`
+}
+
+func (c ScriptChange) String() string {
+	return fmt.Sprintf("%s %s: %s %q -> %q", c.Kind, c.Name, c.Field, c.OldValue, c.NewValue)
+}
+
+type ScriptResult struct {
+	// Output contains the lines printed by the script.
+	Output  []string       `json:"output"`
+	Changes []ScriptChange `json:"changes"`
+	DryRun  bool           `json:"dryRun"`
+}
+
+// String returns the summary of the run, with the changes followed by the output of the script.
+func (r ScriptResult) String() string {
+	var b strings.Builder
+
+	switch {
+	case len(r.Changes) == 0:
+		b.WriteString("No changes.\n")
+	case r.DryRun:
+		fmt.Fprintf(&b, "%d changes would be made:\n", len(r.Changes))
+	default:
+		fmt.Fprintf(&b, "%d changes made:\n", len(r.Changes))
+	}
+
+	for _, change := range r.Changes {
+		b.WriteString("  " + change.String() + "\n")
+	}
+
+	if len(r.Output) > 0 {
+		b.WriteString("\nOutput:\n")
+		for _, line := range r.Output {
+			b.WriteString(line + "\n")
+		}
+	}
+
+	return b.String()
+}
+
+// scriptTransaction collects the undo and redo functions
+// of the edits made by a script run.
+type scriptTransaction struct {
+	undos   []func() error
+	redos   []func() error
+	changes []ScriptChange
+}
+
+func (tx *scriptTransaction) undo() error {
+	for i := len(tx.undos) - 1; i >= 0; i-- {
+		if err := tx.undos[i](); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (tx *scriptTransaction) redo() error {
+	for _, redo := range tx.redos {
+		if err := redo(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// applyScriptEdit calls the handler of a service and records the edit into the transaction.
+func applyScriptEdit[E entity](tx *scriptTransaction, ent E, field string, oldValue, newValue any,
+	reqDataPtr any, handlerFn func(E, *request, *response[E]) error) error {
+
+	res := newResponse[E]()
+	if err := handlerFn(ent, newRequest(reqDataPtr), res); err != nil {
+		return err
+	}
+
+	if !res.changed {
+		return nil
+	}
+
+	tx.undos = append(tx.undos, func() error {
+		_, err := res.undo()
+		return err
+	})
+	tx.redos = append(tx.redos, func() error {
+		_, err := res.redo()
+		return err
+	})
+
+	tx.changes = append(tx.changes, ScriptChange{
+		EntityID: ent.EntityID().String(),
+		Kind:     newEntityKind(ent.EntityKind()),
+		Name:     ent.Name(),
+		Field:    field,
+		OldValue: fmt.Sprint(oldValue),
+		NewValue: fmt.Sprint(newValue),
+	})
+
+	return nil
+}
+
+// scriptEntity exposes an entity of the network to the scripts.
+// The attributes are read from the entity every time, so they are always up to date.
+type scriptEntity struct {
+	ent entity
+}
+
+var (
+	_ starlark.HasAttrs   = (*scriptEntity)(nil)
+	_ starlark.Comparable = (*scriptEntity)(nil)
+)
+
+func newScriptEntity(ent entity) *scriptEntity {
+	return &scriptEntity{ent: ent}
+}
+
+func newScriptEntityList[E entity](entities []E) *starlark.List {
+	values := make([]starlark.Value, 0, len(entities))
+	for _, ent := range entities {
+		values = append(values, newScriptEntity(ent))
+	}
+	return starlark.NewList(values)
+}
+
+func (e *scriptEntity) String() string {
+	return fmt.Sprintf("%s(%q)", e.Type(), e.ent.Name())
+}
+
+func (e *scriptEntity) Type() string {
+	return string(newEntityKind(e.ent.EntityKind()))
+}
+
+func (e *scriptEntity) Freeze() {}
+
+func (e *scriptEntity) Truth() starlark.Bool {
+	return starlark.True
+}
+
+func (e *scriptEntity) Hash() (uint32, error) {
+	return starlark.String(e.ent.EntityID()).Hash()
+}
+
+func (e *scriptEntity) CompareSameType(op syntax.Token, y starlark.Value, _ int) (bool, error) {
+	sameEnt := e.ent.EntityID() == y.(*scriptEntity).ent.EntityID()
+
+	switch op {
+	case syntax.EQL:
+		return sameEnt, nil
+	case syntax.NEQ:
+		return !sameEnt, nil
+	}
+
+	return false, fmt.Errorf("%s %s %s not implemented", e.Type(), op, y.Type())
+}
+
+func (e *scriptEntity) Attr(name string) (starlark.Value, error) {
+	val, ok := e.attrs()[name]
+	if !ok {
+		return nil, nil
+	}
+	return val, nil
+}
+
+func (e *scriptEntity) AttrNames() []string {
+	names := []string{}
+	for name := range e.attrs() {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func (e *scriptEntity) attrs() starlark.StringDict {
+	attrs := starlark.StringDict{
+		"id":   starlark.String(e.ent.EntityID()),
+		"kind": starlark.String(e.Type()),
+		"name": starlark.String(e.ent.Name()),
+		"desc": starlark.String(e.ent.Desc()),
+	}
+
+	switch ent := e.ent.(type) {
+	case *acmelib.Bus:
+		attrs["baudrate"] = starlark.MakeInt(ent.Baudrate())
+
+		nodes := []*acmelib.Node{}
+		messages := []*acmelib.Message{}
+		for _, nodeInt := range ent.NodeInterfaces() {
+			nodes = append(nodes, nodeInt.Node())
+			messages = append(messages, nodeInt.SentMessages()...)
+		}
+		attrs["nodes"] = newScriptEntityList(nodes)
+		attrs["messages"] = newScriptEntityList(messages)
+
+	case *acmelib.Node:
+		attrs["node_id"] = starlark.MakeUint(uint(ent.ID()))
+
+	case *acmelib.Message:
+		attrs["message_id"] = starlark.MakeUint(uint(ent.ID()))
+		attrs["can_id"] = starlark.MakeUint(uint(ent.GetCANID()))
+		attrs["size_byte"] = starlark.MakeInt(ent.SizeByte())
+		attrs["cycle_time"] = starlark.MakeInt(ent.CycleTime())
+		attrs["signals"] = newScriptEntityList(ent.Signals())
+
+		attrs["sender"] = starlark.None
+		attrs["bus"] = starlark.None
+		if nodeInt := ent.SenderNodeInterface(); nodeInt != nil {
+			attrs["sender"] = newScriptEntity(nodeInt.Node())
+			if bus := nodeInt.ParentBus(); bus != nil {
+				attrs["bus"] = newScriptEntity(bus)
+			}
+		}
+
+	case acmelib.Signal:
+		attrs["start_bit"] = starlark.MakeInt(ent.GetStartBit())
+		attrs["size"] = starlark.MakeInt(ent.GetSize())
+
+		attrs["message"] = starlark.None
+		if msg := ent.ParentMessage(); msg != nil {
+			attrs["message"] = newScriptEntity(msg)
+		}
+
+		attrs["type"] = starlark.None
+		attrs["unit"] = starlark.None
+		attrs["enum"] = starlark.None
+		switch ent.Kind() {
+		case acmelib.SignalKindStandard:
+			if stdSig, err := ent.ToStandard(); err == nil {
+				attrs["type"] = newScriptEntity(stdSig.Type())
+				if stdSig.Unit() != nil {
+					attrs["unit"] = newScriptEntity(stdSig.Unit())
+				}
+			}
+
+		case acmelib.SignalKindEnum:
+			if enumSig, err := ent.ToEnum(); err == nil {
+				attrs["enum"] = newScriptEntity(enumSig.Enum())
+			}
+		}
+
+	case *acmelib.SignalType:
+		attrs["size"] = starlark.MakeInt(ent.Size())
+		attrs["signed"] = starlark.Bool(ent.Signed())
+		attrs["min"] = starlark.Float(ent.Min())
+		attrs["max"] = starlark.Float(ent.Max())
+		attrs["scale"] = starlark.Float(ent.Scale())
+		attrs["offset"] = starlark.Float(ent.Offset())
+
+	case *acmelib.SignalUnit:
+		attrs["symbol"] = starlark.String(ent.Symbol())
+
+	case *acmelib.SignalEnum:
+		attrs["size"] = starlark.MakeInt(ent.GetSize())
+
+		values := []starlark.Value{}
+		for _, val := range ent.Values() {
+			values = append(values, starlark.String(val.Name()))
+		}
+		attrs["values"] = starlark.NewList(values)
+	}
+
+	return attrs
+}
+
+type ScriptService struct {
+	windowRouted
+
+	mux *sync.RWMutex
+
+	networkSrv    *NetworkService
+	busSrv        *BusService
+	nodeSrv       *NodeService
+	messageSrv    *MessageService
+	signalSrv     *SignalService
+	signalTypeSrv *SignalTypeService
+	signalUnitSrv *SignalUnitService
+	signalEnumSrv *SignalEnumService
+
+	historyCtr *historyController
+}
+
+func newScriptService(mux *sync.RWMutex, networkSrv *NetworkService, busSrv *BusService, nodeSrv *NodeService,
+	messageSrv *MessageService, signalSrv *SignalService, signalTypeSrv *SignalTypeService,
+	signalUnitSrv *SignalUnitService, signalEnumSrv *SignalEnumService, historyCtr *historyController) *ScriptService {
+
+	return &ScriptService{
+		mux: mux,
+
+		networkSrv:    networkSrv,
+		busSrv:        busSrv,
+		nodeSrv:       nodeSrv,
+		messageSrv:    messageSrv,
+		signalSrv:     signalSrv,
+		signalTypeSrv: signalTypeSrv,
+		signalUnitSrv: signalUnitSrv,
+		signalEnumSrv: signalEnumSrv,
+
+		historyCtr: historyCtr,
+	}
+}
+
+// forWindow returns the service of the window that made the call.
+func (s *ScriptService) forWindow(ctx context.Context) *ScriptService {
+	return s.resolve(ctx).scriptSrv
+}
+
+// Run executes the script. If the script fails, the edits made until
+// the failure are reverted and the partial result is returned with the error.
+func (s *ScriptService) Run(ctx context.Context, req RunScriptReq) (ScriptResult, error) {
+	s = s.forWindow(ctx)
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	res := ScriptResult{
+		Output:  []string{},
+		Changes: []ScriptChange{},
+		DryRun:  req.DryRun,
+	}
+
+	net := s.networkSrv.network
+	if net == nil {
+		return res, errors.New("run script: no network is open")
+	}
+
+	name := req.Name
+	if len(name) == 0 {
+		name = "script.star"
+	}
+
+	tx := &scriptTransaction{}
+
+	thread := &starlark.Thread{
+		Name: name,
+		Print: func(_ *starlark.Thread, msg string) {
+			res.Output = append(res.Output, msg)
+		},
+	}
+	thread.SetMaxExecutionSteps(scriptMaxSteps)
+
+	// the batch edits are plain loops over the entities, so the control
+	// statements are allowed outside of the functions
+	fileOpts := &syntax.FileOptions{
+		TopLevelControl: true,
+		GlobalReassign:  true,
+	}
+
+	_, runErr := starlark.ExecFileOptions(fileOpts, thread, name, req.Source, s.getPredeclared(net, tx))
+
+	res.Changes = tx.changes
+
+	if runErr != nil || req.DryRun {
+		if err := tx.undo(); err != nil {
+			return res, err
+		}
+
+		if runErr != nil {
+			var evalErr *starlark.EvalError
+			if errors.As(runErr, &evalErr) {
+				return res, errors.New(evalErr.Backtrace())
+			}
+			return res, runErr
+		}
+
+		return res, nil
+	}
+
+	if len(tx.changes) == 0 {
+		return res, nil
+	}
+
+	s.historyCtr.sendOperation(
+		serviceKindNetwork,
+		func() (any, error) {
+			s.mux.Lock()
+			defer s.mux.Unlock()
+
+			if err := tx.undo(); err != nil {
+				return nil, err
+			}
+
+			return newNetwork(s.networkSrv.network), nil
+		},
+		func() (any, error) {
+			s.mux.Lock()
+			defer s.mux.Unlock()
+
+			if err := tx.redo(); err != nil {
+				return nil, err
+			}
+
+			return newNetwork(s.networkSrv.network), nil
+		},
+	)
+
+	return res, nil
+}
+
+func (s *ScriptService) getPredeclared(net *acmelib.Network, tx *scriptTransaction) starlark.StringDict {
+	listFn := func(name string, listEntities func(*networkEntities) *starlark.List) *starlark.Builtin {
+		return starlark.NewBuiltin(name, func(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 0); err != nil {
+				return nil, err
+			}
+			return listEntities(collectNetworkEntities(net)), nil
+		})
+	}
+
+	return starlark.StringDict{
+		"network_name": starlark.String(net.Name()),
+
+		"buses": listFn("buses", func(e *networkEntities) *starlark.List { return newScriptEntityList(e.buses) }),
+		"nodes": listFn("nodes", func(e *networkEntities) *starlark.List { return newScriptEntityList(e.nodes) }),
+		"messages": listFn("messages", func(e *networkEntities) *starlark.List {
+			return newScriptEntityList(e.messages)
+		}),
+		"signals": listFn("signals", func(e *networkEntities) *starlark.List {
+			return newScriptEntityList(e.signals)
+		}),
+		"signal_types": listFn("signal_types", func(e *networkEntities) *starlark.List {
+			return newScriptEntityList(e.sigTypes)
+		}),
+		"signal_units": listFn("signal_units", func(e *networkEntities) *starlark.List {
+			return newScriptEntityList(e.sigUnits)
+		}),
+		"signal_enums": listFn("signal_enums", func(e *networkEntities) *starlark.List {
+			return newScriptEntityList(e.sigEnums)
+		}),
+
+		"set_name":       s.newEditBuiltin(tx, "set_name", s.setName),
+		"set_desc":       s.newEditBuiltin(tx, "set_desc", s.setDesc),
+		"set_baudrate":   s.newEditBuiltin(tx, "set_baudrate", s.setBaudrate),
+		"set_node_id":    s.newEditBuiltin(tx, "set_node_id", s.setNodeID),
+		"set_message_id": s.newEditBuiltin(tx, "set_message_id", s.setMessageID),
+		"set_size_byte":  s.newEditBuiltin(tx, "set_size_byte", s.setSizeByte),
+		"set_cycle_time": s.newEditBuiltin(tx, "set_cycle_time", s.setCycleTime),
+		"set_min":        s.newEditBuiltin(tx, "set_min", s.setMin),
+		"set_max":        s.newEditBuiltin(tx, "set_max", s.setMax),
+		"set_scale":      s.newEditBuiltin(tx, "set_scale", s.setScale),
+		"set_offset":     s.newEditBuiltin(tx, "set_offset", s.setOffset),
+		"set_symbol":     s.newEditBuiltin(tx, "set_symbol", s.setSymbol),
+	}
+}
+
+// newEditBuiltin returns a builtin that takes an entity and a value, e.g. set_name(msg, "NEW_NAME").
+func (s *ScriptService) newEditBuiltin(tx *scriptTransaction, name string,
+	editFn func(tx *scriptTransaction, ent entity, val starlark.Value) error) *starlark.Builtin {
+
+	return starlark.NewBuiltin(name, func(_ *starlark.Thread, fn *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var scriptEnt *scriptEntity
+		var val starlark.Value
+		if err := starlark.UnpackPositionalArgs(fn.Name(), args, kwargs, 2, &scriptEnt, &val); err != nil {
+			return nil, err
+		}
+
+		// the library is shared by the signal type, unit and enum services
+		if err := s.signalTypeSrv.library.checkEditable(scriptEnt.ent); err != nil {
+			return nil, fmt.Errorf("%s: %w", fn.Name(), err)
+		}
+
+		if err := editFn(tx, scriptEnt.ent, val); err != nil {
+			return nil, fmt.Errorf("%s: %w", fn.Name(), err)
+		}
+
+		return starlark.None, nil
+	})
+}
+
+func scriptString(val starlark.Value) (string, error) {
+	str, ok := starlark.AsString(val)
+	if !ok {
+		return "", fmt.Errorf("got %s, want string", val.Type())
+	}
+	return str, nil
+}
+
+func scriptInt(val starlark.Value) (int, error) {
+	var res int
+	if err := starlark.AsInt(val, &res); err != nil {
+		return 0, err
+	}
+	return res, nil
+}
+
+func scriptUint(val starlark.Value) (uint, error) {
+	var res uint
+	if err := starlark.AsInt(val, &res); err != nil {
+		return 0, err
+	}
+	return res, nil
+}
+
+func scriptFloat(val starlark.Value) (float64, error) {
+	res, ok := starlark.AsFloat(val)
+	if !ok {
+		return 0, fmt.Errorf("got %s, want float", val.Type())
+	}
+	return res, nil
+}
+
+func scriptKindError(ent entity) error {
+	return fmt.Errorf("not supported by %s", newEntityKind(ent.EntityKind()))
+}
+
+func (s *ScriptService) setName(tx *scriptTransaction, ent entity, val starlark.Value) error {
+	name, err := scriptString(val)
+	if err != nil {
+		return err
+	}
+
+	req := &UpdateNameReq{Name: name}
+	oldName := ent.Name()
+
+	switch tmpEnt := ent.(type) {
+	case *acmelib.Bus:
+		return applyScriptEdit(tx, tmpEnt, "name", oldName, name, req, s.busSrv.handler.updateName)
+	case *acmelib.Node:
+		return applyScriptEdit(tx, tmpEnt, "name", oldName, name, req, s.nodeSrv.handler.updateName)
+	case *acmelib.Message:
+		return applyScriptEdit(tx, tmpEnt, "name", oldName, name, req, s.messageSrv.handler.updateName)
+	case acmelib.Signal:
+		return applyScriptEdit(tx, tmpEnt, "name", oldName, name, req, s.signalSrv.handler.updateName)
+	case *acmelib.SignalType:
+		return applyScriptEdit(tx, tmpEnt, "name", oldName, name, req, s.signalTypeSrv.handler.updateName)
+	case *acmelib.SignalUnit:
+		return applyScriptEdit(tx, tmpEnt, "name", oldName, name, req, s.signalUnitSrv.handler.updateName)
+	case *acmelib.SignalEnum:
+		return applyScriptEdit(tx, tmpEnt, "name", oldName, name, req, s.signalEnumSrv.handler.updateName)
+	}
+
+	return scriptKindError(ent)
+}
+
+func (s *ScriptService) setDesc(tx *scriptTransaction, ent entity, val starlark.Value) error {
+	desc, err := scriptString(val)
+	if err != nil {
+		return err
+	}
+
+	req := &UpdateDescReq{Desc: desc}
+	oldDesc := ent.Desc()
+
+	switch tmpEnt := ent.(type) {
+	case *acmelib.Bus:
+		return applyScriptEdit(tx, tmpEnt, "desc", oldDesc, desc, req, s.busSrv.handler.updateDesc)
+	case *acmelib.Node:
+		return applyScriptEdit(tx, tmpEnt, "desc", oldDesc, desc, req, s.nodeSrv.handler.updateDesc)
+	case *acmelib.Message:
+		return applyScriptEdit(tx, tmpEnt, "desc", oldDesc, desc, req, s.messageSrv.handler.updateDesc)
+	case acmelib.Signal:
+		return applyScriptEdit(tx, tmpEnt, "desc", oldDesc, desc, req, s.signalSrv.handler.updateDesc)
+	case *acmelib.SignalType:
+		return applyScriptEdit(tx, tmpEnt, "desc", oldDesc, desc, req, s.signalTypeSrv.handler.updateDesc)
+	case *acmelib.SignalUnit:
+		return applyScriptEdit(tx, tmpEnt, "desc", oldDesc, desc, req, s.signalUnitSrv.handler.updateDesc)
+	case *acmelib.SignalEnum:
+		return applyScriptEdit(tx, tmpEnt, "desc", oldDesc, desc, req, s.signalEnumSrv.handler.updateDesc)
+	}
+
+	return scriptKindError(ent)
+}
+
+func (s *ScriptService) setBaudrate(tx *scriptTransaction, ent entity, val starlark.Value) error {
+	bus, ok := ent.(*acmelib.Bus)
+	if !ok {
+		return scriptKindError(ent)
+	}
+
+	baudrate, err := scriptInt(val)
+	if err != nil {
+		return err
+	}
+
+	return applyScriptEdit(tx, bus, "baudrate", bus.Baudrate(), baudrate,
+		&UpdateBaudrateReq{Baudrate: baudrate}, s.busSrv.handler.updateBaudrate)
+}
+
+func (s *ScriptService) setNodeID(tx *scriptTransaction, ent entity, val starlark.Value) error {
+	node, ok := ent.(*acmelib.Node)
+	if !ok {
+		return scriptKindError(ent)
+	}
+
+	nodeID, err := scriptUint(val)
+	if err != nil {
+		return err
+	}
+
+	return applyScriptEdit(tx, node, "node id", node.ID(), nodeID,
+		&UpdateNodeIDReq{NodeID: nodeID}, s.nodeSrv.handler.updateNodeID)
+}
+
+func (s *ScriptService) setMessageID(tx *scriptTransaction, ent entity, val starlark.Value) error {
+	msg, ok := ent.(*acmelib.Message)
+	if !ok {
+		return scriptKindError(ent)
+	}
+
+	msgID, err := scriptUint(val)
+	if err != nil {
+		return err
+	}
+
+	return applyScriptEdit(tx, msg, "message id", msg.ID(), msgID,
+		&UpdateMessageIDReq{MessageID: msgID}, s.messageSrv.handler.updateMessageID)
+}
+
+func (s *ScriptService) setSizeByte(tx *scriptTransaction, ent entity, val starlark.Value) error {
+	msg, ok := ent.(*acmelib.Message)
+	if !ok {
+		return scriptKindError(ent)
+	}
+
+	sizeByte, err := scriptInt(val)
+	if err != nil {
+		return err
+	}
+
+	return applyScriptEdit(tx, msg, "size", msg.SizeByte(), sizeByte,
+		&UpdateSizeByteReq{SizeByte: sizeByte}, s.messageSrv.handler.updateSizeByte)
+}
+
+func (s *ScriptService) setCycleTime(tx *scriptTransaction, ent entity, val starlark.Value) error {
+	msg, ok := ent.(*acmelib.Message)
+	if !ok {
+		return scriptKindError(ent)
+	}
+
+	cycleTime, err := scriptInt(val)
+	if err != nil {
+		return err
+	}
+
+	return applyScriptEdit(tx, msg, "cycle time", msg.CycleTime(), cycleTime,
+		&UpdateCycleTimeReq{CycleTime: cycleTime}, s.messageSrv.handler.updateCycleTime)
+}
+
+func (s *ScriptService) setMin(tx *scriptTransaction, ent entity, val starlark.Value) error {
+	sigType, ok := ent.(*acmelib.SignalType)
+	if !ok {
+		return scriptKindError(ent)
+	}
+
+	minVal, err := scriptFloat(val)
+	if err != nil {
+		return err
+	}
+
+	return applyScriptEdit(tx, sigType, "min", sigType.Min(), minVal,
+		&UpdateMinReq{Min: minVal}, s.signalTypeSrv.handler.updateMin)
+}
+
+func (s *ScriptService) setMax(tx *scriptTransaction, ent entity, val starlark.Value) error {
+	sigType, ok := ent.(*acmelib.SignalType)
+	if !ok {
+		return scriptKindError(ent)
+	}
+
+	maxVal, err := scriptFloat(val)
+	if err != nil {
+		return err
+	}
+
+	return applyScriptEdit(tx, sigType, "max", sigType.Max(), maxVal,
+		&UpdateMaxReq{Max: maxVal}, s.signalTypeSrv.handler.updateMax)
+}
+
+func (s *ScriptService) setScale(tx *scriptTransaction, ent entity, val starlark.Value) error {
+	sigType, ok := ent.(*acmelib.SignalType)
+	if !ok {
+		return scriptKindError(ent)
+	}
+
+	scale, err := scriptFloat(val)
+	if err != nil {
+		return err
+	}
+
+	return applyScriptEdit(tx, sigType, "scale", sigType.Scale(), scale,
+		&UpdateScaleReq{Scale: scale}, s.signalTypeSrv.handler.updateScale)
+}
+
+func (s *ScriptService) setOffset(tx *scriptTransaction, ent entity, val starlark.Value) error {
+	sigType, ok := ent.(*acmelib.SignalType)
+	if !ok {
+		return scriptKindError(ent)
+	}
+
+	offset, err := scriptFloat(val)
+	if err != nil {
+		return err
+	}
+
+	return applyScriptEdit(tx, sigType, "offset", sigType.Offset(), offset,
+		&UpdateOffsetReq{Offset: offset}, s.signalTypeSrv.handler.updateOffset)
+}
+
+func (s *ScriptService) setSymbol(tx *scriptTransaction, ent entity, val starlark.Value) error {
+	sigUnit, ok := ent.(*acmelib.SignalUnit)
+	if !ok {
+		return scriptKindError(ent)
+	}
+
+	symbol, err := scriptString(val)
+	if err != nil {
+		return err
+	}
+
+	return applyScriptEdit(tx, sigUnit, "symbol", sigUnit.Symbol(), symbol,
+		&UpdateSymbolReq{Symbol: symbol}, s.signalUnitSrv.handler.updateSymbol)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+const testCycleTimeScript = `
+for msg in messages():
+    if msg.cycle_time == 100:
+        set_cycle_time(msg, 50)
+        print(msg.name)
+`
+
+func newTestScriptManager(t *testing.T) *serviceManager {
+	t.Helper()
+
+	m, _ := newTestManager(t)
+
+	if err := m.openNetwork(filepath.Join("testdata", "simple.binpb")); err != nil {
+		t.Fatal(err)
+	}
+	waitServices(m)
+
+	return m
+}
+
+func countCycleTime(m *serviceManager, cycleTime int) int {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	count := 0
+	for _, msg := range collectNetworkEntities(m.network).messages {
+		if msg.CycleTime() == cycleTime {
+			count++
+		}
+	}
+	return count
+}
+
+func TestScriptDryRun(t *testing.T) {
+	m := newTestScriptManager(t)
+
+	before := countCycleTime(m, 100)
+	if before == 0 {
+		t.Fatal("expected at least one message with a cycle time of 100")
+	}
+
+	res, err := m.scriptSrv.Run(t.Context(), RunScriptReq{Source: testCycleTimeScript, DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(res.Changes) != before {
+		t.Errorf("expected %d changes, got %d", before, len(res.Changes))
+	}
+	if len(res.Output) != before {
+		t.Errorf("expected %d output lines, got %d", before, len(res.Output))
+	}
+
+	if after := countCycleTime(m, 100); after != before {
+		t.Errorf("the dry run modified the network")
+	}
+	waitHistory(t, m, 0)
+}
+
+func TestScriptUndoRedo(t *testing.T) {
+	m := newTestScriptManager(t)
+
+	before := countCycleTime(m, 100)
+
+	if _, err := m.scriptSrv.Run(t.Context(), RunScriptReq{Source: testCycleTimeScript}); err != nil {
+		t.Fatal(err)
+	}
+	waitHistory(t, m, 1)
+
+	if count := countCycleTime(m, 100); count != 0 {
+		t.Errorf("expected no messages with a cycle time of 100, got %d", count)
+	}
+
+	if _, err := m.historySrv.Undo(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if count := countCycleTime(m, 100); count != before {
+		t.Errorf("expected %d messages with a cycle time of 100 after the undo, got %d", before, count)
+	}
+
+	if _, err := m.historySrv.Redo(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if count := countCycleTime(m, 100); count != 0 {
+		t.Errorf("expected no messages with a cycle time of 100 after the redo, got %d", count)
+	}
+}
+
+func TestScriptErrorRollback(t *testing.T) {
+	m := newTestScriptManager(t)
+
+	before := countCycleTime(m, 100)
+
+	source := testCycleTimeScript + "fail('stop')\n"
+	if _, err := m.scriptSrv.Run(t.Context(), RunScriptReq{Source: source}); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	if count := countCycleTime(m, 100); count != before {
+		t.Errorf("the failed script modified the network")
+	}
+	waitHistory(t, m, 0)
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+type SearchMode string
+
+const (
+	SearchModeFuzzy SearchMode = "fuzzy"
+	SearchModeRegex SearchMode = "regex"
+)
+
+type SearchField string
+
+const (
+	SearchFieldName      SearchField = "name"
+	SearchFieldDesc      SearchField = "desc"
+	SearchFieldNodeID    SearchField = "node-id"
+	SearchFieldMessageID SearchField = "message-id"
+	SearchFieldCANID     SearchField = "can-id"
+)
+
+// scores assigned to the different kinds of match
+const (
+	searchScoreExactName  = 100
+	searchScoreNumericID  = 90
+	searchScorePrefixName = 80
+	searchScoreRegexName  = 70
+	searchScoreInnerName  = 60
+
+	// a description match is never ranked higher than this value
+	searchScoreMaxDesc = 40
+)
+
+type SearchReq struct {
+	Query string       `json:"query"`
+	Mode  SearchMode   `json:"mode"`
+	Kinds []EntityKind `json:"kinds"`
+	Limit int          `json:"limit"`
+}
+
+type SearchResult struct {
+	EntityPath
+
+	Paths []EntityPath `json:"paths"`
+
+	MatchedField SearchField `json:"matchedField"`
+	Score        int         `json:"score"`
+}
+
+type SearchService struct {
+	windowRouted
+
+	mux *sync.RWMutex
+
+	busCtr        *busController
+	nodeCtr       *nodeController
+	messageCtr    *messageController
+	signalCtr     *signalController
+	signalTypeCtr *signalTypeController
+	signalUnitCtr *signalUnitController
+	signalEnumCtr *signalEnumController
+}
+
+func newSearchService(mux *sync.RWMutex, busCtr *busController, nodeCtr *nodeController, messageCtr *messageController,
+	signalCtr *signalController, signalTypeCtr *signalTypeController, signalUnitCtr *signalUnitController, signalEnumCtr *signalEnumController) *SearchService {
+
+	return &SearchService{
+		mux: mux,
+
+		busCtr:        busCtr,
+		nodeCtr:       nodeCtr,
+		messageCtr:    messageCtr,
+		signalCtr:     signalCtr,
+		signalTypeCtr: signalTypeCtr,
+		signalUnitCtr: signalUnitCtr,
+		signalEnumCtr: signalEnumCtr,
+	}
+}
+
+// forWindow returns the service of the window that made the call.
+func (s *SearchService) forWindow(ctx context.Context) *SearchService {
+	return s.resolve(ctx).searchSrv
+}
+
+// Search looks for the entities of the network that match the query.
+// Names and descriptions are matched with the selected mode,
+// while node ids, message ids and CAN ids are matched when the query
+// is a decimal or an hexadecimal (0x prefixed) number.
+// The results are sorted from the best to the worst match.
+func (s *SearchService) Search(ctx context.Context, req SearchReq) ([]SearchResult, error) {
+	s = s.forWindow(ctx)
+
+	query := strings.TrimSpace(req.Query)
+	if len(query) == 0 {
+		return []SearchResult{}, nil
+	}
+
+	matcher, err := newSearchMatcher(query, req.Mode)
+	if err != nil {
+		return nil, err
+	}
+
+	kinds := make(map[EntityKind]struct{})
+	for _, kind := range req.Kinds {
+		kinds[kind] = struct{}{}
+	}
+	hasKind := func(kind EntityKind) bool {
+		if len(kinds) == 0 {
+			return true
+		}
+		_, ok := kinds[kind]
+		return ok
+	}
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	res := []SearchResult{}
+	add := func(ent entity, paths []EntityPath, field SearchField, score int) {
+		if score <= 0 {
+			return
+		}
+
+		res = append(res, SearchResult{
+			EntityPath:   newEntityPath(ent),
+			Paths:        paths,
+			MatchedField: field,
+			Score:        score,
+		})
+	}
+
+	if hasKind(EntityKindBus) {
+		for _, bus := range s.busCtr.list() {
+			field, score := matcher.matchEntity(bus)
+			add(bus, newBusEntityPaths(bus), field, score)
+		}
+	}
+
+	if hasKind(EntityKindNode) {
+		for _, node := range s.nodeCtr.list() {
+			field, score := matcher.matchEntity(node)
+			if matcher.matchNumber(uint64(node.ID())) && score < searchScoreNumericID {
+				field, score = SearchFieldNodeID, searchScoreNumericID
+			}
+			add(node, []EntityPath{newEntityPath(node)}, field, score)
+		}
+	}
+
+	if hasKind(EntityKindMessage) {
+		for _, msg := range s.messageCtr.list() {
+			field, score := matcher.matchEntity(msg)
+			if score < searchScoreNumericID {
+				if matcher.matchNumber(uint64(msg.GetCANID())) {
+					field, score = SearchFieldCANID, searchScoreNumericID
+				} else if !msg.HasStaticCANID() && matcher.matchNumber(uint64(msg.ID())) {
+					field, score = SearchFieldMessageID, searchScoreNumericID
+				}
+			}
+			add(msg, newMessageEntityPaths(msg), field, score)
+		}
+	}
+
+	if hasKind(EntityKindSignal) {
+		for _, sig := range s.signalCtr.list() {
+			field, score := matcher.matchEntity(sig)
+			add(sig, newSignalEntityPaths(sig), field, score)
+		}
+	}
+
+	if hasKind(EntityKindSignalType) {
+		for _, sigType := range s.signalTypeCtr.list() {
+			field, score := matcher.matchEntity(sigType)
+			add(sigType, []EntityPath{newEntityPath(sigType)}, field, score)
+		}
+	}
+
+	if hasKind(EntityKindSignalUnit) {
+		for _, sigUnit := range s.signalUnitCtr.list() {
+			field, score := matcher.matchEntity(sigUnit)
+			add(sigUnit, []EntityPath{newEntityPath(sigUnit)}, field, score)
+		}
+	}
+
+	if hasKind(EntityKindSignalEnum) {
+		for _, sigEnum := range s.signalEnumCtr.list() {
+			field, score := matcher.matchEntity(sigEnum)
+			add(sigEnum, []EntityPath{newEntityPath(sigEnum)}, field, score)
+		}
+	}
+
+	slices.SortFunc(res, func(a, b SearchResult) int {
+		if a.Score == b.Score {
+			if len(a.Name) == len(b.Name) {
+				return strings.Compare(a.Name, b.Name)
+			}
+			return len(a.Name) - len(b.Name)
+		}
+		return b.Score - a.Score
+	})
+
+	if req.Limit > 0 && len(res) > req.Limit {
+		res = res[:req.Limit]
+	}
+
+	return res, nil
+}
+
+type searchMatcher struct {
+	mode SearchMode
+
+	lowerQuery string
+	regex      *regexp.Regexp
+
+	number    uint64
+	hasNumber bool
+}
+
+func newSearchMatcher(query string, mode SearchMode) (*searchMatcher, error) {
+	m := &searchMatcher{
+		mode: mode,
+
+		lowerQuery: strings.ToLower(query),
+	}
+
+	switch mode {
+	case SearchModeFuzzy, "":
+		m.mode = SearchModeFuzzy
+
+	case SearchModeRegex:
+		regex, err := regexp.Compile("(?i)" + query)
+		if err != nil {
+			return nil, err
+		}
+		m.regex = regex
+
+	default:
+		return nil, errors.New("search: invalid mode")
+	}
+
+	m.number, m.hasNumber = parseSearchNumber(query)
+
+	return m, nil
+}
+
+// parseSearchNumber parses the query as a decimal or
+// an hexadecimal number prefixed by 0x.
+func parseSearchNumber(query string) (uint64, bool) {
+	base := 10
+	if strings.HasPrefix(query, "0x") || strings.HasPrefix(query, "0X") {
+		query = query[2:]
+		base = 16
+	}
+
+	num, err := strconv.ParseUint(query, base, 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return num, true
+}
+
+func (m *searchMatcher) matchNumber(num uint64) bool {
+	return m.hasNumber && m.number == num
+}
+
+// matchEntity returns the best matching field of the entity and its score.
+// A score of 0 means that the entity does not match.
+func (m *searchMatcher) matchEntity(ent entity) (SearchField, int) {
+	nameScore := m.matchText(ent.Name())
+	if nameScore > 0 {
+		return SearchFieldName, nameScore
+	}
+
+	desc := ent.Desc()
+	if len(desc) == 0 {
+		return SearchFieldName, 0
+	}
+
+	descScore := m.matchText(desc) / 2
+	if descScore > searchScoreMaxDesc {
+		descScore = searchScoreMaxDesc
+	}
+
+	return SearchFieldDesc, descScore
+}
+
+func (m *searchMatcher) matchText(text string) int {
+	if m.mode == SearchModeRegex {
+		if m.regex.MatchString(text) {
+			return searchScoreRegexName
+		}
+		return 0
+	}
+
+	lowerText := strings.ToLower(text)
+
+	switch {
+	case lowerText == m.lowerQuery:
+		return searchScoreExactName
+	case strings.HasPrefix(lowerText, m.lowerQuery):
+		return searchScorePrefixName
+	case strings.Contains(lowerText, m.lowerQuery):
+		return searchScoreInnerName
+	}
+
+	return fuzzyScore(m.lowerQuery, text)
+}
+
+// fuzzyScore checks if all the characters of the lower case pattern appear in order
+// in the text, ignoring case, and returns a score between 1 and 50, 0 if there is no match.
+// Consecutive characters and characters placed at the start of a word
+// (after an underscore, a space or a case change) increase the score.
+func fuzzyScore(pattern, text string) int {
+	patternRunes := []rune(pattern)
+	textRunes := []rune(text)
+
+	if len(patternRunes) == 0 || len(patternRunes) > len(textRunes) {
+		return 0
+	}
+
+	points := 0
+	patIdx := 0
+	lastMatchIdx := -2
+	for idx, r := range textRunes {
+		if patIdx == len(patternRunes) {
+			break
+		}
+
+		if unicode.ToLower(r) != patternRunes[patIdx] {
+			continue
+		}
+
+		points++
+
+		if idx == lastMatchIdx+1 {
+			points += 2
+		}
+
+		if idx == 0 || isSearchWordStart(textRunes[idx-1], r) {
+			points += 3
+		}
+
+		lastMatchIdx = idx
+		patIdx++
+	}
+
+	if patIdx < len(patternRunes) {
+		return 0
+	}
+
+	// the maximum reachable points are 6 per character
+	score := points * 50 / (len(patternRunes) * 6)
+	if score < 1 {
+		score = 1
+	}
+
+	return score
+}
+
+func isSearchWordStart(prev, curr rune) bool {
+	if unicode.IsLower(prev) && unicode.IsUpper(curr) {
+		return true
+	}
+
+	return prev == '_' || prev == '-' || unicode.IsSpace(prev) || unicode.IsDigit(prev)
+}
@@ -8,6 +8,7 @@ import (
 
 	"github.com/squadracorsepolito/acmelib"
 	"github.com/wailsapp/wails/v3/pkg/application"
+	"golang.org/x/exp/maps"
 )
 
 type BaseEntity struct {
@@ -54,6 +55,8 @@ func newCommonServiceHandler(sidebarCtr *sidebarController) *commonServiceHandle
 }
 
 type service[E entity, R any, H serviceHandler[E, R]] struct {
+	windowRouted
+
 	kind serviceKind
 
 	handler H
@@ -61,16 +64,23 @@ type service[E entity, R any, H serviceHandler[E, R]] struct {
 	mux      *sync.RWMutex
 	entities map[acmelib.EntityID]E
 
+	emitter eventEmitter
+
 	loadCh   chan []E
 	addCh    chan E
 	deleteCh chan E
 	clearCh  chan struct{}
 
-	sidebarCtr *sidebarController
-	historyCtr *historyController
+	sidebarCtr    *sidebarController
+	historyCtr    *historyController
+	dependencyCtr *dependencyController
+
+	// checkEditable, if set, is called before the handlers
+	// and returns an error if the entity is read-only
+	checkEditable func(ent E) error
 }
 
-func newService[E entity, R any, H serviceHandler[E, R]](kind serviceKind, handlers H, mux *sync.RWMutex, sidebarCtr *sidebarController) *service[E, R, H] {
+func newService[E entity, R any, H serviceHandler[E, R]](kind serviceKind, handlers H, mux *sync.RWMutex, emitter eventEmitter, sidebarCtr *sidebarController) *service[E, R, H] {
 	return &service[E, R, H]{
 		kind: kind,
 
@@ -79,6 +89,8 @@ func newService[E entity, R any, H serviceHandler[E, R]](kind serviceKind, handl
 		mux:      mux,
 		entities: make(map[acmelib.EntityID]E),
 
+		emitter: emitter,
+
 		loadCh:   make(chan []E),
 		addCh:    make(chan E),
 		deleteCh: make(chan E),
@@ -92,6 +104,10 @@ func (s *service[E, R, H]) setHistoryController(historyCtr *historyController) {
 	s.historyCtr = historyCtr
 }
 
+func (s *service[E, R, H]) setDependencyController(dependencyCtr *dependencyController) {
+	s.dependencyCtr = dependencyCtr
+}
+
 func (s *service[E, R, H]) OnStartup(ctx context.Context, _ application.ServiceOptions) error {
 	go s.run(ctx)
 	return nil
@@ -155,7 +171,7 @@ func (s *service[E, R, H]) handleAdd(ent E) {
 	}
 
 	if len(addEventName) > 0 {
-		application.Get().EmitEvent(addEventName, s.handler.toResponse(ent))
+		s.emitter.emitEvent(addEventName, s.handler.toResponse(ent))
 	}
 }
 
@@ -211,6 +227,12 @@ func (s *service[E, R, H]) handle(entityID string, reqDataPtr any, handlerFn fun
 		return dummyRes, err
 	}
 
+	if s.checkEditable != nil {
+		if err := s.checkEditable(ent); err != nil {
+			return dummyRes, err
+		}
+	}
+
 	req := newRequest(reqDataPtr)
 	res := newResponse[E]()
 
@@ -227,6 +249,7 @@ func (s *service[E, R, H]) handle(entityID string, reqDataPtr any, handlerFn fun
 
 func (s *service[E, R, H]) addEntity(ent E) {
 	s.entities[ent.EntityID()] = ent
+	s.dependencyCtr.sendAdd(ent)
 }
 
 func (s *service[E, R, H]) getEntity(entityID string) (E, error) {
@@ -238,11 +261,48 @@ func (s *service[E, R, H]) getEntity(entityID string) (E, error) {
 	return ent, nil
 }
 
+func (s *service[E, R, H]) listEntities() []E {
+	return maps.Values(s.entities)
+}
+
 func (s *service[E, R, H]) removeEntity(entityID string) {
+	ent, ok := s.entities[acmelib.EntityID(entityID)]
+	if !ok {
+		return
+	}
+
 	delete(s.entities, acmelib.EntityID(entityID))
+	s.dependencyCtr.sendDelete(ent)
 }
 
-func (s *service[E, R, H]) Get(entityID string) (dummyRes R, _ error) {
+// forWindow returns the service of the window that made the call.
+func (s *service[E, R, H]) forWindow(ctx context.Context) *service[E, R, H] {
+	m := s.resolve(ctx)
+
+	var srv any
+	switch s.kind {
+	case serviceKindBus:
+		srv = m.busSrv.service
+	case serviceKindNode:
+		srv = m.nodeSrv.service
+	case serviceKindMessage:
+		srv = m.messageSrv.service
+	case serviceKindSignal:
+		srv = m.signalSrv.service
+	case serviceKindSignalType:
+		srv = m.signalTypeSrv.service
+	case serviceKindSignalUnit:
+		srv = m.signalUnitSrv.service
+	case serviceKindSignalEnum:
+		srv = m.signalEnumSrv.service
+	}
+
+	return srv.(*service[E, R, H])
+}
+
+func (s *service[E, R, H]) Get(ctx context.Context, entityID string) (dummyRes R, _ error) {
+	s = s.forWindow(ctx)
+
 	s.mux.RLock()
 	defer s.mux.RUnlock()
 
@@ -254,7 +314,9 @@ func (s *service[E, R, H]) Get(entityID string) (dummyRes R, _ error) {
 	return s.handler.toResponse(ent), nil
 }
 
-func (s *service[E, R, H]) GetInvalidNames(entityID string) []string {
+func (s *service[E, R, H]) GetInvalidNames(ctx context.Context, entityID string) []string {
+	s = s.forWindow(ctx)
+
 	s.mux.RLock()
 	defer s.mux.RUnlock()
 
@@ -270,7 +332,9 @@ func (s *service[E, R, H]) GetInvalidNames(entityID string) []string {
 	return names
 }
 
-func (s *service[E, R, H]) ListBase() []BaseEntity {
+func (s *service[E, R, H]) ListBase(ctx context.Context) []BaseEntity {
+	s = s.forWindow(ctx)
+
 	s.mux.RLock()
 	defer s.mux.RUnlock()
 
@@ -282,9 +346,35 @@ func (s *service[E, R, H]) ListBase() []BaseEntity {
 	return res
 }
 
+// isEditable reports whether the entity exists and it is not read-only.
+// The caller must hold the mutex.
+func (s *service[E, R, H]) isEditable(entityID string) bool {
+	ent, err := s.getEntity(entityID)
+	if err != nil {
+		return false
+	}
+
+	return s.checkEditable == nil || s.checkEditable(ent) == nil
+}
+
 func (s *service[E, R, H]) getController() *serviceController[E] {
 	return &serviceController[E]{
-		getFn: s.getEntity,
+		getFn:  s.getEntity,
+		listFn: s.listEntities,
+		addFn: func(ent E) {
+			s.addEntity(ent)
+			s.sidebarCtr.sendAdd(ent)
+		},
+		removeFn: func(ent E) {
+			s.removeEntity(ent.EntityID().String())
+			s.sidebarCtr.sendDelete(ent)
+		},
+		checkEditableFn: func(ent E) error {
+			if s.checkEditable == nil {
+				return nil
+			}
+			return s.checkEditable(ent)
+		},
 
 		loadCh:   s.loadCh,
 		addCh:    s.addCh,
@@ -294,7 +384,11 @@ func (s *service[E, R, H]) getController() *serviceController[E] {
 }
 
 type serviceController[E entity] struct {
-	getFn func(entityID string) (E, error)
+	getFn           func(entityID string) (E, error)
+	listFn          func() []E
+	addFn           func(ent E)
+	removeFn        func(ent E)
+	checkEditableFn func(ent E) error
 
 	loadCh   chan<- []E
 	addCh    chan<- E
@@ -306,6 +400,38 @@ func (sc *serviceController[E]) get(entityID string) (E, error) {
 	return sc.getFn(entityID)
 }
 
+// list returns all the entities of the service.
+// The caller must hold the service mutex.
+func (sc *serviceController[E]) list() []E {
+	return sc.listFn()
+}
+
+// add adds the entity to the service and to the sidebar.
+// Unlike sendAdd, it does not wait for the run loop of the service,
+// so it can be called more than once while holding the service mutex.
+// The caller must hold the service mutex.
+func (sc *serviceController[E]) add(ent E) {
+	sc.addFn(ent)
+}
+
+// remove removes the entity from the service and from the sidebar.
+// The caller must hold the service mutex.
+func (sc *serviceController[E]) remove(ent E) {
+	sc.removeFn(ent)
+}
+
+// listEditable returns the entities of the service that are not read-only.
+// The caller must hold the service mutex.
+func (sc *serviceController[E]) listEditable() []E {
+	res := []E{}
+	for _, ent := range sc.listFn() {
+		if sc.checkEditableFn(ent) == nil {
+			res = append(res, ent)
+		}
+	}
+	return res
+}
+
 func (sc *serviceController[E]) sendLoad(entities []E) {
 	sc.loadCh <- entities
 }
@@ -1,28 +1,67 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/squadracorsepolito/acmelib"
+	"github.com/squadracorsepolito/acmelib/dbc"
 	"github.com/wailsapp/wails/v3/pkg/application"
-	"golang.org/x/exp/maps"
 )
 
 type serviceManager struct {
+	// filePath is protected by mux, like the network it is saved to
 	filePath string
 
+	emitter  eventEmitter
+	log      logger
+	prompter prompter
+
+	// fileMux protects the state of the network file
+	// from the concurrent checks of the file watcher and the saves
+	fileMux   sync.Mutex
+	fileState networkFileState
+	// fileChangePending is set while the user is deciding
+	// what to do with the changes made to the file by another program
+	fileChangePending atomic.Bool
+
+	// closeConfirmed is set when the user has already decided
+	// what to do with the unsaved changes before closing the window
+	closeConfirmed atomic.Bool
+	// closePrompting is set while the close confirmation dialog is open
+	closePrompting atomic.Bool
+
+	// windows holds the managers of all the windows
+	windows *windowManagers
+
+	// settingsSrv and clipboard are shared by the managers of all the windows
 	settingsSrv *SettingsService
+	clipboard   *networkClipboard
 
 	mux     *sync.RWMutex
 	network *acmelib.Network
+	library *networkLibrary
 
 	sidebarSrv *SidebarService
 
+	dependencySrv *DependencyService
+	dependencyCtr *dependencyController
+
 	historySrv *HistoryService
 	historyCtr *historyController
 
+	searchSrv  *SearchService
+	renameSrv  *RenameService
+	lintSrv    *LintService
+	scriptSrv  *ScriptService
+	librarySrv *LibraryService
+
 	networkSrv *NetworkService
 
 	busSrv *BusService
@@ -47,58 +86,97 @@ type serviceManager struct {
 	signalEnumCtr *signalEnumController
 }
 
-func newServiceManager() *serviceManager {
+// newServiceManager returns a manager whose services emit their events
+// with the given emitter, log with the given logger and ask the user
+// with the given prompter.
+// The services handle the calls by themselves, the manager whose
+// services are bound to the frontend forwards them with setManagerResolver.
+func newServiceManager(emitter eventEmitter, log logger, prompter prompter, settingsSrv *SettingsService, clipboard *networkClipboard) *serviceManager {
 	mux := &sync.RWMutex{}
 
-	sidebarSrv := newSidebarService()
+	sidebarSrv := newSidebarService(emitter)
 	sidebarCtr := sidebarSrv.getController()
 
-	historySrv := newHistoryService()
+	historySrv := newHistoryService(emitter)
 	historyCtr := historySrv.getController()
 
-	signalTypeSrv := newSignalTypeService(mux, sidebarCtr)
+	dependencySrv := newDependencyService(mux)
+	dependencyCtr := dependencySrv.getController()
+
+	library := newNetworkLibrary()
+
+	signalTypeSrv := newSignalTypeService(mux, emitter, sidebarCtr, library)
 	signalTypeSrv.setHistoryController(historyCtr)
+	signalTypeSrv.setDependencyController(dependencyCtr)
 	signalTypeCtr := signalTypeSrv.getController()
 
-	signalUnitSrv := newSignalUnitService(mux, sidebarCtr)
+	signalUnitSrv := newSignalUnitService(mux, emitter, sidebarCtr, library)
 	signalUnitSrv.setHistoryController(historyCtr)
+	signalUnitSrv.setDependencyController(dependencyCtr)
 	signalUnitCtr := signalUnitSrv.getController()
 
-	signalEnumSrv := newSignalEnumService(mux, sidebarCtr)
+	signalEnumSrv := newSignalEnumService(mux, emitter, sidebarCtr, library)
 	signalEnumSrv.setHistoryController(historyCtr)
+	signalEnumSrv.setDependencyController(dependencyCtr)
 	signalEnumCtr := signalEnumSrv.getController()
 
-	signalSrv := newSignalService(mux, sidebarCtr, signalTypeCtr, signalUnitCtr, signalEnumCtr)
+	signalSrv := newSignalService(mux, emitter, sidebarCtr, signalTypeCtr, signalUnitCtr, signalEnumCtr)
 	signalSrv.setHistoryController(historyCtr)
+	signalSrv.setDependencyController(dependencyCtr)
 	signalCtr := signalSrv.getController()
 
-	messageSrv := newMessageService(mux, sidebarCtr, signalCtr)
+	messageSrv := newMessageService(mux, emitter, sidebarCtr, signalCtr)
 	messageSrv.setHistoryController(historyCtr)
+	messageSrv.setDependencyController(dependencyCtr)
 	messageCtr := messageSrv.getController()
 
-	busSrv := newBusService(mux, sidebarCtr)
+	busSrv := newBusService(mux, emitter, sidebarCtr)
 	busSrv.setHistoryController(historyCtr)
+	busSrv.setDependencyController(dependencyCtr)
 	busCtr := busSrv.getController()
 
-	nodeSrv := newNodeService(mux, sidebarCtr, busSrv, messageCtr)
+	nodeSrv := newNodeService(mux, emitter, sidebarCtr, busSrv, messageCtr, signalCtr)
 	nodeSrv.setHistoryController(historyCtr)
+	nodeSrv.setDependencyController(dependencyCtr)
 	nodeCtr := nodeSrv.getController()
 
-	networkSrv := newNetworkService(newNetworkHandler(sidebarCtr, busCtr), mux, sidebarCtr, historyCtr)
+	searchSrv := newSearchService(mux, busCtr, nodeCtr, messageCtr, signalCtr, signalTypeCtr, signalUnitCtr, signalEnumCtr)
+
+	networkSrv := newNetworkService(newNetworkHandler(sidebarCtr, busCtr), mux, emitter, sidebarCtr, historyCtr)
 
-	return &serviceManager{
+	renameSrv := newRenameService(mux, networkSrv, busCtr, nodeCtr, messageCtr, signalCtr, signalTypeCtr, signalUnitCtr, signalEnumCtr, sidebarCtr, historyCtr)
+	lintSrv := newLintService(mux, settingsSrv, busSrv, nodeSrv, messageSrv, signalSrv, signalTypeSrv, signalUnitSrv, signalEnumSrv)
+	scriptSrv := newScriptService(mux, networkSrv, busSrv, nodeSrv, messageSrv, signalSrv, signalTypeSrv, signalUnitSrv, signalEnumSrv, historyCtr)
+	librarySrv := newLibraryService(mux, library, networkSrv, signalTypeSrv, signalUnitSrv, signalEnumSrv, historyCtr)
+
+	m := &serviceManager{
 		filePath: "",
 
-		settingsSrv: newConfigService(),
+		emitter:  emitter,
+		log:      log,
+		prompter: prompter,
+
+		settingsSrv: settingsSrv,
+		clipboard:   clipboard,
 
 		mux:     mux,
 		network: nil,
+		library: library,
 
 		sidebarSrv: sidebarSrv,
 
+		dependencySrv: dependencySrv,
+		dependencyCtr: dependencyCtr,
+
 		historySrv: historySrv,
 		historyCtr: historyCtr,
 
+		searchSrv:  searchSrv,
+		renameSrv:  renameSrv,
+		lintSrv:    lintSrv,
+		scriptSrv:  scriptSrv,
+		librarySrv: librarySrv,
+
 		networkSrv: networkSrv,
 
 		busSrv: busSrv,
@@ -122,6 +200,38 @@ func newServiceManager() *serviceManager {
 		signalEnumSrv: signalEnumSrv,
 		signalEnumCtr: signalEnumCtr,
 	}
+
+	networkSrv.setManager(m)
+	m.setManagerResolver(func(context.Context) *serviceManager { return m })
+
+	return m
+}
+
+// setManagerResolver sets the function used by the services
+// to find the manager that handles a call.
+func (m *serviceManager) setManagerResolver(resolve managerResolver) {
+	srvs := []interface{ setManagerResolver(managerResolver) }{
+		m.sidebarSrv,
+		m.dependencySrv,
+		m.historySrv,
+		m.searchSrv,
+		m.renameSrv,
+		m.lintSrv,
+		m.scriptSrv,
+		m.librarySrv,
+		m.networkSrv,
+		m.busSrv,
+		m.nodeSrv,
+		m.messageSrv,
+		m.signalSrv,
+		m.signalTypeSrv,
+		m.signalUnitSrv,
+		m.signalEnumSrv,
+	}
+
+	for _, srv := range srvs {
+		srv.setManagerResolver(resolve)
+	}
 }
 
 func (m *serviceManager) getServices() []application.Service {
@@ -129,52 +239,191 @@ func (m *serviceManager) getServices() []application.Service {
 		application.NewService(m.settingsSrv),
 
 		application.NewService(m.sidebarSrv),
-		application.NewService(manager.historySrv),
+		application.NewService(m.dependencySrv),
+		application.NewService(m.historySrv),
+		application.NewService(m.searchSrv),
+		application.NewService(m.renameSrv),
+		application.NewService(m.lintSrv),
+		application.NewService(m.scriptSrv),
+		application.NewService(m.librarySrv),
+
+		application.NewService(m.networkSrv),
+		application.NewService(m.busSrv),
+		application.NewService(m.nodeSrv),
+		application.NewService(m.messageSrv),
+		application.NewService(m.signalSrv),
+		application.NewService(m.signalTypeSrv),
+		application.NewService(m.signalUnitSrv),
+		application.NewService(m.signalEnumSrv),
+	}
+}
+
+// start starts the services until the context is done. It is used for the managers
+// whose services are not bound to Wails, i.e. the ones of the windows,
+// the tests and the command line. The settings service is not started,
+// since it is shared by the managers.
+func (m *serviceManager) start(ctx context.Context) error {
+	srvs := []interface {
+		OnStartup(context.Context, application.ServiceOptions) error
+	}{
+		m.sidebarSrv,
+		m.dependencySrv,
+		m.historySrv,
+		m.busSrv,
+		m.nodeSrv,
+		m.messageSrv,
+		m.signalSrv,
+		m.signalTypeSrv,
+		m.signalUnitSrv,
+		m.signalEnumSrv,
+	}
 
-		application.NewService(manager.networkSrv),
-		application.NewService(manager.busSrv),
-		application.NewService(manager.nodeSrv),
-		application.NewService(manager.messageSrv),
-		application.NewService(manager.signalSrv),
-		application.NewService(manager.signalTypeSrv),
-		application.NewService(manager.signalUnitSrv),
-		application.NewService(manager.signalEnumSrv),
+	for _, srv := range srvs {
+		if err := srv.OnStartup(ctx, application.ServiceOptions{}); err != nil {
+			return err
+		}
 	}
+
+	// the history service is the only one that is not stopped by the context
+	go func() {
+		<-ctx.Done()
+		m.historySrv.OnShutdown()
+	}()
+
+	return nil
 }
 
-func (m *serviceManager) createNetwork() {
+// confirmDiscard checks if the current network has unsaved changes and, if so,
+// asks the user to save them, discard them, or cancel the operation.
+// It returns false if the operation that would throw away the network must not continue.
+func (m *serviceManager) confirmDiscard() (bool, error) {
+	if m.isSaved() {
+		return true, nil
+	}
+
+	m.mux.RLock()
+	netName := m.network.Name()
+	m.mux.RUnlock()
+
+	switch m.prompter.promptUnsavedChanges(netName) {
+	case unsavedChangesChoiceSave:
+		if err := m.saveNetwork(); err != nil {
+			return false, err
+		}
+
+		// the user may have closed the save dialog without choosing a file
+		return m.historySrv.isSaved(), nil
+
+	case unsavedChangesChoiceDiscard:
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// isSaved returns true if there is no network or if it has no unsaved changes.
+func (m *serviceManager) isSaved() bool {
+	m.mux.RLock()
+	hasNetwork := m.network != nil
+	m.mux.RUnlock()
+
+	return !hasNetwork || m.historySrv.isSaved()
+}
+
+// shouldClose is the hook called when the user tries to close the window of the manager.
+// If the network has unsaved changes, the close is cancelled and the user is asked
+// what to do; closeWindow is then called only if the changes are saved or discarded.
+func (m *serviceManager) shouldClose(closeWindow func()) bool {
+	if m.closeConfirmed.Load() || m.isSaved() {
+		return true
+	}
+
+	// the dialog cannot block the thread that is asking to close the window
+	if m.closePrompting.CompareAndSwap(false, true) {
+		go func() {
+			defer m.closePrompting.Store(false)
+
+			ok, err := m.confirmDiscard()
+			if err != nil {
+				m.log.error(err)
+				return
+			}
+
+			if ok {
+				m.closeConfirmed.Store(true)
+				closeWindow()
+			}
+		}()
+	}
+
+	return false
+}
+
+func (m *serviceManager) createNetwork() error {
+	if ok, err := m.confirmDiscard(); !ok {
+		return err
+	}
+
 	net := acmelib.NewNetwork("new_network")
 
-	m.filePath = ""
+	m.setFilePath("")
 	m.clearServices()
+	m.loadLibrary(newNetworkLibrary())
 	m.initNetwork(net)
 	m.historySrv.setSaved(false)
+
+	m.storeFileState()
+
+	return nil
 }
 
-func (m *serviceManager) initNetwork(net *acmelib.Network) {
-	m.network = net
+// networkEntities contains all the entities of a network,
+// the ones shared by more parents are listed once.
+type networkEntities struct {
+	buses    []*acmelib.Bus
+	nodes    []*acmelib.Node
+	messages []*acmelib.Message
+	signals  []acmelib.Signal
+	sigTypes []*acmelib.SignalType
+	sigUnits []*acmelib.SignalUnit
+	sigEnums []*acmelib.SignalEnum
+}
 
-	buses := net.Buses()
-	nodes := make(map[acmelib.EntityID]*acmelib.Node)
-	messages := []*acmelib.Message{}
-	signals := []acmelib.Signal{}
-	sigTypes := make(map[acmelib.EntityID]*acmelib.SignalType)
-	sigUnits := make(map[acmelib.EntityID]*acmelib.SignalUnit)
-	sigEnums := make(map[acmelib.EntityID]*acmelib.SignalEnum)
+func collectNetworkEntities(net *acmelib.Network) *networkEntities {
+	res := &networkEntities{
+		buses:    net.Buses(),
+		nodes:    []*acmelib.Node{},
+		messages: []*acmelib.Message{},
+		signals:  []acmelib.Signal{},
+		sigTypes: []*acmelib.SignalType{},
+		sigUnits: []*acmelib.SignalUnit{},
+		sigEnums: []*acmelib.SignalEnum{},
+	}
 
-	for _, bus := range buses {
+	seen := make(map[acmelib.EntityID]struct{})
+	isNew := func(entID acmelib.EntityID) bool {
+		if _, ok := seen[entID]; ok {
+			return false
+		}
+		seen[entID] = struct{}{}
+		return true
+	}
+
+	for _, bus := range res.buses {
 		for _, nodeInt := range bus.NodeInterfaces() {
 			tmpNode := nodeInt.Node()
-			nodes[tmpNode.EntityID()] = tmpNode
+			if isNew(tmpNode.EntityID()) {
+				res.nodes = append(res.nodes, tmpNode)
+			}
 
 			tmpMessages := nodeInt.SentMessages()
-			messages = append(messages, tmpMessages...)
+			res.messages = append(res.messages, tmpMessages...)
 
 			for _, msg := range tmpMessages {
 				tmpSignals := msg.Signals()
-				signals = append(signals, tmpSignals...)
+				res.signals = append(res.signals, tmpSignals...)
 
-				for _, sig := range msg.Signals() {
+				for _, sig := range tmpSignals {
 
 					switch sig.Kind() {
 					case acmelib.SignalKindStandard:
@@ -182,10 +431,13 @@ func (m *serviceManager) initNetwork(net *acmelib.Network) {
 						if err != nil {
 							panic(err)
 						}
-						sigTypes[stdSig.Type().EntityID()] = stdSig.Type()
 
-						if stdSig.Unit() != nil {
-							sigUnits[stdSig.Unit().EntityID()] = stdSig.Unit()
+						if isNew(stdSig.Type().EntityID()) {
+							res.sigTypes = append(res.sigTypes, stdSig.Type())
+						}
+
+						if stdSig.Unit() != nil && isNew(stdSig.Unit().EntityID()) {
+							res.sigUnits = append(res.sigUnits, stdSig.Unit())
 						}
 
 					case acmelib.SignalKindEnum:
@@ -193,21 +445,36 @@ func (m *serviceManager) initNetwork(net *acmelib.Network) {
 						if err != nil {
 							panic(err)
 						}
-						sigEnums[enumSig.Enum().EntityID()] = enumSig.Enum()
+
+						if isNew(enumSig.Enum().EntityID()) {
+							res.sigEnums = append(res.sigEnums, enumSig.Enum())
+						}
 					}
 				}
 			}
 		}
 	}
 
+	return res
+}
+
+func (m *serviceManager) initNetwork(net *acmelib.Network) {
+	entities := collectNetworkEntities(net)
+
+	m.mux.Lock()
+	m.network = net
+	m.library.addTo(entities)
+	m.mux.Unlock()
+
 	m.networkSrv.load(net)
-	m.busCtr.sendLoad(buses)
-	m.nodeCtr.sendLoad(maps.Values(nodes))
-	m.messageCtr.sendLoad(messages)
-	m.signalCtr.sendLoad(signals)
-	m.signalTypeCtr.sendLoad(maps.Values(sigTypes))
-	m.signalUnitCtr.sendLoad(maps.Values(sigUnits))
-	m.signalEnumCtr.sendLoad(maps.Values(sigEnums))
+	m.dependencyCtr.sendLoad(net)
+	m.busCtr.sendLoad(entities.buses)
+	m.nodeCtr.sendLoad(entities.nodes)
+	m.messageCtr.sendLoad(entities.messages)
+	m.signalCtr.sendLoad(entities.signals)
+	m.signalTypeCtr.sendLoad(entities.sigTypes)
+	m.signalUnitCtr.sendLoad(entities.sigUnits)
+	m.signalEnumCtr.sendLoad(entities.sigEnums)
 }
 
 func (m *serviceManager) getEncoding(path string) acmelib.SaveEncoding {
@@ -219,7 +486,7 @@ func (m *serviceManager) getEncoding(path string) acmelib.SaveEncoding {
 	case ".txtpb":
 		return acmelib.SaveEncodingText
 	}
-	return acmelib.SaveEncodingWire
+	return getPreferences().DefaultSaveEncoding.parse()
 }
 
 func (m *serviceManager) openNetwork(path string) error {
@@ -227,41 +494,75 @@ func (m *serviceManager) openNetwork(path string) error {
 		return nil
 	}
 
-	file, err := os.Open(path)
+	net, library, err := m.loadNetworkFile(path)
 	if err != nil {
 		return err
 	}
-	defer file.Close()
 
-	net, err := acmelib.LoadNetwork(file, m.getEncoding(path))
-	if err != nil {
+	if ok, err := m.confirmDiscard(); !ok {
 		return err
 	}
 
 	m.clearServices()
+	m.loadLibrary(library)
 	m.initNetwork(net)
 	m.historySrv.setSaved(true)
 
-	m.filePath = path
+	m.setFilePath(path)
+	m.storeFileState()
 
-	m.settingsSrv.addRecentNetwork(net.Name(), m.filePath)
+	m.settingsSrv.addRecentNetwork(net.Name(), path)
 
 	return nil
 }
 
+// openWindow opens a new window with its own network, history and sidebar.
+// If the path is not empty, the network is opened in the new window.
+func (m *serviceManager) openWindow(path string) error {
+	if m.windows == nil {
+		return errors.New("open window: the app is not running")
+	}
+
+	return m.windows.open(path)
+}
+
 func (m *serviceManager) saveNetwork() error {
-	if m.filePath == "" {
-		dialog := newSaveNetworkDialog()
-		filename, err := dialog.PromptForSingleSelection()
+	path := m.getFilePath()
+	if path == "" {
+		filename, err := m.prompter.promptSavePath()
 		if err != nil {
-			application.Get().Logger.Error(err.Error())
+			m.log.error(err)
+			return nil
+		}
+
+		// the user has not chosen a file
+		if filename == "" {
 			return nil
 		}
 
 		return m.saveNetworkAs(filename)
 	}
 
-	file, err := os.Create(m.filePath)
+	// do not overwrite the changes made by another program without asking
+	m.fileMux.Lock()
+	defer m.fileMux.Unlock()
+
+	changed, err := m.hasFileChanged()
+	if err != nil {
+		return err
+	}
+
+	if changed {
+		m.mux.RLock()
+		netName := m.network.Name()
+		m.mux.RUnlock()
+
+		if !m.prompter.promptOverwriteChangedFile(netName) {
+			return nil
+		}
+	}
+
+	file, err := os.Create(path)
 	if err != nil {
 		return err
 	}
@@ -270,31 +571,79 @@ func (m *serviceManager) saveNetwork() error {
 	m.mux.Lock()
 	defer m.mux.Unlock()
 
-	fileEnc := m.getEncoding(m.filePath)
-	switch fileEnc {
-	case acmelib.SaveEncodingWire:
-		err = acmelib.SaveNetwork(m.network, fileEnc, file, nil, nil)
-
-	case acmelib.SaveEncodingJSON:
-		err = acmelib.SaveNetwork(m.network, fileEnc, nil, file, nil)
+	fileBuf, err := encodeNetwork(m.network, m.library, m.getEncoding(path), filepath.Dir(path))
+	if err != nil {
+		return err
+	}
 
-	case acmelib.SaveEncodingText:
-		err = acmelib.SaveNetwork(m.network, fileEnc, nil, nil, file)
+	if _, err := file.Write(fileBuf); err != nil {
+		return err
 	}
 
-	if err != nil {
+	if err := file.Sync(); err != nil {
 		return err
 	}
+	m.storeFileStateLocked(path)
 
 	m.historySrv.setSaved(true)
 
-	m.settingsSrv.addRecentNetwork(m.network.Name(), m.filePath)
+	m.settingsSrv.addRecentNetwork(m.network.Name(), path)
 
-	printInfo("NETWORK SAVED")
+	m.log.info("NETWORK SAVED")
 
 	return nil
 }
 
+// autosaveIdleInterval is the interval used to check
+// if the autosave has been enabled in the preferences.
+const autosaveIdleInterval = 5 * time.Second
+
+// runAutosave periodically saves the network if it has unsaved changes,
+// following the autosave interval of the preferences.
+// Networks that have never been saved are skipped, since saving them
+// would require to ask for a file.
+func (m *serviceManager) runAutosave(ctx context.Context) {
+	for {
+		interval := getPreferences().AutosaveInterval
+
+		wait := time.Duration(interval) * time.Second
+		if interval <= 0 {
+			wait = autosaveIdleInterval
+		}
+
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return
+		}
+
+		if interval <= 0 {
+			continue
+		}
+
+		m.mux.RLock()
+		canSave := m.network != nil && m.filePath != ""
+		m.mux.RUnlock()
+
+		if !canSave || m.historySrv.isSaved() {
+			continue
+		}
+
+		// the file watcher asks the user what to do with the changed file
+		m.fileMux.Lock()
+		changed, err := m.hasFileChanged()
+		m.fileMux.Unlock()
+
+		if err != nil || changed {
+			continue
+		}
+
+		if err := m.saveNetwork(); err != nil {
+			m.log.error(err)
+		}
+	}
+}
+
 func (m *serviceManager) trySaveNetwork() error {
 	if m.historySrv.isSaved() {
 		return nil
@@ -304,11 +653,42 @@ func (m *serviceManager) trySaveNetwork() error {
 }
 
 func (m *serviceManager) saveNetworkAs(filename string) error {
-	m.filePath = filename
+	m.setFilePath(filename)
 	return m.saveNetwork()
 }
 
+// getFilePath returns the path of the network file,
+// or an empty string if the network has never been saved.
+func (m *serviceManager) getFilePath() string {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	return m.filePath
+}
+
+func (m *serviceManager) setFilePath(path string) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.filePath = path
+}
+
+// reloadNetwork reloads the network. If its file has been changed
+// by another program, the user is asked to reload it from disk.
 func (m *serviceManager) reloadNetwork() {
+	m.fileMux.Lock()
+	changed, err := m.hasFileChanged()
+	m.fileMux.Unlock()
+
+	if err != nil {
+		m.log.error(err)
+	}
+
+	if changed {
+		m.handleFileChange()
+		return
+	}
+
 	m.clearServices()
 	m.initNetwork(m.network)
 }
@@ -318,25 +698,38 @@ func (m *serviceManager) importDBC(path string) error {
 		return nil
 	}
 
-	dbcFile, err := os.Open(path)
+	fileBuf, err := os.ReadFile(path)
 	if err != nil {
 		return err
 	}
-	defer dbcFile.Close()
 
 	fileName := filepath.Base(path)
 	busName := fileName[:len(fileName)-len(filepath.Ext(path))]
 
-	bus, err := acmelib.ImportDBCFile(busName, dbcFile)
+	bus, err := acmelib.ImportDBCFile(busName, bytes.NewReader(fileBuf))
 	if err != nil {
-		printError(err)
+		m.log.error(err)
+		return err
+	}
+
+	// acmelib ignores the value types of the signals, so the file is parsed
+	// again to find the float signals
+	dbcFile, err := dbc.Parse(path, bytes.NewReader(fileBuf), false)
+	if err != nil {
+		m.log.error(err)
 		return err
 	}
 
 	m.mux.Lock()
+	if err := applyDBCFloatValueTypes(bus, dbcFile, m.signalTypeCtr.list()); err != nil {
+		m.mux.Unlock()
+		m.log.error(err)
+		return err
+	}
+
 	if err := m.network.AddBus(bus); err != nil {
 		m.mux.Unlock()
-		printError(err)
+		m.log.error(err)
 		return err
 	}
 	m.mux.Unlock()
@@ -374,6 +767,32 @@ func (m *serviceManager) importDBC(path string) error {
 	return nil
 }
 
+// replaceNetwork replaces the network with the given one (e.g. the network
+// modified by a plugin) as a single history operation.
+func (m *serviceManager) replaceNetwork(net *acmelib.Network) {
+	m.mux.RLock()
+	oldNet := m.network
+	m.mux.RUnlock()
+
+	swap := func(tmpNet *acmelib.Network) (any, error) {
+		m.clearEntities()
+		m.initNetwork(tmpNet)
+
+		m.mux.RLock()
+		defer m.mux.RUnlock()
+
+		return newNetwork(tmpNet), nil
+	}
+
+	swap(net)
+
+	m.historyCtr.sendOperation(
+		serviceKindNetwork,
+		func() (any, error) { return swap(oldNet) },
+		func() (any, error) { return swap(net) },
+	)
+}
+
 func (m *serviceManager) exportDBC(path string) error {
 	if path == "" {
 		return nil
@@ -382,12 +801,30 @@ func (m *serviceManager) exportDBC(path string) error {
 	m.mux.Lock()
 	defer m.mux.Unlock()
 
-	return acmelib.ExportNetwork(manager.network, path)
+	if err := acmelib.ExportNetwork(m.network, path); err != nil {
+		return err
+	}
+
+	return addDBCFloatValueTypes(m.network, path)
+}
+
+// loadLibrary replaces the library of the network.
+func (m *serviceManager) loadLibrary(library *networkLibrary) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.library.load(library)
 }
 
 func (m *serviceManager) clearServices() {
-	m.sidebarSrv.clear()
 	m.historySrv.clear()
+	m.clearEntities()
+}
+
+// clearEntities clears the entities held by the services, but not the history.
+func (m *serviceManager) clearEntities() {
+	m.sidebarSrv.clear()
+	m.dependencyCtr.sendClear()
 
 	m.networkSrv.clear()
 	m.busCtr.sendClear()
@@ -0,0 +1,384 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files")
+
+// newTestManager returns a service manager running without the Wails app,
+// the events are stored in the returned emitter.
+func newTestManager(t *testing.T) (*serviceManager, *memoryEventEmitter) {
+	t.Helper()
+
+	return newTestWindowManager(t, newNetworkClipboard())
+}
+
+// newTestWindowManager returns a service manager that shares the clipboard
+// with the other ones, like the managers of the windows of the app.
+func newTestWindowManager(t *testing.T, clipboard *networkClipboard) (*serviceManager, *memoryEventEmitter) {
+	t.Helper()
+
+	emitter := newMemoryEventEmitter()
+	log := newMemoryLogger()
+	m := newServiceManager(emitter, log, newFixedPrompter(), newConfigService(emitter, log), clipboard)
+
+	if err := m.start(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+
+	return m, emitter
+}
+
+// waitServices waits until the services have handled the pending loads.
+// The run loop of a service handles one request at a time, so an empty load
+// is received only after the previous requests are completed.
+func waitServices(m *serviceManager) {
+	m.busCtr.sendLoad(nil)
+	m.nodeCtr.sendLoad(nil)
+	m.messageCtr.sendLoad(nil)
+	m.signalCtr.sendLoad(nil)
+	m.signalTypeCtr.sendLoad(nil)
+	m.signalUnitCtr.sendLoad(nil)
+	m.signalEnumCtr.sendLoad(nil)
+}
+
+// waitHistory waits until the history contains the given number of operations.
+func waitHistory(t *testing.T, m *serviceManager, opCount int) {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		m.historySrv.mux.RLock()
+		currOpCount := len(m.historySrv.operations)
+		m.historySrv.mux.RUnlock()
+
+		if currOpCount == opCount {
+			return
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("the history does not contain %d operations", opCount)
+}
+
+// dumpServices returns a textual description of the entities held by the services,
+// the lines are sorted so that the result does not depend on the map iteration order.
+func dumpServices(m *serviceManager) string {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+
+	buses := m.busSrv.listEntities()
+	nodes := m.nodeSrv.listEntities()
+	messages := m.messageSrv.listEntities()
+	signals := m.signalSrv.listEntities()
+	sigTypes := m.signalTypeSrv.listEntities()
+	sigUnits := m.signalUnitSrv.listEntities()
+	sigEnums := m.signalEnumSrv.listEntities()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "buses: %d\nnodes: %d\nmessages: %d\nsignals: %d\nsignal types: %d\nsignal units: %d\nsignal enums: %d\n",
+		len(buses), len(nodes), len(messages), len(signals), len(sigTypes), len(sigUnits), len(sigEnums))
+
+	lines := []string{}
+	for _, bus := range buses {
+		lines = append(lines, fmt.Sprintf("bus %s", bus.Name()))
+	}
+	for _, node := range nodes {
+		lines = append(lines, fmt.Sprintf("node %s interfaces=%d", node.Name(), len(node.Interfaces())))
+	}
+	for _, msg := range messages {
+		lines = append(lines, fmt.Sprintf("message %s id=%d size=%d cycle=%d", msg.Name(), msg.ID(), msg.SizeByte(), msg.CycleTime()))
+	}
+	for _, sig := range signals {
+		lines = append(lines, fmt.Sprintf("signal %s.%s start=%d size=%d", sig.ParentMessage().Name(), sig.Name(), sig.GetStartBit(), sig.GetSize()))
+	}
+	for _, sigType := range sigTypes {
+		lines = append(lines, fmt.Sprintf("signal type %s kind=%s size=%d", sigType.Name(), sigType.Kind(), sigType.Size()))
+	}
+	for _, sigUnit := range sigUnits {
+		lines = append(lines, fmt.Sprintf("signal unit %s symbol=%s", sigUnit.Name(), sigUnit.Symbol()))
+	}
+	for _, sigEnum := range sigEnums {
+		lines = append(lines, fmt.Sprintf("signal enum %s values=%d", sigEnum.Name(), len(sigEnum.Values())))
+	}
+
+	slices.Sort(lines)
+
+	b.WriteString(strings.Join(lines, "\n") + "\n")
+
+	return b.String()
+}
+
+// dumpExportedDBC exports the network and returns the content of the exported files.
+func dumpExportedDBC(t *testing.T, m *serviceManager) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	if err := m.exportDBC(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	paths := []string{}
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	slices.Sort(paths)
+
+	var b strings.Builder
+	for _, path := range paths {
+		relPath, err := filepath.Rel(dir, path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fileBuf, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fmt.Fprintf(&b, "== %s ==\n%s", filepath.ToSlash(relPath), sortDBCValueTables(string(fileBuf)))
+	}
+
+	return b.String()
+}
+
+// sortDBCValueTables sorts the value tables of an exported DBC file,
+// since acmelib writes them in map iteration order.
+func sortDBCValueTables(content string) string {
+	lines := strings.SplitAfter(content, "\n")
+
+	for start := 0; start < len(lines); start++ {
+		if !strings.HasPrefix(lines[start], "VAL_TABLE_ ") {
+			continue
+		}
+
+		end := start
+		for end < len(lines) && strings.HasPrefix(lines[end], "VAL_TABLE_ ") {
+			end++
+		}
+
+		slices.Sort(lines[start:end])
+		start = end
+	}
+
+	return strings.Join(lines, "")
+}
+
+func assertGolden(t *testing.T, name, got string) {
+	t.Helper()
+
+	path := filepath.Join("testdata", "golden", name+".golden")
+
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got != strings.ReplaceAll(string(want), "\r\n", "\n") {
+		t.Errorf("%s does not match the golden file, run the tests with -update to see the differences", name)
+	}
+}
+
+func TestOpenNetworkGolden(t *testing.T) {
+	for _, fileName := range []string{"simple.binpb", "net_json.json", "net_text.txtpb"} {
+		t.Run(fileName, func(t *testing.T) {
+			m, emitter := newTestManager(t)
+
+			if err := m.openNetwork(filepath.Join("testdata", fileName)); err != nil {
+				t.Fatal(err)
+			}
+			waitServices(m)
+
+			if len(emitter.getEvents(NetworkLoaded)) != 1 {
+				t.Errorf("expected one %s event", NetworkLoaded)
+			}
+
+			if !m.historySrv.isSaved() {
+				t.Error("an opened network must be saved")
+			}
+
+			assertGolden(t, "open_"+fileName, dumpServices(m)+dumpExportedDBC(t, m))
+		})
+	}
+}
+
+func TestImportDBCGolden(t *testing.T) {
+	for _, fileName := range []string{"simple.dbc", "HVCB.dbc"} {
+		t.Run(fileName, func(t *testing.T) {
+			m, _ := newTestManager(t)
+
+			if err := m.createNetwork(); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := m.importDBC(filepath.Join("testdata", fileName)); err != nil {
+				t.Fatal(err)
+			}
+			waitServices(m)
+
+			// the exported signal enums are not sorted, so only the services are compared
+			assertGolden(t, "import_"+fileName, dumpServices(m))
+		})
+	}
+}
+
+func TestImportDBCUndoRedo(t *testing.T) {
+	m, emitter := newTestManager(t)
+
+	if err := m.createNetwork(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.importDBC(filepath.Join("testdata", "simple.dbc")); err != nil {
+		t.Fatal(err)
+	}
+	waitHistory(t, m, 1)
+
+	getBusCount := func() int {
+		m.mux.RLock()
+		defer m.mux.RUnlock()
+
+		return len(m.network.Buses())
+	}
+
+	if _, err := m.historySrv.Undo(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if busCount := getBusCount(); busCount != 0 {
+		t.Errorf("expected no buses after the undo, got %d", busCount)
+	}
+	// the redo holds the network lock while it adds the bus back,
+	// so the bus service must have handled the deletion first
+	waitServices(m)
+
+	if _, err := m.historySrv.Redo(t.Context()); err != nil {
+		t.Fatal(err)
+	}
+	if busCount := getBusCount(); busCount != 1 {
+		t.Errorf("expected one bus after the redo, got %d", busCount)
+	}
+
+	if evtCount := len(emitter.getEvents(HistoryNetworkModify)); evtCount != 2 {
+		t.Errorf("expected 2 %s events, got %d", HistoryNetworkModify, evtCount)
+	}
+}
+
+func TestDiffLines(t *testing.T) {
+	oldLines := []string{"a", "b", "c", "d"}
+	newLines := []string{"a", "c", "x", "d"}
+
+	got := diffLines(oldLines, newLines)
+	want := []NetworkDiffLine{
+		{Kind: NetworkDiffLineKindEqual, Text: "a"},
+		{Kind: NetworkDiffLineKindRemoved, Text: "b"},
+		{Kind: NetworkDiffLineKindEqual, Text: "c"},
+		{Kind: NetworkDiffLineKindAdded, Text: "x"},
+		{Kind: NetworkDiffLineKindEqual, Text: "d"},
+	}
+
+	if !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSaveNetworkChangedFile(t *testing.T) {
+	m, _ := newTestManager(t)
+	prompter := m.prompter.(*fixedPrompter)
+
+	fileBuf, err := os.ReadFile(filepath.Join("testdata", "simple.binpb"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "simple.binpb")
+	if err := os.WriteFile(path, fileBuf, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.openNetwork(path); err != nil {
+		t.Fatal(err)
+	}
+	waitServices(m)
+
+	changedBuf := []byte("changed by another program")
+	if err := os.WriteFile(path, changedBuf, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	prompter.overwriteChangedFile = false
+	if err := m.saveNetwork(); err != nil {
+		t.Fatal(err)
+	}
+
+	savedBuf, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(savedBuf) != string(changedBuf) {
+		t.Error("the file changed by another program must not be overwritten without confirmation")
+	}
+
+	prompter.overwriteChangedFile = true
+	if err := m.saveNetwork(); err != nil {
+		t.Fatal(err)
+	}
+
+	savedBuf, err = os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(savedBuf) == string(changedBuf) {
+		t.Error("expected the file to be overwritten after the confirmation")
+	}
+}
+
+func TestSaveNetworkResumesFileWatcher(t *testing.T) {
+	m, _ := newTestManager(t)
+
+	path := filepath.Join(t.TempDir(), "new_network.binpb")
+	if err := m.createNetwork(); err != nil {
+		t.Fatal(err)
+	}
+	waitServices(m)
+
+	// the differences have been sent, but the frontend has never answered
+	m.fileChangePending.Store(true)
+
+	if err := m.saveNetworkAs(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if m.fileChangePending.Load() {
+		t.Error("the file watcher must resume after the network is saved")
+	}
+}
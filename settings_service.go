@@ -3,8 +3,12 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"os"
 	"path/filepath"
+	"slices"
 	"sync"
 
 	"github.com/wailsapp/wails/v3/pkg/application"
@@ -17,21 +21,81 @@ const (
 type RecentNetwork struct {
 	Name string `json:"name"`
 	Path string `json:"path"`
+
+	// Pinned networks are never removed from the recent networks,
+	// even if their file does not exist anymore.
+	Pinned bool `json:"pinned"`
 }
 
 func newRecentNetwork(name, path string) RecentNetwork {
 	return RecentNetwork{Name: name, Path: path}
 }
 
+type LintRuleKind string
+
+const (
+	// LintRuleMessageSenderPrefix requires the name of a message
+	// to start with the name of its sender node.
+	LintRuleMessageSenderPrefix LintRuleKind = "message-sender-prefix"
+	// LintRuleNameCase requires the names of the selected kinds to be in the given case.
+	LintRuleNameCase LintRuleKind = "name-case"
+	// LintRuleEnumValuePrefix requires the names of the values of an enum to start
+	// with the given prefix, or with the enum name followed by an underscore if the prefix is empty.
+	LintRuleEnumValuePrefix LintRuleKind = "enum-value-prefix"
+	// LintRuleRequiredDesc requires the entities of the selected kinds to have a description.
+	LintRuleRequiredDesc LintRuleKind = "required-desc"
+	// LintRuleUnitDimension requires the unit of a signal to measure the quantity
+	// suggested by the name of the signal (e.g. a current signal with a voltage unit).
+	LintRuleUnitDimension LintRuleKind = "unit-dimension"
+)
+
+type LintRule struct {
+	Kind    LintRuleKind `json:"kind"`
+	Enabled bool         `json:"enabled"`
+
+	// Kinds are the entity kinds checked by the name case and the required description rules.
+	Kinds []EntityKind `json:"kinds"`
+	// Case is the name case required by the name case rule.
+	Case NameCase `json:"case"`
+	// Prefix is the prefix required by the enum value prefix rule.
+	Prefix string `json:"prefix"`
+}
+
+func newDefaultLintRules() []LintRule {
+	return []LintRule{
+		{Kind: LintRuleMessageSenderPrefix, Enabled: true, Kinds: []EntityKind{}},
+		{Kind: LintRuleNameCase, Enabled: true, Kinds: []EntityKind{EntityKindSignal}, Case: NameCaseUpperSnake},
+		{Kind: LintRuleEnumValuePrefix, Enabled: true, Kinds: []EntityKind{}},
+		{Kind: LintRuleRequiredDesc, Enabled: true, Kinds: []EntityKind{EntityKindMessage, EntityKindSignal}},
+		{Kind: LintRuleUnitDimension, Enabled: true, Kinds: []EntityKind{}},
+	}
+}
+
 type Settings struct {
 	Version        int             `json:"version"`
 	RecentNetworks []RecentNetwork `json:"recentNetworks"`
+	LintRules      []LintRule      `json:"lintRules"`
+
+	// ReopenLastNetwork opens the most recent network when the app starts.
+	ReopenLastNetwork bool `json:"reopenLastNetwork"`
+
+	Preferences Preferences `json:"preferences"`
+
+	// Keybindings contains the keybindings overridden by the user,
+	// mapped by command id. An empty string means that the command is not bound.
+	Keybindings map[string]string `json:"keybindings"`
+
+	APIServer APIServerSettings `json:"apiServer"`
 }
 
 func newDefaultSettings() *Settings {
 	return &Settings{
-		Version:        1,
+		Version:        currentSettingsVersion,
 		RecentNetworks: []RecentNetwork{},
+		LintRules:      newDefaultLintRules(),
+		Preferences:    newDefaultPreferences(),
+		Keybindings:    make(map[string]string),
+		APIServer:      newDefaultAPIServerSettings(),
 	}
 }
 
@@ -45,9 +109,17 @@ type SettingsService struct {
 	settingsTained bool
 
 	saveCh chan struct{}
+
+	// recentNetworksListener is called every time the recent networks change
+	recentNetworksListener func([]RecentNetwork)
+	// apiServerListener is called every time the API server settings change
+	apiServerListener func(APIServerSettings)
+
+	emitter eventEmitter
+	log     logger
 }
 
-func newConfigService() *SettingsService {
+func newConfigService(emitter eventEmitter, log logger) *SettingsService {
 	return &SettingsService{
 		mux: new(sync.RWMutex),
 
@@ -57,7 +129,10 @@ func newConfigService() *SettingsService {
 		settings:       newDefaultSettings(),
 		settingsTained: true,
 
-		saveCh: make(chan struct{}),
+		saveCh: make(chan struct{}, 1),
+
+		emitter: emitter,
+		log:     log,
 	}
 }
 
@@ -89,6 +164,19 @@ func (cs *SettingsService) initFolder() error {
 	return cs.handleSave()
 }
 
+// getPluginsDir returns the folder that contains the plugins,
+// it is empty if the settings folder is not initialized.
+func (cs *SettingsService) getPluginsDir() string {
+	if cs.dir == "" {
+		return ""
+	}
+	return filepath.Join(cs.dir, pluginsDirName)
+}
+
+func (cs *SettingsService) isLoaded() bool {
+	return cs.settingsFilePath != ""
+}
+
 func (cs *SettingsService) load(path string) error {
 	fileBuf, err := os.ReadFile(path)
 	if err != nil {
@@ -100,7 +188,22 @@ func (cs *SettingsService) load(path string) error {
 		return err
 	}
 	cs.settings = cfg
-	cs.settingsTained = false
+	cs.settingsTained = migrateSettings(cfg)
+
+	// an empty token would let the requests without a token reach the api server
+	if cfg.APIServer.Token == "" {
+		cfg.APIServer.Token = newAPIToken()
+		cs.settingsTained = true
+	}
+
+	if err := cfg.Preferences.validate(); err != nil {
+		// the settings can be loaded before the app is created,
+		// so the standard logger is used
+		log.Printf("invalid preferences, the default ones are used: %v", err)
+		cfg.Preferences = newDefaultPreferences()
+		cs.settingsTained = true
+	}
+	setPreferences(cfg.Preferences)
 
 	cs.filterRecentNetworks()
 
@@ -149,30 +252,40 @@ func (cs *SettingsService) run(ctx context.Context) {
 }
 
 func (cs *SettingsService) OnStartup(ctx context.Context, _ application.ServiceOptions) error {
-	if err := cs.initFolder(); err != nil {
-		return err
+	// the settings may have been already loaded to configure the app
+	if !cs.isLoaded() {
+		if err := cs.initFolder(); err != nil {
+			return err
+		}
 	}
 
 	go cs.run(ctx)
 
+	cs.notifyRecentNetworks()
+
 	return nil
 }
 
 func (cs *SettingsService) OnShutdown() {
 	if err := cs.handleSave(); err != nil {
-		printError(err)
+		cs.log.error(err)
 	}
 }
 
+// sendSave asks to save the settings, it does not block
+// if a save is already pending.
 func (cs *SettingsService) sendSave() {
-	cs.saveCh <- struct{}{}
+	select {
+	case cs.saveCh <- struct{}{}:
+	default:
+	}
 }
 
 func (cs *SettingsService) filterRecentNetworks() {
 	tmpRecentNets := []RecentNetwork{}
 
 	for _, recNet := range cs.settings.RecentNetworks {
-		if cs.fileExists(recNet.Path) {
+		if recNet.Pinned || cs.fileExists(recNet.Path) {
 			tmpRecentNets = append(tmpRecentNets, recNet)
 		}
 	}
@@ -184,20 +297,26 @@ func (cs *SettingsService) addRecentNetwork(name, path string) {
 	cs.mux.Lock()
 	defer cs.mux.Unlock()
 
-	tmpRecentNets := []RecentNetwork{newRecentNetwork(name, path)}
+	newRecNet := newRecentNetwork(name, path)
+	tmpRecentNets := []RecentNetwork{}
 
 	for _, recNet := range cs.settings.RecentNetworks {
 		if recNet.Path == path {
+			newRecNet.Pinned = recNet.Pinned
 			continue
 		}
 
 		tmpRecentNets = append(tmpRecentNets, recNet)
 	}
 
-	cs.settings.RecentNetworks = tmpRecentNets
+	cs.settings.RecentNetworks = append([]RecentNetwork{newRecNet}, tmpRecentNets...)
 	cs.settingsTained = true
 
 	cs.sendSave()
+
+	cs.emitUpdate()
+
+	go cs.notifyRecentNetworks()
 }
 
 func (cs *SettingsService) renameRecentNetwork(name, path string) {
@@ -220,6 +339,283 @@ func (cs *SettingsService) renameRecentNetwork(name, path string) {
 	cs.settingsTained = true
 
 	cs.sendSave()
+
+	cs.emitUpdate()
+
+	go cs.notifyRecentNetworks()
+}
+
+func (cs *SettingsService) setRecentNetworksListener(listener func([]RecentNetwork)) {
+	cs.mux.Lock()
+	defer cs.mux.Unlock()
+
+	cs.recentNetworksListener = listener
+}
+
+func (cs *SettingsService) notifyRecentNetworks() {
+	cs.mux.RLock()
+	listener := cs.recentNetworksListener
+	recentNets := slices.Clone(cs.settings.RecentNetworks)
+	cs.mux.RUnlock()
+
+	if listener != nil {
+		listener(recentNets)
+	}
+}
+
+// getLastNetworkPath returns the path of the most recent network
+// that still exists, or an empty string if there is none.
+func (cs *SettingsService) getLastNetworkPath() string {
+	cs.mux.RLock()
+	defer cs.mux.RUnlock()
+
+	for _, recNet := range cs.settings.RecentNetworks {
+		if cs.fileExists(recNet.Path) {
+			return recNet.Path
+		}
+	}
+
+	return ""
+}
+
+func (cs *SettingsService) shouldReopenLastNetwork() bool {
+	cs.mux.RLock()
+	defer cs.mux.RUnlock()
+
+	return cs.settings.ReopenLastNetwork
+}
+
+// PinRecentNetwork pins or unpins the recent network with the given path.
+func (cs *SettingsService) PinRecentNetwork(path string, pinned bool) (Settings, error) {
+	cs.mux.Lock()
+	defer cs.mux.Unlock()
+
+	idx := slices.IndexFunc(cs.settings.RecentNetworks, func(recNet RecentNetwork) bool {
+		return recNet.Path == path
+	})
+	if idx < 0 {
+		return *cs.settings, errors.New("pin recent network: not found")
+	}
+
+	if cs.settings.RecentNetworks[idx].Pinned == pinned {
+		return *cs.settings, nil
+	}
+
+	cs.settings.RecentNetworks[idx].Pinned = pinned
+	cs.settingsTained = true
+
+	cs.sendSave()
+
+	cs.emitUpdate()
+
+	go cs.notifyRecentNetworks()
+
+	return *cs.settings, nil
+}
+
+// ClearRecentNetworks removes all the recent networks that are not pinned.
+func (cs *SettingsService) ClearRecentNetworks() Settings {
+	cs.mux.Lock()
+	defer cs.mux.Unlock()
+
+	cs.settings.RecentNetworks = slices.DeleteFunc(cs.settings.RecentNetworks, func(recNet RecentNetwork) bool {
+		return !recNet.Pinned
+	})
+	cs.settingsTained = true
+
+	cs.sendSave()
+
+	cs.emitUpdate()
+
+	go cs.notifyRecentNetworks()
+
+	return *cs.settings
+}
+
+func (cs *SettingsService) UpdateReopenLastNetwork(reopen bool) Settings {
+	cs.mux.Lock()
+	defer cs.mux.Unlock()
+
+	if cs.settings.ReopenLastNetwork == reopen {
+		return *cs.settings
+	}
+
+	cs.settings.ReopenLastNetwork = reopen
+	cs.settingsTained = true
+
+	cs.sendSave()
+
+	cs.emitUpdate()
+
+	return *cs.settings
+}
+
+func (cs *SettingsService) getLintRules() []LintRule {
+	cs.mux.RLock()
+	defer cs.mux.RUnlock()
+
+	return slices.Clone(cs.settings.LintRules)
+}
+
+// UpdateLintRules replaces the rules used by the linter.
+func (cs *SettingsService) UpdateLintRules(rules []LintRule) (Settings, error) {
+	cs.mux.Lock()
+	defer cs.mux.Unlock()
+
+	for _, rule := range rules {
+		switch rule.Kind {
+		case LintRuleMessageSenderPrefix, LintRuleEnumValuePrefix, LintRuleRequiredDesc, LintRuleUnitDimension:
+		case LintRuleNameCase:
+			if len(rule.Case) == 0 {
+				return *cs.settings, errors.New("update lint rules: missing case of the name case rule")
+			}
+		default:
+			return *cs.settings, fmt.Errorf("update lint rules: invalid rule kind %s", rule.Kind)
+		}
+	}
+
+	cs.settings.LintRules = rules
+	cs.settingsTained = true
+
+	cs.sendSave()
+
+	cs.emitUpdate()
+
+	return *cs.settings, nil
+}
+
+// Update replaces the user preferences.
+func (cs *SettingsService) Update(prefs Preferences) (Settings, error) {
+	cs.mux.Lock()
+	defer cs.mux.Unlock()
+
+	if err := prefs.validate(); err != nil {
+		return *cs.settings, err
+	}
+
+	cs.settings.Preferences = prefs
+	cs.settingsTained = true
+	setPreferences(prefs)
+
+	cs.sendSave()
+
+	cs.emitUpdate()
+
+	return *cs.settings, nil
+}
+
+func (cs *SettingsService) getKeybinding(commandID string) (string, bool) {
+	cs.mux.RLock()
+	defer cs.mux.RUnlock()
+
+	keys, ok := cs.settings.Keybindings[commandID]
+	return keys, ok
+}
+
+func (cs *SettingsService) setKeybinding(commandID, keys string) {
+	cs.mux.Lock()
+	defer cs.mux.Unlock()
+
+	cs.settings.Keybindings[commandID] = keys
+	cs.settingsTained = true
+
+	cs.sendSave()
+
+	cs.emitUpdate()
+}
+
+func (cs *SettingsService) removeKeybinding(commandID string) {
+	cs.mux.Lock()
+	defer cs.mux.Unlock()
+
+	if _, ok := cs.settings.Keybindings[commandID]; !ok {
+		return
+	}
+
+	delete(cs.settings.Keybindings, commandID)
+	cs.settingsTained = true
+
+	cs.sendSave()
+
+	cs.emitUpdate()
+}
+
+func (cs *SettingsService) setAPIServerListener(listener func(APIServerSettings)) {
+	cs.mux.Lock()
+	defer cs.mux.Unlock()
+
+	cs.apiServerListener = listener
+}
+
+func (cs *SettingsService) getAPIServerSettings() APIServerSettings {
+	cs.mux.RLock()
+	defer cs.mux.RUnlock()
+
+	return cs.settings.APIServer
+}
+
+// notifyAPIServer must be called without holding the mutex.
+func (cs *SettingsService) notifyAPIServer() {
+	cs.mux.RLock()
+	listener := cs.apiServerListener
+	apiSettings := cs.settings.APIServer
+	cs.mux.RUnlock()
+
+	if listener != nil {
+		listener(apiSettings)
+	}
+}
+
+type UpdateAPIServerReq struct {
+	Enabled bool `json:"enabled"`
+	Port    int  `json:"port"`
+}
+
+// UpdateAPIServer enables or disables the local automation API
+// and changes the port it listens on.
+func (cs *SettingsService) UpdateAPIServer(req UpdateAPIServerReq) (Settings, error) {
+	cs.mux.Lock()
+	defer cs.mux.Unlock()
+
+	if req.Port < minAPIServerPort || req.Port > maxAPIServerPort {
+		return *cs.settings, fmt.Errorf("update api server: the port must be between %d and %d", minAPIServerPort, maxAPIServerPort)
+	}
+
+	cs.settings.APIServer.Enabled = req.Enabled
+	cs.settings.APIServer.Port = req.Port
+	cs.settingsTained = true
+
+	cs.sendSave()
+
+	cs.emitUpdate()
+
+	go cs.notifyAPIServer()
+
+	return *cs.settings, nil
+}
+
+// RegenerateAPIToken replaces the token of the local automation API,
+// the clients using the old one are rejected.
+func (cs *SettingsService) RegenerateAPIToken() Settings {
+	cs.mux.Lock()
+	defer cs.mux.Unlock()
+
+	cs.settings.APIServer.Token = newAPIToken()
+	cs.settingsTained = true
+
+	cs.sendSave()
+
+	cs.emitUpdate()
+
+	go cs.notifyAPIServer()
+
+	return *cs.settings
+}
+
+// emitUpdate notifies the frontend that the settings have changed.
+// The caller must hold the mutex.
+func (cs *SettingsService) emitUpdate() {
+	cs.emitter.emitEvent(SettingsUpdated, *cs.settings)
 }
 
 func (cs *SettingsService) Get() Settings {
@@ -144,6 +144,8 @@ func newSidebarDeleteReq(itemKey string) *sidebarDeleteReq {
 }
 
 type SidebarService struct {
+	windowRouted
+
 	items map[string]*sidebarItem
 	root  *sidebarItem
 
@@ -153,9 +155,11 @@ type SidebarService struct {
 	updateNameCh chan *sidebarUpdateNameReq
 	addCh        chan *sidebarAddReq
 	deleteCh     chan *sidebarDeleteReq
+
+	emitter eventEmitter
 }
 
-func newSidebarService() *SidebarService {
+func newSidebarService(emitter eventEmitter) *SidebarService {
 	return &SidebarService{
 		items: make(map[string]*sidebarItem),
 
@@ -163,6 +167,8 @@ func newSidebarService() *SidebarService {
 		updateNameCh: make(chan *sidebarUpdateNameReq),
 		addCh:        make(chan *sidebarAddReq),
 		deleteCh:     make(chan *sidebarDeleteReq),
+
+		emitter: emitter,
 	}
 }
 
@@ -325,7 +331,7 @@ func (s *SidebarService) handleLoad(req *sidebarLoadReq) {
 		sigEnumGroupItem.addChild(sigEnumItem)
 	}
 
-	app.EmitEvent(SidebarLoad)
+	s.emitter.emitEvent(SidebarLoad)
 }
 
 func (s *SidebarService) handleUpdateName(req *sidebarUpdateNameReq) {
@@ -339,7 +345,7 @@ func (s *SidebarService) handleUpdateName(req *sidebarUpdateNameReq) {
 
 	item.name = req.name
 
-	app.EmitEvent(SidebarUpdateName, SidebarUpdateNameEvent{
+	s.emitter.emitEvent(SidebarUpdateName, SidebarUpdateNameEvent{
 		UpdatedID: item.id,
 		Name:      item.name,
 	})
@@ -361,7 +367,7 @@ func (s *SidebarService) handleAdd(req *sidebarAddReq) {
 		s.addItem(child)
 	}
 
-	app.EmitEvent(SidebarAdd, SidebarAddEvent{
+	s.emitter.emitEvent(SidebarAdd, SidebarAddEvent{
 		AddedItem: req.item.convert(),
 	})
 }
@@ -383,7 +389,7 @@ func (s *SidebarService) handleDelete(req *sidebarDeleteReq) {
 	}
 	delete(s.items, req.itemKey)
 
-	app.EmitEvent(SidebarDelete, SidebarDeleteEvent{
+	s.emitter.emitEvent(SidebarDelete, SidebarDeleteEvent{
 		DeletedID: item.id,
 	})
 }
@@ -397,7 +403,14 @@ func (s *SidebarService) getController() *sidebarController {
 	}
 }
 
-func (s *SidebarService) Get() Sidebar {
+// forWindow returns the service of the window that made the call.
+func (s *SidebarService) forWindow(ctx context.Context) *SidebarService {
+	return s.resolve(ctx).sidebarSrv
+}
+
+func (s *SidebarService) Get(ctx context.Context) Sidebar {
+	s = s.forWindow(ctx)
+
 	s.mux.RLock()
 	defer s.mux.RUnlock()
 
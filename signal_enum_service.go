@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"slices"
 	"strings"
@@ -45,6 +47,12 @@ type SignalEnum struct {
 	MaxIndex int               `json:"maxIndex"`
 	Values   []SignalEnumValue `json:"values"`
 
+	KeepInLibrary bool `json:"keepInLibrary"`
+	// LibraryPath is the path of the library file the entity is linked to,
+	// a linked entity is read-only.
+	LibraryPath string `json:"libraryPath"`
+	ReadOnly    bool   `json:"readOnly"`
+
 	References []Reference `json:"references"`
 }
 
@@ -67,68 +75,53 @@ func newSignalEnum(sigEnum *acmelib.SignalEnum) SignalEnum {
 		return res
 	}
 
-	rootRefs := []*reference{}
-	refs := make(map[acmelib.EntityID]*reference)
-	for _, sig := range sigEnum.References() {
-		sigRef := newReference(sig)
-		refs[sig.EntityID()] = sigRef
-
-		var msgRef *reference
-		msg := sig.ParentMessage()
-		msgRef, ok := refs[msg.EntityID()]
-		if !ok {
-			msgRef = newReference(msg)
-			refs[msg.EntityID()] = msgRef
-		}
-		msgRef.addChild(sigRef)
-
-		var nodeRef *reference
-		node := msg.SenderNodeInterface().Node()
-		nodeRef, ok = refs[node.EntityID()]
-		if !ok {
-			nodeRef = newReference(node)
-			refs[node.EntityID()] = nodeRef
-		}
-		nodeRef.addChild(msgRef)
-
-		var busRef *reference
-		bus := msg.SenderNodeInterface().ParentBus()
-		busRef, ok = refs[bus.EntityID()]
-		if !ok {
-			busRef = newReference(bus)
-			refs[bus.EntityID()] = busRef
-			rootRefs = append(rootRefs, busRef)
-		}
-		busRef.addChild(nodeRef)
-	}
-
-	for _, tmpRef := range rootRefs {
-		res.References = append(res.References, tmpRef.toResponse())
-	}
+	res.References = newSignalReferences(sigEnum.References())
 
 	return res
 }
 
+type SignalEnumImportPreview struct {
+	// SourceEnumNames are the names of the enums found in the source.
+	SourceEnumNames []string          `json:"sourceEnumNames"`
+	Values          []EnumSourceValue `json:"values"`
+	// Size is the size in bits of the enum after the import.
+	Size     int      `json:"size"`
+	Warnings []string `json:"warnings"`
+}
+
 type SignalEnumService struct {
 	*service[*acmelib.SignalEnum, SignalEnum, *signalEnumHandler]
+
+	library *networkLibrary
 }
 
-func newSignalEnumService(mux *sync.RWMutex, sidebar *sidebarController) *SignalEnumService {
-	return &SignalEnumService{
-		service: newService(serviceKindSignalEnum, newSignalEnumHandler(sidebar), mux, sidebar),
+func newSignalEnumService(mux *sync.RWMutex, emitter eventEmitter, sidebar *sidebarController, library *networkLibrary) *SignalEnumService {
+	srv := &SignalEnumService{
+		service: newService(serviceKindSignalEnum, newSignalEnumHandler(sidebar, library), mux, emitter, sidebar),
+
+		library: library,
 	}
+
+	// the entities linked to a library file are updated only from the file
+	srv.checkEditable = func(ent *acmelib.SignalEnum) error {
+		return library.checkEditable(ent)
+	}
+
+	return srv
 }
 
-func (s *SignalEnumService) Create() (SignalEnum, error) {
+// forWindow returns the service of the window that made the call.
+func (s *SignalEnumService) forWindow(ctx context.Context) *SignalEnumService {
+	return s.resolve(ctx).signalEnumSrv
+}
+
+func (s *SignalEnumService) Create(ctx context.Context) (SignalEnum, error) {
+	s = s.forWindow(ctx)
+
 	s.mux.Lock()
 	defer s.mux.Unlock()
 
-	takenNames := make(map[string]struct{})
-	for _, sigEnum := range s.entities {
-		takenNames[sigEnum.Name()] = struct{}{}
-	}
-
-	sigEnum := acmelib.NewSignalEnum(getNewName("signal_enum", takenNames))
+	sigEnum := acmelib.NewSignalEnum(getNewName("signal_enum", s.getTakenNames()))
 
 	s.addEntity(sigEnum)
 	s.sidebarCtr.sendAdd(sigEnum)
@@ -149,7 +142,9 @@ func (s *SignalEnumService) Create() (SignalEnum, error) {
 	return s.handler.toResponse(sigEnum), nil
 }
 
-func (s *SignalEnumService) Delete(entityID string) error {
+func (s *SignalEnumService) Delete(ctx context.Context, entityID string) error {
+	s = s.forWindow(ctx)
+
 	s.mux.Lock()
 	defer s.mux.Unlock()
 
@@ -159,21 +154,82 @@ func (s *SignalEnumService) Delete(entityID string) error {
 	}
 
 	if sigEnum.ReferenceCount() > 0 {
-		return fmt.Errorf("signal enum %s is referenced %d times", sigEnum.Name(), sigEnum.ReferenceCount())
+		return newReferencedError("signal enum", sigEnum, sigEnum.References())
 	}
 
-	s.removeEntity(entityID)
+	return s.delete(sigEnum, nil, nil)
+}
+
+// DeleteWithReplacement deletes the signal enum after retargeting all the signals
+// that are referencing it to the replacement signal enum.
+// The whole operation is recorded as a single history entry.
+func (s *SignalEnumService) DeleteWithReplacement(ctx context.Context, entityID string, req DeleteWithReplacementReq) error {
+	s = s.forWindow(ctx)
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	sigEnum, err := s.getEntity(entityID)
+	if err != nil {
+		return err
+	}
+
+	if len(req.ReplacementEntityID) == 0 {
+		if sigEnum.ReferenceCount() > 0 {
+			return newReferencedError("signal enum", sigEnum, sigEnum.References())
+		}
+
+		return s.delete(sigEnum, nil, nil)
+	}
+
+	if req.ReplacementEntityID == entityID {
+		return errors.New("a signal enum cannot be replaced by itself")
+	}
+
+	replacement, err := s.getEntity(req.ReplacementEntityID)
+	if err != nil {
+		return err
+	}
+
+	return s.delete(sigEnum, sigEnum.References(), replacement)
+}
+
+// delete sets the replacement signal enum to the given signals,
+// then it removes the signal enum and records the operation.
+func (s *SignalEnumService) delete(sigEnum *acmelib.SignalEnum, refSignals []*acmelib.EnumSignal, replacement *acmelib.SignalEnum) error {
+	if err := setSignalsEnum(refSignals, sigEnum, replacement); err != nil {
+		return err
+	}
+
+	// a deleted entity is no longer kept in the library, nor linked to a library file
+	restoreLibrary := s.library.remove(sigEnum)
+
+	s.removeEntity(sigEnum.EntityID().String())
 	s.sidebarCtr.sendDelete(sigEnum)
 
 	s.sendHistoryOp(
 		func() (*acmelib.SignalEnum, error) {
+			if err := setSignalsEnum(refSignals, replacement, sigEnum); err != nil {
+				return nil, err
+			}
+
+			restoreLibrary()
+
 			s.addEntity(sigEnum)
 			s.sidebarCtr.sendAdd(sigEnum)
+
 			return sigEnum, nil
 		},
 		func() (*acmelib.SignalEnum, error) {
+			if err := setSignalsEnum(refSignals, sigEnum, replacement); err != nil {
+				return nil, err
+			}
+
+			s.library.remove(sigEnum)
+
 			s.removeEntity(sigEnum.EntityID().String())
 			s.sidebarCtr.sendDelete(sigEnum)
+
 			return sigEnum, nil
 		},
 	)
@@ -181,7 +237,50 @@ func (s *SignalEnumService) Delete(entityID string) error {
 	return nil
 }
 
-func (s *SignalEnumService) ListBrief() []SignalEnumBrief {
+// FindDuplicates returns the groups of signal enums with the same values.
+func (s *SignalEnumService) FindDuplicates(ctx context.Context) []DuplicateGroup {
+	s = s.forWindow(ctx)
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	return findDuplicateGroups(s.listEntities(), getSignalEnumDuplicateKey)
+}
+
+// MergeDuplicates retargets the signals referencing the given signal enums
+// to the survivor and deletes them. The whole operation is recorded as a single history entry.
+func (s *SignalEnumService) MergeDuplicates(ctx context.Context, req MergeDuplicatesReq) (SignalEnum, error) {
+	s = s.forWindow(ctx)
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	survivor, duplicates, err := s.getMergeEntities(req, getSignalEnumDuplicateKey)
+	if err != nil {
+		return SignalEnum{}, err
+	}
+
+	refSignals := make(map[acmelib.EntityID][]*acmelib.EnumSignal)
+	for _, dup := range duplicates {
+		refSignals[dup.EntityID()] = dup.References()
+	}
+
+	err = s.merge(survivor, duplicates, s.library, func(dup *acmelib.SignalEnum, undo bool) error {
+		if undo {
+			return setSignalsEnum(refSignals[dup.EntityID()], survivor, dup)
+		}
+		return setSignalsEnum(refSignals[dup.EntityID()], dup, survivor)
+	})
+	if err != nil {
+		return SignalEnum{}, err
+	}
+
+	return s.handler.toResponse(survivor), nil
+}
+
+func (s *SignalEnumService) ListBrief(ctx context.Context) []SignalEnumBrief {
+	s = s.forWindow(ctx)
+
 	s.mux.RLock()
 	defer s.mux.RUnlock()
 
@@ -200,52 +299,261 @@ func (s *SignalEnumService) ListBrief() []SignalEnumBrief {
 	return res
 }
 
-func (s *SignalEnumService) UpdateName(entityID string, req UpdateNameReq) (SignalEnum, error) {
+func (s *SignalEnumService) UpdateName(ctx context.Context, entityID string, req UpdateNameReq) (SignalEnum, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateName)
 }
 
-func (s *SignalEnumService) UpdateDesc(entityID string, req UpdateDescReq) (SignalEnum, error) {
+func (s *SignalEnumService) UpdateDesc(ctx context.Context, entityID string, req UpdateDescReq) (SignalEnum, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateDesc)
 }
 
-func (s *SignalEnumService) AddValue(entityID string) (SignalEnum, error) {
+func (s *SignalEnumService) AddValue(ctx context.Context, entityID string) (SignalEnum, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, nil, s.handler.addValue)
 }
 
-func (s *SignalEnumService) RemoveValues(entityID string, req RemoveValuesReq) (SignalEnum, error) {
+func (s *SignalEnumService) RemoveValues(ctx context.Context, entityID string, req RemoveValuesReq) (SignalEnum, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.removeValues)
 }
 
-func (s *SignalEnumService) ReorderValue(entityID string, req ReorderValueReq) (SignalEnum, error) {
+func (s *SignalEnumService) ReorderValue(ctx context.Context, entityID string, req ReorderValueReq) (SignalEnum, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.reorderValueHandler)
 }
 
-func (s *SignalEnumService) UpdateValueName(entityID string, req UpdateValueNameReq) (SignalEnum, error) {
+func (s *SignalEnumService) UpdateValueName(ctx context.Context, entityID string, req UpdateValueNameReq) (SignalEnum, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateValueName)
 }
 
-func (s *SignalEnumService) UpdateValueDesc(entityID string, req UpdateValueDescReq) (SignalEnum, error) {
+func (s *SignalEnumService) UpdateValueDesc(ctx context.Context, entityID string, req UpdateValueDescReq) (SignalEnum, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateValueDesc)
 }
 
-func (s *SignalEnumService) UpdateValueIndex(entityID string, req UpdateValueIndexReq) (SignalEnum, error) {
+func (s *SignalEnumService) UpdateValueIndex(ctx context.Context, entityID string, req UpdateValueIndexReq) (SignalEnum, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateValueIndex)
 }
 
+// PreviewImportValues parses the source of the import and returns the values that
+// ImportValues would add, the size of the enum after the import and the warnings
+// about the signals using the enum that are too small for it.
+func (s *SignalEnumService) PreviewImportValues(ctx context.Context, entityID string, req ImportValuesReq) (SignalEnumImportPreview, error) {
+	s = s.forWindow(ctx)
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	sigEnum, err := s.getEntity(entityID)
+	if err != nil {
+		return SignalEnumImportPreview{}, err
+	}
+
+	sources, err := parseEnumSources(req.Format, req.Source)
+	if err != nil {
+		return SignalEnumImportPreview{}, err
+	}
+
+	values, err := getImportedValues(sigEnum, sources, &req)
+	if err != nil {
+		return SignalEnumImportPreview{}, err
+	}
+
+	// the size is computed from the largest index of the values after the import
+	maxIndex := 0
+	for _, val := range values {
+		maxIndex = max(maxIndex, val.Index)
+	}
+	if !req.Replace {
+		maxIndex = max(maxIndex, sigEnum.MaxIndex())
+	}
+
+	size := max(sigEnum.MinSize(), getEnumSizeFromIndex(maxIndex))
+
+	return SignalEnumImportPreview{
+		SourceEnumNames: getEnumSourceNames(sources),
+		Values:          values,
+		Size:            size,
+		Warnings:        getEnumSizeWarnings(sigEnum, size),
+	}, nil
+}
+
+// ImportValues adds the values of an enum declared in a C header,
+// in a CSV table or in a DBC value table.
+func (s *SignalEnumService) ImportValues(ctx context.Context, entityID string, req ImportValuesReq) (SignalEnum, error) {
+	s = s.forWindow(ctx)
+	return s.handle(entityID, &req, s.handler.importValues)
+}
+
+// ExportC returns the C declaration of the signal enum.
+func (s *SignalEnumService) ExportC(ctx context.Context, entityID string) (string, error) {
+	s = s.forWindow(ctx)
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	sigEnum, err := s.getEntity(entityID)
+	if err != nil {
+		return "", err
+	}
+
+	return exportCEnum(sigEnum), nil
+}
+
+// UpdateKeepInLibrary sets whether the signal enum is kept in the library of the network,
+// so it is saved even when no signal references it.
+func (s *SignalEnumService) UpdateKeepInLibrary(ctx context.Context, entityID string, req UpdateKeepInLibraryReq) (SignalEnum, error) {
+	s = s.forWindow(ctx)
+	return s.handle(entityID, &req, s.handler.updateKeepInLibrary)
+}
+
+func (s *SignalEnumService) getTakenNames() map[string]struct{} {
+	takenNames := make(map[string]struct{})
+	for _, sigEnum := range s.entities {
+		takenNames[sigEnum.Name()] = struct{}{}
+	}
+	return takenNames
+}
+
+// getImportedValues returns the values of the selected enum of the sources.
+// When the values are added to the current ones, the values that are already in the enum
+// are skipped and the ones with a taken name or index are rejected.
+func getImportedValues(sigEnum *acmelib.SignalEnum, sources []enumSource, req *ImportValuesReq) ([]EnumSourceValue, error) {
+	src, err := selectEnumSource(sources, req.SourceEnumName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkEnumSourceValues(src.values); err != nil {
+		return nil, err
+	}
+
+	if req.Replace {
+		return src.values, nil
+	}
+
+	currNames := make(map[string]*acmelib.SignalEnumValue)
+	currIndexes := make(map[int]*acmelib.SignalEnumValue)
+	for _, val := range sigEnum.Values() {
+		currNames[val.Name()] = val
+		currIndexes[val.Index()] = val
+	}
+
+	res := []EnumSourceValue{}
+	for _, val := range src.values {
+		if currVal, ok := currNames[val.Name]; ok {
+			if currVal.Index() == val.Index {
+				continue
+			}
+			return nil, fmt.Errorf("the value %s is already in the enum with index %d", val.Name, currVal.Index())
+		}
+
+		if currVal, ok := currIndexes[val.Index]; ok {
+			return nil, fmt.Errorf("the index %d of %s is already taken by %s", val.Index, val.Name, currVal.Name())
+		}
+
+		res = append(res, val)
+	}
+
+	return res, nil
+}
+
+// getEnumSizeWarnings returns a warning for every signal using the enum
+// that is smaller than the given size, since the signal must grow.
+func getEnumSizeWarnings(sigEnum *acmelib.SignalEnum, size int) []string {
+	warnings := []string{}
+	for _, enumSig := range sigEnum.References() {
+		sigSize := enumSig.GetSize()
+		if sigSize >= size {
+			continue
+		}
+
+		warning := fmt.Sprintf("the signal %s is %d bits, it must grow to %d bits", enumSig.Name(), sigSize, size)
+
+		parMsg := enumSig.ParentMessage()
+		if parMsg != nil && enumSig.ParentMultiplexerSignal() == nil {
+			if getSignalFreeSpace(parMsg, enumSig) < size-sigSize {
+				warning += fmt.Sprintf(", but there is not enough space left in the message %s", parMsg.Name())
+			}
+		}
+
+		warnings = append(warnings, warning)
+	}
+
+	return warnings
+}
+
+// getSignalFreeSpace returns the number of bits the signal can grow in its message,
+// the following signals are shifted to fill the gaps between them.
+func getSignalFreeSpace(msg *acmelib.Message, sig acmelib.Signal) int {
+	// the slice of the message must not be sorted in place
+	signals := slices.Clone(msg.Signals())
+	slices.SortFunc(signals, func(a, b acmelib.Signal) int {
+		return a.GetStartBit() - b.GetStartBit()
+	})
+
+	freeSpace := 0
+	prevEndBit := 0
+	found := false
+	for _, tmpSig := range signals {
+		if found {
+			freeSpace += tmpSig.GetStartBit() - prevEndBit
+		} else if tmpSig.EntityID() == sig.EntityID() {
+			found = true
+		}
+
+		prevEndBit = tmpSig.GetStartBit() + tmpSig.GetSize()
+	}
+
+	return freeSpace + msg.SizeByte()*8 - prevEndBit
+}
+
+// setSignalsEnum changes the signal enum of the given signals from oldEnum to newEnum.
+// If a signal cannot be updated, the already updated ones are restored.
+func setSignalsEnum(signals []*acmelib.EnumSignal, oldEnum, newEnum *acmelib.SignalEnum) error {
+	for idx, enumSig := range signals {
+		if err := enumSig.SetEnum(newEnum); err != nil {
+			for _, tmpSig := range signals[:idx] {
+				tmpSig.SetEnum(oldEnum)
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
 type signalEnumRes = response[*acmelib.SignalEnum]
 
 type signalEnumHandler struct {
 	*commonServiceHandler
+
+	library *networkLibrary
 }
 
-func newSignalEnumHandler(sidebar *sidebarController) *signalEnumHandler {
+func newSignalEnumHandler(sidebar *sidebarController, library *networkLibrary) *signalEnumHandler {
 	return &signalEnumHandler{
 		commonServiceHandler: newCommonServiceHandler(sidebar),
+
+		library: library,
 	}
 }
 
 func (h *signalEnumHandler) toResponse(sigEnum *acmelib.SignalEnum) SignalEnum {
-	return newSignalEnum(sigEnum)
+	res := newSignalEnum(sigEnum)
+	res.KeepInLibrary = h.library.isKept(sigEnum.EntityID())
+
+	if link, ok := h.library.getLink(sigEnum.EntityID()); ok {
+		res.LibraryPath = link.Path
+		res.ReadOnly = true
+	}
+
+	return res
 }
 
 func (h *signalEnumHandler) updateName(sigEnum *acmelib.SignalEnum, req *request, res *signalEnumRes) error {
@@ -604,3 +912,93 @@ func (h *signalEnumHandler) updateValueIndex(sigEnum *acmelib.SignalEnum, req *r
 
 	return nil
 }
+
+func (h *signalEnumHandler) updateKeepInLibrary(sigEnum *acmelib.SignalEnum, req *request, res *signalEnumRes) error {
+	return updateKeepInLibrary(h.library, sigEnum, req, res)
+}
+
+func (h *signalEnumHandler) importValues(sigEnum *acmelib.SignalEnum, req *request, res *signalEnumRes) error {
+	parsedReq := req.toImportValues()
+
+	sources, err := parseEnumSources(parsedReq.Format, parsedReq.Source)
+	if err != nil {
+		return err
+	}
+
+	srcValues, err := getImportedValues(sigEnum, sources, parsedReq)
+	if err != nil {
+		return err
+	}
+
+	if len(srcValues) == 0 && !parsedReq.Replace {
+		return nil
+	}
+
+	remValues := []*acmelib.SignalEnumValue{}
+	if parsedReq.Replace {
+		remValues = sigEnum.Values()
+	}
+
+	// the values are added from the lowest index, so the enum grows one step at a time
+	slices.SortFunc(srcValues, func(a, b EnumSourceValue) int { return a.Index - b.Index })
+
+	addValues := []*acmelib.SignalEnumValue{}
+	for _, srcVal := range srcValues {
+		val := acmelib.NewSignalEnumValue(srcVal.Name, srcVal.Index)
+		val.SetDesc(srcVal.Desc)
+		addValues = append(addValues, val)
+	}
+
+	if err := swapSignalEnumValues(sigEnum, remValues, addValues); err != nil {
+		return err
+	}
+
+	res.setUndo(
+		func() (*acmelib.SignalEnum, error) {
+			if err := swapSignalEnumValues(sigEnum, addValues, remValues); err != nil {
+				return nil, err
+			}
+			return sigEnum, nil
+		},
+	)
+
+	res.setRedo(
+		func() (*acmelib.SignalEnum, error) {
+			if err := swapSignalEnumValues(sigEnum, remValues, addValues); err != nil {
+				return nil, err
+			}
+			return sigEnum, nil
+		},
+	)
+
+	return nil
+}
+
+// swapSignalEnumValues removes the given values and adds the new ones.
+// If a value cannot be removed or added, the enum is restored.
+func swapSignalEnumValues(sigEnum *acmelib.SignalEnum, remValues, addValues []*acmelib.SignalEnumValue) error {
+	for idx, val := range remValues {
+		if err := sigEnum.RemoveValue(val.EntityID()); err != nil {
+			for _, tmpVal := range remValues[:idx] {
+				sigEnum.AddValue(tmpVal)
+			}
+
+			return err
+		}
+	}
+
+	for idx, val := range addValues {
+		if err := sigEnum.AddValue(val); err != nil {
+			for _, tmpVal := range addValues[:idx] {
+				sigEnum.RemoveValue(tmpVal.EntityID())
+			}
+			for _, tmpVal := range remValues {
+				sigEnum.AddValue(tmpVal)
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"strings"
 	"sync"
 
@@ -122,13 +124,20 @@ type SignalService struct {
 	*service[acmelib.Signal, Signal, *signalHandler]
 }
 
-func newSignalService(mux *sync.RWMutex, sidebar *sidebarController, sigTypeCtr *signalTypeController, sigUnitCtr *signalUnitController, sigEnumCtr *signalEnumController) *SignalService {
+func newSignalService(mux *sync.RWMutex, emitter eventEmitter, sidebar *sidebarController, sigTypeCtr *signalTypeController, sigUnitCtr *signalUnitController, sigEnumCtr *signalEnumController) *SignalService {
 	return &SignalService{
-		service: newService(serviceKindSignal, newSignalHandler(sidebar, sigTypeCtr, sigUnitCtr, sigEnumCtr), mux, sidebar),
+		service: newService(serviceKindSignal, newSignalHandler(sidebar, sigTypeCtr, sigUnitCtr, sigEnumCtr), mux, emitter, sidebar),
 	}
 }
 
-func (s *SignalService) GetInvalidNames(entityID string) []string {
+// forWindow returns the service of the window that made the call.
+func (s *SignalService) forWindow(ctx context.Context) *SignalService {
+	return s.resolve(ctx).signalSrv
+}
+
+func (s *SignalService) GetInvalidNames(ctx context.Context, entityID string) []string {
+	s = s.forWindow(ctx)
+
 	s.mux.RLock()
 	defer s.mux.RUnlock()
 
@@ -155,23 +164,28 @@ func (s *SignalService) GetInvalidNames(entityID string) []string {
 	return names
 }
 
-func (s *SignalService) UpdateName(entityID string, req UpdateNameReq) (Signal, error) {
+func (s *SignalService) UpdateName(ctx context.Context, entityID string, req UpdateNameReq) (Signal, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateName)
 }
 
-func (s *SignalService) UpdateDesc(entityID string, req UpdateDescReq) (Signal, error) {
+func (s *SignalService) UpdateDesc(ctx context.Context, entityID string, req UpdateDescReq) (Signal, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateDesc)
 }
 
-func (s *SignalService) UpdateSignalType(entityID string, req UpdateSignalTypeReq) (Signal, error) {
+func (s *SignalService) UpdateSignalType(ctx context.Context, entityID string, req UpdateSignalTypeReq) (Signal, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateSignalType)
 }
 
-func (s *SignalService) UpdateSignalUnit(entityID string, req UpdateSignalUnitReq) (Signal, error) {
+func (s *SignalService) UpdateSignalUnit(ctx context.Context, entityID string, req UpdateSignalUnitReq) (Signal, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateSignalUnit)
 }
 
-func (s *SignalService) UpdateSignalEnum(entityID string, req UpdateSignalEnumReq) (Signal, error) {
+func (s *SignalService) UpdateSignalEnum(ctx context.Context, entityID string, req UpdateSignalEnumReq) (Signal, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateSignalEnum)
 }
 
@@ -342,11 +356,26 @@ func (h *signalHandler) updateSignalUnit(sig acmelib.Signal, req *request, res *
 		}
 	}
 
+	var typeConv *signalTypeConversion
+	if parsedReq.ConvertType && oldSigUnit != nil && sigUnit != nil {
+		// the conversion edits the signal type, so it must not be read-only
+		if err := h.sigTypeCtr.checkEditableFn(stdSig.Type()); err != nil {
+			return err
+		}
+
+		typeConv, err = newSignalTypeConversion(stdSig, oldSigUnit, sigUnit)
+		if err != nil {
+			return err
+		}
+	}
+
 	stdSig.SetUnit(sigUnit)
+	typeConv.apply(false)
 
 	res.setUndo(
 		func() (acmelib.Signal, error) {
 			stdSig.SetUnit(oldSigUnit)
+			typeConv.apply(true)
 			return sig, nil
 		},
 	)
@@ -354,6 +383,7 @@ func (h *signalHandler) updateSignalUnit(sig acmelib.Signal, req *request, res *
 	res.setRedo(
 		func() (acmelib.Signal, error) {
 			stdSig.SetUnit(sigUnit)
+			typeConv.apply(false)
 			return sig, nil
 		},
 	)
@@ -361,6 +391,72 @@ func (h *signalHandler) updateSignalUnit(sig acmelib.Signal, req *request, res *
 	return nil
 }
 
+// signalTypeConversion rescales the type of a signal when its unit changes.
+type signalTypeConversion struct {
+	sigType *acmelib.SignalType
+
+	oldScale, oldOffset, oldMin, oldMax float64
+	newScale, newOffset, newMin, newMax float64
+}
+
+// newSignalTypeConversion computes the type of the signal in the new unit.
+// The type is shared by all the signals that use it, so it is converted
+// only if the signal is the only one that uses it.
+func newSignalTypeConversion(stdSig *acmelib.StandardSignal, from, to *acmelib.SignalUnit) (*signalTypeConversion, error) {
+	scale, offset, err := getUnitConversion(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	sigType := stdSig.Type()
+	if refCount := sigType.ReferenceCount(); refCount > 1 {
+		return nil, fmt.Errorf("the signal type %s is used by %d signals, it cannot be converted", sigType.Name(), refCount)
+	}
+
+	conv := &signalTypeConversion{
+		sigType: sigType,
+
+		oldScale:  sigType.Scale(),
+		oldOffset: sigType.Offset(),
+		oldMin:    sigType.Min(),
+		oldMax:    sigType.Max(),
+
+		newScale:  sigType.Scale() * scale,
+		newOffset: sigType.Offset()*scale + offset,
+		newMin:    sigType.Min()*scale + offset,
+		newMax:    sigType.Max()*scale + offset,
+	}
+
+	// the limits of a float signal type are the ones of the float
+	if isFloatSignalType(sigType) {
+		conv.newMin = conv.oldMin
+		conv.newMax = conv.oldMax
+	}
+
+	return conv, nil
+}
+
+// apply sets the converted values to the type or, if undo is set, the original ones.
+// It does nothing if the conversion is nil.
+func (c *signalTypeConversion) apply(undo bool) {
+	if c == nil {
+		return
+	}
+
+	if undo {
+		c.sigType.SetScale(c.oldScale)
+		c.sigType.SetOffset(c.oldOffset)
+		c.sigType.SetMin(c.oldMin)
+		c.sigType.SetMax(c.oldMax)
+		return
+	}
+
+	c.sigType.SetScale(c.newScale)
+	c.sigType.SetOffset(c.newOffset)
+	c.sigType.SetMin(c.newMin)
+	c.sigType.SetMax(c.newMax)
+}
+
 func (h *signalHandler) updateSignalEnum(sig acmelib.Signal, req *request, res *signalRes) error {
 	enumSig, err := sig.ToEnum()
 	if err != nil {
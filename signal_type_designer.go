@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"math"
+)
+
+// The designer derives the raw encoding of a signal type from the physical requirements:
+// the range of the values and the resolution needed. The physical value is
+// raw*scale + offset, so the scale is the resolution and the raw values
+// must cover the range. The candidates are:
+//
+//   - unsigned with no offset, if the range is not negative;
+//   - signed with no offset, if the range is negative;
+//   - unsigned with the offset set to the minimum.
+//
+// The candidate with the smallest size is chosen, the ones without offset
+// are preferred on the same size since they are easier to read on the bus.
+
+// designEpsilon absorbs the rounding errors of the floating point divisions,
+// e.g. 150/0.1 is not exactly 1500.
+const designEpsilon = 1e-9
+
+type DesignSignalTypeReq struct {
+	Min        float64 `json:"min"`
+	Max        float64 `json:"max"`
+	Resolution float64 `json:"resolution"`
+	// Name is the name of the created signal type, a new one is generated if it is empty.
+	Name string `json:"name"`
+}
+
+type SignalTypeDesign struct {
+	Size   int     `json:"size"`
+	Signed bool    `json:"signed"`
+	Scale  float64 `json:"scale"`
+	Offset float64 `json:"offset"`
+
+	// Min and Max are the required physical range.
+	Min float64 `json:"min"`
+	Max float64 `json:"max"`
+
+	// QuantizationError is the maximum difference between a physical value
+	// and the encoded one when the raw value is rounded.
+	QuantizationError float64 `json:"quantizationError"`
+
+	// RepresentableMin and RepresentableMax are the physical range
+	// that can be encoded with the size.
+	RepresentableMin float64 `json:"representableMin"`
+	RepresentableMax float64 `json:"representableMax"`
+}
+
+// designSignalType returns the smallest encoding that covers the requirements.
+func designSignalType(req DesignSignalTypeReq) (SignalTypeDesign, error) {
+	for _, val := range []float64{req.Min, req.Max, req.Resolution} {
+		if math.IsNaN(val) || math.IsInf(val, 0) {
+			return SignalTypeDesign{}, errors.New("design signal type: the values must be finite")
+		}
+	}
+
+	if req.Resolution <= 0 {
+		return SignalTypeDesign{}, errors.New("design signal type: the resolution must be positive")
+	}
+
+	if req.Max <= req.Min {
+		return SignalTypeDesign{}, errors.New("design signal type: the max must be greater than the min")
+	}
+
+	scale := req.Resolution
+
+	candidates := []SignalTypeDesign{}
+
+	if req.Min >= 0 {
+		rawMax := math.Ceil(req.Max/scale - designEpsilon)
+		candidates = append(candidates, newSignalTypeDesign(req, false, 0, getUnsignedSize(rawMax)))
+	} else {
+		rawMin := math.Floor(req.Min/scale + designEpsilon)
+		rawMax := math.Ceil(req.Max/scale - designEpsilon)
+		candidates = append(candidates, newSignalTypeDesign(req, true, 0, getSignedSize(rawMin, rawMax)))
+	}
+
+	if req.Min != 0 {
+		rawMax := math.Ceil((req.Max-req.Min)/scale - designEpsilon)
+		candidates = append(candidates, newSignalTypeDesign(req, false, req.Min, getUnsignedSize(rawMax)))
+	}
+
+	best := candidates[0]
+	for _, candidate := range candidates[1:] {
+		if candidate.Size < best.Size {
+			best = candidate
+		}
+	}
+
+	if best.Size > 64 {
+		return SignalTypeDesign{}, errors.New("design signal type: the range needs more than 64 bits at the given resolution")
+	}
+
+	return best, nil
+}
+
+func newSignalTypeDesign(req DesignSignalTypeReq, signed bool, offset float64, size int) SignalTypeDesign {
+	design := SignalTypeDesign{
+		Size:   size,
+		Signed: signed,
+		Scale:  req.Resolution,
+		Offset: offset,
+
+		Min: req.Min,
+		Max: req.Max,
+
+		QuantizationError: req.Resolution / 2,
+	}
+
+	if signed {
+		design.RepresentableMin = offset - math.Exp2(float64(size-1))*design.Scale
+		design.RepresentableMax = offset + (math.Exp2(float64(size-1))-1)*design.Scale
+	} else {
+		design.RepresentableMin = offset
+		design.RepresentableMax = offset + (math.Exp2(float64(size))-1)*design.Scale
+	}
+
+	return design
+}
+
+// getUnsignedSize returns the number of bits needed to encode the raw values from 0 to rawMax.
+// It returns 65 if they do not fit 64 bits.
+func getUnsignedSize(rawMax float64) int {
+	for size := 1; size <= 64; size++ {
+		if rawMax <= math.Exp2(float64(size))-1 {
+			return size
+		}
+	}
+	return 65
+}
+
+// getSignedSize returns the number of bits needed to encode the raw values
+// from rawMin to rawMax in two's complement. It returns 65 if they do not fit 64 bits.
+func getSignedSize(rawMin, rawMax float64) int {
+	for size := 2; size <= 64; size++ {
+		limit := math.Exp2(float64(size - 1))
+		if rawMin >= -limit && rawMax <= limit-1 {
+			return size
+		}
+	}
+	return 65
+}
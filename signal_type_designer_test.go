@@ -0,0 +1,57 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDesignSignalType(t *testing.T) {
+	tests := []struct {
+		name string
+		req  DesignSignalTypeReq
+		want SignalTypeDesign
+	}{
+		{
+			name: "temperature with offset",
+			req:  DesignSignalTypeReq{Min: -40, Max: 150, Resolution: 0.1},
+			want: SignalTypeDesign{Size: 11, Signed: false, Scale: 0.1, Offset: -40, RepresentableMin: -40, RepresentableMax: 164.7},
+		},
+		{
+			name: "positive range without offset",
+			req:  DesignSignalTypeReq{Min: 0, Max: 255, Resolution: 1},
+			want: SignalTypeDesign{Size: 8, Signed: false, Scale: 1, Offset: 0, RepresentableMin: 0, RepresentableMax: 255},
+		},
+		{
+			name: "symmetric range signed",
+			req:  DesignSignalTypeReq{Min: -100, Max: 100, Resolution: 1},
+			want: SignalTypeDesign{Size: 8, Signed: true, Scale: 1, Offset: 0, RepresentableMin: -128, RepresentableMax: 127},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := designSignalType(tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got.Size != tt.want.Size || got.Signed != tt.want.Signed || got.Offset != tt.want.Offset {
+				t.Errorf("got size=%d signed=%t offset=%v, want size=%d signed=%t offset=%v",
+					got.Size, got.Signed, got.Offset, tt.want.Size, tt.want.Signed, tt.want.Offset)
+			}
+
+			if math.Abs(got.RepresentableMin-tt.want.RepresentableMin) > 1e-9 || math.Abs(got.RepresentableMax-tt.want.RepresentableMax) > 1e-9 {
+				t.Errorf("got range %v…%v, want %v…%v",
+					got.RepresentableMin, got.RepresentableMax, tt.want.RepresentableMin, tt.want.RepresentableMax)
+			}
+
+			if got.QuantizationError != tt.req.Resolution/2 {
+				t.Errorf("got quantization error %v, want %v", got.QuantizationError, tt.req.Resolution/2)
+			}
+		})
+	}
+
+	if _, err := designSignalType(DesignSignalTypeReq{Min: 10, Max: 0, Resolution: 1}); err == nil {
+		t.Error("expected an error for an empty range")
+	}
+}
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"errors"
 	"fmt"
 	"slices"
 	"strings"
@@ -16,35 +19,26 @@ const (
 	SignalTypeKindFlag    SignalTypeKind = "flag"
 	SignalTypeKindInteger SignalTypeKind = "integer"
 	SignalTypeKindDecimal SignalTypeKind = "decimal"
+	SignalTypeKindFloat32 SignalTypeKind = "float32"
+	SignalTypeKindFloat64 SignalTypeKind = "float64"
 )
 
 func newSignalTypeKind(kind acmelib.SignalTypeKind) SignalTypeKind {
 	return SignalTypeKind(kind.String())
 }
 
-func compareSignalTypeKind(a, b SignalTypeKind) int {
-	if a == b {
-		return 0
-	}
-
-	switch a {
-	case SignalTypeKindFlag:
-		return -1
-
-	case SignalTypeKindInteger:
-		if b == SignalTypeKindFlag {
-			return 1
-		}
-		return -1
-
-	case SignalTypeKindDecimal:
-		if b == SignalTypeKindCustom {
-			return -1
-		}
-		return 1
-	}
+// signalTypeKindOrder is the order of the kinds in the lists of signal types.
+var signalTypeKindOrder = []SignalTypeKind{
+	SignalTypeKindFlag,
+	SignalTypeKindInteger,
+	SignalTypeKindDecimal,
+	SignalTypeKindFloat32,
+	SignalTypeKindFloat64,
+	SignalTypeKindCustom,
+}
 
-	return 1
+func compareSignalTypeKind(a, b SignalTypeKind) int {
+	return cmp.Compare(slices.Index(signalTypeKindOrder, a), slices.Index(signalTypeKindOrder, b))
 }
 
 type SignalTypeBrief struct {
@@ -58,7 +52,7 @@ func newSignalTypeBrief(sigType *acmelib.SignalType) SignalTypeBrief {
 	return SignalTypeBrief{
 		BaseEntity: newBaseEntity(sigType),
 
-		Kind: newSignalTypeKind(sigType.Kind()),
+		Kind: getSignalTypeKind(sigType),
 		Size: sigType.Size(),
 	}
 }
@@ -74,29 +68,49 @@ type SignalType struct {
 	Scale  float64        `json:"scale"`
 	Offset float64        `json:"offset"`
 
+	KeepInLibrary bool `json:"keepInLibrary"`
+	// LibraryPath is the path of the library file the entity is linked to,
+	// a linked entity is read-only.
+	LibraryPath string `json:"libraryPath"`
+	ReadOnly    bool   `json:"readOnly"`
+
 	ReferenceCount int         `json:"referenceCount"`
 	References     []Reference `json:"references"`
 }
 
 type SignalTypeService struct {
 	*service[*acmelib.SignalType, SignalType, *signalTypeHandler]
+
+	library *networkLibrary
 }
 
-func newSignalTypeService(mux *sync.RWMutex, sidebar *sidebarController) *SignalTypeService {
-	return &SignalTypeService{
-		service: newService(serviceKindSignalType, newSignalTypeHandler(sidebar), mux, sidebar),
+func newSignalTypeService(mux *sync.RWMutex, emitter eventEmitter, sidebar *sidebarController, library *networkLibrary) *SignalTypeService {
+	srv := &SignalTypeService{
+		service: newService(serviceKindSignalType, newSignalTypeHandler(sidebar, library), mux, emitter, sidebar),
+
+		library: library,
+	}
+
+	// the entities linked to a library file are updated only from the file
+	srv.checkEditable = func(ent *acmelib.SignalType) error {
+		return library.checkEditable(ent)
 	}
+
+	return srv
 }
 
-func (s *SignalTypeService) Create(req CreateSignalTypeReq) (SignalType, error) {
+// forWindow returns the service of the window that made the call.
+func (s *SignalTypeService) forWindow(ctx context.Context) *SignalTypeService {
+	return s.resolve(ctx).signalTypeSrv
+}
+
+func (s *SignalTypeService) Create(ctx context.Context, req CreateSignalTypeReq) (SignalType, error) {
+	s = s.forWindow(ctx)
+
 	s.mux.Lock()
 	defer s.mux.Unlock()
 
-	takenNames := make(map[string]struct{})
-	for _, sigType := range s.entities {
-		takenNames[sigType.Name()] = struct{}{}
-	}
-	name := getNewName("signal_type", takenNames)
+	name := getNewName("signal_type", s.getTakenNames())
 
 	var sigType *acmelib.SignalType
 	switch req.SignalTypeKind {
@@ -123,28 +137,94 @@ func (s *SignalTypeService) Create(req CreateSignalTypeReq) (SignalType, error)
 			return SignalType{}, err
 		}
 		sigType = tmpSigType
+
+	case SignalTypeKindFloat32, SignalTypeKindFloat64:
+		// the size of a float is fixed, it can be omitted
+		if size, _ := getFloatLimit(req.SignalTypeKind); req.Size != 0 && req.Size != size {
+			return SignalType{}, fmt.Errorf("create signal type: a %s signal type must be %d bits, got %d", req.SignalTypeKind, size, req.Size)
+		}
+
+		tmpSigType, err := newFloatSignalType(name, req.SignalTypeKind, 1, 0)
+		if err != nil {
+			return SignalType{}, err
+		}
+		sigType = tmpSigType
+
+	default:
+		return SignalType{}, fmt.Errorf("create signal type: invalid kind %s", req.SignalTypeKind)
+	}
+
+	return s.create(sigType), nil
+}
+
+// Design proposes the encoding of a signal type that covers the given physical
+// range with the given resolution, without creating it.
+func (s *SignalTypeService) Design(ctx context.Context, req DesignSignalTypeReq) (SignalTypeDesign, error) {
+	s = s.forWindow(ctx)
+	return designSignalType(req)
+}
+
+// CreateFromDesign creates the signal type proposed by Design.
+func (s *SignalTypeService) CreateFromDesign(ctx context.Context, req DesignSignalTypeReq) (SignalType, error) {
+	s = s.forWindow(ctx)
+
+	design, err := designSignalType(req)
+	if err != nil {
+		return SignalType{}, err
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	takenNames := s.getTakenNames()
+
+	name := req.Name
+	if len(name) == 0 {
+		name = getNewName("signal_type", takenNames)
+	} else if _, ok := takenNames[name]; ok {
+		return SignalType{}, fmt.Errorf("create signal type: the name %s is already taken", name)
+	}
+
+	sigType, err := acmelib.NewCustomSignalType(name, design.Size, design.Signed, design.Min, design.Max, design.Scale, design.Offset)
+	if err != nil {
+		return SignalType{}, err
+	}
+
+	return s.create(sigType), nil
+}
+
+func (s *SignalTypeService) getTakenNames() map[string]struct{} {
+	takenNames := make(map[string]struct{})
+	for _, sigType := range s.entities {
+		takenNames[sigType.Name()] = struct{}{}
 	}
+	return takenNames
+}
 
+// create adds the signal type and records the operation.
+func (s *SignalTypeService) create(sigType *acmelib.SignalType) SignalType {
 	s.addEntity(sigType)
 	s.sidebarCtr.sendAdd(sigType)
 
 	s.sendHistoryOp(
 		func() (*acmelib.SignalType, error) {
-			s.addEntity(sigType)
-			s.sidebarCtr.sendAdd(sigType)
+			s.removeEntity(sigType.EntityID().String())
+			s.sidebarCtr.sendDelete(sigType)
 			return sigType, nil
 		},
 		func() (*acmelib.SignalType, error) {
-			s.removeEntity(sigType.EntityID().String())
-			s.sidebarCtr.sendDelete(sigType)
+			s.addEntity(sigType)
+			s.sidebarCtr.sendAdd(sigType)
 			return sigType, nil
 		},
 	)
 
-	return s.handler.toResponse(sigType), nil
+	return s.handler.toResponse(sigType)
 }
 
-func (s *SignalTypeService) Delete(entityID string) error {
+func (s *SignalTypeService) Delete(ctx context.Context, entityID string) error {
+	s = s.forWindow(ctx)
+
 	s.mux.Lock()
 	defer s.mux.Unlock()
 
@@ -154,21 +234,82 @@ func (s *SignalTypeService) Delete(entityID string) error {
 	}
 
 	if sigType.ReferenceCount() > 0 {
-		return fmt.Errorf("signal type %s is referenced %d times", sigType.Name(), sigType.ReferenceCount())
+		return newReferencedError("signal type", sigType, sigType.References())
+	}
+
+	return s.delete(sigType, nil, nil)
+}
+
+// DeleteWithReplacement deletes the signal type after retargeting all the signals
+// that are referencing it to the replacement signal type.
+// The whole operation is recorded as a single history entry.
+func (s *SignalTypeService) DeleteWithReplacement(ctx context.Context, entityID string, req DeleteWithReplacementReq) error {
+	s = s.forWindow(ctx)
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	sigType, err := s.getEntity(entityID)
+	if err != nil {
+		return err
+	}
+
+	if len(req.ReplacementEntityID) == 0 {
+		if sigType.ReferenceCount() > 0 {
+			return newReferencedError("signal type", sigType, sigType.References())
+		}
+
+		return s.delete(sigType, nil, nil)
+	}
+
+	if req.ReplacementEntityID == entityID {
+		return errors.New("a signal type cannot be replaced by itself")
+	}
+
+	replacement, err := s.getEntity(req.ReplacementEntityID)
+	if err != nil {
+		return err
+	}
+
+	return s.delete(sigType, sigType.References(), replacement)
+}
+
+// delete sets the replacement signal type to the given signals,
+// then it removes the signal type and records the operation.
+func (s *SignalTypeService) delete(sigType *acmelib.SignalType, refSignals []*acmelib.StandardSignal, replacement *acmelib.SignalType) error {
+	if err := setSignalsType(refSignals, sigType, replacement); err != nil {
+		return err
 	}
 
-	s.removeEntity(entityID)
+	// a deleted entity is no longer kept in the library, nor linked to a library file
+	restoreLibrary := s.library.remove(sigType)
+
+	s.removeEntity(sigType.EntityID().String())
 	s.sidebarCtr.sendDelete(sigType)
 
 	s.sendHistoryOp(
 		func() (*acmelib.SignalType, error) {
+			if err := setSignalsType(refSignals, replacement, sigType); err != nil {
+				return nil, err
+			}
+
+			restoreLibrary()
+
 			s.addEntity(sigType)
 			s.sidebarCtr.sendAdd(sigType)
+
 			return sigType, nil
 		},
 		func() (*acmelib.SignalType, error) {
+			if err := setSignalsType(refSignals, sigType, replacement); err != nil {
+				return nil, err
+			}
+
+			s.library.remove(sigType)
+
 			s.removeEntity(sigType.EntityID().String())
 			s.sidebarCtr.sendDelete(sigType)
+
 			return sigType, nil
 		},
 	)
@@ -176,7 +317,50 @@ func (s *SignalTypeService) Delete(entityID string) error {
 	return nil
 }
 
-func (s *SignalTypeService) ListBrief() []SignalTypeBrief {
+// FindDuplicates returns the groups of structurally identical signal types.
+func (s *SignalTypeService) FindDuplicates(ctx context.Context) []DuplicateGroup {
+	s = s.forWindow(ctx)
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	return findDuplicateGroups(s.listEntities(), getSignalTypeDuplicateKey)
+}
+
+// MergeDuplicates retargets the signals referencing the given signal types
+// to the survivor and deletes them. The whole operation is recorded as a single history entry.
+func (s *SignalTypeService) MergeDuplicates(ctx context.Context, req MergeDuplicatesReq) (SignalType, error) {
+	s = s.forWindow(ctx)
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	survivor, duplicates, err := s.getMergeEntities(req, getSignalTypeDuplicateKey)
+	if err != nil {
+		return SignalType{}, err
+	}
+
+	refSignals := make(map[acmelib.EntityID][]*acmelib.StandardSignal)
+	for _, dup := range duplicates {
+		refSignals[dup.EntityID()] = dup.References()
+	}
+
+	err = s.merge(survivor, duplicates, s.library, func(dup *acmelib.SignalType, undo bool) error {
+		if undo {
+			return setSignalsType(refSignals[dup.EntityID()], survivor, dup)
+		}
+		return setSignalsType(refSignals[dup.EntityID()], dup, survivor)
+	})
+	if err != nil {
+		return SignalType{}, err
+	}
+
+	return s.handler.toResponse(survivor), nil
+}
+
+func (s *SignalTypeService) ListBrief(ctx context.Context) []SignalTypeBrief {
+	s = s.forWindow(ctx)
+
 	s.mux.RLock()
 	defer s.mux.RUnlock()
 
@@ -200,43 +384,77 @@ func (s *SignalTypeService) ListBrief() []SignalTypeBrief {
 	return res
 }
 
-func (s *SignalTypeService) UpdateName(entityID string, req UpdateNameReq) (SignalType, error) {
+func (s *SignalTypeService) UpdateName(ctx context.Context, entityID string, req UpdateNameReq) (SignalType, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateName)
 }
 
-func (s *SignalTypeService) UpdateDesc(entityID string, req UpdateDescReq) (SignalType, error) {
+func (s *SignalTypeService) UpdateDesc(ctx context.Context, entityID string, req UpdateDescReq) (SignalType, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateDesc)
 }
 
-func (s *SignalTypeService) UpdateSigned(entityID string, req UpdateSignedReq) (SignalType, error) {
+func (s *SignalTypeService) UpdateSigned(ctx context.Context, entityID string, req UpdateSignedReq) (SignalType, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateSigned)
 }
 
-func (s *SignalTypeService) UpdateMin(entityID string, req UpdateMinReq) (SignalType, error) {
+func (s *SignalTypeService) UpdateMin(ctx context.Context, entityID string, req UpdateMinReq) (SignalType, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateMin)
 }
 
-func (s *SignalTypeService) UpdateMax(entityID string, req UpdateMaxReq) (SignalType, error) {
+func (s *SignalTypeService) UpdateMax(ctx context.Context, entityID string, req UpdateMaxReq) (SignalType, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateMax)
 }
 
-func (s *SignalTypeService) UpdateScale(entityID string, req UpdateScaleReq) (SignalType, error) {
+func (s *SignalTypeService) UpdateScale(ctx context.Context, entityID string, req UpdateScaleReq) (SignalType, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateScale)
 }
 
-func (s *SignalTypeService) UpdateOffset(entityID string, req UpdateOffsetReq) (SignalType, error) {
+func (s *SignalTypeService) UpdateOffset(ctx context.Context, entityID string, req UpdateOffsetReq) (SignalType, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateOffset)
 }
 
+// UpdateKeepInLibrary sets whether the signal type is kept in the library of the network,
+// so it is saved even when no signal references it.
+func (s *SignalTypeService) UpdateKeepInLibrary(ctx context.Context, entityID string, req UpdateKeepInLibraryReq) (SignalType, error) {
+	s = s.forWindow(ctx)
+	return s.handle(entityID, &req, s.handler.updateKeepInLibrary)
+}
+
+// setSignalsType changes the signal type of the given signals from oldType to newType.
+// If a signal cannot be updated, the already updated ones are restored.
+func setSignalsType(signals []*acmelib.StandardSignal, oldType, newType *acmelib.SignalType) error {
+	for idx, stdSig := range signals {
+		if err := stdSig.SetType(newType); err != nil {
+			for _, tmpSig := range signals[:idx] {
+				tmpSig.SetType(oldType)
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
 type signalTypeRes = response[*acmelib.SignalType]
 
 type signalTypeHandler struct {
 	*commonServiceHandler
+
+	library *networkLibrary
 }
 
-func newSignalTypeHandler(sidebar *sidebarController) *signalTypeHandler {
+func newSignalTypeHandler(sidebar *sidebarController, library *networkLibrary) *signalTypeHandler {
 	return &signalTypeHandler{
 		commonServiceHandler: newCommonServiceHandler(sidebar),
+
+		library: library,
 	}
 }
 
@@ -246,7 +464,7 @@ func (h *signalTypeHandler) toResponse(sigType *acmelib.SignalType) SignalType {
 	res := SignalType{
 		base: getBase(sigType),
 
-		Kind:   newSignalTypeKind(sigType.Kind()),
+		Kind:   getSignalTypeKind(sigType),
 		Size:   int(sigType.Size()),
 		Signed: sigType.Signed(),
 		Min:    sigType.Min(),
@@ -254,51 +472,21 @@ func (h *signalTypeHandler) toResponse(sigType *acmelib.SignalType) SignalType {
 		Scale:  sigType.Scale(),
 		Offset: sigType.Offset(),
 
+		KeepInLibrary: h.library.isKept(sigType.EntityID()),
+
 		ReferenceCount: refCount,
 	}
 
+	if link, ok := h.library.getLink(sigType.EntityID()); ok {
+		res.LibraryPath = link.Path
+		res.ReadOnly = true
+	}
+
 	if refCount == 0 {
 		return res
 	}
 
-	rootRefs := []*reference{}
-	refs := make(map[acmelib.EntityID]*reference)
-	for _, sig := range sigType.References() {
-		sigRef := newReference(sig)
-		refs[sig.EntityID()] = sigRef
-
-		var msgRef *reference
-		msg := sig.ParentMessage()
-		msgRef, ok := refs[msg.EntityID()]
-		if !ok {
-			msgRef = newReference(msg)
-			refs[msg.EntityID()] = msgRef
-		}
-		msgRef.addChild(sigRef)
-
-		var nodeRef *reference
-		node := msg.SenderNodeInterface().Node()
-		nodeRef, ok = refs[node.EntityID()]
-		if !ok {
-			nodeRef = newReference(node)
-			refs[node.EntityID()] = nodeRef
-		}
-		nodeRef.addChild(msgRef)
-
-		var busRef *reference
-		bus := msg.SenderNodeInterface().ParentBus()
-		busRef, ok = refs[bus.EntityID()]
-		if !ok {
-			busRef = newReference(bus)
-			refs[bus.EntityID()] = busRef
-			rootRefs = append(rootRefs, busRef)
-		}
-		busRef.addChild(nodeRef)
-	}
-
-	for _, tmpRef := range rootRefs {
-		res.References = append(res.References, tmpRef.toResponse())
-	}
+	res.References = newSignalReferences(sigType.References())
 
 	return res
 }
@@ -365,6 +553,10 @@ func (h *signalTypeHandler) updateDesc(sigType *acmelib.SignalType, req *request
 }
 
 func (h *signalTypeHandler) updateSigned(sigType *acmelib.SignalType, req *request, res *signalTypeRes) error {
+	if isFloatSignalType(sigType) {
+		return errors.New("the sign of a float signal type cannot be changed")
+	}
+
 	parsedReq := req.toUpdateSigned()
 
 	signed := parsedReq.Signed
@@ -394,6 +586,10 @@ func (h *signalTypeHandler) updateSigned(sigType *acmelib.SignalType, req *reque
 }
 
 func (h *signalTypeHandler) updateMin(sigType *acmelib.SignalType, req *request, res *signalTypeRes) error {
+	if isFloatSignalType(sigType) {
+		return errors.New("the min of a float signal type cannot be changed")
+	}
+
 	parsedReq := req.toUpdateMin()
 
 	min := parsedReq.Min
@@ -423,6 +619,10 @@ func (h *signalTypeHandler) updateMin(sigType *acmelib.SignalType, req *request,
 }
 
 func (h *signalTypeHandler) updateMax(sigType *acmelib.SignalType, req *request, res *signalTypeRes) error {
+	if isFloatSignalType(sigType) {
+		return errors.New("the max of a float signal type cannot be changed")
+	}
+
 	parsedReq := req.toUpdateMax()
 
 	max := parsedReq.Max
@@ -508,3 +708,7 @@ func (h *signalTypeHandler) updateOffset(sigType *acmelib.SignalType, req *reque
 
 	return nil
 }
+
+func (h *signalTypeHandler) updateKeepInLibrary(sigType *acmelib.SignalType, req *request, res *signalTypeRes) error {
+	return updateKeepInLibrary(h.library, sigType, req, res)
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"slices"
@@ -68,6 +70,15 @@ type SignalUnit struct {
 
 	Kind   SignalUnitKind `json:"kind"`
 	Symbol string         `json:"symbol"`
+	// Dimension is found by looking up the symbol in the unit library,
+	// it is empty if the symbol is unknown.
+	Dimension UnitDimension `json:"dimension"`
+
+	KeepInLibrary bool `json:"keepInLibrary"`
+	// LibraryPath is the path of the library file the entity is linked to,
+	// a linked entity is read-only.
+	LibraryPath string `json:"libraryPath"`
+	ReadOnly    bool   `json:"readOnly"`
 
 	ReferenceCount int         `json:"referenceCount"`
 	References     []Reference `json:"references"`
@@ -79,8 +90,9 @@ func newSignalUnit(sigUnit *acmelib.SignalUnit) SignalUnit {
 	res := SignalUnit{
 		base: getBase(sigUnit),
 
-		Kind:   newSignalUnitKind(sigUnit.Kind()),
-		Symbol: sigUnit.Symbol(),
+		Kind:      newSignalUnitKind(sigUnit.Kind()),
+		Symbol:    sigUnit.Symbol(),
+		Dimension: getUnitDimension(sigUnit),
 
 		ReferenceCount: refCount,
 	}
@@ -89,69 +101,86 @@ func newSignalUnit(sigUnit *acmelib.SignalUnit) SignalUnit {
 		return res
 	}
 
-	rootRefs := []*reference{}
-	refs := make(map[acmelib.EntityID]*reference)
-	for _, stdSig := range sigUnit.References() {
-		sigRef := newReference(stdSig)
-		refs[stdSig.EntityID()] = sigRef
-
-		var msgRef *reference
-		msg := stdSig.ParentMessage()
-		msgRef, ok := refs[msg.EntityID()]
-		if !ok {
-			msgRef = newReference(msg)
-			refs[msg.EntityID()] = msgRef
-		}
-		msgRef.addChild(sigRef)
-
-		var nodeRef *reference
-		node := msg.SenderNodeInterface().Node()
-		nodeRef, ok = refs[node.EntityID()]
-		if !ok {
-			nodeRef = newReference(node)
-			refs[node.EntityID()] = nodeRef
-		}
-		nodeRef.addChild(msgRef)
-
-		var busRef *reference
-		bus := msg.SenderNodeInterface().ParentBus()
-		busRef, ok = refs[bus.EntityID()]
-		if !ok {
-			busRef = newReference(bus)
-			refs[bus.EntityID()] = busRef
-			rootRefs = append(rootRefs, busRef)
-		}
-		busRef.addChild(nodeRef)
-	}
-
-	for _, tmpRef := range rootRefs {
-		res.References = append(res.References, tmpRef.toResponse())
-	}
+	res.References = newSignalReferences(sigUnit.References())
 
 	return res
 }
 
 type SignalUnitService struct {
 	*service[*acmelib.SignalUnit, SignalUnit, *signalUnitHandler]
+
+	library *networkLibrary
 }
 
-func newSignalUnitService(mux *sync.RWMutex, sidebar *sidebarController) *SignalUnitService {
-	return &SignalUnitService{
-		service: newService(serviceKindSignalUnit, newSignalUnitHandler(sidebar), mux, sidebar),
+func newSignalUnitService(mux *sync.RWMutex, emitter eventEmitter, sidebar *sidebarController, library *networkLibrary) *SignalUnitService {
+	srv := &SignalUnitService{
+		service: newService(serviceKindSignalUnit, newSignalUnitHandler(sidebar, library), mux, emitter, sidebar),
+
+		library: library,
+	}
+
+	// the entities linked to a library file are updated only from the file
+	srv.checkEditable = func(ent *acmelib.SignalUnit) error {
+		return library.checkEditable(ent)
 	}
+
+	return srv
 }
 
-func (s *SignalUnitService) Create() (SignalUnit, error) {
+// forWindow returns the service of the window that made the call.
+func (s *SignalUnitService) forWindow(ctx context.Context) *SignalUnitService {
+	return s.resolve(ctx).signalUnitSrv
+}
+
+func (s *SignalUnitService) Create(ctx context.Context) (SignalUnit, error) {
+	s = s.forWindow(ctx)
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	takenNames := s.getTakenNames()
+
+	return s.create(acmelib.NewSignalUnit(getNewName("signal_unit", takenNames), acmelib.SignalUnitKindCustom, "")), nil
+}
+
+// ListLibraryUnits returns the units of the built-in library.
+func (s *SignalUnitService) ListLibraryUnits(ctx context.Context) []UnitDefinition {
+	s = s.forWindow(ctx)
+	return slices.Clone(unitLibrary)
+}
+
+// CreateFromLibrary creates a signal unit from the unit of the built-in library with the given symbol.
+func (s *SignalUnitService) CreateFromLibrary(ctx context.Context, symbol string) (SignalUnit, error) {
+	s = s.forWindow(ctx)
+
+	unit, ok := lookupUnit(symbol)
+	if !ok {
+		return SignalUnit{}, fmt.Errorf("create signal unit: unit %q not found in the library", symbol)
+	}
+
 	s.mux.Lock()
 	defer s.mux.Unlock()
 
+	takenNames := s.getTakenNames()
+
+	name := unit.Name
+	if _, ok := takenNames[name]; ok {
+		name = getNewName(unit.Name, takenNames)
+	}
+
+	return s.create(acmelib.NewSignalUnit(name, unit.Kind.parse(), unit.Symbol)), nil
+}
+
+func (s *SignalUnitService) getTakenNames() map[string]struct{} {
 	takenNames := make(map[string]struct{})
 	for _, sigUnit := range s.entities {
 		takenNames[sigUnit.Name()] = struct{}{}
 	}
+	return takenNames
+}
 
-	sigUnit := acmelib.NewSignalUnit(getNewName("signal_unit", takenNames), acmelib.SignalUnitKindCustom, "")
-
+// create adds the signal unit and records the operation.
+func (s *SignalUnitService) create(sigUnit *acmelib.SignalUnit) SignalUnit {
 	s.addEntity(sigUnit)
 	s.sidebarCtr.sendAdd(sigUnit)
 
@@ -168,10 +197,12 @@ func (s *SignalUnitService) Create() (SignalUnit, error) {
 		},
 	)
 
-	return s.handler.toResponse(sigUnit), nil
+	return s.handler.toResponse(sigUnit)
 }
 
-func (s *SignalUnitService) Delete(entityID string) error {
+func (s *SignalUnitService) Delete(ctx context.Context, entityID string) error {
+	s = s.forWindow(ctx)
+
 	s.mux.Lock()
 	defer s.mux.Unlock()
 
@@ -181,21 +212,73 @@ func (s *SignalUnitService) Delete(entityID string) error {
 	}
 
 	if sigUnit.ReferenceCount() > 0 {
-		return fmt.Errorf("signal unit %s is referenced %d times", sigUnit.Name(), sigUnit.ReferenceCount())
+		return newReferencedError("signal unit", sigUnit, sigUnit.References())
+	}
+
+	return s.delete(sigUnit, nil, nil)
+}
+
+// DeleteWithReplacement deletes the signal unit after retargeting all the signals
+// that are referencing it to the replacement signal unit.
+// If the replacement is not specified, the unit is cleared from the signals.
+// The whole operation is recorded as a single history entry.
+func (s *SignalUnitService) DeleteWithReplacement(ctx context.Context, entityID string, req DeleteWithReplacementReq) error {
+	s = s.forWindow(ctx)
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	sigUnit, err := s.getEntity(entityID)
+	if err != nil {
+		return err
+	}
+
+	if len(req.ReplacementEntityID) == 0 {
+		return s.delete(sigUnit, sigUnit.References(), nil)
+	}
+
+	if req.ReplacementEntityID == entityID {
+		return errors.New("a signal unit cannot be replaced by itself")
+	}
+
+	replacement, err := s.getEntity(req.ReplacementEntityID)
+	if err != nil {
+		return err
 	}
 
-	s.removeEntity(entityID)
+	return s.delete(sigUnit, sigUnit.References(), replacement)
+}
+
+// delete sets the replacement signal unit to the given signals,
+// then it removes the signal unit and records the operation.
+func (s *SignalUnitService) delete(sigUnit *acmelib.SignalUnit, refSignals []*acmelib.StandardSignal, replacement *acmelib.SignalUnit) error {
+	setSignalsUnit(refSignals, replacement)
+
+	// a deleted entity is no longer kept in the library, nor linked to a library file
+	restoreLibrary := s.library.remove(sigUnit)
+
+	s.removeEntity(sigUnit.EntityID().String())
 	s.sidebarCtr.sendDelete(sigUnit)
 
 	s.sendHistoryOp(
 		func() (*acmelib.SignalUnit, error) {
+			setSignalsUnit(refSignals, sigUnit)
+
+			restoreLibrary()
+
 			s.addEntity(sigUnit)
 			s.sidebarCtr.sendAdd(sigUnit)
+
 			return sigUnit, nil
 		},
 		func() (*acmelib.SignalUnit, error) {
+			setSignalsUnit(refSignals, replacement)
+
+			s.library.remove(sigUnit)
+
 			s.removeEntity(sigUnit.EntityID().String())
 			s.sidebarCtr.sendDelete(sigUnit)
+
 			return sigUnit, nil
 		},
 	)
@@ -203,7 +286,53 @@ func (s *SignalUnitService) Delete(entityID string) error {
 	return nil
 }
 
-func (s *SignalUnitService) ListBrief() []SignalUnitBrief {
+// FindDuplicates returns the groups of signal units with the same kind and symbol,
+// the units without a symbol are never grouped.
+func (s *SignalUnitService) FindDuplicates(ctx context.Context) []DuplicateGroup {
+	s = s.forWindow(ctx)
+
+	s.mux.RLock()
+	defer s.mux.RUnlock()
+
+	return findDuplicateGroups(s.listEntities(), getSignalUnitDuplicateKey)
+}
+
+// MergeDuplicates retargets the signals referencing the given signal units
+// to the survivor and deletes them. The whole operation is recorded as a single history entry.
+func (s *SignalUnitService) MergeDuplicates(ctx context.Context, req MergeDuplicatesReq) (SignalUnit, error) {
+	s = s.forWindow(ctx)
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	survivor, duplicates, err := s.getMergeEntities(req, getSignalUnitDuplicateKey)
+	if err != nil {
+		return SignalUnit{}, err
+	}
+
+	refSignals := make(map[acmelib.EntityID][]*acmelib.StandardSignal)
+	for _, dup := range duplicates {
+		refSignals[dup.EntityID()] = dup.References()
+	}
+
+	err = s.merge(survivor, duplicates, s.library, func(dup *acmelib.SignalUnit, undo bool) error {
+		if undo {
+			setSignalsUnit(refSignals[dup.EntityID()], dup)
+		} else {
+			setSignalsUnit(refSignals[dup.EntityID()], survivor)
+		}
+		return nil
+	})
+	if err != nil {
+		return SignalUnit{}, err
+	}
+
+	return s.handler.toResponse(survivor), nil
+}
+
+func (s *SignalUnitService) ListBrief(ctx context.Context) []SignalUnitBrief {
+	s = s.forWindow(ctx)
+
 	s.mux.RLock()
 	defer s.mux.RUnlock()
 
@@ -223,36 +352,66 @@ func (s *SignalUnitService) ListBrief() []SignalUnitBrief {
 	return res
 }
 
-func (s *SignalUnitService) UpdateName(entityID string, req UpdateNameReq) (SignalUnit, error) {
+func (s *SignalUnitService) UpdateName(ctx context.Context, entityID string, req UpdateNameReq) (SignalUnit, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateName)
 }
 
-func (s *SignalUnitService) UpdateDesc(entityID string, req UpdateDescReq) (SignalUnit, error) {
+func (s *SignalUnitService) UpdateDesc(ctx context.Context, entityID string, req UpdateDescReq) (SignalUnit, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateDesc)
 }
 
-func (s *SignalUnitService) UpdateKind(entityID string, req UpdateSignalUnitKindReq) (SignalUnit, error) {
+func (s *SignalUnitService) UpdateKind(ctx context.Context, entityID string, req UpdateSignalUnitKindReq) (SignalUnit, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateKind)
 }
 
-func (s *SignalUnitService) UpdateSymbol(entityID string, req UpdateSymbolReq) (SignalUnit, error) {
+func (s *SignalUnitService) UpdateSymbol(ctx context.Context, entityID string, req UpdateSymbolReq) (SignalUnit, error) {
+	s = s.forWindow(ctx)
 	return s.handle(entityID, &req, s.handler.updateSymbol)
 }
 
+// UpdateKeepInLibrary sets whether the signal unit is kept in the library of the network,
+// so it is saved even when no signal references it.
+func (s *SignalUnitService) UpdateKeepInLibrary(ctx context.Context, entityID string, req UpdateKeepInLibraryReq) (SignalUnit, error) {
+	s = s.forWindow(ctx)
+	return s.handle(entityID, &req, s.handler.updateKeepInLibrary)
+}
+
+// setSignalsUnit sets the signal unit of the given signals, the unit may be nil.
+func setSignalsUnit(signals []*acmelib.StandardSignal, sigUnit *acmelib.SignalUnit) {
+	for _, stdSig := range signals {
+		stdSig.SetUnit(sigUnit)
+	}
+}
+
 type signalUnitRes = response[*acmelib.SignalUnit]
 
 type signalUnitHandler struct {
 	*commonServiceHandler
+
+	library *networkLibrary
 }
 
-func newSignalUnitHandler(sidebar *sidebarController) *signalUnitHandler {
+func newSignalUnitHandler(sidebar *sidebarController, library *networkLibrary) *signalUnitHandler {
 	return &signalUnitHandler{
 		commonServiceHandler: newCommonServiceHandler(sidebar),
+
+		library: library,
 	}
 }
 
 func (h *signalUnitHandler) toResponse(sigUnit *acmelib.SignalUnit) SignalUnit {
-	return newSignalUnit(sigUnit)
+	res := newSignalUnit(sigUnit)
+	res.KeepInLibrary = h.library.isKept(sigUnit.EntityID())
+
+	if link, ok := h.library.getLink(sigUnit.EntityID()); ok {
+		res.LibraryPath = link.Path
+		res.ReadOnly = true
+	}
+
+	return res
 }
 
 func (h *signalUnitHandler) updateName(sigUnit *acmelib.SignalUnit, req *request, res *signalUnitRes) error {
@@ -375,3 +534,7 @@ func (h *signalUnitHandler) updateSymbol(sigUnit *acmelib.SignalUnit, req *reque
 
 	return nil
 }
+
+func (h *signalUnitHandler) updateKeepInLibrary(sigUnit *acmelib.SignalUnit, req *request, res *signalUnitRes) error {
+	return updateKeepInLibrary(h.library, sigUnit, req, res)
+}
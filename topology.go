@@ -0,0 +1,357 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/squadracorsepolito/acmelib"
+)
+
+// The topology of a network is the wiring of its nodes to its buses:
+// a node is attached to a bus through one of its numbered interfaces,
+// and a gateway node is attached to more than one bus. The nodes that
+// are not attached to any bus are not part of the topology.
+
+type ExportTopologyReq struct {
+	Path string `json:"path"`
+	// EdgeLabels adds the message counts and the bus load to the diagram.
+	EdgeLabels bool `json:"edgeLabels"`
+}
+
+type TopologyFormat string
+
+const (
+	TopologyFormatDOT     TopologyFormat = "dot"
+	TopologyFormatMermaid TopologyFormat = "mermaid"
+	TopologyFormatSVG     TopologyFormat = "svg"
+)
+
+// getTopologyFormat returns the format of a topology file from its extension.
+func getTopologyFormat(path string) (TopologyFormat, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".dot", ".gv":
+		return TopologyFormatDOT, nil
+	case ".mmd", ".mermaid":
+		return TopologyFormatMermaid, nil
+	case ".svg":
+		return TopologyFormatSVG, nil
+	}
+
+	return "", fmt.Errorf("unsupported topology file %s, the extension must be .dot, .gv, .mmd or .svg", filepath.Base(path))
+}
+
+type topologyBus struct {
+	name     string
+	baudrate int
+	load     float64
+}
+
+func (b *topologyBus) label(withLoad bool) []string {
+	if !withLoad {
+		return []string{b.name}
+	}
+
+	return []string{b.name, fmt.Sprintf("%d kbit/s, load %.1f%%", b.baudrate/1000, b.load)}
+}
+
+// topologyLink is a node interface attached to a bus.
+type topologyLink struct {
+	busIdx int
+	number int
+
+	sentCount     int
+	receivedCount int
+	// load is the part of the bus load caused by the messages sent through the interface.
+	load float64
+}
+
+func (l *topologyLink) label(withCounts bool) string {
+	if !withCounts {
+		return fmt.Sprintf("#%d", l.number)
+	}
+
+	return fmt.Sprintf("#%d: %d tx, %d rx, %.1f%%", l.number, l.sentCount, l.receivedCount, l.load)
+}
+
+type topologyNode struct {
+	name  string
+	links []topologyLink
+}
+
+func (n *topologyNode) isGateway() bool {
+	// a bus holds at most one interface of a node
+	return len(n.links) > 1
+}
+
+type topology struct {
+	name  string
+	buses []*topologyBus
+	// nodes are sorted by the first bus they are attached to.
+	nodes []*topologyNode
+}
+
+func newTopology(net *acmelib.Network) (*topology, error) {
+	res := &topology{name: net.Name()}
+
+	nodes := make(map[acmelib.EntityID]*topologyNode)
+
+	for busIdx, bus := range net.Buses() {
+		load, msgLoads, err := acmelib.CalculateBusLoad(bus, 1000)
+		if err != nil {
+			return nil, err
+		}
+
+		res.buses = append(res.buses, &topologyBus{
+			name:     bus.Name(),
+			baudrate: bus.Baudrate(),
+			load:     load,
+		})
+
+		for _, nodeInt := range bus.NodeInterfaces() {
+			node := nodeInt.Node()
+
+			tNode, ok := nodes[node.EntityID()]
+			if !ok {
+				tNode = &topologyNode{name: node.Name()}
+				nodes[node.EntityID()] = tNode
+				res.nodes = append(res.nodes, tNode)
+			}
+
+			link := topologyLink{
+				busIdx: busIdx,
+				number: nodeInt.Number(),
+
+				sentCount:     len(nodeInt.SentMessages()),
+				receivedCount: len(nodeInt.ReceivedMessages()),
+			}
+
+			// the percentage of a message load is its share of the bus traffic,
+			// so the load of the interface is computed from the bit rate
+			for _, msgLoad := range msgLoads {
+				if msgLoad.Message.SenderNodeInterface() == nodeInt {
+					link.load += msgLoad.BitsPerSec / float64(bus.Baudrate()) * 100
+				}
+			}
+
+			tNode.links = append(tNode.links, link)
+		}
+	}
+
+	return res, nil
+}
+
+// render returns the topology in the given format. If edgeLabels is set,
+// the edges show the message counts of the interfaces and the buses show their load.
+func (t *topology) render(format TopologyFormat, edgeLabels bool) (string, error) {
+	switch format {
+	case TopologyFormatDOT:
+		return t.renderDOT(edgeLabels), nil
+	case TopologyFormatMermaid:
+		return t.renderMermaid(edgeLabels), nil
+	case TopologyFormatSVG:
+		return t.renderSVG(edgeLabels), nil
+	}
+
+	return "", fmt.Errorf("unsupported topology format %q", format)
+}
+
+func quoteDOT(lines ...string) string {
+	escaped := []string{}
+	for _, line := range lines {
+		line = strings.ReplaceAll(line, `\`, `\\`)
+		escaped = append(escaped, strings.ReplaceAll(line, `"`, `\"`))
+	}
+	return `"` + strings.Join(escaped, `\n`) + `"`
+}
+
+func (t *topology) renderDOT(edgeLabels bool) string {
+	b := new(strings.Builder)
+
+	fmt.Fprintf(b, "graph %s {\n", quoteDOT(t.name))
+	b.WriteString("\tnode [fontname=\"Helvetica\", fontsize=12];\n")
+	b.WriteString("\tedge [fontname=\"Helvetica\", fontsize=10];\n\n")
+
+	for idx, bus := range t.buses {
+		fmt.Fprintf(b, "\tb%d [label=%s, shape=box, style=\"filled,bold\", fillcolor=\"#dbe7f5\", width=3];\n", idx, quoteDOT(bus.label(edgeLabels)...))
+	}
+
+	b.WriteString("\n")
+
+	for idx, node := range t.nodes {
+		if node.isGateway() {
+			fmt.Fprintf(b, "\tn%d [label=%s, shape=box, style=\"rounded,filled\", fillcolor=\"#fce8c8\", peripheries=2];\n", idx, quoteDOT(node.name))
+			continue
+		}
+		fmt.Fprintf(b, "\tn%d [label=%s, shape=box, style=rounded];\n", idx, quoteDOT(node.name))
+	}
+
+	b.WriteString("\n")
+
+	for idx, node := range t.nodes {
+		for _, link := range node.links {
+			fmt.Fprintf(b, "\tn%d -- b%d [label=%s];\n", idx, link.busIdx, quoteDOT(link.label(edgeLabels)))
+		}
+	}
+
+	b.WriteString("}\n")
+
+	return b.String()
+}
+
+func quoteMermaid(lines ...string) string {
+	escaped := []string{}
+	for _, line := range lines {
+		escaped = append(escaped, strings.ReplaceAll(line, `"`, "#quot;"))
+	}
+	return `"` + strings.Join(escaped, "<br/>") + `"`
+}
+
+func (t *topology) renderMermaid(edgeLabels bool) string {
+	b := new(strings.Builder)
+
+	b.WriteString("flowchart TB\n")
+
+	for idx, bus := range t.buses {
+		fmt.Fprintf(b, "\tb%d[[%s]]\n", idx, quoteMermaid(bus.label(edgeLabels)...))
+	}
+
+	for idx, node := range t.nodes {
+		if node.isGateway() {
+			fmt.Fprintf(b, "\tn%d{{%s}}\n", idx, quoteMermaid(node.name))
+			continue
+		}
+		fmt.Fprintf(b, "\tn%d(%s)\n", idx, quoteMermaid(node.name))
+	}
+
+	for idx, node := range t.nodes {
+		for _, link := range node.links {
+			fmt.Fprintf(b, "\tn%d ---|%s| b%d\n", idx, quoteMermaid(link.label(edgeLabels)), link.busIdx)
+		}
+	}
+
+	if len(t.buses) > 0 {
+		b.WriteString("\tclassDef bus fill:#dbe7f5,stroke:#3b6ea5\n")
+		b.WriteString("\tclassDef gateway fill:#fce8c8,stroke:#c07a17\n")
+
+		for idx := range t.buses {
+			fmt.Fprintf(b, "\tclass b%d bus\n", idx)
+		}
+	}
+
+	for idx, node := range t.nodes {
+		if node.isGateway() {
+			fmt.Fprintf(b, "\tclass n%d gateway\n", idx)
+		}
+	}
+
+	return b.String()
+}
+
+// The svg is drawn with the buses as horizontal rails stacked under a row of nodes.
+// Each node drops a wire that ends with a junction on every rail it is attached to.
+const (
+	topologySVGMargin     = 20
+	topologySVGCharWidth  = 7
+	topologySVGNodeHeight = 32
+	topologySVGNodePad    = 12
+	topologySVGMinColumn  = 90
+	topologySVGRailTop    = 60
+	topologySVGRailGap    = 56
+)
+
+func getTopologySVGTextWidth(text string) int {
+	return utf8.RuneCountInString(text) * topologySVGCharWidth
+}
+
+func (t *topology) renderSVG(edgeLabels bool) string {
+	// the bus labels are on the left of the rails
+	railStart := topologySVGMargin
+	for _, bus := range t.buses {
+		for _, line := range bus.label(edgeLabels) {
+			railStart = max(railStart, topologySVGMargin+getTopologySVGTextWidth(line)+topologySVGNodePad)
+		}
+	}
+
+	// each node has its own column, wide enough for its name and its link labels
+	columns := make([]int, len(t.nodes))
+	columnStart := railStart + topologySVGNodePad
+	width := columnStart
+	for idx, node := range t.nodes {
+		colWidth := max(topologySVGMinColumn, getTopologySVGTextWidth(node.name)+2*topologySVGNodePad)
+		for _, link := range node.links {
+			// the link label starts from the wire in the middle of the column
+			colWidth = max(colWidth, 2*(getTopologySVGTextWidth(link.label(edgeLabels))+8))
+		}
+
+		columns[idx] = colWidth + topologySVGNodePad
+		width += columns[idx]
+	}
+	width += topologySVGMargin
+
+	nodeTop := topologySVGMargin
+	railY := func(busIdx int) int {
+		return nodeTop + topologySVGNodeHeight + topologySVGRailTop + busIdx*topologySVGRailGap
+	}
+
+	height := railY(len(t.buses)-1) + topologySVGMargin + 10
+	height = max(height, nodeTop+topologySVGNodeHeight+topologySVGMargin)
+
+	b := new(strings.Builder)
+
+	fmt.Fprintf(b, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%d\" height=\"%d\" viewBox=\"0 0 %d %d\" font-family=\"Helvetica, Arial, sans-serif\" font-size=\"12\">\n", width, height, width, height)
+	fmt.Fprintf(b, "\t<title>%s</title>\n", html.EscapeString(t.name))
+	fmt.Fprintf(b, "\t<rect width=\"%d\" height=\"%d\" fill=\"#ffffff\"/>\n", width, height)
+
+	for idx, bus := range t.buses {
+		y := railY(idx)
+
+		fmt.Fprintf(b, "\t<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"6\" rx=\"3\" fill=\"#3b6ea5\"/>\n", railStart, y-3, width-railStart-topologySVGMargin)
+
+		lines := bus.label(edgeLabels)
+		for lineIdx, line := range lines {
+			fontWeight := "bold"
+			if lineIdx > 0 {
+				fontWeight = "normal"
+			}
+			lineY := y + 4 + (lineIdx*2-len(lines)+1)*8
+			fmt.Fprintf(b, "\t<text x=\"%d\" y=\"%d\" text-anchor=\"end\" font-weight=\"%s\">%s</text>\n", railStart-topologySVGNodePad/2, lineY, fontWeight, html.EscapeString(line))
+		}
+	}
+
+	x := columnStart
+	for idx, node := range t.nodes {
+		centerX := x + columns[idx]/2 - topologySVGNodePad/2
+
+		lastRailY := nodeTop + topologySVGNodeHeight
+		for _, link := range node.links {
+			lastRailY = max(lastRailY, railY(link.busIdx))
+		}
+
+		fmt.Fprintf(b, "\t<line x1=\"%d\" y1=\"%d\" x2=\"%d\" y2=\"%d\" stroke=\"#555555\" stroke-width=\"2\"/>\n", centerX, nodeTop+topologySVGNodeHeight, centerX, lastRailY)
+
+		for _, link := range node.links {
+			y := railY(link.busIdx)
+			fmt.Fprintf(b, "\t<circle cx=\"%d\" cy=\"%d\" r=\"5\" fill=\"#555555\"/>\n", centerX, y)
+			fmt.Fprintf(b, "\t<text x=\"%d\" y=\"%d\" font-size=\"10\">%s</text>\n", centerX+6, y-8, html.EscapeString(link.label(edgeLabels)))
+		}
+
+		fill, stroke, strokeWidth := "#f4f4f4", "#555555", 1
+		if node.isGateway() {
+			fill, stroke, strokeWidth = "#fce8c8", "#c07a17", 2
+		}
+
+		boxWidth := max(topologySVGMinColumn, getTopologySVGTextWidth(node.name)+2*topologySVGNodePad)
+		fmt.Fprintf(b, "\t<rect x=\"%d\" y=\"%d\" width=\"%d\" height=\"%d\" rx=\"6\" fill=\"%s\" stroke=\"%s\" stroke-width=\"%d\"/>\n",
+			centerX-boxWidth/2, nodeTop, boxWidth, topologySVGNodeHeight, fill, stroke, strokeWidth)
+		fmt.Fprintf(b, "\t<text x=\"%d\" y=\"%d\" text-anchor=\"middle\">%s</text>\n", centerX, nodeTop+topologySVGNodeHeight/2+4, html.EscapeString(node.name))
+
+		x += columns[idx]
+	}
+
+	b.WriteString("</svg>\n")
+
+	return b.String()
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/squadracorsepolito/acmelib"
+)
+
+func newTestTopology(t *testing.T) *topology {
+	t.Helper()
+
+	net := acmelib.NewNetwork("car")
+
+	powertrain := acmelib.NewBus("powertrain")
+	powertrain.SetBaudrate(500_000)
+	body := acmelib.NewBus("body \"low\"")
+	body.SetBaudrate(125_000)
+
+	for _, bus := range []*acmelib.Bus{powertrain, body} {
+		if err := net.AddBus(bus); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	inverter := acmelib.NewNode("inverter", 1, 1)
+	gateway := acmelib.NewNode("gateway", 2, 2)
+
+	attach := func(bus *acmelib.Bus, nodeInt *acmelib.NodeInterface) {
+		if err := bus.AddNodeInterface(nodeInt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	attach(powertrain, inverter.Interfaces()[0])
+	attach(powertrain, gateway.Interfaces()[0])
+	attach(body, gateway.Interfaces()[1])
+
+	msg := acmelib.NewMessage("status", 1, 8)
+	msg.SetCycleTime(10)
+	if err := inverter.Interfaces()[0].AddSentMessage(msg); err != nil {
+		t.Fatal(err)
+	}
+	if err := gateway.Interfaces()[0].AddReceivedMessage(msg); err != nil {
+		t.Fatal(err)
+	}
+
+	topology, err := newTopology(net)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return topology
+}
+
+func TestTopology(t *testing.T) {
+	topology := newTestTopology(t)
+
+	if len(topology.buses) != 2 || len(topology.nodes) != 2 {
+		t.Fatalf("expected 2 buses and 2 nodes, got %d and %d", len(topology.buses), len(topology.nodes))
+	}
+
+	// the buses are sorted by name, so the gateway is found first on the body bus
+	gateway, inverter := topology.nodes[0], topology.nodes[1]
+	if inverter.isGateway() || !gateway.isGateway() {
+		t.Error("only a node attached to more than one bus is a gateway")
+	}
+
+	link := inverter.links[0]
+	if link.sentCount != 1 || link.load <= 0 || math.Abs(link.load-topology.buses[1].load) > 1e-9 {
+		t.Errorf("the inverter must cause all the load of the powertrain bus, got %+v", link)
+	}
+
+	if gateway.links[0].number != 1 || gateway.links[0].busIdx != 0 || gateway.links[1].receivedCount != 1 {
+		t.Errorf("unexpected gateway links %+v", gateway.links)
+	}
+}
+
+func TestTopologyRender(t *testing.T) {
+	topology := newTestTopology(t)
+
+	dot, err := topology.render(TopologyFormatDOT, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{`graph "car" {`, `b0 [label="body \"low\"\n125 kbit/s`, `n0 -- b0 [label="#1: 0 tx, 0 rx, 0.0%"];`, "peripheries=2"} {
+		if !strings.Contains(dot, expected) {
+			t.Errorf("the dot output does not contain %s:\n%s", expected, dot)
+		}
+	}
+
+	mermaid, err := topology.render(TopologyFormatMermaid, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{"flowchart TB", `b0[["body #quot;low#quot;"]]`, `n0{{"gateway"}}`, `n1 ---|"#0"| b1`} {
+		if !strings.Contains(mermaid, expected) {
+			t.Errorf("the mermaid output does not contain %s:\n%s", expected, mermaid)
+		}
+	}
+
+	for _, edgeLabels := range []bool{false, true} {
+		svg, err := topology.render(TopologyFormatSVG, edgeLabels)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		junctions := 0
+		decoder := xml.NewDecoder(strings.NewReader(svg))
+		for {
+			token, err := decoder.Token()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				t.Fatalf("invalid svg: %v", err)
+			}
+
+			if elem, ok := token.(xml.StartElement); ok && elem.Name.Local == "circle" {
+				junctions++
+			}
+		}
+
+		if junctions != 3 {
+			t.Errorf("expected a junction for each of the 3 attached interfaces, got %d", junctions)
+		}
+	}
+}
+
+func TestGetTopologyFormat(t *testing.T) {
+	for path, expected := range map[string]TopologyFormat{"net.dot": TopologyFormatDOT, "net.GV": TopologyFormatDOT, "net.mmd": TopologyFormatMermaid, "net.svg": TopologyFormatSVG} {
+		if format, err := getTopologyFormat(path); err != nil || format != expected {
+			t.Errorf("%s: expected %s, got %s (%v)", path, expected, format, err)
+		}
+	}
+
+	if _, err := getTopologyFormat("net.png"); err == nil {
+		t.Error("an unsupported extension must be rejected")
+	}
+}
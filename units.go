@@ -0,0 +1,289 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/squadracorsepolito/acmelib"
+)
+
+// acmelib stores only the kind and the symbol of a signal unit, so the physical
+// dimension and the conversion to SI of a unit are taken from the built-in library
+// by matching its symbol. The units with a symbol that is not in the library
+// (e.g. the custom ones) have no dimension and they are never converted.
+
+// UnitDimension is the physical quantity measured by a unit.
+type UnitDimension string
+
+const (
+	UnitDimensionNone            UnitDimension = ""
+	UnitDimensionRatio           UnitDimension = "ratio"
+	UnitDimensionTemperature     UnitDimension = "temperature"
+	UnitDimensionCurrent         UnitDimension = "current"
+	UnitDimensionVoltage         UnitDimension = "voltage"
+	UnitDimensionResistance      UnitDimension = "resistance"
+	UnitDimensionCharge          UnitDimension = "charge"
+	UnitDimensionCapacitance     UnitDimension = "capacitance"
+	UnitDimensionPower           UnitDimension = "power"
+	UnitDimensionEnergy          UnitDimension = "energy"
+	UnitDimensionTime            UnitDimension = "time"
+	UnitDimensionFrequency       UnitDimension = "frequency"
+	UnitDimensionLength          UnitDimension = "length"
+	UnitDimensionSpeed           UnitDimension = "speed"
+	UnitDimensionAcceleration    UnitDimension = "acceleration"
+	UnitDimensionAngle           UnitDimension = "angle"
+	UnitDimensionAngularVelocity UnitDimension = "angular-velocity"
+	UnitDimensionMass            UnitDimension = "mass"
+	UnitDimensionForce           UnitDimension = "force"
+	UnitDimensionTorque          UnitDimension = "torque"
+	UnitDimensionPressure        UnitDimension = "pressure"
+	UnitDimensionVolume          UnitDimension = "volume"
+)
+
+// UnitDefinition describes a unit of the built-in library.
+// A value in the unit is converted to SI with value*Factor + Offset.
+type UnitDefinition struct {
+	Symbol    string         `json:"symbol"`
+	Name      string         `json:"name"`
+	Dimension UnitDimension  `json:"dimension"`
+	Kind      SignalUnitKind `json:"kind"`
+	Factor    float64        `json:"factor"`
+	Offset    float64        `json:"offset"`
+	// Aliases are the other symbols commonly used for the unit.
+	Aliases []string `json:"aliases"`
+}
+
+func newUnitDefinition(symbol, name string, dim UnitDimension, factor, offset float64, aliases ...string) UnitDefinition {
+	kind := SignalUnitKindCustom
+	switch dim {
+	case UnitDimensionTemperature:
+		kind = SignalUnitKindTemperature
+	case UnitDimensionCurrent, UnitDimensionVoltage, UnitDimensionResistance, UnitDimensionCharge, UnitDimensionCapacitance:
+		kind = SignalUnitKindElectrical
+	case UnitDimensionPower, UnitDimensionEnergy:
+		kind = SignalUnitKindPower
+	}
+
+	if aliases == nil {
+		aliases = []string{}
+	}
+
+	return UnitDefinition{
+		Symbol:    symbol,
+		Name:      name,
+		Dimension: dim,
+		Kind:      kind,
+		Factor:    factor,
+		Offset:    offset,
+		Aliases:   aliases,
+	}
+}
+
+// unitLibrary contains the units commonly used in the automotive networks.
+var unitLibrary = []UnitDefinition{
+	newUnitDefinition("%", "percent", UnitDimensionRatio, 0.01, 0),
+	newUnitDefinition("‰", "per_mille", UnitDimensionRatio, 0.001, 0),
+
+	newUnitDefinition("K", "kelvin", UnitDimensionTemperature, 1, 0),
+	newUnitDefinition("°C", "degree_celsius", UnitDimensionTemperature, 1, 273.15, "degC", "deg C", "℃"),
+	newUnitDefinition("°F", "degree_fahrenheit", UnitDimensionTemperature, 5.0/9.0, 273.15-32*5.0/9.0, "degF", "deg F", "℉"),
+
+	newUnitDefinition("A", "ampere", UnitDimensionCurrent, 1, 0),
+	newUnitDefinition("mA", "milliampere", UnitDimensionCurrent, 1e-3, 0),
+	newUnitDefinition("µA", "microampere", UnitDimensionCurrent, 1e-6, 0, "uA"),
+	newUnitDefinition("kA", "kiloampere", UnitDimensionCurrent, 1e3, 0),
+
+	newUnitDefinition("V", "volt", UnitDimensionVoltage, 1, 0),
+	newUnitDefinition("mV", "millivolt", UnitDimensionVoltage, 1e-3, 0),
+	newUnitDefinition("kV", "kilovolt", UnitDimensionVoltage, 1e3, 0),
+
+	newUnitDefinition("Ω", "ohm", UnitDimensionResistance, 1, 0, "Ohm", "ohm"),
+	newUnitDefinition("mΩ", "milliohm", UnitDimensionResistance, 1e-3, 0, "mOhm", "mohm"),
+	newUnitDefinition("kΩ", "kiloohm", UnitDimensionResistance, 1e3, 0, "kOhm", "kohm"),
+	newUnitDefinition("MΩ", "megaohm", UnitDimensionResistance, 1e6, 0, "MOhm"),
+
+	newUnitDefinition("C", "coulomb", UnitDimensionCharge, 1, 0),
+	newUnitDefinition("Ah", "ampere_hour", UnitDimensionCharge, 3600, 0),
+	newUnitDefinition("mAh", "milliampere_hour", UnitDimensionCharge, 3.6, 0),
+
+	newUnitDefinition("F", "farad", UnitDimensionCapacitance, 1, 0),
+	newUnitDefinition("mF", "millifarad", UnitDimensionCapacitance, 1e-3, 0),
+	newUnitDefinition("µF", "microfarad", UnitDimensionCapacitance, 1e-6, 0, "uF"),
+
+	newUnitDefinition("W", "watt", UnitDimensionPower, 1, 0),
+	newUnitDefinition("mW", "milliwatt", UnitDimensionPower, 1e-3, 0),
+	newUnitDefinition("kW", "kilowatt", UnitDimensionPower, 1e3, 0),
+	newUnitDefinition("hp", "horsepower", UnitDimensionPower, 745.69987158227022, 0),
+	newUnitDefinition("PS", "metric_horsepower", UnitDimensionPower, 735.49875, 0),
+
+	newUnitDefinition("J", "joule", UnitDimensionEnergy, 1, 0),
+	newUnitDefinition("kJ", "kilojoule", UnitDimensionEnergy, 1e3, 0),
+	newUnitDefinition("Wh", "watt_hour", UnitDimensionEnergy, 3600, 0),
+	newUnitDefinition("kWh", "kilowatt_hour", UnitDimensionEnergy, 3.6e6, 0),
+
+	newUnitDefinition("s", "second", UnitDimensionTime, 1, 0, "sec"),
+	newUnitDefinition("ms", "millisecond", UnitDimensionTime, 1e-3, 0),
+	newUnitDefinition("µs", "microsecond", UnitDimensionTime, 1e-6, 0, "us"),
+	newUnitDefinition("min", "minute", UnitDimensionTime, 60, 0),
+	newUnitDefinition("h", "hour", UnitDimensionTime, 3600, 0),
+
+	newUnitDefinition("Hz", "hertz", UnitDimensionFrequency, 1, 0),
+	newUnitDefinition("kHz", "kilohertz", UnitDimensionFrequency, 1e3, 0),
+
+	newUnitDefinition("m", "meter", UnitDimensionLength, 1, 0),
+	newUnitDefinition("mm", "millimeter", UnitDimensionLength, 1e-3, 0),
+	newUnitDefinition("cm", "centimeter", UnitDimensionLength, 1e-2, 0),
+	newUnitDefinition("km", "kilometer", UnitDimensionLength, 1e3, 0),
+
+	newUnitDefinition("m/s", "meter_per_second", UnitDimensionSpeed, 1, 0),
+	newUnitDefinition("km/h", "kilometer_per_hour", UnitDimensionSpeed, 1/3.6, 0, "kph", "kmh"),
+	newUnitDefinition("mph", "mile_per_hour", UnitDimensionSpeed, 0.44704, 0),
+
+	newUnitDefinition("m/s²", "meter_per_second_squared", UnitDimensionAcceleration, 1, 0, "m/s^2", "m/s2"),
+	newUnitDefinition("gn", "standard_gravity", UnitDimensionAcceleration, 9.80665, 0),
+
+	newUnitDefinition("rad", "radian", UnitDimensionAngle, 1, 0),
+	newUnitDefinition("°", "degree", UnitDimensionAngle, math.Pi/180, 0, "deg"),
+
+	newUnitDefinition("rad/s", "radian_per_second", UnitDimensionAngularVelocity, 1, 0),
+	newUnitDefinition("°/s", "degree_per_second", UnitDimensionAngularVelocity, math.Pi/180, 0, "deg/s"),
+	newUnitDefinition("rpm", "revolution_per_minute", UnitDimensionAngularVelocity, 2*math.Pi/60, 0, "RPM", "1/min"),
+
+	newUnitDefinition("kg", "kilogram", UnitDimensionMass, 1, 0),
+	newUnitDefinition("g", "gram", UnitDimensionMass, 1e-3, 0),
+
+	newUnitDefinition("N", "newton", UnitDimensionForce, 1, 0),
+	newUnitDefinition("kN", "kilonewton", UnitDimensionForce, 1e3, 0),
+
+	newUnitDefinition("N·m", "newton_meter", UnitDimensionTorque, 1, 0, "Nm", "N.m", "N*m"),
+
+	newUnitDefinition("Pa", "pascal", UnitDimensionPressure, 1, 0),
+	newUnitDefinition("kPa", "kilopascal", UnitDimensionPressure, 1e3, 0),
+	newUnitDefinition("bar", "bar", UnitDimensionPressure, 1e5, 0),
+	newUnitDefinition("mbar", "millibar", UnitDimensionPressure, 100, 0),
+	newUnitDefinition("psi", "pound_per_square_inch", UnitDimensionPressure, 6894.757293168361, 0),
+
+	newUnitDefinition("m³", "cubic_meter", UnitDimensionVolume, 1, 0, "m^3", "m3"),
+	newUnitDefinition("L", "liter", UnitDimensionVolume, 1e-3, 0, "l"),
+	newUnitDefinition("mL", "milliliter", UnitDimensionVolume, 1e-6, 0, "ml"),
+}
+
+// unitsBySymbol maps the symbols and the aliases to the units of the library.
+var unitsBySymbol = func() map[string]UnitDefinition {
+	res := make(map[string]UnitDefinition)
+	for _, unit := range unitLibrary {
+		res[unit.Symbol] = unit
+		for _, alias := range unit.Aliases {
+			res[alias] = unit
+		}
+	}
+	return res
+}()
+
+// ambiguousUnitSymbols are the symbols of the library that are also used for other units,
+// e.g. a bare "C" is often written for the degree celsius instead of the coulomb.
+// They match the unit of the library only if the signal unit has its kind,
+// like the ones created from the library.
+var ambiguousUnitSymbols = map[string]struct{}{
+	"C": {},
+}
+
+// lookupUnit returns the unit of the library with the given symbol or alias.
+func lookupUnit(symbol string) (UnitDefinition, bool) {
+	unit, ok := unitsBySymbol[strings.TrimSpace(symbol)]
+	return unit, ok
+}
+
+// lookupSignalUnit returns the unit of the library matching the symbol of the signal unit.
+// An ambiguous symbol does not match any unit, unless the kinds of the units are the same.
+func lookupSignalUnit(sigUnit *acmelib.SignalUnit) (UnitDefinition, bool) {
+	unit, ok := lookupUnit(sigUnit.Symbol())
+	if !ok {
+		return UnitDefinition{}, false
+	}
+
+	if _, ok := ambiguousUnitSymbols[strings.TrimSpace(sigUnit.Symbol())]; ok && sigUnit.Kind() != unit.Kind.parse() {
+		return UnitDefinition{}, false
+	}
+
+	return unit, true
+}
+
+// getUnitDimension returns the dimension of a signal unit,
+// it is none if the symbol is not in the library or it is ambiguous.
+func getUnitDimension(sigUnit *acmelib.SignalUnit) UnitDimension {
+	unit, ok := lookupSignalUnit(sigUnit)
+	if !ok {
+		return UnitDimensionNone
+	}
+	return unit.Dimension
+}
+
+// getUnitConversion returns the scale and the offset that convert
+// a value from one signal unit to the other: to = from*scale + offset.
+func getUnitConversion(from, to *acmelib.SignalUnit) (scale, offset float64, _ error) {
+	fromUnit, ok := lookupSignalUnit(from)
+	if !ok {
+		return 0, 0, fmt.Errorf("the unit %s has an unknown or ambiguous symbol %q", from.Name(), from.Symbol())
+	}
+
+	toUnit, ok := lookupSignalUnit(to)
+	if !ok {
+		return 0, 0, fmt.Errorf("the unit %s has an unknown or ambiguous symbol %q", to.Name(), to.Symbol())
+	}
+
+	if fromUnit.Dimension != toUnit.Dimension {
+		return 0, 0, fmt.Errorf("cannot convert %s (%s) to %s (%s)", from.Name(), fromUnit.Dimension, to.Name(), toUnit.Dimension)
+	}
+
+	scale = fromUnit.Factor / toUnit.Factor
+	offset = (fromUnit.Offset - toUnit.Offset) / toUnit.Factor
+
+	return scale, offset, nil
+}
+
+// unitNameKeywords maps the words found in the names of the signals
+// to the dimensions that the unit of the signal may have.
+var unitNameKeywords = map[string][]UnitDimension{
+	"CURRENT": {UnitDimensionCurrent},
+	"CURR":    {UnitDimensionCurrent},
+	"AMP":     {UnitDimensionCurrent},
+
+	"VOLTAGE": {UnitDimensionVoltage},
+	"VOLT":    {UnitDimensionVoltage},
+
+	"TEMPERATURE": {UnitDimensionTemperature},
+	"TEMP":        {UnitDimensionTemperature},
+
+	"POWER": {UnitDimensionPower},
+	"PWR":   {UnitDimensionPower},
+
+	"ENERGY": {UnitDimensionEnergy},
+
+	"PRESSURE": {UnitDimensionPressure},
+	"PRESS":    {UnitDimensionPressure},
+
+	"TORQUE": {UnitDimensionTorque},
+	"TRQ":    {UnitDimensionTorque},
+
+	"FREQUENCY": {UnitDimensionFrequency},
+	"FREQ":      {UnitDimensionFrequency},
+
+	"SPEED":    {UnitDimensionSpeed, UnitDimensionAngularVelocity},
+	"VELOCITY": {UnitDimensionSpeed, UnitDimensionAngularVelocity},
+	"RPM":      {UnitDimensionAngularVelocity},
+
+	"ANGLE": {UnitDimensionAngle},
+}
+
+// getNameDimensions returns the dimensions implied by the words of the name,
+// or nil if the name does not contain any known word.
+func getNameDimensions(name string) []UnitDimension {
+	for _, word := range splitNameWords(name) {
+		if dims, ok := unitNameKeywords[strings.ToUpper(word)]; ok {
+			return dims
+		}
+	}
+	return nil
+}
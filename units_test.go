@@ -0,0 +1,73 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/squadracorsepolito/acmelib"
+)
+
+func TestUnitConversion(t *testing.T) {
+	tests := []struct {
+		from, to    string
+		value, want float64
+	}{
+		{"°C", "K", 25, 298.15},
+		{"K", "°C", 0, -273.15},
+		{"°F", "°C", 212, 100},
+		{"mA", "A", 1500, 1.5},
+		{"km/h", "m/s", 36, 10},
+		{"rpm", "rad/s", 60, 2 * math.Pi},
+	}
+
+	for _, tt := range tests {
+		from := acmelib.NewSignalUnit("from", acmelib.SignalUnitKindCustom, tt.from)
+		to := acmelib.NewSignalUnit("to", acmelib.SignalUnitKindCustom, tt.to)
+
+		scale, offset, err := getUnitConversion(from, to)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got := tt.value*scale + offset; math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%v %s: got %v %s, want %v", tt.value, tt.from, got, tt.to, tt.want)
+		}
+	}
+
+	volt := acmelib.NewSignalUnit("volt", acmelib.SignalUnitKindElectrical, "V")
+	amp := acmelib.NewSignalUnit("amp", acmelib.SignalUnitKindElectrical, "A")
+	if _, _, err := getUnitConversion(volt, amp); err == nil {
+		t.Error("expected an error converting a voltage to a current")
+	}
+}
+
+func TestNameDimensions(t *testing.T) {
+	dims := getNameDimensions("BMS_PackCurrent")
+	if len(dims) != 1 || dims[0] != UnitDimensionCurrent {
+		t.Errorf("got %v, want %v", dims, []UnitDimension{UnitDimensionCurrent})
+	}
+
+	if dims := getNameDimensions("STATUS_FLAGS"); dims != nil {
+		t.Errorf("got %v, want no dimensions", dims)
+	}
+}
+
+func TestAmbiguousUnitSymbol(t *testing.T) {
+	// a bare "C" may be a coulomb or a degree celsius
+	sigUnit := acmelib.NewSignalUnit("temp_unit", acmelib.SignalUnitKindTemperature, "C")
+
+	if dim := getUnitDimension(sigUnit); dim != UnitDimensionNone {
+		t.Errorf("got dimension %s, want none", dim)
+	}
+
+	kelvin := acmelib.NewSignalUnit("kelvin", acmelib.SignalUnitKindTemperature, "K")
+	if _, _, err := getUnitConversion(sigUnit, kelvin); err == nil {
+		t.Error("expected an error converting a unit with an ambiguous symbol")
+	}
+
+	// the units created from the library have the kind of the library unit
+	coulomb := acmelib.NewSignalUnit("coulomb", acmelib.SignalUnitKindElectrical, "C")
+	if dim := getUnitDimension(coulomb); dim != UnitDimensionCharge {
+		t.Errorf("got dimension %s, want %s", dim, UnitDimensionCharge)
+	}
+}
@@ -2,24 +2,23 @@ package main
 
 import (
 	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/wailsapp/wails/v3/pkg/application"
 )
 
-func printInfo(msg string, args ...any) {
-	application.Get().Logger.Info(msg, args...)
-}
-
-func printError(err error) {
-	application.Get().Logger.Error(err.Error())
-}
-
+// getNewName returns a name that is not taken built with the naming template of the preferences.
 func getNewName(baseName string, takenNames map[string]struct{}) string {
+	template := strings.ReplaceAll(getPreferences().NamingTemplate, namingTemplateName, baseName)
+
 	res := ""
 	count := 0
 
 	for {
-		res = fmt.Sprintf("new_%s_%d", baseName, count)
+		res = strings.ReplaceAll(template, namingTemplateIndex, strconv.Itoa(count))
 
 		if _, ok := takenNames[res]; !ok {
 			break
@@ -50,3 +49,160 @@ func newOpenNetworkDialog() *application.OpenFileDialogStruct {
 
 	return dialog
 }
+
+func newLibraryFileDialog() *application.OpenFileDialogStruct {
+	dialog := application.OpenFileDialog()
+
+	dialog.AddFilter("canturin library file", "*"+libraryFileExtension)
+
+	return dialog
+}
+
+// questionDialogTimeout is the time after which a question dialog
+// without an answer is considered dismissed.
+const questionDialogTimeout = 2 * time.Minute
+
+// waitForChoice shows the dialog and returns the choice sent on choiceCh by its buttons.
+// On Linux, closing the dialog without clicking a button runs no callback,
+// so cancelChoice is returned if no choice is made within questionDialogTimeout.
+// choiceCh must be buffered, so that a late click does not block the callback.
+func waitForChoice[T any](dialog *application.MessageDialog, choiceCh <-chan T, cancelChoice T) T {
+	dialog.Show()
+
+	select {
+	case choice := <-choiceCh:
+		return choice
+	case <-time.After(questionDialogTimeout):
+		return cancelChoice
+	}
+}
+
+type unsavedChangesChoice int
+
+const (
+	unsavedChangesChoiceCancel unsavedChangesChoice = iota
+	unsavedChangesChoiceSave
+	unsavedChangesChoiceDiscard
+)
+
+// promptUnsavedChanges asks the user what to do with the unsaved changes of the network.
+// It blocks until the user makes a choice, so it must not be called from the main thread.
+func promptUnsavedChanges(networkName string) unsavedChangesChoice {
+	choiceCh := make(chan unsavedChangesChoice, 1)
+
+	dialog := application.QuestionDialog().
+		SetTitle("Unsaved changes").
+		SetMessage(fmt.Sprintf("The network %s has unsaved changes. Do you want to save them?", networkName))
+
+	dialog.AddButton("Save").SetAsDefault().OnClick(func() {
+		choiceCh <- unsavedChangesChoiceSave
+	})
+	dialog.AddButton("Discard").OnClick(func() {
+		choiceCh <- unsavedChangesChoiceDiscard
+	})
+	dialog.AddButton("Cancel").SetAsCancel().OnClick(func() {
+		choiceCh <- unsavedChangesChoiceCancel
+	})
+
+	return waitForChoice(dialog, choiceCh, unsavedChangesChoiceCancel)
+}
+
+type fileChangedChoice int
+
+const (
+	fileChangedChoiceKeep fileChangedChoice = iota
+	fileChangedChoiceReload
+	fileChangedChoiceDiff
+)
+
+// promptFileChanged asks the user what to do when the file of the network
+// has been changed by another program.
+// It blocks until the user makes a choice, so it must not be called from the main thread.
+func promptFileChanged(networkName string, saved bool) fileChangedChoice {
+	choiceCh := make(chan fileChangedChoice, 1)
+
+	msg := fmt.Sprintf("The file of the network %s has been changed by another program. Do you want to reload it?", networkName)
+	if !saved {
+		msg += " Your unsaved changes will be lost."
+	}
+
+	dialog := application.QuestionDialog().
+		SetTitle("File changed").
+		SetMessage(msg)
+
+	dialog.AddButton("Reload").SetAsDefault().OnClick(func() {
+		choiceCh <- fileChangedChoiceReload
+	})
+	dialog.AddButton("Keep Current").SetAsCancel().OnClick(func() {
+		choiceCh <- fileChangedChoiceKeep
+	})
+	dialog.AddButton("Show Differences").OnClick(func() {
+		choiceCh <- fileChangedChoiceDiff
+	})
+
+	return waitForChoice(dialog, choiceCh, fileChangedChoiceKeep)
+}
+
+// promptOverwriteChangedFile asks the user to confirm the save of a network
+// whose file has been changed by another program since it was loaded.
+// It blocks until the user makes a choice, so it must not be called from the main thread.
+func promptOverwriteChangedFile(networkName string) bool {
+	choiceCh := make(chan bool, 1)
+
+	dialog := application.QuestionDialog().
+		SetTitle("File changed").
+		SetMessage(fmt.Sprintf("The file of the network %s has been changed by another program since it was loaded. Do you want to overwrite it?", networkName))
+
+	dialog.AddButton("Overwrite").OnClick(func() {
+		choiceCh <- true
+	})
+	dialog.AddButton("Cancel").SetAsDefault().SetAsCancel().OnClick(func() {
+		choiceCh <- false
+	})
+
+	return waitForChoice(dialog, choiceCh, false)
+}
+
+// promptLibraryUpdate shows the changes of the linked library files
+// and asks the user to confirm their update.
+// It blocks until the user makes a choice, so it must not be called from the main thread.
+func promptLibraryUpdate(changes []LibraryChange) bool {
+	choiceCh := make(chan bool, 1)
+
+	lines := []string{}
+	for _, change := range changes {
+		lines = append(lines, fmt.Sprintf("%s: %s", filepath.Base(change.LibraryPath), change.String()))
+	}
+
+	dialog := application.QuestionDialog().
+		SetTitle("Update libraries").
+		SetMessage(fmt.Sprintf("The linked libraries have changed:\n\n%s\n\nDo you want to update the network?", strings.Join(lines, "\n")))
+
+	dialog.AddButton("Update").SetAsDefault().OnClick(func() {
+		choiceCh <- true
+	})
+	dialog.AddButton("Cancel").SetAsCancel().OnClick(func() {
+		choiceCh <- false
+	})
+
+	return waitForChoice(dialog, choiceCh, false)
+}
+
+// promptTopologyEdgeLabels asks the user whether the exported topology
+// shows the message counts and the bus load. It blocks until the user makes a choice.
+func promptTopologyEdgeLabels() bool {
+	choiceCh := make(chan bool, 1)
+
+	dialog := application.QuestionDialog().
+		SetTitle("Export topology").
+		SetMessage("Do you want to label the edges with the message counts and the bus load?")
+
+	dialog.AddButton("Add labels").SetAsDefault().OnClick(func() {
+		choiceCh <- true
+	})
+	dialog.AddButton("No labels").SetAsCancel().OnClick(func() {
+		choiceCh <- false
+	})
+
+	return waitForChoice(dialog, choiceCh, false)
+}
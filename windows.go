@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/wailsapp/wails/v3/pkg/application"
+	"github.com/wailsapp/wails/v3/pkg/events"
+	"golang.org/x/exp/maps"
+)
+
+// Every window has its own service manager, with its own network, history and sidebar,
+// and the events emitted by a manager are sent only to its window.
+// The services bound to the frontend are the ones of a router manager that never
+// loads a network: their methods take the context of the call, which carries
+// the id of the window that made it, and forward the call to the services of that window.
+// The settings and the clipboard are shared by all the windows.
+
+// managerResolver returns the manager that handles a call made with the given context.
+type managerResolver func(ctx context.Context) *serviceManager
+
+// windowRouted is embedded by the services bound to the frontend.
+type windowRouted struct {
+	resolve managerResolver
+}
+
+func (wr *windowRouted) setManagerResolver(resolve managerResolver) {
+	wr.resolve = resolve
+}
+
+type managedWindow struct {
+	window  *application.WebviewWindow
+	manager *serviceManager
+	// cancel stops the services of the manager
+	cancel context.CancelFunc
+}
+
+type windowManagers struct {
+	mux     sync.RWMutex
+	windows map[uint]*managedWindow
+
+	settingsSrv *SettingsService
+	clipboard   *networkClipboard
+
+	apiEvents *eventSubscribers
+	log       logger
+
+	// router holds the services bound to the frontend
+	router *serviceManager
+
+	// quitConfirmed is set when the user has already decided
+	// what to do with the unsaved changes of all the windows before quitting
+	quitConfirmed atomic.Bool
+	// quitPrompting is set while the quit confirmation dialogs are open
+	quitPrompting atomic.Bool
+}
+
+// newWindowManagers returns the managers of the windows. The events of every window
+// are also sent to the given subscribers, and the settings service is shared by all the windows.
+func newWindowManagers(settingsSrv *SettingsService, apiEvents *eventSubscribers, log logger) *windowManagers {
+	w := &windowManagers{
+		windows: make(map[uint]*managedWindow),
+
+		settingsSrv: settingsSrv,
+		clipboard:   newNetworkClipboard(),
+
+		apiEvents: apiEvents,
+		log:       log,
+	}
+
+	w.router = newServiceManager(newMultiEventEmitter(newWailsEventEmitter(), apiEvents), log, newDialogPrompter(), settingsSrv, w.clipboard)
+	w.router.windows = w
+	w.router.setManagerResolver(w.get)
+
+	return w
+}
+
+// getServices returns the services bound to the frontend.
+func (w *windowManagers) getServices() []application.Service {
+	return w.router.getServices()
+}
+
+// open opens a new window with its own manager.
+// If the path is not empty, the network is opened in the new window.
+func (w *windowManagers) open(path string) error {
+	window := application.Get().NewWebviewWindowWithOptions(application.WebviewWindowOptions{
+		Title: "canturin",
+		Mac: application.MacWindow{
+			InvisibleTitleBarHeight: 50,
+			Backdrop:                application.MacBackdropTranslucent,
+		},
+		BackgroundColour:       application.NewRGB(27, 38, 54),
+		URL:                    "/",
+		OpenInspectorOnStartup: true,
+	})
+
+	m := newServiceManager(newMultiEventEmitter(newWindowEventEmitter(window), w.apiEvents), w.log, newDialogPrompter(), w.settingsSrv, w.clipboard)
+	m.windows = w
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := m.start(ctx); err != nil {
+		cancel()
+		window.Close()
+		return err
+	}
+
+	go m.runAutosave(ctx)
+	go m.runFileWatcher(ctx)
+
+	windowID := window.ID()
+
+	w.mux.Lock()
+	w.windows[windowID] = &managedWindow{
+		window:  window,
+		manager: m,
+		cancel:  cancel,
+	}
+	w.mux.Unlock()
+
+	// Ask what to do with the unsaved changes before closing the window.
+	window.RegisterHook(events.Common.WindowClosing, func(e *application.WindowEvent) {
+		if !w.quitConfirmed.Load() && !m.shouldClose(window.Close) {
+			e.Cancel()
+			return
+		}
+
+		w.remove(windowID)
+	})
+
+	if path == "" {
+		return nil
+	}
+
+	go func() {
+		if err := m.openNetwork(path); err != nil {
+			m.log.error(err)
+		}
+	}()
+
+	return nil
+}
+
+// remove stops the manager of the window.
+func (w *windowManagers) remove(windowID uint) {
+	w.mux.Lock()
+	mw, ok := w.windows[windowID]
+	delete(w.windows, windowID)
+	w.mux.Unlock()
+
+	if ok {
+		mw.cancel()
+	}
+}
+
+func (w *windowManagers) getByID(windowID uint) (*serviceManager, bool) {
+	w.mux.RLock()
+	defer w.mux.RUnlock()
+
+	mw, ok := w.windows[windowID]
+	if !ok {
+		return nil, false
+	}
+
+	return mw.manager, true
+}
+
+// list returns the managers of the open windows, in the order the windows were opened.
+func (w *windowManagers) list() []*serviceManager {
+	w.mux.RLock()
+	defer w.mux.RUnlock()
+
+	windowIDs := maps.Keys(w.windows)
+	slices.Sort(windowIDs)
+
+	res := []*serviceManager{}
+	for _, windowID := range windowIDs {
+		res = append(res, w.windows[windowID].manager)
+	}
+
+	return res
+}
+
+// current returns the manager of the focused window, or of the first window
+// if none is focused. The router is returned when no window is open,
+// so the calls fail as if the network was not loaded.
+func (w *windowManagers) current() *serviceManager {
+	if window := application.Get().CurrentWindow(); window != nil {
+		if m, ok := w.getByID(window.ID()); ok {
+			return m
+		}
+	}
+
+	if managers := w.list(); len(managers) > 0 {
+		return managers[0]
+	}
+
+	return w.router
+}
+
+// get returns the manager of the window that made the call.
+// The calls that do not come from a window (e.g. the ones of the API server)
+// are handled by the focused window.
+func (w *windowManagers) get(ctx context.Context) *serviceManager {
+	if windowID, ok := ctx.Value(application.WindowIDKey).(uint); ok {
+		if m, ok := w.getByID(windowID); ok {
+			return m
+		}
+	}
+
+	return w.current()
+}
+
+// emitEvent sends the event to the focused window,
+// it is used by the commands run from the menu and by the keybindings.
+func (w *windowManagers) emitEvent(name string, data ...any) {
+	w.current().emitter.emitEvent(name, data...)
+}
+
+// shouldQuit is the hook called when the user tries to quit the application from the OS.
+// If a window has unsaved changes, the quit is cancelled and the user is asked
+// what to do with the changes of every window; the application is then closed
+// only if all the changes are saved or discarded.
+func (w *windowManagers) shouldQuit() bool {
+	if w.quitConfirmed.Load() {
+		return true
+	}
+
+	unsaved := []*serviceManager{}
+	for _, m := range w.list() {
+		if !m.isSaved() {
+			unsaved = append(unsaved, m)
+		}
+	}
+
+	if len(unsaved) == 0 {
+		return true
+	}
+
+	// the dialogs cannot block the thread that is asking to quit
+	if w.quitPrompting.CompareAndSwap(false, true) {
+		go func() {
+			defer w.quitPrompting.Store(false)
+
+			for _, m := range unsaved {
+				ok, err := m.confirmDiscard()
+				if err != nil {
+					m.log.error(err)
+					return
+				}
+
+				if !ok {
+					return
+				}
+			}
+
+			w.quitConfirmed.Store(true)
+			application.Get().Quit()
+		}()
+	}
+
+	return false
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/wailsapp/wails/v3/pkg/application"
+)
+
+func TestWindowRouting(t *testing.T) {
+	m1, _ := newTestManager(t)
+	m2, _ := newTestManager(t)
+
+	if err := m1.openNetwork(filepath.Join("testdata", "simple.binpb")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m2.createNetwork(); err != nil {
+		t.Fatal(err)
+	}
+	waitServices(m1)
+	waitServices(m2)
+
+	w := newWindowManagers(m1.settingsSrv, newEventSubscribers(), newMemoryLogger())
+	w.windows[1] = &managedWindow{manager: m1, cancel: func() {}}
+	w.windows[2] = &managedWindow{manager: m2, cancel: func() {}}
+
+	ctx := context.WithValue(t.Context(), application.WindowIDKey, uint(2))
+
+	if buses := w.router.busSrv.ListBase(ctx); len(buses) != 0 {
+		t.Errorf("expected no buses in the second window, got %d", len(buses))
+	}
+
+	// the calls that do not come from a window are handled by the first one
+	if buses := w.router.busSrv.ListBase(t.Context()); len(buses) == 0 {
+		t.Error("expected the buses of the first window")
+	}
+
+	if _, err := w.router.networkSrv.UpdateName(ctx, UpdateNameReq{Name: "renamed"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if name := m2.networkSrv.Get(t.Context()).Name; name != "renamed" {
+		t.Errorf("expected the second network to be renamed, got %s", name)
+	}
+	if name := m1.networkSrv.Get(t.Context()).Name; name == "renamed" {
+		t.Error("the first network must not be renamed")
+	}
+}
+
+func TestCopyBetweenWindows(t *testing.T) {
+	clipboard := newNetworkClipboard()
+	m1, _ := newTestWindowManager(t, clipboard)
+	m2, _ := newTestWindowManager(t, clipboard)
+
+	if err := m1.openNetwork(filepath.Join("testdata", "simple.binpb")); err != nil {
+		t.Fatal(err)
+	}
+	if err := m2.createNetwork(); err != nil {
+		t.Fatal(err)
+	}
+	waitServices(m1)
+	waitServices(m2)
+
+	m1.mux.RLock()
+	bus := m1.network.Buses()[0]
+	m1.mux.RUnlock()
+
+	if err := m1.copyEntity(bus.EntityID().String()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m2.pasteBus(); err != nil {
+		t.Fatal(err)
+	}
+	waitServices(m2)
+
+	m2.mux.RLock()
+	buses := m2.network.Buses()
+	m2.mux.RUnlock()
+
+	if len(buses) != 1 {
+		t.Fatalf("expected one pasted bus, got %d", len(buses))
+	}
+
+	if buses[0].Name() != bus.Name() {
+		t.Errorf("expected the bus %s, got %s", bus.Name(), buses[0].Name())
+	}
+	if buses[0].EntityID() == bus.EntityID() {
+		t.Error("the pasted bus must have a new entity id")
+	}
+}